@@ -11,13 +11,29 @@ import (
 	"sync"
 
 	BazelLog "github.com/aspect-build/aspect-gazelle/common/logger"
+	bzl "github.com/bazelbuild/buildtools/build"
+	"github.com/bmatcuk/doublestar/v4"
 )
 
+// repoRoot => *loadedIgnores
 var ignores sync.Map
 
+// The ignores of a repository along with the state of the files they were loaded from.
+type loadedIgnores struct {
+	stamp    string
+	excludes []string
+}
+
+// LoadBazelIgnore returns the directories ignored by Bazel in the repository at
+// repoRoot, as declared by the .bazelignore file and the REPO.bazel ignore_directories().
+//
+// Each entry is a repository relative path or a glob pattern, see IsBazelIgnored.
+//
+// The ignores are cached per repository and reloaded when either file changes.
 func LoadBazelIgnore(repoRoot string) ([]string, error) {
-	if v, ok := ignores.Load(repoRoot); ok {
-		return v.([]string), nil
+	stamp := ignoreFilesStamp(repoRoot)
+	if v, ok := ignores.Load(repoRoot); ok && v.(*loadedIgnores).stamp == stamp {
+		return v.(*loadedIgnores).excludes, nil
 	}
 
 	loaded, err := loadBazelIgnore(repoRoot)
@@ -25,8 +41,57 @@ func LoadBazelIgnore(repoRoot string) ([]string, error) {
 		return nil, err
 	}
 
-	excludes, _ := ignores.LoadOrStore(repoRoot, loaded)
-	return excludes.([]string), nil
+	repoIgnores, err := loadRepoIgnoreDirectories(repoRoot)
+	if err != nil {
+		return nil, err
+	}
+	loaded = append(loaded, repoIgnores...)
+
+	ignores.Store(repoRoot, &loadedIgnores{stamp: stamp, excludes: loaded})
+	return loaded, nil
+}
+
+// ignoreFilesStamp identifies the current version of the .bazelignore and REPO.bazel
+// files of a repository by their modification time and size.
+func ignoreFilesStamp(repoRoot string) string {
+	var stamp strings.Builder
+	for _, f := range []string{".bazelignore", "REPO.bazel"} {
+		if info, err := os.Stat(path.Join(repoRoot, f)); err == nil {
+			fmt.Fprintf(&stamp, "%d:%d", info.ModTime().UnixNano(), info.Size())
+		}
+		stamp.WriteByte(';')
+	}
+	return stamp.String()
+}
+
+// IsBazelIgnoreGlob reports whether the ignore entry is a glob pattern as opposed to a literal path.
+func IsBazelIgnoreGlob(ignore string) bool {
+	return strings.ContainsAny(ignore, "*?[{")
+}
+
+// IsBazelIgnored reports whether the repository relative path is an ignored
+// directory or within one.
+//
+// Like Bazel a literal entry ignores the directory and everything beneath it, as
+// does a glob pattern matching the directory. A `*` does not match across `/`
+// while `**` matches any number of directories.
+func IsBazelIgnored(ignores []string, rel string) bool {
+	for _, ignore := range ignores {
+		if !IsBazelIgnoreGlob(ignore) {
+			if rel == ignore || strings.HasPrefix(rel, ignore+"/") {
+				return true
+			}
+			continue
+		}
+
+		// Match the directory itself or any of its parents
+		for dir := rel; dir != "." && dir != ""; dir = path.Dir(dir) {
+			if doublestar.MatchUnvalidated(ignore, dir) {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 func loadBazelIgnore(repoRoot string) ([]string, error) {
@@ -48,18 +113,10 @@ func loadBazelIgnore(repoRoot string) ([]string, error) {
 		if ignore == "" || string(ignore[0]) == "#" {
 			continue
 		}
-		// Bazel ignore paths are always relative to repo root.
-		// Glob patterns are not supported.
-		if strings.ContainsAny(ignore, "*?[") {
-			BazelLog.Warnf("the .bazelignore exclusion pattern must not be a glob %s", ignore)
-			continue
-		}
 
-		// Clean the path to remove any extra '.', './' etc otherwise
-		// the exclude matching won't work correctly.
-		ignore = path.Clean(ignore)
-
-		excludes = append(excludes, ignore)
+		if ignore, ok := cleanIgnore(".bazelignore", ignore); ok {
+			excludes = append(excludes, ignore)
+		}
 	}
 
 	if err := scanner.Err(); err != nil {
@@ -68,3 +125,61 @@ func loadBazelIgnore(repoRoot string) ([]string, error) {
 
 	return excludes, nil
 }
+
+// loadRepoIgnoreDirectories loads the REPO.bazel ignore_directories([...]) patterns.
+func loadRepoIgnoreDirectories(repoRoot string) ([]string, error) {
+	content, err := os.ReadFile(path.Join(repoRoot, "REPO.bazel"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("REPO.bazel exists but couldn't be read: %v", err)
+	}
+
+	f, err := bzl.ParseDefault("REPO.bazel", content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse REPO.bazel: %w", err)
+	}
+
+	excludes := []string{}
+
+	for _, stmt := range f.Stmt {
+		call, isCall := stmt.(*bzl.CallExpr)
+		if !isCall || callName(call) != "ignore_directories" || len(call.List) == 0 {
+			continue
+		}
+
+		list, isList := call.List[0].(*bzl.ListExpr)
+		if !isList {
+			BazelLog.Warnf("REPO.bazel ignore_directories() must be passed a list of strings")
+			continue
+		}
+
+		for _, e := range list.List {
+			if ignore, ok := cleanIgnore("REPO.bazel ignore_directories()", stringValue(e)); ok {
+				excludes = append(excludes, ignore)
+			}
+		}
+	}
+
+	return excludes, nil
+}
+
+// cleanIgnore normalizes an ignore entry, returning false if it is invalid.
+func cleanIgnore(source, ignore string) (string, bool) {
+	if ignore == "" {
+		return "", false
+	}
+
+	// Bazel ignore paths are always relative to repo root.
+	// Clean the path to remove any extra '.', './' etc otherwise
+	// the exclude matching won't work correctly.
+	ignore = path.Clean(strings.TrimPrefix(ignore, "/"))
+
+	if IsBazelIgnoreGlob(ignore) && !doublestar.ValidatePattern(ignore) {
+		BazelLog.Warnf("the %s exclusion pattern is not a valid glob: %s", source, ignore)
+		return "", false
+	}
+
+	return ignore, true
+}
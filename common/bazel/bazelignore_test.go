@@ -18,7 +18,7 @@ func TestLoadBazelIgnore(t *testing.T) {
 	if err != nil {
 		t.Fatalf("loadBazelIgnore returned error: %v", err)
 	}
-	if len(excludes) != 2 || excludes[0] != "foo" || excludes[1] != "bar/baz" {
+	if len(excludes) != 3 || excludes[0] != "foo" || excludes[1] != "bar/baz" || excludes[2] != "star-*-glob" {
 		t.Errorf("unexpected excludes: %v", excludes)
 	}
 }
@@ -39,3 +39,76 @@ func TestLoadBazelIgnoreScannerError(t *testing.T) {
 		t.Fatal("expected an error for a .bazelignore line exceeding the scanner buffer, got nil")
 	}
 }
+
+func TestLoadBazelIgnoreRepoIgnoreDirectories(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(path.Join(dir, ".bazelignore"), []byte("foo\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	content := "ignore_directories([\"**/node_modules\", \"./bar\", \"[invalid\"])\n"
+	if err := os.WriteFile(path.Join(dir, "REPO.bazel"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	excludes, err := LoadBazelIgnore(dir)
+	if err != nil {
+		t.Fatalf("LoadBazelIgnore returned error: %v", err)
+	}
+	if len(excludes) != 3 || excludes[0] != "foo" || excludes[1] != "**/node_modules" || excludes[2] != "bar" {
+		t.Errorf("unexpected excludes: %v", excludes)
+	}
+}
+
+func TestIsBazelIgnored(t *testing.T) {
+	ignores := []string{"foo", "bar/baz", "**/node_modules", "out-*", "gen/*/tmp"}
+
+	for rel, expected := range map[string]bool{
+		"":                     false,
+		"foo":                  true,
+		"foo/sub":              true,
+		"foobar":               false,
+		"bar":                  false,
+		"bar/baz/x":            true,
+		"node_modules":         true,
+		"a/b/node_modules":     true,
+		"a/node_modules/pkg/x": true,
+		"out-dir/x":            true,
+		"src/out-dir":          false,
+		"gen/a/tmp/x":          true,
+		"gen/a/b/tmp":          false,
+	} {
+		if actual := IsBazelIgnored(ignores, rel); actual != expected {
+			t.Errorf("IsBazelIgnored(%q) = %v, expected %v", rel, actual, expected)
+		}
+	}
+}
+
+func TestLoadBazelIgnoreReloadsChanges(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(path.Join(dir, ".bazelignore"), []byte("foo\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	excludes, err := LoadBazelIgnore(dir)
+	if err != nil {
+		t.Fatalf("LoadBazelIgnore returned error: %v", err)
+	}
+	if len(excludes) != 1 || excludes[0] != "foo" {
+		t.Errorf("unexpected excludes: %v", excludes)
+	}
+
+	if err := os.WriteFile(path.Join(dir, ".bazelignore"), []byte("foo\nbar\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path.Join(dir, "REPO.bazel"), []byte("ignore_directories([\"baz\"])\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	excludes, err = LoadBazelIgnore(dir)
+	if err != nil {
+		t.Fatalf("LoadBazelIgnore returned error: %v", err)
+	}
+	if len(excludes) != 3 || excludes[0] != "foo" || excludes[1] != "bar" || excludes[2] != "baz" {
+		t.Errorf("expected the changed ignores to be reloaded, got: %v", excludes)
+	}
+}
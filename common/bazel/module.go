@@ -0,0 +1,396 @@
+package bazel
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	BazelLog "github.com/aspect-build/aspect-gazelle/common/logger"
+	bzl "github.com/bazelbuild/buildtools/build"
+)
+
+// Repositories created by well known extension tags when no name is specified,
+// keyed by "<extension>.<tag>".
+var defaultTagRepoNames = map[string]string{
+	"maven.install":          "maven",
+	"npm.npm_translate_lock": "npm",
+}
+
+// A repository created by a module extension and imported by use_repo().
+type ExtensionRepo struct {
+	// The name of the repository in the root module.
+	ApparentName string
+
+	// The name of the repository created by the extension.
+	Name string
+
+	// The extension .bzl file and name, for example "@aspect_rules_js//npm:extensions.bzl" and "npm".
+	ExtensionFile string
+	Extension     string
+
+	// The extension tag creating the repository, for example "npm_translate_lock",
+	// or empty if the repository was not created by a tag in the root module.
+	Tag string
+}
+
+// The repository mapping of the root module declared in MODULE.bazel.
+//
+// Methods may be invoked on a nil *ModuleFile, for example when a workspace has
+// no MODULE.bazel, in which case no repositories are known.
+type ModuleFile struct {
+	// The module(name, repo_name) of the root module.
+	Name     string
+	RepoName string
+
+	// Apparent names of bazel_dep() modules keyed by module name.
+	deps map[string]string
+
+	// Repositories imported from module extensions.
+	repos []ExtensionRepo
+}
+
+// A MODULE.bazel loaded from a workspace and the digests of the files it was
+// loaded from, nil digests for files that do not exist.
+type loadedModuleFile struct {
+	module  *ModuleFile
+	digests map[string]*[sha256.Size]byte
+}
+
+// Loaded module files keyed by the workspace root.
+var moduleFiles sync.Map
+
+// LoadModuleFile loads the MODULE.bazel, including any include() segments, of the
+// workspace at repoRoot.
+//
+// The result is reused until the MODULE.bazel or an included file changes, such
+// as between runs in watch mode.
+//
+// Returns nil if the workspace has no MODULE.bazel.
+func LoadModuleFile(repoRoot string) (*ModuleFile, error) {
+	if v, ok := moduleFiles.Load(repoRoot); ok && v.(*loadedModuleFile).isCurrent(repoRoot) {
+		return v.(*loadedModuleFile).module, nil
+	}
+
+	loaded, err := loadModuleFile(repoRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	moduleFiles.Store(repoRoot, loaded)
+	return loaded.module, nil
+}
+
+func loadModuleFile(repoRoot string) (*loadedModuleFile, error) {
+	content, err := os.ReadFile(path.Join(repoRoot, "MODULE.bazel"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return &loadedModuleFile{digests: map[string]*[sha256.Size]byte{"MODULE.bazel": nil}}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("MODULE.bazel exists but couldn't be read: %v", err)
+	}
+
+	p := newModuleParser()
+	if err := p.parse(repoRoot, "MODULE.bazel", content); err != nil {
+		return nil, err
+	}
+	return &loadedModuleFile{module: p.module(), digests: p.digests}, nil
+}
+
+// isCurrent returns true if none of the files the module was loaded from has changed.
+func (l *loadedModuleFile) isCurrent(repoRoot string) bool {
+	for f, digest := range l.digests {
+		content, err := os.ReadFile(path.Join(repoRoot, f))
+		if errors.Is(err, fs.ErrNotExist) && digest == nil {
+			continue
+		}
+		if err != nil || digest == nil || sha256.Sum256(content) != *digest {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseModuleFile parses the content of a MODULE.bazel file. Any include()
+// statements are ignored.
+func ParseModuleFile(filename string, content []byte) (*ModuleFile, error) {
+	p := newModuleParser()
+	if err := p.parse("", filename, content); err != nil {
+		return nil, err
+	}
+	return p.module(), nil
+}
+
+// ApparentName returns the name of a module or extension repository as visible
+// to the root module, or "" if the repository is not visible.
+func (m *ModuleFile) ApparentName(name string) string {
+	if m == nil {
+		return ""
+	}
+	if apparent, ok := m.deps[name]; ok {
+		return apparent
+	}
+	for _, r := range m.repos {
+		if r.Name == name {
+			return r.ApparentName
+		}
+	}
+	return ""
+}
+
+// ModuleName returns the name of the bazel_dep() module visible to the root
+// module by the apparent name, or "" if no module has that name.
+func (m *ModuleFile) ModuleName(apparentName string) string {
+	if m == nil {
+		return ""
+	}
+	for name, apparent := range m.deps {
+		if apparent == apparentName {
+			return name
+		}
+	}
+	return ""
+}
+
+// ExtensionRepo returns the apparent name of the first repository created by the
+// tag of the named extension, for example ("npm", "npm_translate_lock"), or "" if
+// no such repository is imported into the root module.
+func (m *ModuleFile) ExtensionRepo(extension, tag string) string {
+	if m == nil {
+		return ""
+	}
+	for _, r := range m.repos {
+		if r.Extension == extension && r.Tag == tag {
+			return r.ApparentName
+		}
+	}
+	return ""
+}
+
+// ExtensionRepos returns all repositories imported from module extensions.
+func (m *ModuleFile) ExtensionRepos() []ExtensionRepo {
+	if m == nil {
+		return nil
+	}
+	return m.repos
+}
+
+// A use_extension() proxy assigned to a variable in MODULE.bazel.
+type extensionProxy struct {
+	file, name string
+
+	// Repositories created by tags of this proxy, name => tag.
+	tagRepos map[string]string
+}
+
+type moduleParser struct {
+	m       *ModuleFile
+	proxies map[string]*extensionProxy
+
+	// use_repo() imports in declaration order, resolved once all tags are known.
+	imports []extensionImport
+
+	// The digests of the parsed files of a workspace.
+	digests map[string]*[sha256.Size]byte
+}
+
+type extensionImport struct {
+	proxy              *extensionProxy
+	apparentName, name string
+}
+
+func newModuleParser() *moduleParser {
+	return &moduleParser{
+		m: &ModuleFile{
+			deps: make(map[string]string),
+		},
+		proxies: make(map[string]*extensionProxy),
+		digests: make(map[string]*[sha256.Size]byte),
+	}
+}
+
+func (p *moduleParser) module() *ModuleFile {
+	for _, imp := range p.imports {
+		p.m.repos = append(p.m.repos, ExtensionRepo{
+			ApparentName:  imp.apparentName,
+			Name:          imp.name,
+			ExtensionFile: imp.proxy.file,
+			Extension:     imp.proxy.name,
+			Tag:           imp.proxy.tagRepos[imp.name],
+		})
+	}
+	return p.m
+}
+
+func (p *moduleParser) parse(repoRoot, filename string, content []byte) error {
+	f, err := bzl.ParseModule(filename, content)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", filename, err)
+	}
+
+	if repoRoot != "" {
+		digest := sha256.Sum256(content)
+		p.digests[filename] = &digest
+	}
+
+	for _, stmt := range f.Stmt {
+		switch s := stmt.(type) {
+		case *bzl.AssignExpr:
+			// proxy = use_extension("@repo//:extensions.bzl", "name")
+			lhs, isIdent := s.LHS.(*bzl.Ident)
+			call, isCall := s.RHS.(*bzl.CallExpr)
+			if isIdent && isCall && callName(call) == "use_extension" {
+				p.proxies[lhs.Name] = &extensionProxy{
+					file:     stringArg(call, 0, "extension_bzl_file"),
+					name:     stringArg(call, 1, "extension_name"),
+					tagRepos: make(map[string]string),
+				}
+			}
+		case *bzl.CallExpr:
+			if err := p.parseCall(repoRoot, s); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (p *moduleParser) parseCall(repoRoot string, call *bzl.CallExpr) error {
+	switch fn := call.X.(type) {
+	case *bzl.Ident:
+		switch fn.Name {
+		case "module":
+			p.m.Name = stringArg(call, -1, "name")
+			p.m.RepoName = stringArg(call, -1, "repo_name")
+		case "bazel_dep":
+			name := stringArg(call, -1, "name")
+			repoName := name
+			if v := kwarg(call, "repo_name"); v != nil {
+				// repo_name = None declares a dependency without a visible repository
+				if ident, isIdent := v.(*bzl.Ident); isIdent && ident.Name == "None" {
+					return nil
+				}
+				repoName = stringValue(v)
+			}
+			if name != "" && repoName != "" {
+				p.m.deps[name] = repoName
+			}
+		case "use_repo":
+			p.parseUseRepo(call)
+		case "include":
+			return p.parseInclude(repoRoot, stringArg(call, 0, "label"))
+		}
+
+	case *bzl.DotExpr:
+		// proxy.tag(name = "repo", ...)
+		ident, isIdent := fn.X.(*bzl.Ident)
+		if !isIdent {
+			return nil
+		}
+		proxy := p.proxies[ident.Name]
+		if proxy == nil {
+			return nil
+		}
+		if name := stringArg(call, -1, "name"); name != "" {
+			proxy.tagRepos[name] = fn.Name
+		} else if name, isDefault := defaultTagRepoNames[proxy.name+"."+fn.Name]; isDefault {
+			proxy.tagRepos[name] = fn.Name
+		}
+	}
+
+	return nil
+}
+
+// use_repo(proxy, "name", apparent = "name", ...)
+func (p *moduleParser) parseUseRepo(call *bzl.CallExpr) {
+	if len(call.List) == 0 {
+		return
+	}
+	ident, isIdent := call.List[0].(*bzl.Ident)
+	if !isIdent {
+		return
+	}
+	proxy := p.proxies[ident.Name]
+	if proxy == nil {
+		BazelLog.Debugf("MODULE.bazel use_repo() of unknown extension %q", ident.Name)
+		return
+	}
+
+	for _, arg := range call.List[1:] {
+		if assign, isAssign := arg.(*bzl.AssignExpr); isAssign {
+			if lhs, isIdent := assign.LHS.(*bzl.Ident); isIdent {
+				p.imports = append(p.imports, extensionImport{proxy, lhs.Name, stringValue(assign.RHS)})
+			}
+		} else if name := stringValue(arg); name != "" {
+			p.imports = append(p.imports, extensionImport{proxy, name, name})
+		}
+	}
+}
+
+// include("//path:file.MODULE.bazel")
+func (p *moduleParser) parseInclude(repoRoot, label string) error {
+	if repoRoot == "" || label == "" {
+		return nil
+	}
+
+	if !strings.HasPrefix(label, "//") {
+		BazelLog.Warnf("MODULE.bazel include() of %q not supported, only main repository labels can be included", label)
+		return nil
+	}
+
+	pkg, name, _ := strings.Cut(strings.TrimPrefix(label, "//"), ":")
+	if name == "" {
+		name = path.Base(pkg)
+	}
+	filename := path.Join(pkg, name)
+
+	content, err := os.ReadFile(path.Join(repoRoot, filename))
+	if err != nil {
+		return fmt.Errorf("failed to read MODULE.bazel include %q: %w", label, err)
+	}
+	return p.parse(repoRoot, filename, content)
+}
+
+func callName(call *bzl.CallExpr) string {
+	if ident, isIdent := call.X.(*bzl.Ident); isIdent {
+		return ident.Name
+	}
+	return ""
+}
+
+func kwarg(call *bzl.CallExpr, name string) bzl.Expr {
+	for _, arg := range call.List {
+		if assign, isAssign := arg.(*bzl.AssignExpr); isAssign {
+			if lhs, isIdent := assign.LHS.(*bzl.Ident); isIdent && lhs.Name == name {
+				return assign.RHS
+			}
+		}
+	}
+	return nil
+}
+
+// stringArg returns the string value of a keyword argument or, if pos >= 0, the
+// positional argument at that position.
+func stringArg(call *bzl.CallExpr, pos int, name string) string {
+	if v := kwarg(call, name); v != nil {
+		return stringValue(v)
+	}
+	if pos >= 0 && pos < len(call.List) {
+		if _, isAssign := call.List[pos].(*bzl.AssignExpr); !isAssign {
+			return stringValue(call.List[pos])
+		}
+	}
+	return ""
+}
+
+func stringValue(e bzl.Expr) string {
+	if str, isString := e.(*bzl.StringExpr); isString {
+		return str.Value
+	}
+	return ""
+}
@@ -0,0 +1,153 @@
+package bazel
+
+import (
+	"os"
+	"path"
+	"testing"
+)
+
+const testModuleFile = `
+module(name = "my_module", repo_name = "my_repo")
+
+bazel_dep(name = "rules_kotlin", version = "2.0.0", repo_name = "io_bazel_rules_kotlin")
+bazel_dep(name = "aspect_rules_js", version = "2.0.0")
+bazel_dep(name = "hidden", version = "1.0.0", repo_name = None)
+
+npm = use_extension("@aspect_rules_js//npm:extensions.bzl", "npm")
+npm.npm_translate_lock(
+    name = "npm_deps",
+    pnpm_lock = "//:pnpm-lock.yaml",
+)
+use_repo(npm, npm = "npm_deps")
+
+maven = use_extension("@rules_jvm_external//:extensions.bzl", "maven")
+maven.install(artifacts = ["a:b:1.0"])
+use_repo(maven, "maven", "unpinned_maven")
+`
+
+func TestParseModuleFile(t *testing.T) {
+	m, err := ParseModuleFile("MODULE.bazel", []byte(testModuleFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Name != "my_module" || m.RepoName != "my_repo" {
+		t.Errorf("unexpected module name: %q, %q", m.Name, m.RepoName)
+	}
+
+	for name, expected := range map[string]string{
+		"rules_kotlin":    "io_bazel_rules_kotlin",
+		"aspect_rules_js": "aspect_rules_js",
+		"hidden":          "",
+		"unknown":         "",
+		"npm_deps":        "npm",
+		"maven":           "maven",
+		"unpinned_maven":  "unpinned_maven",
+	} {
+		if actual := m.ApparentName(name); actual != expected {
+			t.Errorf("ApparentName(%q) = %q, expected %q", name, actual, expected)
+		}
+	}
+
+	for apparent, expected := range map[string]string{
+		"io_bazel_rules_kotlin": "rules_kotlin",
+		"aspect_rules_js":       "aspect_rules_js",
+		"rules_kotlin":          "",
+		"npm":                   "",
+	} {
+		if actual := m.ModuleName(apparent); actual != expected {
+			t.Errorf("ModuleName(%q) = %q, expected %q", apparent, actual, expected)
+		}
+	}
+
+	if actual := m.ExtensionRepo("npm", "npm_translate_lock"); actual != "npm" {
+		t.Errorf("ExtensionRepo(npm, npm_translate_lock) = %q, expected npm", actual)
+	}
+	if actual := m.ExtensionRepo("maven", "install"); actual != "maven" {
+		t.Errorf("ExtensionRepo(maven, install) = %q, expected maven", actual)
+	}
+	if actual := m.ExtensionRepo("pnpm", "pnpm"); actual != "" {
+		t.Errorf("ExtensionRepo(pnpm, pnpm) = %q, expected none", actual)
+	}
+
+	if repos := m.ExtensionRepos(); len(repos) != 3 || repos[0].ExtensionFile != "@aspect_rules_js//npm:extensions.bzl" {
+		t.Errorf("unexpected extension repos: %v", repos)
+	}
+}
+
+func TestLoadModuleFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(path.Join(dir, "deps"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path.Join(dir, "MODULE.bazel"), []byte(`include("//deps:js.MODULE.bazel")`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path.Join(dir, "deps", "js.MODULE.bazel"), []byte(`bazel_dep(name = "aspect_rules_ts", version = "3.0.0", repo_name = "ts")`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := LoadModuleFile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := m.ApparentName("aspect_rules_ts"); actual != "ts" {
+		t.Errorf("expected the included bazel_dep, got %q", actual)
+	}
+}
+
+func TestLoadModuleFileMissing(t *testing.T) {
+	m, err := LoadModuleFile(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m != nil || m.ApparentName("rules_go") != "" || m.ExtensionRepo("npm", "npm_translate_lock") != "" {
+		t.Errorf("expected no repositories without a MODULE.bazel, got %v", m)
+	}
+}
+
+func TestLoadModuleFileChanged(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(path.Join(dir, "deps"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	write := func(name, content string) {
+		if err := os.WriteFile(path.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("MODULE.bazel", `include("//deps:js.MODULE.bazel")`)
+	write("deps/js.MODULE.bazel", `bazel_dep(name = "aspect_rules_ts", version = "3.0.0", repo_name = "ts")`)
+
+	m1, err := LoadModuleFile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m2, _ := LoadModuleFile(dir); m2 != m1 {
+		t.Errorf("expected the unchanged module file to be reused")
+	}
+
+	write("deps/js.MODULE.bazel", `bazel_dep(name = "aspect_rules_ts", version = "3.0.0", repo_name = "rules_ts")`)
+
+	m3, err := LoadModuleFile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := m3.ApparentName("aspect_rules_ts"); actual != "rules_ts" {
+		t.Errorf("expected the changed include to be reloaded, got %q", actual)
+	}
+}
+
+func TestDefaultTagRepoNames(t *testing.T) {
+	m, err := ParseModuleFile("MODULE.bazel", []byte(`
+npm = use_extension("@aspect_rules_js//npm:extensions.bzl", "npm")
+npm.npm_translate_lock(pnpm_lock = "//:pnpm-lock.yaml")
+use_repo(npm, "npm")
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := m.ExtensionRepo("npm", "npm_translate_lock"); actual != "npm" {
+		t.Errorf("ExtensionRepo(npm, npm_translate_lock) = %q, expected the default npm", actual)
+	}
+}
@@ -0,0 +1,317 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bazelbuild/bazel-gazelle/config"
+	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+	bzl "github.com/bazelbuild/buildtools/build"
+)
+
+const gazelleDiagnosticReporterKey = "aspect:diagnostics.reporter"
+
+// Severity of a Diagnostic.
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+	SeverityInfo
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	case SeverityInfo:
+		return "info"
+	}
+	return fmt.Sprintf("severity(%d)", int(s))
+}
+
+var severityPrefix = map[Severity]string{
+	SeverityError:   "Error",
+	SeverityWarning: "Warning",
+	SeverityInfo:    "Info",
+}
+
+// DiagnosticCode is a stable identifier for a class of diagnostics, such as
+// `JS001 unresolved-import`. The ID is unique across all languages, the Name
+// is a short human readable slug.
+type DiagnosticCode struct {
+	ID   string
+	Name string
+}
+
+func (dc DiagnosticCode) String() string {
+	if dc.Name == "" {
+		return dc.ID
+	}
+	return dc.ID + " " + dc.Name
+}
+
+// Generic codes used by the deprecated *Errorf functions.
+var (
+	CodeMisconfigured = DiagnosticCode{"GZL001", "misconfigured"}
+	CodeGeneration    = DiagnosticCode{"GZL002", "generation-error"}
+	CodeImport        = DiagnosticCode{"GZL003", "import-error"}
+)
+
+// Location within a file. Line and Column are 1-based, 0 when unknown.
+type Location struct {
+	File   string
+	Line   int
+	Column int
+}
+
+func (l Location) IsZero() bool {
+	return l.File == ""
+}
+
+func (l Location) String() string {
+	if l.Line <= 0 {
+		return l.File
+	}
+	if l.Column <= 0 {
+		return fmt.Sprintf("%s:%d", l.File, l.Line)
+	}
+	return fmt.Sprintf("%s:%d:%d", l.File, l.Line, l.Column)
+}
+
+// Diagnostic is a structured problem reported by a gazelle language.
+//
+// Diagnostic implements error where Error() is the plain message, allowing
+// diagnostics to flow through the existing error accumulation and cancellation.
+type Diagnostic struct {
+	Severity Severity
+	Code     DiagnosticCode
+	Message  string
+
+	// The source file the diagnostic applies to, relative to the repository root.
+	Source Location
+
+	// The BUILD file and directive the diagnostic originates from, if any.
+	BuildFile Location
+	Directive string
+
+	// A human readable suggestion for how to fix the problem.
+	SuggestedFix string
+}
+
+// NewDiagnostic creates an error Diagnostic with a formatted message.
+func NewDiagnostic(code DiagnosticCode, msg string, args ...any) Diagnostic {
+	return Diagnostic{
+		Severity: SeverityError,
+		Code:     code,
+		Message:  fmt.Sprintf(msg, args...),
+	}
+}
+
+func (d Diagnostic) Error() string {
+	return d.Message
+}
+
+// AsWarning returns a copy of the diagnostic with a warning severity.
+func (d Diagnostic) AsWarning() Diagnostic {
+	d.Severity = SeverityWarning
+	return d
+}
+
+// WithSource returns a copy of the diagnostic pointing to the source file
+// location. The line and column may be 0 when unknown.
+func (d Diagnostic) WithSource(file string, line, column int) Diagnostic {
+	d.Source = Location{File: file, Line: line, Column: column}
+	return d
+}
+
+// WithDirective returns a copy of the diagnostic pointing to the `# gazelle:{key}`
+// directive within the BUILD file f.
+func (d Diagnostic) WithDirective(f *rule.File, key string) Diagnostic {
+	d.Directive = key
+	if f != nil {
+		d.BuildFile = directiveLocation(f, key)
+	}
+	return d
+}
+
+// WithBuildFile returns a copy of the diagnostic pointing to the BUILD file f.
+func (d Diagnostic) WithBuildFile(f *rule.File) Diagnostic {
+	if f != nil {
+		d.BuildFile = Location{File: buildFileRel(f)}
+	}
+	return d
+}
+
+// WithRule returns a copy of the diagnostic pointing to the rule r within the
+// BUILD file of the package of from.
+//
+// Only rules already present in the BUILD file on disk have a known line, rules
+// generated by this run point to the BUILD file alone.
+func (d Diagnostic) WithRule(c *config.Config, from label.Label, r *rule.Rule) Diagnostic {
+	d.BuildFile = ruleLocation(c, from.Pkg, r.Name())
+	return d
+}
+
+// WithFix returns a copy of the diagnostic with a suggested fix.
+func (d Diagnostic) WithFix(fix string, args ...any) Diagnostic {
+	d.SuggestedFix = fmt.Sprintf(fix, args...)
+	return d
+}
+
+// DiagnosticReporter receives every diagnostic as it is reported.
+//
+// Reporters may be invoked concurrently.
+type DiagnosticReporter func(d Diagnostic)
+
+// SetDiagnosticReporter registers a reporter on c.Exts which all diagnostics
+// reported via ReportDiagnostic will be forwarded to.
+func SetDiagnosticReporter(c *config.Config, reporter DiagnosticReporter) {
+	c.Exts[gazelleDiagnosticReporterKey] = reporter
+}
+
+// ReportDiagnostic reports a diagnostic to the registered DiagnosticReporter.
+//
+// Error diagnostics cancel the gazelle execution if possible. If cancellation is not
+// setup, the process may exit.
+//
+// Diagnostics of lower severity are only printed to stderr when no reporter is registered.
+func ReportDiagnostic(c *config.Config, d Diagnostic) {
+	reporter, hasReporter := c.Exts[gazelleDiagnosticReporterKey].(DiagnosticReporter)
+	if hasReporter {
+		reporter(d)
+	}
+
+	if d.Severity != SeverityError {
+		if !hasReporter {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", severityPrefix[d.Severity], d.Message)
+		}
+		return
+	}
+
+	cancelOrFatal(c, d)
+}
+
+// DiagnosticsFromError returns all diagnostics contained within err, such as
+// the error returned after a cancellation caused by ReportDiagnostic.
+func DiagnosticsFromError(err error) []Diagnostic {
+	var diags []Diagnostic
+	collectDiagnostics(err, &diags)
+	return diags
+}
+
+func collectDiagnostics(err error, diags *[]Diagnostic) {
+	switch e := err.(type) {
+	case nil:
+		return
+	case Diagnostic:
+		*diags = append(*diags, e)
+	case *errAccumulator:
+		for _, err := range e.snapshot() {
+			collectDiagnostics(err, diags)
+		}
+	case interface{ Unwrap() []error }:
+		for _, err := range e.Unwrap() {
+			collectDiagnostics(err, diags)
+		}
+	default:
+		collectDiagnostics(errors.Unwrap(err), diags)
+	}
+}
+
+// directiveLocation returns the location of the last `# gazelle:{key}` directive in f,
+// aligning with the "last directive wins" semantics of directives.
+func directiveLocation(f *rule.File, key string) Location {
+	loc := Location{File: buildFileRel(f)}
+	if f.File == nil {
+		return loc
+	}
+
+	prefix := "# gazelle:" + key
+	visit := func(comments []bzl.Comment) {
+		for _, c := range comments {
+			if t := strings.TrimSpace(c.Token); t == prefix || strings.HasPrefix(t, prefix+" ") {
+				loc.Line = c.Start.Line
+				loc.Column = c.Start.LineRune
+			}
+		}
+	}
+
+	for _, stmt := range f.File.Stmt {
+		cmts := stmt.Comment()
+		visit(cmts.Before)
+		visit(cmts.Suffix)
+		visit(cmts.After)
+	}
+	visit(f.File.Comments.After)
+
+	return loc
+}
+
+// ruleLocation returns the location of the rule named name within the BUILD file
+// of the package rel. Read from disk, only when a diagnostic is reported.
+func ruleLocation(c *config.Config, rel, name string) Location {
+	for _, base := range c.ValidBuildFileNames {
+		p := filepath.Join(c.RepoRoot, filepath.FromSlash(rel), base)
+		info, err := os.Stat(p)
+		if err != nil || info.IsDir() {
+			continue
+		}
+
+		loc := buildFileRuleLocations(p, info)[name]
+		loc.File = path.Join(rel, base)
+		return loc
+	}
+
+	return Location{File: path.Join(rel, c.DefaultBuildFileName())}
+}
+
+// The rule locations of a BUILD file, parsed once per version of the file.
+type buildFileRules struct {
+	modTime   time.Time
+	size      int64
+	locations map[string]Location
+}
+
+// BUILD file path => *buildFileRules
+var buildFileRulesCache sync.Map
+
+// buildFileRuleLocations returns the line and column of each rule in the BUILD file p,
+// re-parsing the file only when it has changed since the last diagnostic.
+func buildFileRuleLocations(p string, info os.FileInfo) map[string]Location {
+	if v, ok := buildFileRulesCache.Load(p); ok {
+		if cached := v.(*buildFileRules); cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+			return cached.locations
+		}
+	}
+
+	locations := make(map[string]Location)
+	if data, err := os.ReadFile(p); err == nil {
+		if f, err := bzl.ParseBuild(p, data); err == nil {
+			for _, r := range f.Rules("") {
+				if name := r.Name(); name != "" {
+					if _, exists := locations[name]; !exists {
+						start, _ := r.Call.Span()
+						locations[name] = Location{Line: start.Line, Column: start.LineRune}
+					}
+				}
+			}
+		}
+	}
+
+	buildFileRulesCache.Store(p, &buildFileRules{modTime: info.ModTime(), size: info.Size(), locations: locations})
+	return locations
+}
+
+func buildFileRel(f *rule.File) string {
+	return path.Join(f.Pkg, path.Base(f.Path))
+}
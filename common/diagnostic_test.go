@@ -0,0 +1,138 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bazelbuild/bazel-gazelle/config"
+	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+)
+
+var testCode = DiagnosticCode{"TST001", "test-code"}
+
+func TestReportDiagnostic_ErrorCancels(t *testing.T) {
+	c := config.New()
+	ctx := SetupCancellableContext(c, context.Background())
+
+	ReportDiagnostic(c, NewDiagnostic(testCode, "bad %s", "thing").WithSource("a/b.ts", 3, 4))
+
+	if ctx.Err() == nil {
+		t.Fatal("expected context to be cancelled")
+	}
+
+	err := CheckCancellation(c)
+	if err == nil || err.Error() != "bad thing" {
+		t.Fatalf("expected plain message error, got %v", err)
+	}
+
+	diags := DiagnosticsFromError(fmt.Errorf("wrapped: %w", err))
+	if len(diags) != 1 {
+		t.Fatalf("expected 1 diagnostic, got %d", len(diags))
+	}
+	if diags[0].Code != testCode || diags[0].Source.String() != "a/b.ts:3:4" {
+		t.Errorf("unexpected diagnostic %+v", diags[0])
+	}
+}
+
+func TestReportDiagnostic_WarningDoesNotCancel(t *testing.T) {
+	c := config.New()
+	ctx := SetupCancellableContext(c, context.Background())
+
+	var reported []Diagnostic
+	SetDiagnosticReporter(c, func(d Diagnostic) {
+		reported = append(reported, d)
+	})
+
+	ReportDiagnostic(c, NewDiagnostic(testCode, "careful").AsWarning())
+
+	if ctx.Err() != nil {
+		t.Errorf("warnings should not cancel, got %v", ctx.Err())
+	}
+	if len(reported) != 1 || reported[0].Severity != SeverityWarning {
+		t.Errorf("expected the warning to be reported, got %v", reported)
+	}
+}
+
+func TestDiagnosticsFromError_Joined(t *testing.T) {
+	c := config.New()
+	SetupCancellableContext(c, context.Background())
+
+	ReportDiagnostic(c, NewDiagnostic(testCode, "one"))
+	ReportDiagnostic(c, NewDiagnostic(testCode, "two"))
+
+	diags := DiagnosticsFromError(CheckCancellation(c))
+	if len(diags) != 2 || diags[0].Message != "one" || diags[1].Message != "two" {
+		t.Errorf("expected both diagnostics, got %v", diags)
+	}
+
+	if diags := DiagnosticsFromError(fmt.Errorf("plain")); len(diags) != 0 {
+		t.Errorf("expected no diagnostics from a plain error, got %v", diags)
+	}
+}
+
+func TestDiagnostic_WithDirective(t *testing.T) {
+	f, err := rule.LoadData("pkg/BUILD.bazel", "pkg", []byte(`
+# gazelle:js_visibility //a
+# gazelle:js_visibility_extra x
+
+# gazelle:js_visibility //b
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewDiagnostic(testCode, "x").WithDirective(f, "js_visibility")
+	if got := d.BuildFile.String(); got != "pkg/BUILD.bazel:5:1" {
+		t.Errorf("expected last directive location, got %q", got)
+	}
+	if d.Directive != "js_visibility" {
+		t.Errorf("expected directive key, got %q", d.Directive)
+	}
+}
+
+func TestDiagnostic_WithRule(t *testing.T) {
+	c := config.New()
+	c.RepoRoot = t.TempDir()
+	c.ValidBuildFileNames = []string{"BUILD.bazel"}
+
+	if err := os.MkdirAll(filepath.Join(c.RepoRoot, "pkg"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(c.RepoRoot, "pkg", "BUILD.bazel"), []byte(`
+ts_project(name = "a")
+
+ts_project(
+    name = "b",
+)
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	existing := NewDiagnostic(testCode, "x").WithRule(c, label.New("", "pkg", "b"), rule.NewRule("ts_project", "b"))
+	if got := existing.BuildFile.String(); got != "pkg/BUILD.bazel:4:1" {
+		t.Errorf("expected existing rule location, got %q", got)
+	}
+
+	generated := NewDiagnostic(testCode, "x").WithRule(c, label.New("", "pkg", "c"), rule.NewRule("ts_project", "c"))
+	if got := generated.BuildFile.String(); got != "pkg/BUILD.bazel" {
+		t.Errorf("expected BUILD file location, got %q", got)
+	}
+
+	// The cached locations are dropped once the BUILD file changes
+	if err := os.WriteFile(filepath.Join(c.RepoRoot, "pkg", "BUILD.bazel"), []byte(`
+ts_project(
+    name = "c",
+)
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	updated := NewDiagnostic(testCode, "x").WithRule(c, label.New("", "pkg", "c"), rule.NewRule("ts_project", "c"))
+	if got := updated.BuildFile.String(); got != "pkg/BUILD.bazel:2:1" {
+		t.Errorf("expected updated rule location, got %q", got)
+	}
+}
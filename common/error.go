@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"slices"
 	"sync"
 
 	BazelLog "github.com/aspect-build/aspect-gazelle/common/logger"
@@ -49,31 +50,35 @@ func CheckCancellation(c *config.Config) error {
 //
 // If possible, the gazelle execution is cancelled. If cancellation is not setup, the
 // process may exit.
+//
+// Deprecated: use ReportDiagnostic with a language specific DiagnosticCode.
 func MisconfiguredErrorf(c *config.Config, msg string, args ...any) {
-	cancelOrFatal(c, msg, args...)
+	ReportDiagnostic(c, NewDiagnostic(CodeMisconfigured, msg, args...))
 }
 
+// Deprecated: use ReportDiagnostic with a language specific DiagnosticCode.
 func GenerationErrorf(c *config.Config, msg string, args ...any) {
-	cancelOrFatal(c, msg, args...)
+	ReportDiagnostic(c, NewDiagnostic(CodeGeneration, msg, args...))
 }
 
+// Deprecated: use ReportDiagnostic with a language specific DiagnosticCode.
 func ImportErrorf(c *config.Config, msg string, args ...any) {
 	// TODO: only log if running in non-strict mode?
 
-	cancelOrFatal(c, msg, args...)
+	ReportDiagnostic(c, NewDiagnostic(CodeImport, msg, args...))
 }
 
-func cancelOrFatal(c *config.Config, msg string, args ...any) {
+func cancelOrFatal(c *config.Config, err error) {
 	if acc, ok := c.Exts[gazelleContextCancelErrorsKey].(*errAccumulator); ok {
-		acc.add(fmt.Errorf(msg, args...))
+		acc.add(err)
 		if ctxCancel, ctxExists := c.Exts[gazelleContextCancelKey]; ctxExists {
 			ctxCancel.(context.CancelCauseFunc)(acc)
 			return
 		}
 	}
 
-	fmt.Fprintf(os.Stderr, msg, args...)
-	BazelLog.Fatalf(msg, args...)
+	fmt.Fprint(os.Stderr, err.Error())
+	BazelLog.Fatal(err.Error())
 }
 
 // errAccumulator is an error that joins every error appended to it; passed as
@@ -91,6 +96,13 @@ func (a *errAccumulator) add(err error) {
 	a.mu.Unlock()
 }
 
+// snapshot returns a copy of the errors added so far.
+func (a *errAccumulator) snapshot() []error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return slices.Clone(a.errs)
+}
+
 // surface returns the accumulator once, then nil — so walk's w.errs gets a single entry.
 func (a *errAccumulator) surface() error {
 	a.mu.Lock()
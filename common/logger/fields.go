@@ -0,0 +1,130 @@
+package logger
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Fields describe the context of log messages, such as the language and package
+// being processed. Empty fields are omitted.
+type Fields struct {
+	// The gazelle language name.
+	Language string
+	// The package (BUILD file directory) relative to the repository root.
+	Package string
+	// The orion plugin id.
+	Plugin string
+	// The phase such as "configure", "generate" or "resolve".
+	Phase string
+	// The file being processed, relative to the repository root.
+	File string
+}
+
+// merge returns a copy of f with all non-empty fields of o applied.
+func (f Fields) merge(o Fields) Fields {
+	if o.Language != "" {
+		f.Language = o.Language
+	}
+	if o.Package != "" {
+		f.Package = o.Package
+	}
+	if o.Plugin != "" {
+		f.Plugin = o.Plugin
+	}
+	if o.Phase != "" {
+		f.Phase = o.Phase
+	}
+	if o.File != "" {
+		f.File = o.File
+	}
+	return f
+}
+
+// textPrefix renders the non-empty fields as a `[key=value ...] ` prefix for text output.
+func (f Fields) textPrefix() string {
+	var sb strings.Builder
+	add := func(key, value string) {
+		if value == "" {
+			return
+		}
+		if sb.Len() == 0 {
+			sb.WriteByte('[')
+		} else {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(key)
+		sb.WriteByte('=')
+		sb.WriteString(value)
+	}
+	add("lang", f.Language)
+	add("pkg", f.Package)
+	add("plugin", f.Plugin)
+	add("phase", f.Phase)
+	add("file", f.File)
+	if sb.Len() == 0 {
+		return ""
+	}
+	sb.WriteString("] ")
+	return sb.String()
+}
+
+// Logger logs messages with a set of contextual Fields.
+//
+// The level of a Logger is resolved once from the ASPECT_LOG_LEVEL overrides
+// matching its language and package.
+type Logger struct {
+	fields Fields
+	level  Level
+}
+
+// With returns a Logger with the given context fields.
+func With(fields Fields) *Logger {
+	return &Logger{
+		fields: fields,
+		level:  levelFor(fields),
+	}
+}
+
+// With returns a copy of the Logger with additional or overridden context fields.
+func (l *Logger) With(fields Fields) *Logger {
+	return With(l.fields.merge(fields))
+}
+
+func (l *Logger) Tracef(format string, args ...any) {
+	l.logf(TraceLevel, format, args...)
+}
+
+func (l *Logger) Debugf(format string, args ...any) {
+	l.logf(DebugLevel, format, args...)
+}
+
+func (l *Logger) Infof(format string, args ...any) {
+	l.logf(InfoLevel, format, args...)
+}
+
+func (l *Logger) Warnf(format string, args ...any) {
+	l.logf(WarnLevel, format, args...)
+}
+
+func (l *Logger) Errorf(format string, args ...any) {
+	l.logf(ErrorLevel, format, args...)
+}
+
+func (l *Logger) Fatalf(format string, args ...any) {
+	fatal(&l.fields, fmt.Sprintf(format, args...))
+}
+
+func (l *Logger) IsLevelEnabled(lvl Level) bool {
+	return l.level <= lvl
+}
+
+func (l *Logger) IsTraceEnabled() bool {
+	return l.level <= TraceLevel
+}
+
+func (l *Logger) logf(lvl Level, format string, args ...any) {
+	if l.level > lvl {
+		return
+	}
+	output(lvl, &l.fields, fmt.Sprintf(format, args...))
+}
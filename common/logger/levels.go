@@ -0,0 +1,99 @@
+package logger
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A level override for a language or a package (and its subpackages).
+type levelOverride struct {
+	language string
+	pkg      string
+	isPkg    bool
+	level    Level
+}
+
+func parseLevel(s string) (Level, error) {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "TRACE":
+		return TraceLevel, nil
+	case "DEBUG":
+		return DebugLevel, nil
+	case "INFO":
+		return InfoLevel, nil
+	case "WARN":
+		return WarnLevel, nil
+	case "ERROR":
+		return ErrorLevel, nil
+	}
+	return 0, fmt.Errorf("unknown level %q", s)
+}
+
+// parseLevelSpec parses an ASPECT_LOG_LEVEL value: a comma separated list of
+// a default level and `{name}={level}` overrides, where the name is either a
+// language name or a `//` prefixed package.
+//
+// For example: `info,js=trace,orion=warn,//some/pkg=debug`
+func parseLevelSpec(spec string) (*Level, []levelOverride, error) {
+	var defaultLevel *Level
+	var overrides []levelOverride
+
+	for entry := range strings.SplitSeq(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name, levelStr, hasName := strings.Cut(entry, "=")
+		if !hasName {
+			levelStr = name
+		}
+
+		l, err := parseLevel(levelStr)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if !hasName {
+			defaultLevel = &l
+			continue
+		}
+
+		name = strings.TrimSpace(name)
+		if pkg, isPkg := strings.CutPrefix(name, "//"); isPkg {
+			overrides = append(overrides, levelOverride{pkg: strings.Trim(pkg, "/"), isPkg: true, level: l})
+		} else if name != "" {
+			overrides = append(overrides, levelOverride{language: name, level: l})
+		} else {
+			return nil, nil, fmt.Errorf("invalid level override %q", entry)
+		}
+	}
+
+	return defaultLevel, overrides, nil
+}
+
+// levelFor returns the effective level for the given fields.
+//
+// The most specific (longest) matching package override wins, followed by a
+// language override, followed by the default level.
+func levelFor(f Fields) Level {
+	result := level
+	matchedPkgLen := -1
+
+	for _, o := range overrides {
+		if o.isPkg {
+			if len(o.pkg) > matchedPkgLen && isPkgOrSubpkg(f.Package, o.pkg) {
+				result = o.level
+				matchedPkgLen = len(o.pkg)
+			}
+		} else if matchedPkgLen == -1 && o.language == f.Language && f.Language != "" {
+			result = o.level
+		}
+	}
+
+	return result
+}
+
+func isPkgOrSubpkg(rel, pkg string) bool {
+	return pkg == "" || rel == pkg || strings.HasPrefix(rel, pkg+"/")
+}
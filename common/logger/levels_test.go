@@ -0,0 +1,78 @@
+package logger
+
+import (
+	"testing"
+)
+
+func TestParseLevelSpec(t *testing.T) {
+	defaultLevel, overrides, err := parseLevelSpec("info, js=trace,orion=WARN,//a/b=debug,//=error")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if defaultLevel == nil || *defaultLevel != InfoLevel {
+		t.Errorf("expected default level info, got %v", defaultLevel)
+	}
+
+	expected := []levelOverride{
+		{language: "js", level: TraceLevel},
+		{language: "orion", level: WarnLevel},
+		{pkg: "a/b", isPkg: true, level: DebugLevel},
+		{pkg: "", isPkg: true, level: ErrorLevel},
+	}
+	if len(overrides) != len(expected) {
+		t.Fatalf("expected %d overrides, got %v", len(expected), overrides)
+	}
+	for i := range expected {
+		if overrides[i] != expected[i] {
+			t.Errorf("override %d: expected %+v, got %+v", i, expected[i], overrides[i])
+		}
+	}
+}
+
+func TestParseLevelSpec_Invalid(t *testing.T) {
+	for _, spec := range []string{"loud", "js=loud", "=trace"} {
+		if _, _, err := parseLevelSpec(spec); err == nil {
+			t.Errorf("expected error for %q", spec)
+		}
+	}
+}
+
+func TestLevelFor(t *testing.T) {
+	_, o, err := parseLevelSpec("js=trace,orion=error,//a=debug,//a/b/c=error")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prevLevel, prevOverrides := level, overrides
+	defer func() { level, overrides = prevLevel, prevOverrides }()
+	level, overrides = WarnLevel, o
+
+	cases := []struct {
+		fields   Fields
+		expected Level
+	}{
+		{Fields{}, WarnLevel},
+		{Fields{Language: "go"}, WarnLevel},
+		{Fields{Language: "js"}, TraceLevel},
+		{Fields{Language: "orion", Package: "x"}, ErrorLevel},
+		{Fields{Language: "js", Package: "a"}, DebugLevel},
+		{Fields{Language: "js", Package: "a/b"}, DebugLevel},
+		{Fields{Language: "js", Package: "ab"}, TraceLevel},
+		{Fields{Package: "a/b/c/d"}, ErrorLevel},
+	}
+	for _, c := range cases {
+		if got := levelFor(c.fields); got != c.expected {
+			t.Errorf("levelFor(%+v): expected %v, got %v", c.fields, c.expected, got)
+		}
+	}
+}
+
+func TestFieldsTextPrefix(t *testing.T) {
+	f := Fields{Language: "orion", Package: "a/b", Plugin: "p"}
+	if got := f.textPrefix(); got != "[lang=orion pkg=a/b plugin=p] " {
+		t.Errorf("unexpected prefix %q", got)
+	}
+	if got := (Fields{}).textPrefix(); got != "" {
+		t.Errorf("expected empty prefix, got %q", got)
+	}
+}
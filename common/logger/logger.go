@@ -25,9 +25,15 @@ const (
 
 var level = WarnLevel
 
+// Per language or package level overrides, see ASPECT_LOG_LEVEL.
+var overrides []levelOverride
+
 // Clone the default to align defaults.
 var logger = log.New(log.Writer(), log.Prefix(), log.Flags())
 
+// The structured (json) handler, nil when using the default text output.
+var structured *structuredHandler
+
 func init() {
 	// When running bazel tests output to a file in the test.outputs directory.
 	if os.Getenv("BAZEL_TEST") == "1" {
@@ -64,23 +70,25 @@ func init() {
 	// Override known noisy loggers
 	logging.SetLevel(logging.WARNING, "yq-lib")
 
-	// Override the default log level
-	if os.Getenv("ASPECT_LOG_LEVEL") != "" {
-		envLevel := strings.ToUpper(strings.TrimSpace(os.Getenv("ASPECT_LOG_LEVEL")))
-		switch envLevel {
-		case "TRACE":
-			level = TraceLevel
-		case "DEBUG":
-			level = DebugLevel
-		case "INFO":
-			level = InfoLevel
-		case "WARN":
-			level = WarnLevel
-		case "ERROR":
-			level = ErrorLevel
-		default:
-			log.Fatalf("Invalid CLI log level: %s\n", envLevel)
+	// Override the default log level and/or add per language/package overrides
+	if envLevel := os.Getenv("ASPECT_LOG_LEVEL"); envLevel != "" {
+		defaultLevel, levelOverrides, err := parseLevelSpec(envLevel)
+		if err != nil {
+			log.Fatalf("Invalid CLI log level: %v\n", err)
 		}
+		if defaultLevel != nil {
+			level = *defaultLevel
+		}
+		overrides = levelOverrides
+	}
+
+	// The output format
+	switch format := strings.ToLower(strings.TrimSpace(os.Getenv("ASPECT_LOG_FORMAT"))); format {
+	case "", "text":
+	case "json":
+		structured = newStructuredHandler(logger.Writer())
+	default:
+		log.Fatalf("Invalid CLI log format: %s\n", format)
 	}
 }
 
@@ -92,48 +100,48 @@ func Tracef(format string, args ...any) {
 	if level > TraceLevel {
 		return
 	}
-	logger.Printf(format, args...)
+	output(TraceLevel, nil, fmt.Sprintf(format, args...))
 }
 
 func Debugf(format string, args ...any) {
 	if level > DebugLevel {
 		return
 	}
-	logger.Printf(format, args...)
+	output(DebugLevel, nil, fmt.Sprintf(format, args...))
 }
 
 func Infof(format string, args ...any) {
 	if level > InfoLevel {
 		return
 	}
-	logger.Printf(format, args...)
+	output(InfoLevel, nil, fmt.Sprintf(format, args...))
 }
 
 func Warnf(format string, args ...any) {
 	if level > WarnLevel {
 		return
 	}
-	logger.Printf(format, args...)
+	output(WarnLevel, nil, fmt.Sprintf(format, args...))
 }
 
 func Error(msg string) {
 	if level > ErrorLevel {
 		return
 	}
-	logger.Print(msg)
+	output(ErrorLevel, nil, msg)
 }
 func Errorf(format string, args ...any) {
 	if level > ErrorLevel {
 		return
 	}
-	logger.Printf(format, args...)
+	output(ErrorLevel, nil, fmt.Sprintf(format, args...))
 }
 
 func Fatalf(format string, args ...any) {
-	logger.Fatalf(format+"\n", args...)
+	fatal(nil, fmt.Sprintf(format, args...))
 }
 func Fatal(msg string) {
-	logger.Fatal(msg + "\n")
+	fatal(nil, msg)
 }
 
 func IsLevelEnabled(l Level) bool {
@@ -144,3 +152,22 @@ func IsLevelEnabled(l Level) bool {
 func IsTraceEnabled() bool {
 	return level <= TraceLevel
 }
+
+func output(l Level, fields *Fields, msg string) {
+	if structured != nil {
+		structured.log(l, fields, msg)
+		return
+	}
+
+	if fields != nil {
+		if prefix := fields.textPrefix(); prefix != "" {
+			msg = prefix + msg
+		}
+	}
+	logger.Print(msg)
+}
+
+func fatal(fields *Fields, msg string) {
+	output(FatalLevel, fields, msg+"\n")
+	os.Exit(1)
+}
@@ -0,0 +1,66 @@
+package logger
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// slog levels for the Levels without a slog equivalent.
+const (
+	slogTraceLevel = slog.LevelDebug - 4
+	slogFatalLevel = slog.LevelError + 4
+)
+
+var slogLevels = map[Level]slog.Level{
+	TraceLevel: slogTraceLevel,
+	DebugLevel: slog.LevelDebug,
+	InfoLevel:  slog.LevelInfo,
+	WarnLevel:  slog.LevelWarn,
+	ErrorLevel: slog.LevelError,
+	FatalLevel: slogFatalLevel,
+}
+
+// structuredHandler writes log records as JSON lines.
+type structuredHandler struct {
+	logger *slog.Logger
+}
+
+func newStructuredHandler(w io.Writer) *structuredHandler {
+	handler := slog.NewJSONHandler(w, &slog.HandlerOptions{
+		// Levels are filtered before reaching the handler.
+		Level: slogTraceLevel,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.LevelKey && len(groups) == 0 {
+				switch a.Value.Any().(slog.Level) {
+				case slogTraceLevel:
+					a.Value = slog.StringValue("TRACE")
+				case slogFatalLevel:
+					a.Value = slog.StringValue("FATAL")
+				}
+			}
+			return a
+		},
+	})
+	return &structuredHandler{logger: slog.New(handler)}
+}
+
+func (h *structuredHandler) log(l Level, fields *Fields, msg string) {
+	var attrs []slog.Attr
+	if fields != nil {
+		attrs = make([]slog.Attr, 0, 5)
+		addAttr := func(key, value string) {
+			if value != "" {
+				attrs = append(attrs, slog.String(key, value))
+			}
+		}
+		addAttr("lang", fields.Language)
+		addAttr("pkg", fields.Package)
+		addAttr("plugin", fields.Plugin)
+		addAttr("phase", fields.Phase)
+		addAttr("file", fields.File)
+	}
+
+	h.logger.LogAttrs(context.Background(), slogLevels[l], strings.TrimRight(msg, "\n"), attrs...)
+}
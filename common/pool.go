@@ -0,0 +1,262 @@
+package common
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// Priority of work submitted to a WorkerPool. Higher priority work is always
+// started before lower priority work.
+type Priority int
+
+const (
+	// PriorityNormal is the default priority.
+	PriorityNormal Priority = iota
+	// PriorityHigh is for work blocking the package currently being generated.
+	PriorityHigh
+
+	priorityCount
+)
+
+// The number of queued tasks per worker before Group.Go applies back-pressure.
+const queuedTasksPerWorker = 4
+
+var (
+	jobs       atomic.Int32
+	sharedOnce sync.Once
+	shared     *WorkerPool
+)
+
+// SetJobs sets the size of the process-wide SharedPool.
+//
+// Must be invoked before the first use of SharedPool. Values < 1 reset to DefaultJobs().
+func SetJobs(n int) {
+	jobs.Store(int32(n))
+}
+
+// DefaultJobs returns the default number of parallel jobs.
+//
+// GOMAXPROCS defaults to the number of CPUs available to the process which
+// includes cgroup CPU limits.
+func DefaultJobs() int {
+	return runtime.GOMAXPROCS(0)
+}
+
+// SharedPool returns the process-wide WorkerPool all languages should submit work to.
+func SharedPool() *WorkerPool {
+	sharedOnce.Do(func() {
+		n := int(jobs.Load())
+		if n < 1 {
+			n = DefaultJobs()
+		}
+		shared = NewWorkerPool(n)
+	})
+	return shared
+}
+
+// A fixed size pool of workers executing tasks by priority.
+type WorkerPool struct {
+	size     int
+	maxQueue int
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queues  [priorityCount][]*poolTask
+	queued  int
+	started bool
+	closing bool
+	workers sync.WaitGroup
+}
+
+// NewWorkerPool creates a pool of size workers. Workers are started lazily
+// and stopped by Close.
+func NewWorkerPool(size int) *WorkerPool {
+	if size < 1 {
+		size = 1
+	}
+	p := &WorkerPool{
+		size:     size,
+		maxQueue: size * queuedTasksPerWorker,
+	}
+	p.cond = sync.NewCond(&p.mu)
+	return p
+}
+
+// Size returns the number of workers in the pool.
+func (p *WorkerPool) Size() int {
+	return p.size
+}
+
+type poolTask struct {
+	claimed atomic.Bool
+	run     func()
+}
+
+// claim marks the task as started, returning false if it was already started.
+func (t *poolTask) claim() bool {
+	return t.claimed.CompareAndSwap(false, true)
+}
+
+// enqueue adds the task to the queue, returning false if the queue is full.
+func (p *WorkerPool) enqueue(prio Priority, t *poolTask) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closing || p.queued >= p.maxQueue {
+		return false
+	}
+
+	if !p.started {
+		p.started = true
+		p.workers.Add(p.size)
+		for range p.size {
+			go p.work()
+		}
+	}
+
+	p.queues[prio] = append(p.queues[prio], t)
+	p.queued++
+	p.cond.Signal()
+	return true
+}
+
+// next returns the next task by priority, or nil once the pool is closing
+// and no tasks are left.
+func (p *WorkerPool) next() *poolTask {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for p.queued == 0 {
+		if p.closing {
+			return nil
+		}
+		p.cond.Wait()
+	}
+
+	for prio := priorityCount - 1; prio >= 0; prio-- {
+		if q := p.queues[prio]; len(q) > 0 {
+			t := q[0]
+			q[0] = nil
+			p.queues[prio] = q[1:]
+			p.queued--
+			return t
+		}
+	}
+
+	panic("unreachable")
+}
+
+func (p *WorkerPool) work() {
+	defer p.workers.Done()
+
+	for {
+		t := p.next()
+		if t == nil {
+			return
+		}
+		if t.claim() {
+			t.run()
+		}
+	}
+}
+
+// Close stops the workers once the queued tasks have completed, tasks submitted
+// meanwhile run on the submitting goroutine.
+//
+// Workers are started again if the pool is used after Close.
+func (p *WorkerPool) Close() {
+	p.mu.Lock()
+	if !p.started {
+		p.mu.Unlock()
+		return
+	}
+	p.closing = true
+	p.cond.Broadcast()
+	p.mu.Unlock()
+
+	p.workers.Wait()
+
+	p.mu.Lock()
+	p.started = false
+	p.closing = false
+	p.mu.Unlock()
+}
+
+// A Group is a collection of tasks submitted to a WorkerPool, similar to an errgroup.Group.
+//
+// Tasks may themselves create and wait on groups: a waiting Group runs its own
+// not yet started tasks on the waiting goroutine so nested groups can not
+// deadlock when all workers are busy.
+type Group struct {
+	pool     *WorkerPool
+	priority Priority
+
+	wg    sync.WaitGroup
+	mu    sync.Mutex
+	tasks []*poolTask
+
+	errOnce sync.Once
+	err     error
+}
+
+// NewGroup creates a Group submitting tasks with the given priority.
+func (p *WorkerPool) NewGroup(priority Priority) *Group {
+	return &Group{
+		pool:     p,
+		priority: priority,
+	}
+}
+
+// Go submits f to the pool. If the pool queue is full f is run immediately on
+// the calling goroutine, applying back-pressure to the submitter.
+//
+// The first non-nil error is returned by Wait.
+func (g *Group) Go(f func() error) {
+	g.wg.Add(1)
+
+	t := &poolTask{}
+	t.run = func() {
+		defer g.wg.Done()
+		if err := f(); err != nil {
+			g.errOnce.Do(func() {
+				g.err = err
+			})
+		}
+	}
+
+	if !g.pool.enqueue(g.priority, t) {
+		t.claim()
+		t.run()
+		return
+	}
+
+	g.mu.Lock()
+	g.tasks = append(g.tasks, t)
+	g.mu.Unlock()
+}
+
+// Wait blocks until all tasks have completed and returns the first error, if any.
+func (g *Group) Wait() error {
+	// Run any tasks not yet picked up by a worker, including tasks
+	// submitted by other tasks of this group while waiting.
+	for {
+		g.mu.Lock()
+		tasks := g.tasks
+		g.tasks = nil
+		g.mu.Unlock()
+
+		if len(tasks) == 0 {
+			break
+		}
+
+		for _, t := range tasks {
+			if t.claim() {
+				t.run()
+			}
+		}
+	}
+
+	g.wg.Wait()
+	return g.err
+}
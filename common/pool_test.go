@@ -0,0 +1,183 @@
+package common
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestWorkerPool_Group(t *testing.T) {
+	g := NewWorkerPool(4).NewGroup(PriorityNormal)
+
+	var count atomic.Int32
+	for range 100 {
+		g.Go(func() error {
+			count.Add(1)
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if count.Load() != 100 {
+		t.Errorf("expected 100 tasks to run, got %d", count.Load())
+	}
+}
+
+func TestWorkerPool_GroupError(t *testing.T) {
+	g := NewWorkerPool(2).NewGroup(PriorityNormal)
+
+	first := errors.New("first")
+	g.Go(func() error { return first })
+	if err := g.Wait(); err != first {
+		t.Errorf("expected the task error, got %v", err)
+	}
+}
+
+func TestWorkerPool_NestedGroups(t *testing.T) {
+	// A single worker would deadlock if nested groups relied on other workers.
+	pool := NewWorkerPool(1)
+
+	var count atomic.Int32
+	outer := pool.NewGroup(PriorityNormal)
+	for range 10 {
+		outer.Go(func() error {
+			inner := pool.NewGroup(PriorityHigh)
+			for range 10 {
+				inner.Go(func() error {
+					count.Add(1)
+					return nil
+				})
+			}
+			return inner.Wait()
+		})
+	}
+
+	if err := outer.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if count.Load() != 100 {
+		t.Errorf("expected 100 nested tasks to run, got %d", count.Load())
+	}
+}
+
+func TestWorkerPool_Priority(t *testing.T) {
+	pool := NewWorkerPool(1)
+
+	// Block the only worker until all tasks are queued.
+	started := make(chan struct{})
+	release := make(chan struct{})
+	blocker := pool.NewGroup(PriorityNormal)
+	blocker.Go(func() error {
+		close(started)
+		<-release
+		return nil
+	})
+	<-started
+
+	var mu sync.Mutex
+	var order []Priority
+	record := func(p Priority) func() error {
+		return func() error {
+			mu.Lock()
+			defer mu.Unlock()
+			order = append(order, p)
+			return nil
+		}
+	}
+
+	normal := pool.NewGroup(PriorityNormal)
+	high := pool.NewGroup(PriorityHigh)
+	normal.Go(record(PriorityNormal))
+	high.Go(record(PriorityHigh))
+
+	close(release)
+	blocker.Wait()
+
+	// Wait on the groups once the worker, not Wait, has run the tasks.
+	for {
+		mu.Lock()
+		n := len(order)
+		mu.Unlock()
+		if n == 2 {
+			break
+		}
+	}
+	high.Wait()
+	normal.Wait()
+
+	expected := []Priority{PriorityHigh, PriorityNormal}
+	if !slices.Equal(order, expected) {
+		t.Errorf("expected tasks to run by priority %v, got %v", expected, order)
+	}
+}
+
+func TestWorkerPool_BackPressure(t *testing.T) {
+	pool := NewWorkerPool(1)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	blocker := pool.NewGroup(PriorityNormal)
+	blocker.Go(func() error {
+		close(started)
+		<-release
+		return nil
+	})
+	<-started
+
+	// Fill the queue, the following task must run on the calling goroutine.
+	g := pool.NewGroup(PriorityNormal)
+	for range queuedTasksPerWorker {
+		g.Go(func() error { return nil })
+	}
+
+	ranInline := false
+	g.Go(func() error {
+		ranInline = true
+		return nil
+	})
+	if !ranInline {
+		t.Error("expected a full queue to run the task inline")
+	}
+
+	close(release)
+	blocker.Wait()
+	g.Wait()
+}
+
+func TestWorkerPool_Close(t *testing.T) {
+	pool := NewWorkerPool(2)
+
+	// Closing an unused pool is a no-op
+	pool.Close()
+
+	for range 2 {
+		g := pool.NewGroup(PriorityNormal)
+		var count atomic.Int32
+		for range 10 {
+			g.Go(func() error {
+				count.Add(1)
+				return nil
+			})
+		}
+		if err := g.Wait(); err != nil {
+			t.Fatal(err)
+		}
+		if count.Load() != 10 {
+			t.Errorf("expected 10 tasks to run, got %d", count.Load())
+		}
+
+		// Waits for the workers to exit, the next iteration starts them again
+		pool.Close()
+
+		pool.mu.Lock()
+		started := pool.started
+		pool.mu.Unlock()
+		if started {
+			t.Error("expected the workers to be stopped")
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package rule
+
+import (
+	"iter"
+	"slices"
+	"strings"
+
+	"github.com/bazelbuild/bazel-gazelle/rule"
+	bzl "github.com/bazelbuild/buildtools/build"
+)
+
+// Utils for `# keep` comments preserving user edits in BUILD files.
+//
+// Keep semantics apply at three levels:
+//   - whole-rule: a `# keep` on the rule, the rule is never modified or removed
+//   - per-attribute: a `# keep` on the attribute assignment, the attribute value is never modified
+//   - per-list-element: a `# keep` on an element of a list attribute, the element is never removed
+
+// IsRuleKept reports whether the rule has a whole-rule `# keep` comment.
+func IsRuleKept(r *rule.Rule) bool {
+	return r != nil && r.ShouldKeep()
+}
+
+// IsAttrKept reports whether the attribute assignment has a `# keep` comment.
+//
+// Only AssignExpr-level `# keep` comments are honored: comments placed on the
+// RHS are not round-tripped by gazelle, so honoring them would be non-idempotent.
+func IsAttrKept(r *rule.Rule, attr string) bool {
+	return HasKeepComment(r.AttrComments(attr))
+}
+
+// IsAttrPinned reports whether the attribute value is user-pinned and should not be
+// overwritten by generated values.
+//
+// An attribute is pinned when its assignment has a `# keep` comment or its value
+// is not a literal, such as a `glob()`, `select()`, variable reference or concatenation
+// that gazelle can not merge with a generated value.
+func IsAttrPinned(r *rule.Rule, attr string) bool {
+	value := r.Attr(attr)
+	if value == nil {
+		return false
+	}
+	return !isLiteral(value) || IsAttrKept(r, attr)
+}
+
+// PinnedAttrs returns the names of all pinned attributes of the rule.
+func PinnedAttrs(r *rule.Rule) []string {
+	var pinned []string
+	for _, attr := range r.AttrKeys() {
+		if IsAttrPinned(r, attr) {
+			pinned = append(pinned, attr)
+		}
+	}
+	return pinned
+}
+
+// The private attribute recording the pinned attributes of a generated rule.
+const pinnedAttrsKey = "_aspect_pinned_attrs"
+
+// PreservePinnedAttrs copies the pinned attribute values of an existing rule to
+// the generated rule so merging does not clobber user edits. The pinned attributes
+// are recorded on the generated rule, see IsPinnedAttr.
+//
+// Returns the names of the pinned attributes.
+func PreservePinnedAttrs(existing, generated *rule.Rule) []string {
+	if existing == nil {
+		return nil
+	}
+
+	pinned := PinnedAttrs(existing)
+	for _, attr := range pinned {
+		generated.SetAttr(attr, existing.Attr(attr))
+	}
+	if len(pinned) > 0 {
+		generated.SetPrivateAttr(pinnedAttrsKey, pinned)
+	}
+	return pinned
+}
+
+// IsPinnedAttr reports whether the attribute of a generated rule was pinned in
+// the existing rule and must not be modified, such as when resolving dependencies.
+func IsPinnedAttr(generated *rule.Rule, attr string) bool {
+	pinned, _ := generated.PrivateAttr(pinnedAttrsKey).([]string)
+	return slices.Contains(pinned, attr)
+}
+
+// KeptListValues yields the string values in a list attribute carrying a `# keep` comment.
+func KeptListValues(expr bzl.Expr) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		list, ok := expr.(*bzl.ListExpr)
+		if !ok {
+			return
+		}
+		for _, e := range list.List {
+			if !rule.ShouldKeep(e) {
+				continue
+			}
+			str, ok := e.(*bzl.StringExpr)
+			if !ok {
+				continue
+			}
+			if !yield(str.Value) {
+				return
+			}
+		}
+	}
+}
+
+// HasKeepComment reports whether any `# keep` comment is present in c.
+func HasKeepComment(c *bzl.Comments) bool {
+	if c == nil {
+		return false
+	}
+	return hasKeepToken(c.Before) || hasKeepToken(c.Suffix)
+}
+
+func hasKeepToken(cs []bzl.Comment) bool {
+	for _, cm := range cs {
+		text := strings.TrimSpace(strings.TrimPrefix(cm.Token, "#"))
+		if text == "keep" || strings.HasPrefix(text, "keep: ") {
+			return true
+		}
+	}
+	return false
+}
+
+// isLiteral reports whether the expression is a literal value gazelle can merge.
+func isLiteral(expr bzl.Expr) bool {
+	switch e := expr.(type) {
+	case *bzl.StringExpr, *bzl.LiteralExpr, *bzl.ListExpr, *bzl.DictExpr:
+		return true
+	case *bzl.Ident:
+		return e.Name == "True" || e.Name == "False" || e.Name == "None"
+	}
+	return false
+}
@@ -0,0 +1,148 @@
+package rule
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/bazelbuild/bazel-gazelle/rule"
+)
+
+func loadRule(t *testing.T, src string) *rule.Rule {
+	t.Helper()
+
+	f, err := rule.LoadData("BUILD.bazel", "", []byte(src))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(f.Rules) != 1 {
+		t.Fatalf("expected 1 rule, got %d", len(f.Rules))
+	}
+	return f.Rules[0]
+}
+
+func TestIsAttrPinned(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		src  string
+		want bool
+	}{
+		{
+			name: "missing srcs: not pinned",
+			src:  `ts_project(name="r")`,
+			want: false,
+		},
+		{
+			name: "plain list: not pinned",
+			src:  `ts_project(name="r", srcs=["a.ts"])`,
+			want: false,
+		},
+		{
+			name: "per-entry keep: not pinned (handled separately)",
+			src:  `ts_project(name="r", srcs=["a.ts"  # keep` + "\n])",
+			want: false,
+		},
+		{
+			name: "keep suffix on assignment line: pinned",
+			src:  "ts_project(\n  name=\"r\",\n  srcs=[\"a.ts\"],  # keep\n)\n",
+			want: true,
+		},
+		{
+			name: "keep: <reason> on assignment line: pinned",
+			src:  "ts_project(\n  name=\"r\",\n  srcs=[\"a.ts\"],  # keep: generated list\n)\n",
+			want: true,
+		},
+		{
+			name: "keep before assignment: pinned",
+			src:  "ts_project(\n  name=\"r\",\n  # keep\n  srcs=[\"a.ts\"],\n)\n",
+			want: true,
+		},
+		{
+			name: "keep between `=` and list (attaches to RHS): not pinned — gazelle drops this comment on write, so honoring it would be non-idempotent",
+			src:  "ts_project(\n  name=\"r\",\n  srcs =\n      # keep\n      [\"a.ts\"],\n)\n",
+			want: false,
+		},
+		{
+			name: "glob: pinned",
+			src:  `ts_project(name="r", srcs=glob(["*.ts"]))`,
+			want: true,
+		},
+		{
+			name: "list + glob: pinned",
+			src:  `ts_project(name="r", srcs=["a.ts"] + glob(["*.ts"]))`,
+			want: true,
+		},
+		{
+			name: "variable: pinned",
+			src:  `ts_project(name="r", srcs=SRCS)`,
+			want: true,
+		},
+		{
+			name: "string: not pinned",
+			src:  `ts_project(name="r", srcs="a.ts")`,
+			want: false,
+		},
+		{
+			name: "bool: not pinned",
+			src:  `ts_project(name="r", srcs=True)`,
+			want: false,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := IsAttrPinned(loadRule(t, tc.src), "srcs"); got != tc.want {
+				t.Errorf("IsAttrPinned = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestIsRuleKept(t *testing.T) {
+	if !IsRuleKept(loadRule(t, "ts_project(name=\"r\")  # keep\n")) {
+		t.Error("expected a rule with a keep comment to be kept")
+	}
+	if IsRuleKept(loadRule(t, `ts_project(name="r")`)) {
+		t.Error("expected a rule without a keep comment to not be kept")
+	}
+	if IsRuleKept(nil) {
+		t.Error("expected a nil rule to not be kept")
+	}
+}
+
+func TestPinnedAttrs(t *testing.T) {
+	r := loadRule(t, "kt_jvm_library(\n  name=\"r\",\n  srcs=glob([\"*.kt\"]),\n  deps=[\"//a\"],  # keep\n  visibility=[\"//visibility:public\"],\n)\n")
+
+	got := PinnedAttrs(r)
+	slices.Sort(got)
+	if !slices.Equal(got, []string{"deps", "srcs"}) {
+		t.Errorf("PinnedAttrs = %v, want [deps srcs]", got)
+	}
+}
+
+func TestKeptListValues(t *testing.T) {
+	r := loadRule(t, "ts_project(\n  name=\"r\",\n  srcs=[\n    \"a.ts\",  # keep\n    \"b.ts\",\n    \"c.ts\",  # keep\n  ],\n)\n")
+
+	got := slices.Collect(KeptListValues(r.Attr("srcs")))
+	if !slices.Equal(got, []string{"a.ts", "c.ts"}) {
+		t.Errorf("KeptListValues = %v, want [a.ts c.ts]", got)
+	}
+}
+
+func TestPreservePinnedAttrs(t *testing.T) {
+	existing := loadRule(t, "kt_jvm_library(\n  name=\"r\",\n  srcs=glob([\"*.kt\"]),\n  deps=[\"//a\"],\n)\n")
+	generated := rule.NewRule("kt_jvm_library", "r")
+	generated.SetAttr("srcs", []string{"a.kt"})
+	generated.SetAttr("deps", []string{"//b"})
+
+	pinned := PreservePinnedAttrs(existing, generated)
+	if !slices.Equal(pinned, []string{"srcs"}) {
+		t.Errorf("PreservePinnedAttrs = %v, want [srcs]", pinned)
+	}
+	if generated.Attr("srcs") != existing.Attr("srcs") {
+		t.Error("expected the pinned srcs to be copied from the existing rule")
+	}
+	if !IsPinnedAttr(generated, "srcs") || IsPinnedAttr(generated, "deps") {
+		t.Error("expected only srcs to be recorded as pinned")
+	}
+	if PreservePinnedAttrs(nil, generated) != nil {
+		t.Error("expected no pinned attributes without an existing rule")
+	}
+}
@@ -40,6 +40,12 @@ func RemoveRule(args language.GenerateArgs, ruleName string, generatedKinds *tre
 		return
 	}
 
+	// Never remove rules the user has marked with `# keep`
+	if IsRuleKept(existing) {
+		BazelLog.Debugf("remove rule '%s:%s' skipped, rule is kept", args.Rel, ruleName)
+		return
+	}
+
 	// Only remove rules controlled by this gazelle plugin
 	if mappedKind, isMapped := getMappedKind(args, generatedKinds, existing.Kind()); isMapped {
 		BazelLog.Infof("remove rule '%s:%s' (%q mapped as %q)", args.Rel, existing.Name(), mappedKind, existing.Kind())
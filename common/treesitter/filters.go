@@ -1,6 +1,10 @@
 package treesitter
 
 import (
+	"fmt"
+	"slices"
+	"strings"
+
 	sitter "github.com/smacker/go-tree-sitter"
 )
 
@@ -16,10 +20,14 @@ import (
 //   - https://tree-sitter.github.io/tree-sitter/using-parsers/queries/3-predicates-and-directives.html
 //
 // Predicates implemented here:
-//   - eq?, not-eq?
-//   - match?, not-match?
+//   - eq?, not-eq?, any-eq?, any-not-eq?
+//   - match?, not-match?, any-match?, any-not-match?
+//   - any-of?, not-any-of?
+//   - is?, is-not?: properties set by the #set! directives of the pattern
 //
-// Not implemented: any-eq?, any-not-eq?, any-match?, any-not-match?, any-of?, not-any-of?, is?, is-not?, set!.
+// Predicates on quantified captures must hold for all nodes of the capture, the
+// any- variants for at least one node, per the tree-sitter reference impl:
+//   - https://github.com/tree-sitter/tree-sitter/blob/master/lib/binding_rust/lib.rs
 func matchesAllPredicates(q *sitterQuery, m *sitter.QueryMatch, qc *sitter.QueryCursor, input []byte) bool {
 	predicates := q.PredicatesForPattern(uint32(m.PatternIndex))
 	if len(predicates) == 0 {
@@ -31,69 +39,192 @@ func matchesAllPredicates(q *sitterQuery, m *sitter.QueryMatch, qc *sitter.Query
 		operator := q.StringValueForId(steps[0].ValueId)
 
 		switch operator {
-		case "eq?", "not-eq?":
-			isPositive := operator == "eq?"
+		case "eq?", "not-eq?", "any-eq?", "any-not-eq?":
+			isPositive := operator == "eq?" || operator == "any-eq?"
+			matchAll := !strings.HasPrefix(operator, "any-")
 
-			expectedCaptureNameLeft := q.CaptureNameForId(steps[1].ValueId)
+			left := captureContents(m, steps[1].ValueId, input)
 
 			if steps[2].Type == sitter.QueryPredicateStepTypeCapture {
-				expectedCaptureNameRight := q.CaptureNameForId(steps[2].ValueId)
-
-				// Pairwise + equal-length, per tree-sitter reference impl:
-				// https://github.com/tree-sitter/tree-sitter/blob/master/lib/binding_rust/lib.rs
-				var leftContents, rightContents []string
-				for _, c := range m.Captures {
-					captureName := q.CaptureNameForId(c.Index)
-					if captureName == expectedCaptureNameLeft {
-						leftContents = append(leftContents, c.Node.Content(input))
-					}
-					if captureName == expectedCaptureNameRight {
-						rightContents = append(rightContents, c.Node.Content(input))
-					}
-				}
+				right := captureContents(m, steps[2].ValueId, input)
 
-				if len(leftContents) != len(rightContents) {
+				// Pairwise + equal-length
+				if matchAll && len(left) != len(right) {
 					return false
 				}
-				for i := range leftContents {
-					if (leftContents[i] == rightContents[i]) != isPositive {
-						return false
-					}
+				if !anyOrAll(matchAll, min(len(left), len(right)), func(i int) bool {
+					return (left[i] == right[i]) == isPositive
+				}) {
+					return false
 				}
 			} else {
-				expectedValueRight := q.StringValueForId(steps[2].ValueId)
-
-				for _, c := range m.Captures {
-					captureName := q.CaptureNameForId(c.Index)
+				expected := q.StringValueForId(steps[2].ValueId)
 
-					if expectedCaptureNameLeft != captureName {
-						continue
-					}
-
-					if (c.Node.Content(input) == expectedValueRight) != isPositive {
-						return false
-					}
+				if !anyOrAll(matchAll, len(left), func(i int) bool {
+					return (left[i] == expected) == isPositive
+				}) {
+					return false
 				}
 			}
 
-		case "match?", "not-match?":
-			isPositive := operator == "match?"
+		case "match?", "not-match?", "any-match?", "any-not-match?":
+			isPositive := operator == "match?" || operator == "any-match?"
+			matchAll := !strings.HasPrefix(operator, "any-")
 
-			expectedCaptureName := q.CaptureNameForId(steps[1].ValueId)
 			matcher := q.MatcherForId(steps[2].ValueId)
+			nodes := captureNodes(m, steps[1].ValueId)
 
-			for _, c := range m.Captures {
-				captureName := q.CaptureNameForId(c.Index)
-				if expectedCaptureName != captureName {
-					continue
-				}
+			if !anyOrAll(matchAll, len(nodes), func(i int) bool {
+				return matcher(input[nodes[i].StartByte():nodes[i].EndByte()]) == isPositive
+			}) {
+				return false
+			}
+
+		case "any-of?", "not-any-of?":
+			isPositive := operator == "any-of?"
+
+			values := make([]string, 0, len(steps)-2)
+			for _, s := range steps[2:] {
+				values = append(values, q.StringValueForId(s.ValueId))
+			}
 
-				if matcher(input[c.Node.StartByte():c.Node.EndByte()]) != isPositive {
+			for _, content := range captureContents(m, steps[1].ValueId, input) {
+				if slices.Contains(values, content) != isPositive {
 					return false
 				}
 			}
+
+		case "is?", "is-not?":
+			isPositive := operator == "is?"
+
+			// Properties are per pattern
+			value, isSet := q.MetadataForPattern(uint32(m.PatternIndex))[q.StringValueForId(steps[1].ValueId)]
+			if (isSet && value == q.StringValueForId(steps[2].ValueId)) != isPositive {
+				return false
+			}
+
+		default:
+			// Directives such as #set! do not filter matches, unknown
+			// predicates are rejected when the query is compiled.
 		}
 	}
 
 	return true
 }
+
+// anyOrAll returns if the condition holds for all or any of the n values.
+func anyOrAll(all bool, n int, cond func(i int) bool) bool {
+	for i := range n {
+		if cond(i) != all {
+			return !all
+		}
+	}
+	return all
+}
+
+// The nodes of a capture, multiple for quantified captures.
+func captureNodes(m *sitter.QueryMatch, captureId uint32) []*sitter.Node {
+	var nodes []*sitter.Node
+	for _, c := range m.Captures {
+		if c.Index == captureId {
+			nodes = append(nodes, c.Node)
+		}
+	}
+	return nodes
+}
+
+func captureContents(m *sitter.QueryMatch, captureId uint32, input []byte) []string {
+	var contents []string
+	for _, c := range m.Captures {
+		if c.Index == captureId {
+			contents = append(contents, c.Node.Content(input))
+		}
+	}
+	return contents
+}
+
+func isDirective(operator string) bool {
+	return strings.HasSuffix(operator, "!")
+}
+
+// The minimum and maximum (-1 if unbounded) number of arguments of standard
+// predicates and directives.
+var predicateArity = map[string][2]int{
+	"eq?":            {2, 2},
+	"not-eq?":        {2, 2},
+	"any-eq?":        {2, 2},
+	"any-not-eq?":    {2, 2},
+	"match?":         {2, 2},
+	"not-match?":     {2, 2},
+	"any-match?":     {2, 2},
+	"any-not-match?": {2, 2},
+	"any-of?":        {2, -1},
+	"not-any-of?":    {2, -1},
+	"is?":            {2, 2},
+	"is-not?":        {2, 2},
+	"set!":           {2, 2},
+}
+
+// validatePredicate returns an error for an unknown or malformed predicate.
+func validatePredicate(q *sitterQuery, steps []sitter.QueryPredicateStep) error {
+	operator := q.StringValueForId(steps[0].ValueId)
+	args := steps[1:]
+
+	arity, isStandard := predicateArity[operator]
+	if !isStandard {
+		if isDirective(operator) {
+			// Unknown directives such as nvim-treesitter #offset! have no effect
+			return nil
+		}
+		return fmt.Errorf("unknown predicate #%s", operator)
+	}
+
+	if len(args) < arity[0] || (arity[1] >= 0 && len(args) > arity[1]) {
+		return fmt.Errorf("wrong number of arguments to #%s predicate, got %d", operator, len(args))
+	}
+
+	isCapture := func(i int) bool {
+		return args[i].Type == sitter.QueryPredicateStepTypeCapture
+	}
+
+	switch operator {
+	case "eq?", "not-eq?", "any-eq?", "any-not-eq?":
+		if !isCapture(0) {
+			return fmt.Errorf("first argument to #%s predicate must be a capture", operator)
+		}
+	case "match?", "not-match?", "any-match?", "any-not-match?", "any-of?", "not-any-of?":
+		if !isCapture(0) {
+			return fmt.Errorf("first argument to #%s predicate must be a capture", operator)
+		}
+		for i := 1; i < len(args); i++ {
+			if isCapture(i) {
+				return fmt.Errorf("arguments to #%s predicate must be strings", operator)
+			}
+		}
+	case "is?", "is-not?", "set!":
+		// A property key and value, go-tree-sitter rejects a key without a value
+		for i := range args {
+			if isCapture(i) {
+				return fmt.Errorf("#%s key and value must be strings", operator)
+			}
+		}
+	}
+
+	return nil
+}
+
+// patternMetadata collects the properties set by the #set! directives of a pattern.
+func patternMetadata(q *sitterQuery, predicates [][]sitter.QueryPredicateStep) map[string]string {
+	var metadata map[string]string
+	for _, steps := range predicates {
+		if q.StringValueForId(steps[0].ValueId) != "set!" {
+			continue
+		}
+
+		if metadata == nil {
+			metadata = make(map[string]string)
+		}
+		metadata[q.StringValueForId(steps[1].ValueId)] = q.StringValueForId(steps[2].ValueId)
+	}
+	return metadata
+}
@@ -1,6 +1,7 @@
 package treesitter_test
 
 import (
+	"errors"
 	"slices"
 	"strings"
 	"testing"
@@ -318,6 +319,14 @@ func TestQueryErrors_errorFormat(t *testing.T) {
 	if lines[1] != "        ^" {
 		t.Errorf("caret line: got %q, want %q", lines[1], "        ^")
 	}
+
+	var syntaxErr *treesitter.SyntaxError
+	if !errors.As(errs[0], &syntaxErr) {
+		t.Fatalf("expected a *SyntaxError, got %T", errs[0])
+	}
+	if syntaxErr.Line != 3 || syntaxErr.Column != 1 || syntaxErr.SourceLine != ")" {
+		t.Errorf("got %d:%d %q, want 3:1 %q", syntaxErr.Line, syntaxErr.Column, syntaxErr.SourceLine, ")")
+	}
 }
 
 func TestCapturesMap_allCapturesPresent(t *testing.T) {
@@ -409,3 +418,111 @@ func TestGetQuery_unknownNodeErrorCompatibility(t *testing.T) {
 		t.Fatalf("got %q, want %q", err.Error(), want)
 	}
 }
+
+// #any-match?/#any-eq? with a quantified capture: at least one binding must match.
+func TestAnyPredicates_quantified(t *testing.T) {
+	ast := mustParseGo(t, `package foo
+func Foo() {}
+func bar() {}
+`)
+
+	for _, tc := range []struct {
+		predicate string
+		want      int
+	}{
+		{`(#any-match? @n "^[A-Z]")`, 1},
+		{`(#any-match? @n "^[0-9]")`, 0},
+		{`(#any-not-match? @n "^[A-Z]")`, 1},
+		{`(#any-eq? @n "bar")`, 1},
+		{`(#any-eq? @n "baz")`, 0},
+		{`(#any-not-eq? @n "bar")`, 1},
+		{`(#eq? @n "bar")`, 0},
+	} {
+		q := mustQuery(t, `(source_file
+			(function_declaration name: (identifier) @n)
+			(function_declaration name: (identifier) @n)
+			`+tc.predicate+`)`)
+		if n := countMatches(ast, q); n != tc.want {
+			t.Errorf("%s: got %d matches, want %d", tc.predicate, n, tc.want)
+		}
+	}
+}
+
+func TestAnyOfPredicate(t *testing.T) {
+	ast := mustParseGo(t, goFunctions)
+
+	q := mustQuery(t, `(function_declaration name: (identifier) @name (#any-of? @name "Foo" "baz" "qux"))`)
+	if got, want := collectCaptures(ast, q, "name"), []string{"Foo", "baz"}; !slices.Equal(got, want) {
+		t.Errorf("any-of?: got %v, want %v", got, want)
+	}
+
+	q = mustQuery(t, `(function_declaration name: (identifier) @name (#not-any-of? @name "Foo" "baz"))`)
+	if got, want := collectCaptures(ast, q, "name"), []string{"Bar"}; !slices.Equal(got, want) {
+		t.Errorf("not-any-of?: got %v, want %v", got, want)
+	}
+}
+
+// #set! properties are returned as match metadata and asserted by #is?/#is-not?.
+func TestSetDirectiveMetadata(t *testing.T) {
+	ast := mustParseGo(t, goFunctions)
+	q := mustQuery(t, `
+		((function_declaration name: (identifier) @name)
+			(#match? @name "^[A-Z]")
+			(#set! visibility "public")
+			(#set! exported "true"))
+		((function_declaration name: (identifier) @name)
+			(#not-match? @name "^[A-Z]"))`)
+
+	var got []string
+	for m := range ast.QueryMatches(q) {
+		got = append(got, m.Captures["name"]+":"+m.Metadata["visibility"])
+		if _, exported := m.Metadata["exported"]; exported != (m.Metadata["visibility"] == "public") {
+			t.Errorf("unexpected metadata for %s: %v", m.Captures["name"], m.Metadata)
+		}
+	}
+	if want := []string{"Foo:public", "Bar:public", "baz:"}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	for _, tc := range []struct {
+		predicate string
+		want      []string
+	}{
+		{`(#is? kind "func")`, []string{"Foo", "Bar", "baz"}},
+		{`(#is? kind "type")`, nil},
+		{`(#is-not? kind "type")`, []string{"Foo", "Bar", "baz"}},
+		{`(#is-not? kind "func")`, nil},
+	} {
+		q := mustQuery(t, `((function_declaration name: (identifier) @name) (#set! kind "func") `+tc.predicate+`)`)
+		if got := collectCaptures(ast, q, "name"); !slices.Equal(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.predicate, got, tc.want)
+		}
+	}
+}
+
+// Unknown or malformed predicates fail at query-compile time instead of being ignored.
+func TestGetQuery_invalidPredicates(t *testing.T) {
+	for _, predicate := range []string{
+		`(#unknown? @name)`,
+		`(#eq? @name)`,
+		`(#eq? "Foo" @name)`,
+		`(#any-of? @name)`,
+		`(#any-of? @name @name)`,
+		`(#match? @name @name)`,
+		`(#set! @name)`,
+		`(#set! key)`,
+		`(#is? @name @name)`,
+		`(#is-not? key)`,
+	} {
+		if _, err := treesitter.GetQuery(goLang, `((function_declaration name: (identifier) @name) `+predicate+`)`); err == nil {
+			t.Errorf("expected an error for %s", predicate)
+		}
+	}
+
+	// Unknown directives have no effect
+	ast := mustParseGo(t, goFunctions)
+	q := mustQuery(t, `((function_declaration name: (identifier) @name) (#offset! @name 0 1 0 -1))`)
+	if n := countMatches(ast, q); n != 3 {
+		t.Errorf("got %d matches, want 3", n)
+	}
+}
@@ -0,0 +1,127 @@
+package treesitter
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// All supported grammars.
+var grammars = []LanguageGrammar{
+	Kotlin,
+	Starlark,
+	Typescript,
+	TypescriptX,
+	JSON,
+	Java,
+	Go,
+	Rust,
+	Ruby,
+	HCL,
+	Python,
+	C,
+	Cpp,
+	Scala,
+	Swift,
+	CSharp,
+	Bash,
+	Protobuf,
+}
+
+// IsGrammar reports whether g is the name of a supported grammar.
+func IsGrammar(g LanguageGrammar) bool {
+	return slices.Contains(grammars, g)
+}
+
+// LookupPathLanguage returns the default grammar for the file extension of p.
+func LookupPathLanguage(p string) (LanguageGrammar, bool) {
+	return lookupPathLanguage(p)
+}
+
+// GrammarMapping maps file suffixes or glob patterns to grammars, overriding
+// the default extension based mapping.
+//
+// A mapping is immutable, Add returns a new mapping so parent configurations
+// can be shared with children. Methods may be invoked on a nil *GrammarMapping.
+type GrammarMapping struct {
+	entries []grammarEntry
+}
+
+type grammarEntry struct {
+	dir, pattern string
+	grammar      LanguageGrammar
+}
+
+// Add returns a new mapping with pattern mapped to grammar, taking precedence
+// over all existing entries.
+//
+// The pattern is either a file name suffix such as ".star" or ".mts.tmpl", a
+// file name such as "Jenkinsfile", or a glob pattern. Globs without a `/` match
+// the file base name, otherwise the path relative to dir.
+func (m *GrammarMapping) Add(dir, pattern string, grammar LanguageGrammar) (*GrammarMapping, error) {
+	if !IsGrammar(grammar) {
+		return nil, fmt.Errorf("unknown grammar %q", grammar)
+	}
+	if pattern == "" {
+		return nil, fmt.Errorf("empty grammar pattern")
+	}
+	if isGrammarGlob(pattern) && !doublestar.ValidatePattern(pattern) {
+		return nil, fmt.Errorf("invalid grammar glob pattern %q", pattern)
+	}
+
+	r := &GrammarMapping{}
+	if m != nil {
+		r.entries = slices.Clone(m.entries)
+	}
+	r.entries = append(r.entries, grammarEntry{dir: dir, pattern: pattern, grammar: grammar})
+	return r, nil
+}
+
+// Lookup returns the grammar mapped to the path p, the most recently added
+// matching entry taking precedence.
+func (m *GrammarMapping) Lookup(p string) (LanguageGrammar, bool) {
+	if m == nil {
+		return "", false
+	}
+
+	base := path.Base(p)
+	for _, e := range slices.Backward(m.entries) {
+		if e.matches(p, base) {
+			return e.grammar, true
+		}
+	}
+	return "", false
+}
+
+// String returns a stable representation of the mapping, such as for cache keys.
+func (m *GrammarMapping) String() string {
+	if m == nil {
+		return ""
+	}
+
+	var sb strings.Builder
+	for _, e := range m.entries {
+		fmt.Fprintf(&sb, "%s:%s=%s;", e.dir, e.pattern, e.grammar)
+	}
+	return sb.String()
+}
+
+func (e grammarEntry) matches(p, base string) bool {
+	if !isGrammarGlob(e.pattern) {
+		if strings.HasPrefix(e.pattern, ".") {
+			return strings.HasSuffix(base, e.pattern)
+		}
+		return base == e.pattern
+	}
+	if !strings.Contains(e.pattern, "/") {
+		return doublestar.MatchUnvalidated(e.pattern, base)
+	}
+	return doublestar.MatchUnvalidated(path.Join(e.dir, e.pattern), p)
+}
+
+func isGrammarGlob(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?[{/")
+}
@@ -0,0 +1,89 @@
+package treesitter
+
+import "testing"
+
+func TestGrammarMapping(t *testing.T) {
+	var m *GrammarMapping
+	if _, found := m.Lookup("a/b.star"); found {
+		t.Errorf("nil mapping must not match")
+	}
+
+	for _, add := range []struct {
+		dir, pattern string
+		grammar      LanguageGrammar
+	}{
+		{"", ".star", Starlark},
+		{"", "Jenkinsfile", Java},
+		{"", "*.mts.tmpl", Typescript},
+		{"web", "legacy/**/*.js", TypescriptX},
+		{"", ".js.tmpl", Bash},
+		{"", ".tmpl", JSON},
+	} {
+		var err error
+		m, err = m.Add(add.dir, add.pattern, add.grammar)
+		if err != nil {
+			t.Fatalf("Add(%q, %q): %v", add.pattern, add.grammar, err)
+		}
+	}
+
+	tests := []struct {
+		path      string
+		wantLang  LanguageGrammar
+		wantFound bool
+	}{
+		{"a/b.star", Starlark, true},
+		{"Jenkinsfile", Java, true},
+		{"a/Jenkinsfile", Java, true},
+		{"a/MyJenkinsfile", "", false},
+		{"web/legacy/a/b.js", TypescriptX, true},
+		{"legacy/a/b.js", "", false},
+		{"web/b.js", "", false},
+
+		// Later entries take precedence
+		{"a/b.mts.tmpl", JSON, true},
+		{"a/b.js.tmpl", JSON, true},
+
+		{"a/b.go", "", false},
+	}
+
+	for _, tc := range tests {
+		gotLang, gotFound := m.Lookup(tc.path)
+		if gotLang != tc.wantLang || gotFound != tc.wantFound {
+			t.Errorf("Lookup(%q) = (%q, %v), want (%q, %v)", tc.path, gotLang, gotFound, tc.wantLang, tc.wantFound)
+		}
+	}
+}
+
+func TestGrammarMappingImmutable(t *testing.T) {
+	parent, err := (*GrammarMapping)(nil).Add("", ".star", Starlark)
+	if err != nil {
+		t.Fatal(err)
+	}
+	child, err := parent.Add("", ".star", Python)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if g, _ := parent.Lookup("a.star"); g != Starlark {
+		t.Errorf("parent mapping modified by child, got %q", g)
+	}
+	if g, _ := child.Lookup("a.star"); g != Python {
+		t.Errorf("child mapping = %q, want %q", g, Python)
+	}
+	if parent.String() == child.String() {
+		t.Errorf("expected distinct mapping keys, got %q", parent.String())
+	}
+}
+
+func TestGrammarMappingInvalid(t *testing.T) {
+	var m *GrammarMapping
+	if _, err := m.Add("", ".css", "css"); err == nil {
+		t.Errorf("expected unknown grammar error")
+	}
+	if _, err := m.Add("", "", Go); err == nil {
+		t.Errorf("expected empty pattern error")
+	}
+	if _, err := m.Add("", "a/[b", Go); err == nil {
+		t.Errorf("expected invalid glob error")
+	}
+}
@@ -0,0 +1,363 @@
+package treesitter
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"sync"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// Retention of recently parsed trees keyed by path, allowing files that are
+// repeatedly edited such as in watch mode to be incrementally reparsed.
+//
+// When a retained file is parsed again the retained tree is edited with the
+// byte diff between the sources and reused by the tree-sitter parser. Query
+// results on the previous tree are reused unless the changed ranges of the new
+// tree intersect previously captured nodes or contain new matches.
+
+// DefaultRetainedTrees is the default number of trees retained in watch mode.
+const DefaultRetainedTrees = 256
+
+var retention = &treeRetention{
+	trees: make(map[retainedKey]*list.Element),
+}
+
+// SetRetainedTrees sets the maximum number of trees retained for incremental
+// reparsing. Values < 1 disable retention and release all retained trees.
+func SetRetainedTrees(n int) {
+	retention.setMax(n)
+}
+
+type treeRetention struct {
+	mu    sync.Mutex
+	max   int
+	trees map[retainedKey]*list.Element
+
+	// *retainedTree in order of use, most recent at the front
+	lru list.List
+}
+
+type retainedKey struct {
+	grammar LanguageGrammar
+	path    string
+}
+
+// A retained tree and the query results on that tree.
+type retainedTree struct {
+	key        retainedKey
+	sourceCode []byte
+	tree       *sitter.Tree
+
+	mu      sync.Mutex
+	results map[*sitterQuery]*retainedResults
+}
+
+type retainedResults struct {
+	matches []ASTQueryMatch
+
+	// The byte ranges of all captured nodes
+	captures []byteRange
+}
+
+type byteRange struct {
+	start, end uint32
+}
+
+func (r byteRange) intersects(o byteRange) bool {
+	return r.start < o.end && o.start < r.end
+}
+
+func (t *retainedTree) setResults(q *sitterQuery, r *retainedResults) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.results[q] = r
+}
+
+func (r *treeRetention) enabled() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.max > 0
+}
+
+func (r *treeRetention) setMax(n int) {
+	r.mu.Lock()
+	r.max = max(n, 0)
+	evicted := r.evict(nil)
+	r.mu.Unlock()
+
+	deleteTrees(evicted)
+}
+
+// take removes and returns the retained tree for the key, or nil if none.
+func (r *treeRetention) take(key retainedKey) *retainedTree {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e, found := r.trees[key]
+	if !found {
+		return nil
+	}
+	delete(r.trees, key)
+	return r.lru.Remove(e).(*retainedTree)
+}
+
+// put retains the tree, releasing the least recently used trees beyond the limit.
+func (r *treeRetention) put(t *retainedTree) {
+	r.mu.Lock()
+	evicted := r.add(t)
+	r.mu.Unlock()
+
+	// Released outside the lock, sending may block on a full delete channel.
+	deleteTrees(evicted)
+}
+
+// add retains the tree and returns the trees no longer retained.
+func (r *treeRetention) add(t *retainedTree) []*sitter.Tree {
+	if r.max == 0 {
+		return []*sitter.Tree{t.tree}
+	}
+
+	var evicted []*sitter.Tree
+
+	// A concurrent parse of the same file may have already retained a tree
+	if e, found := r.trees[t.key]; found {
+		evicted = append(evicted, r.lru.Remove(e).(*retainedTree).tree)
+	}
+
+	r.trees[t.key] = r.lru.PushFront(t)
+	return r.evict(evicted)
+}
+
+// evict removes the least recently used trees beyond the limit, appending them to evicted.
+func (r *treeRetention) evict(evicted []*sitter.Tree) []*sitter.Tree {
+	for r.lru.Len() > r.max {
+		t := r.lru.Remove(r.lru.Back()).(*retainedTree)
+		delete(r.trees, t.key)
+		evicted = append(evicted, t.tree)
+	}
+	return evicted
+}
+
+func deleteTrees(trees []*sitter.Tree) {
+	for _, t := range trees {
+		treeDeleteCh <- t
+	}
+}
+
+// A single edit transforming one source into another.
+type sourceEdit struct {
+	start, oldEnd, newEnd uint32
+}
+
+// diffSource computes the edit between the old and new source by trimming the
+// common prefix and suffix.
+func diffSource(oldSource, newSource []byte) sourceEdit {
+	prefix := 0
+	for prefix < len(oldSource) && prefix < len(newSource) && oldSource[prefix] == newSource[prefix] {
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(oldSource)-prefix && suffix < len(newSource)-prefix && oldSource[len(oldSource)-1-suffix] == newSource[len(newSource)-1-suffix] {
+		suffix++
+	}
+
+	return sourceEdit{
+		start:  uint32(prefix),
+		oldEnd: uint32(len(oldSource) - suffix),
+		newEnd: uint32(len(newSource) - suffix),
+	}
+}
+
+func (e sourceEdit) isEmpty() bool {
+	return e.start == e.oldEnd && e.start == e.newEnd
+}
+
+func (e sourceEdit) input(oldSource, newSource []byte) sitter.EditInput {
+	return sitter.EditInput{
+		StartIndex:  e.start,
+		OldEndIndex: e.oldEnd,
+		NewEndIndex: e.newEnd,
+		StartPoint:  byteToPoint(newSource, e.start),
+		OldEndPoint: byteToPoint(oldSource, e.oldEnd),
+		NewEndPoint: byteToPoint(newSource, e.newEnd),
+	}
+}
+
+// shift maps a byte range of the old source to the new source, returning false
+// if the range intersects the edit.
+func (e sourceEdit) shift(r byteRange) (byteRange, bool) {
+	if r.end < e.start || (r.end == e.start && r.start < r.end) {
+		return r, true
+	}
+	if r.start > e.oldEnd || (r.start == e.oldEnd && r.start < r.end) {
+		delta := int64(e.newEnd) - int64(e.oldEnd)
+		return byteRange{uint32(int64(r.start) + delta), uint32(int64(r.end) + delta)}, true
+	}
+	return r, false
+}
+
+func byteToPoint(source []byte, offset uint32) sitter.Point {
+	prefix := source[:offset]
+	row := bytes.Count(prefix, []byte{'\n'})
+	col := len(prefix) - (bytes.LastIndexByte(prefix, '\n') + 1)
+	return sitter.Point{Row: uint32(row), Column: uint32(col)}
+}
+
+// The state of an incrementally reparsed tree relative to the previous tree.
+type incrementalParse struct {
+	prev *retainedTree
+	edit sourceEdit
+
+	// Ranges of the new tree which may differ from the previous tree
+	changed []changedRange
+}
+
+type changedRange struct {
+	byteRange
+	startPoint, endPoint sitter.Point
+}
+
+// reparse incrementally parses the source code reusing the previously retained tree.
+func reparse(ctx context.Context, parser *sitter.Parser, prev *retainedTree, sourceCode []byte) (*sitter.Tree, *incrementalParse, error) {
+	edit := diffSource(prev.sourceCode, sourceCode)
+
+	// The retained tree is exclusively owned once taken from the retention.
+	oldTree := prev.tree
+	defer func() {
+		treeDeleteCh <- oldTree
+	}()
+
+	if !edit.isEmpty() {
+		oldTree.Edit(edit.input(prev.sourceCode, sourceCode))
+	}
+
+	tree, err := parser.ParseCtx(ctx, oldTree, sourceCode)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	inc := &incrementalParse{
+		prev: prev,
+		edit: edit,
+	}
+	if !edit.isEmpty() {
+		inc.changed = changedRanges(oldTree.RootNode(), tree.RootNode(), edit)
+	}
+	return tree, inc, nil
+}
+
+// changedRanges returns the ranges of the top-level nodes of the new tree not
+// present in the edited old tree, including the nodes adjacent to each change so
+// matches depending on siblings of a changed node are detected.
+func changedRanges(oldRoot, newRoot *sitter.Node, edit sourceEdit) []changedRange {
+	type nodeKey struct {
+		start, end uint32
+		kind       string
+	}
+
+	// Top-level nodes of the edited old tree unaffected by the edit
+	unchanged := make(map[nodeKey]struct{}, oldRoot.ChildCount())
+	for i := range int(oldRoot.ChildCount()) {
+		n := oldRoot.Child(i)
+		if !n.HasChanges() {
+			unchanged[nodeKey{n.StartByte(), n.EndByte(), n.Type()}] = struct{}{}
+		}
+	}
+
+	count := int(newRoot.ChildCount())
+	if count == 0 || oldRoot.Type() != newRoot.Type() {
+		return []changedRange{nodeRange(newRoot, newRoot)}
+	}
+
+	changed := make([]bool, count)
+	for i := range count {
+		n := newRoot.Child(i)
+		_, isUnchanged := unchanged[nodeKey{n.StartByte(), n.EndByte(), n.Type()}]
+
+		// Nodes at the edit, which may be zero-width such as a deletion
+		atEdit := n.StartByte() <= edit.newEnd && edit.start <= n.EndByte()
+
+		if !isUnchanged || atEdit {
+			changed[max(i-1, 0)] = true
+			changed[i] = true
+			changed[min(i+1, count-1)] = true
+		}
+	}
+
+	var ranges []changedRange
+	for i := 0; i < count; i++ {
+		if !changed[i] {
+			continue
+		}
+		j := i
+		for j+1 < count && changed[j+1] {
+			j++
+		}
+		ranges = append(ranges, nodeRange(newRoot.Child(i), newRoot.Child(j)))
+		i = j
+	}
+	return ranges
+}
+
+func nodeRange(first, last *sitter.Node) changedRange {
+	return changedRange{
+		byteRange:  byteRange{first.StartByte(), last.EndByte()},
+		startPoint: first.StartPoint(),
+		endPoint:   last.EndPoint(),
+	}
+}
+
+// reuse returns the results of the query on the previous tree if they are
+// unaffected by the changes.
+func (inc *incrementalParse) reuse(tree *treeAst, q *sitterQuery) (*retainedResults, bool) {
+	inc.prev.mu.Lock()
+	prev, found := inc.prev.results[q]
+	inc.prev.mu.Unlock()
+	if !found {
+		return nil, false
+	}
+
+	// Previously captured nodes within the changed ranges
+	shifted := make([]byteRange, 0, len(prev.captures))
+	for _, c := range prev.captures {
+		r, ok := inc.edit.shift(c)
+		if !ok {
+			return nil, false
+		}
+		for _, changed := range inc.changed {
+			if r.intersects(changed.byteRange) {
+				return nil, false
+			}
+		}
+		shifted = append(shifted, r)
+	}
+
+	// New matches within the changed ranges
+	for _, changed := range inc.changed {
+		if tree.hasMatchInRange(q, changed) {
+			return nil, false
+		}
+	}
+
+	return &retainedResults{matches: prev.matches, captures: shifted}, true
+}
+
+func (tree *treeAst) hasMatchInRange(q *sitterQuery, r changedRange) bool {
+	qc := sitter.NewQueryCursor()
+	defer qc.Close()
+	qc.SetPointRange(r.startPoint, r.endPoint)
+	qc.Exec(q.q, tree.sitterTree.RootNode())
+
+	for {
+		m, ok := qc.NextMatch()
+		if !ok {
+			return false
+		}
+		if matchesAllPredicates(q, m, qc, tree.sourceCode) {
+			return true
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package treesitter
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDiffSource(t *testing.T) {
+	tests := []struct {
+		old, new string
+		want     sourceEdit
+	}{
+		{"abc", "abc", sourceEdit{3, 3, 3}},
+		{"abc", "abXc", sourceEdit{2, 2, 3}},
+		{"abXc", "abc", sourceEdit{2, 3, 2}},
+		{"abc", "aXYc", sourceEdit{1, 2, 3}},
+		{"", "abc", sourceEdit{0, 0, 3}},
+		{"aaa", "aa", sourceEdit{2, 3, 2}},
+	}
+
+	for _, tc := range tests {
+		if got := diffSource([]byte(tc.old), []byte(tc.new)); got != tc.want {
+			t.Errorf("diffSource(%q, %q) = %v, want %v", tc.old, tc.new, got, tc.want)
+		}
+	}
+}
+
+func TestSourceEditShift(t *testing.T) {
+	// "abcdef" => "abXYZef"
+	edit := sourceEdit{start: 2, oldEnd: 4, newEnd: 5}
+
+	tests := []struct {
+		r      byteRange
+		want   byteRange
+		wantOk bool
+	}{
+		{byteRange{0, 2}, byteRange{0, 2}, true},
+		{byteRange{4, 6}, byteRange{5, 7}, true},
+		{byteRange{1, 3}, byteRange{1, 3}, false},
+		{byteRange{3, 5}, byteRange{3, 5}, false},
+		{byteRange{2, 4}, byteRange{2, 4}, false},
+	}
+
+	for _, tc := range tests {
+		got, ok := edit.shift(tc.r)
+		if got != tc.want || ok != tc.wantOk {
+			t.Errorf("shift(%v) = (%v, %v), want (%v, %v)", tc.r, got, ok, tc.want, tc.wantOk)
+		}
+	}
+}
+
+func TestByteToPoint(t *testing.T) {
+	src := []byte("ab\ncde\nf")
+	for offset, want := range map[uint32][2]uint32{
+		0: {0, 0},
+		2: {0, 2},
+		3: {1, 0},
+		5: {1, 2},
+		8: {2, 1},
+	} {
+		p := byteToPoint(src, offset)
+		if p.Row != want[0] || p.Column != want[1] {
+			t.Errorf("byteToPoint(%d) = %d:%d, want %d:%d", offset, p.Row, p.Column, want[0], want[1])
+		}
+	}
+}
+
+const importsQuery = `(import_spec path: (interpreted_string_literal) @path)`
+
+func queryImports(t *testing.T, src string) ([]string, *treeAst) {
+	t.Helper()
+
+	ast, err := ParseSourceCode(goGrammar, "pkg/a.go", []byte(src))
+	if err != nil {
+		t.Fatalf("ParseSourceCode: %v", err)
+	}
+	t.Cleanup(ast.Close)
+
+	q, err := GetQuery(goGrammar, importsQuery)
+	if err != nil {
+		t.Fatalf("GetQuery: %v", err)
+	}
+
+	var imports []string
+	for captures := range ast.Query(q) {
+		imports = append(imports, captures["path"])
+	}
+	return imports, ast.(*treeAst)
+}
+
+func TestIncrementalReparse(t *testing.T) {
+	SetRetainedTrees(DefaultRetainedTrees)
+	t.Cleanup(func() {
+		SetRetainedTrees(0)
+	})
+
+	const v1 = `package a
+
+import "fmt"
+
+func A() { fmt.Println("a") }
+
+func B() {}
+`
+
+	imports, ast := queryImports(t, v1)
+	if want := []string{`"fmt"`}; !slices.Equal(imports, want) {
+		t.Fatalf("imports = %v, want %v", imports, want)
+	}
+	if ast.incremental != nil {
+		t.Errorf("expected a full parse without a retained tree")
+	}
+
+	// Edit a function body, unrelated to the imports
+	v2 := v1 + "\nfunc C() { B() }\n"
+	imports, ast = queryImports(t, v2)
+	if want := []string{`"fmt"`}; !slices.Equal(imports, want) {
+		t.Fatalf("imports = %v, want %v", imports, want)
+	}
+	if ast.incremental == nil || len(ast.incremental.changed) == 0 {
+		t.Fatalf("expected an incremental reparse with changed ranges")
+	}
+	q, _ := GetQuery(goGrammar, importsQuery)
+	if _, reused := ast.incremental.reuse(ast, q.(*sitterQuery)); !reused {
+		t.Errorf("expected query results to be reused")
+	}
+
+	// Add an import, requiring the query to re-run
+	v3 := `package a
+
+import "fmt"
+import "os"
+
+func A() { fmt.Println("a") }
+
+func B() {}
+` + "\nfunc C() { B() }\n"
+	imports, ast = queryImports(t, v3)
+	if want := []string{`"fmt"`, `"os"`}; !slices.Equal(imports, want) {
+		t.Fatalf("imports = %v, want %v", imports, want)
+	}
+	if _, reused := ast.incremental.reuse(ast, q.(*sitterQuery)); reused {
+		t.Errorf("expected query results to not be reused")
+	}
+
+	// Identical content
+	imports, ast = queryImports(t, v3)
+	if want := []string{`"fmt"`, `"os"`}; !slices.Equal(imports, want) {
+		t.Fatalf("imports = %v, want %v", imports, want)
+	}
+	if ast.incremental == nil || len(ast.incremental.changed) != 0 {
+		t.Errorf("expected an incremental reparse without changes")
+	}
+}
+
+func TestRetainedTreesEviction(t *testing.T) {
+	SetRetainedTrees(1)
+	t.Cleanup(func() {
+		SetRetainedTrees(0)
+	})
+
+	for _, p := range []string{"a.go", "b.go"} {
+		ast, err := ParseSourceCode(goGrammar, p, []byte(goSource))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ast.Close()
+	}
+
+	if retention.take(retainedKey{Go, "a.go"}) != nil {
+		t.Errorf("expected a.go to be evicted")
+	}
+	if retention.take(retainedKey{Go, "b.go"}) == nil {
+		t.Errorf("expected b.go to be retained")
+	}
+}
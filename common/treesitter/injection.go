@@ -0,0 +1,138 @@
+package treesitter
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"regexp"
+	"slices"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// Embedded language (injection) support for files containing code of other
+// languages, such as <script> blocks of Vue SFCs or fenced code blocks in Markdown.
+//
+// Regions of the embedded language are located within the host file, then
+// parsed as a single document with ParseSourceRanges.
+
+// The conventional tree-sitter capture name of an embedded language region.
+const InjectionContentCapture = "injection.content"
+
+// A Range of bytes within source code.
+type Range struct {
+	StartByte, EndByte uint32
+}
+
+// CaptureRanges yields the byte range of the named capture of each match.
+func (tree *treeAst) CaptureRanges(query TreeQuery, capture string) iter.Seq[Range] {
+	return func(yield func(Range) bool) {
+		// TreeQuery is sealed; *sitterQuery is the only implementation.
+		q := query.(*sitterQuery)
+
+		qc := sitter.NewQueryCursor()
+		defer qc.Close()
+		qc.Exec(q.q, tree.sitterTree.RootNode())
+
+		for {
+			m, ok := qc.NextMatch()
+			if !ok {
+				return
+			}
+
+			if !matchesAllPredicates(q, m, qc, tree.sourceCode) {
+				continue
+			}
+
+			for _, c := range m.Captures {
+				if q.CaptureNameForId(c.Index) != capture {
+					continue
+				}
+				if !yield(Range{c.Node.StartByte(), c.Node.EndByte()}) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// RegexRanges returns the ranges of each regex match within the source code.
+//
+// The range of a match is the `content` named group, otherwise the first group
+// or the whole match if the regex has no groups.
+func RegexRanges(re *regexp.Regexp, sourceCode []byte) []Range {
+	group := 0
+	if i := re.SubexpIndex("content"); i > 0 {
+		group = i
+	} else if re.NumSubexp() > 0 {
+		group = 1
+	}
+
+	var ranges []Range
+	for _, m := range re.FindAllSubmatchIndex(sourceCode, -1) {
+		// Unmatched optional groups
+		if m[2*group] < 0 {
+			continue
+		}
+		ranges = append(ranges, Range{uint32(m[2*group]), uint32(m[2*group+1])})
+	}
+	return ranges
+}
+
+// ParseSourceRanges parses the ranges of the source code as a single document
+// of the language, such as all regions of an embedded language within a file.
+//
+// Node positions are relative to the start of the source code, not the ranges.
+func ParseSourceRanges(lang Language, filePath string, sourceCode []byte, ranges []Range) (AST, error) {
+	ranges = normalizeRanges(ranges, uint32(len(sourceCode)))
+	if len(ranges) == 0 {
+		return nil, fmt.Errorf("no source ranges to parse in %q", filePath)
+	}
+
+	included := make([]sitter.Range, 0, len(ranges))
+	for _, r := range ranges {
+		included = append(included, sitter.Range{
+			StartByte:  r.StartByte,
+			EndByte:    r.EndByte,
+			StartPoint: byteToPoint(sourceCode, r.StartByte),
+			EndPoint:   byteToPoint(sourceCode, r.EndByte),
+		})
+	}
+
+	parser := sitter.NewParser()
+	defer parser.Close()
+	parser.SetLanguage(lang.sitterLang())
+	parser.SetIncludedRanges(included)
+
+	tree, err := parser.ParseCtx(context.Background(), nil, sourceCode)
+	if err != nil {
+		return nil, err
+	}
+
+	return &treeAst{lang: lang, filePath: filePath, sourceCode: sourceCode, sitterTree: tree}, nil
+}
+
+// normalizeRanges sorts and merges overlapping ranges, dropping empty or out of
+// bounds ranges as required by tree-sitter included ranges.
+func normalizeRanges(ranges []Range, size uint32) []Range {
+	sorted := make([]Range, 0, len(ranges))
+	for _, r := range ranges {
+		r.EndByte = min(r.EndByte, size)
+		if r.StartByte < r.EndByte {
+			sorted = append(sorted, r)
+		}
+	}
+	slices.SortFunc(sorted, func(a, b Range) int {
+		return int(a.StartByte) - int(b.StartByte)
+	})
+
+	merged := sorted[:0]
+	for _, r := range sorted {
+		if n := len(merged); n > 0 && r.StartByte <= merged[n-1].EndByte {
+			merged[n-1].EndByte = max(merged[n-1].EndByte, r.EndByte)
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
@@ -0,0 +1,73 @@
+package treesitter
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const markdownSource = "# Title\n\n```go\npackage a\n\nfunc A() {}\n```\n\nText\n\n```go\nfunc B() {}\n```\n"
+
+func TestRegexRanges(t *testing.T) {
+	src := []byte(markdownSource)
+
+	for _, expr := range []string{"(?s)```go\n(.*?)```", "(?s)(```)go\n(?P<content>.*?)```"} {
+		ranges := RegexRanges(regexp.MustCompile(expr), src)
+
+		var regions []string
+		for _, r := range ranges {
+			regions = append(regions, string(src[r.StartByte:r.EndByte]))
+		}
+		if want := []string{"package a\n\nfunc A() {}\n", "func B() {}\n"}; !slices.Equal(regions, want) {
+			t.Errorf("RegexRanges(%q) = %q, want %q", expr, regions, want)
+		}
+	}
+}
+
+func TestNormalizeRanges(t *testing.T) {
+	got := normalizeRanges([]Range{{10, 20}, {0, 5}, {15, 30}, {3, 3}, {40, 100}}, 50)
+	if want := []Range{{0, 5}, {10, 30}, {40, 50}}; !slices.Equal(got, want) {
+		t.Errorf("normalizeRanges = %v, want %v", got, want)
+	}
+}
+
+// Embedded regions are parsed as a single document with absolute positions.
+func TestParseSourceRanges(t *testing.T) {
+	src := []byte(markdownSource)
+	ranges := RegexRanges(regexp.MustCompile("(?s)```go\n(.*?)```"), src)
+
+	ast, err := ParseSourceRanges(goGrammar, "README.md", src, ranges)
+	if err != nil {
+		t.Fatalf("ParseSourceRanges: %v", err)
+	}
+	t.Cleanup(ast.Close)
+
+	q, err := GetQuery(goGrammar, `(function_declaration name: (identifier) @name)`)
+	if err != nil {
+		t.Fatalf("GetQuery: %v", err)
+	}
+
+	var names []string
+	for captures := range ast.Query(q) {
+		names = append(names, captures["name"])
+	}
+	if want := []string{"A", "B"}; !slices.Equal(names, want) {
+		t.Errorf("captured names = %v, want %v", names, want)
+	}
+
+	var offsets []uint32
+	for r := range ast.CaptureRanges(q, "name") {
+		offsets = append(offsets, r.StartByte)
+	}
+	want := []uint32{uint32(strings.Index(markdownSource, "A()")), uint32(strings.Index(markdownSource, "B()"))}
+	if !slices.Equal(offsets, want) {
+		t.Errorf("capture offsets = %v, want %v", offsets, want)
+	}
+}
+
+func TestParseSourceRangesEmpty(t *testing.T) {
+	if _, err := ParseSourceRanges(goGrammar, "README.md", []byte("text"), nil); err == nil {
+		t.Errorf("expected an error without ranges")
+	}
+}
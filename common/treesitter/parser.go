@@ -24,7 +24,6 @@ import (
 	"strings"
 	"unsafe"
 
-	BazelLog "github.com/aspect-build/aspect-gazelle/common/logger"
 	sitter "github.com/smacker/go-tree-sitter"
 )
 
@@ -57,6 +56,13 @@ const (
 	Ruby        LanguageGrammar = "ruby"
 	HCL         LanguageGrammar = "hcl"
 	Python      LanguageGrammar = "python"
+	C           LanguageGrammar = "c"
+	Cpp         LanguageGrammar = "cpp"
+	Scala       LanguageGrammar = "scala"
+	Swift       LanguageGrammar = "swift"
+	CSharp      LanguageGrammar = "csharp"
+	Bash        LanguageGrammar = "bash"
+	Protobuf    LanguageGrammar = "protobuf"
 )
 
 // Language is an opaque grammar handle, sealed to this package so the
@@ -100,9 +106,28 @@ func (tree *treeLanguage) String() string {
 // pass it on without conversion.
 type ASTQueryResult = map[string]string
 
+// ASTQueryMatch is a single query match including the properties set by the
+// #set! directives of the matching pattern.
+type ASTQueryMatch struct {
+	Captures ASTQueryResult
+
+	// The #set! directive properties, nil if none. Shared by all matches of
+	// the pattern and must not be modified.
+	Metadata map[string]string
+}
+
 type AST interface {
 	// Query yields the captures of each match.
 	Query(query TreeQuery) iter.Seq[ASTQueryResult]
+
+	// QueryMatches yields each match with the #set! directive metadata.
+	QueryMatches(query TreeQuery) iter.Seq[ASTQueryMatch]
+
+	// CaptureRanges yields the byte range of the named capture of each match,
+	// such as the regions of an embedded language.
+	CaptureRanges(query TreeQuery, capture string) iter.Seq[Range]
+
+	// QueryErrors returns a *SyntaxError for each parse error.
 	QueryErrors() []error
 
 	// Release all resources related to this AST.
@@ -115,6 +140,12 @@ type treeAst struct {
 	sourceCode []byte
 
 	sitterTree *sitter.Tree
+
+	// The retained copy of this tree recording query results, if retention is enabled
+	retained *retainedTree
+
+	// The incremental parse producing this tree, if reparsed from a retained tree
+	incremental *incrementalParse
 }
 
 var _ AST = (*treeAst)(nil)
@@ -123,6 +154,8 @@ func (tree *treeAst) Close() {
 	t := tree.sitterTree
 	tree.sitterTree = nil
 	tree.sourceCode = nil
+	tree.retained = nil
+	tree.incremental = nil
 	if t != nil {
 		// Pass the tree to the background deletion channel and nil out the reference here
 		treeDeleteCh <- t
@@ -133,15 +166,6 @@ func (tree *treeAst) String() string {
 	return fmt.Sprintf("treeAst{\n lang: %q,\n filePath: %q,\n AST:\n  %v\n}", tree.lang.Grammar(), tree.filePath, tree.sitterTree.RootNode().String())
 }
 
-func PathToLanguage(p string) LanguageGrammar {
-	lang, found := lookupPathLanguage(p)
-	if !found {
-		// TODO: allow override or fallback language for files
-		BazelLog.Fatalf("Unknown source file extension %q", path.Ext(p))
-	}
-	return lang
-}
-
 // Based on https://github.com/github-linguist/linguist/blob/master/lib/linguist/languages.yml
 var extLanguages = map[string]LanguageGrammar{
 	"go": Go,
@@ -189,6 +213,41 @@ var extLanguages = map[string]LanguageGrammar{
 	"py":  Python,
 	"pyw": Python,
 	"pyi": Python,
+
+	"c": C,
+
+	// Linguist says `.h` is C, however the C++ grammar also parses C headers
+	// while the C grammar fails on C++ headers.
+	"h": Cpp,
+
+	"cc":  Cpp,
+	"cp":  Cpp,
+	"cpp": Cpp,
+	"cxx": Cpp,
+	"c++": Cpp,
+	"hh":  Cpp,
+	"hpp": Cpp,
+	"hxx": Cpp,
+	"h++": Cpp,
+	"inl": Cpp,
+	"ipp": Cpp,
+	"tcc": Cpp,
+	"tpp": Cpp,
+
+	"scala": Scala,
+	"sc":    Scala,
+	"sbt":   Scala,
+
+	"swift": Swift,
+
+	"cs":  CSharp,
+	"csx": CSharp,
+
+	"sh":   Bash,
+	"bash": Bash,
+	"bats": Bash,
+
+	"proto": Protobuf,
 }
 
 // In theory, this is a mirror of
@@ -206,10 +265,36 @@ func ParseSourceCode(lang Language, filePath string, sourceCode []byte) (AST, er
 	defer parser.Close()
 	parser.SetLanguage(lang.sitterLang())
 
-	tree, err := parser.ParseCtx(ctx, nil, sourceCode)
+	if filePath == "" || !retention.enabled() {
+		tree, err := parser.ParseCtx(ctx, nil, sourceCode)
+		if err != nil {
+			return nil, err
+		}
+
+		return &treeAst{lang: lang, filePath: filePath, sourceCode: sourceCode, sitterTree: tree}, nil
+	}
+
+	key := retainedKey{grammar: lang.Grammar(), path: filePath}
+
+	var tree *sitter.Tree
+	var inc *incrementalParse
+	var err error
+	if prev := retention.take(key); prev != nil {
+		tree, inc, err = reparse(ctx, parser, prev, sourceCode)
+	} else {
+		tree, err = parser.ParseCtx(ctx, nil, sourceCode)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	return &treeAst{lang: lang, filePath: filePath, sourceCode: sourceCode, sitterTree: tree}, nil
+	retained := &retainedTree{
+		key:        key,
+		sourceCode: sourceCode,
+		tree:       tree.Copy(),
+		results:    make(map[*sitterQuery]*retainedResults),
+	}
+	retention.put(retained)
+
+	return &treeAst{lang: lang, filePath: filePath, sourceCode: sourceCode, sitterTree: tree, retained: retained, incremental: inc}, nil
 }
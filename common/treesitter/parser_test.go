@@ -42,6 +42,14 @@ func TestLookupPathLanguage(t *testing.T) {
 		{"foo.go", Go, true},
 		{"a/b/c.tsx", TypescriptX, true},
 		{"foo.json", JSON, true},
+		{"a/b.c", C, true},
+		{"a/b.h", Cpp, true},
+		{"a/b.c++", Cpp, true},
+		{"A.scala", Scala, true},
+		{"A.swift", Swift, true},
+		{"A.cs", CSharp, true},
+		{"run.sh", Bash, true},
+		{"a/b.proto", Protobuf, true},
 
 		// Previously panicked: no extension at all.
 		{"Makefile", "", false},
@@ -66,13 +74,6 @@ func TestLookupPathLanguage(t *testing.T) {
 	}
 }
 
-// PathToLanguage returns the mapped grammar for a recognized extension.
-func TestPathToLanguage_known(t *testing.T) {
-	if got := PathToLanguage("a/b/main.go"); got != Go {
-		t.Errorf("PathToLanguage = %q, want %q", got, Go)
-	}
-}
-
 // NewLanguage round-trips the grammar it was constructed with.
 func TestNewLanguage_grammarRoundTrip(t *testing.T) {
 	if got := goGrammar.Grammar(); got != Go {
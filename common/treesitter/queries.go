@@ -38,9 +38,38 @@ func GetQuery(lang Language, queryStr string) (TreeQuery, error) {
 
 func (tree *treeAst) Query(query TreeQuery) iter.Seq[ASTQueryResult] {
 	return func(yield func(ASTQueryResult) bool) {
+		for m := range tree.QueryMatches(query) {
+			if !yield(m.Captures) {
+				return
+			}
+		}
+	}
+}
+
+func (tree *treeAst) QueryMatches(query TreeQuery) iter.Seq[ASTQueryMatch] {
+	return func(yield func(ASTQueryMatch) bool) {
 		// TreeQuery is sealed; *sitterQuery is the only implementation.
 		q := query.(*sitterQuery)
 
+		// Reuse the results of the previous tree if unaffected by the changes.
+		if tree.incremental != nil {
+			if prev, ok := tree.incremental.reuse(tree, q); ok {
+				tree.retained.setResults(q, prev)
+				for _, m := range prev.matches {
+					if !yield(m) {
+						return
+					}
+				}
+				return
+			}
+		}
+
+		// Record the results when retaining the tree for incremental reparsing.
+		var results *retainedResults
+		if tree.retained != nil {
+			results = &retainedResults{}
+		}
+
 		// Execute the query.
 		qc := sitter.NewQueryCursor()
 		defer qc.Close()
@@ -57,9 +86,25 @@ func (tree *treeAst) Query(query TreeQuery) iter.Seq[ASTQueryResult] {
 				continue
 			}
 
-			if !yield(tree.mapQueryMatchCaptures(m, q)) {
-				break
+			match := ASTQueryMatch{
+				Captures: tree.mapQueryMatchCaptures(m, q),
+				Metadata: q.MetadataForPattern(uint32(m.PatternIndex)),
+			}
+			if results != nil {
+				results.matches = append(results.matches, match)
+				for _, c := range m.Captures {
+					results.captures = append(results.captures, byteRange{c.Node.StartByte(), c.Node.EndByte()})
+				}
 			}
+
+			if !yield(match) {
+				// Incomplete results can not be reused
+				return
+			}
+		}
+
+		if results != nil {
+			tree.retained.setResults(q, results)
 		}
 	}
 }
@@ -113,8 +158,26 @@ func (tree *treeAst) QueryErrors() []error {
 	return errors
 }
 
-// queryErrorAt renders the error's source line with a caret at the error
-// position, from plain position data — no AST navigation.
+// A SyntaxError is a parse error at a position of the source code.
+type SyntaxError struct {
+	// The 1-based line and column of the error, the column as a byte offset
+	// within the line.
+	Line, Column int
+
+	// The source line containing the error.
+	SourceLine string
+}
+
+// Error renders the error's source line with a caret at the error position.
+func (e *SyntaxError) Error() string {
+	pre := fmt.Sprintf("     %d: ", e.Line)
+	arw := strings.Repeat(" ", len(pre)+e.Column-1) + "^"
+
+	return fmt.Sprintf("%s%s\n%s", pre, e.SourceLine, arw)
+}
+
+// queryErrorAt creates the SyntaxError at the error position from plain
+// position data — no AST navigation.
 func queryErrorAt(source []byte, row, col, startByte uint32) error {
 	// col is the byte offset within the line.
 	lineStart := int(startByte - col)
@@ -123,8 +186,9 @@ func queryErrorAt(source []byte, row, col, startByte uint32) error {
 		lineEnd = int(startByte) + i
 	}
 
-	pre := fmt.Sprintf("     %d: ", row+1)
-	arw := strings.Repeat(" ", len(pre)+int(col)) + "^"
-
-	return fmt.Errorf("%s%s\n%s", pre, source[lineStart:lineEnd], arw)
+	return &SyntaxError{
+		Line:       int(row) + 1,
+		Column:     int(col) + 1,
+		SourceLine: string(source[lineStart:lineEnd]),
+	}
 }
@@ -19,6 +19,9 @@ type sitterQuery struct {
 	// Pre-parsed match?/not-match? predicate expressions, indexed by the
 	// predicate string value id. Nil for string values that are not matchers.
 	matchers []common.BytesMatcher
+
+	// Properties set by #set! directives, indexed by pattern.
+	metadata []map[string]string
 }
 
 var _ TreeQuery = (*sitterQuery)(nil)
@@ -41,33 +44,47 @@ func newSitterQuery(lang *sitter.Language, query string) (*sitterQuery, error) {
 		stringValues[i] = q.StringValueForId(i)
 	}
 
-	predicatePatterns := make([][][]sitter.QueryPredicateStep, q.PatternCount())
-	matchers := make([]common.BytesMatcher, q.StringCount())
+	sq := &sitterQuery{
+		q:                 q,
+		stringValues:      stringValues,
+		captureNames:      captureNames,
+		predicatePatterns: make([][][]sitter.QueryPredicateStep, q.PatternCount()),
+		matchers:          make([]common.BytesMatcher, q.StringCount()),
+		metadata:          make([]map[string]string, q.PatternCount()),
+	}
+
 	for i := uint32(0); i < q.PatternCount(); i++ {
-		predicatePatterns[i] = q.PredicatesForPattern(i)
+		sq.predicatePatterns[i] = q.PredicatesForPattern(i)
+
+		// Strip the terminating Done step so the steps are the operator and its arguments
+		for p, steps := range sq.predicatePatterns[i] {
+			if n := len(steps); n > 0 && steps[n-1].Type == sitter.QueryPredicateStepTypeDone {
+				sq.predicatePatterns[i][p] = steps[:n-1]
+			}
+		}
+
+		// Validate predicates and parse match? predicate expressions so an
+		// invalid predicate fails here instead of when the query is run.
+		for _, steps := range sq.predicatePatterns[i] {
+			if err := validatePredicate(sq, steps); err != nil {
+				return nil, err
+			}
 
-		// Parse match? predicate expressions so an invalid expression fails
-		// here instead of when the query is run against a match.
-		for _, steps := range predicatePatterns[i] {
 			switch stringValues[steps[0].ValueId] {
-			case "match?", "not-match?":
+			case "match?", "not-match?", "any-match?", "any-not-match?":
 				exprId := steps[2].ValueId
 				m, err := common.ParseMatcher(stringValues[exprId])
 				if err != nil {
 					return nil, fmt.Errorf("invalid %s predicate expression %q: %w", stringValues[steps[0].ValueId], stringValues[exprId], err)
 				}
-				matchers[exprId] = m
+				sq.matchers[exprId] = m
 			}
 		}
+
+		sq.metadata[i] = patternMetadata(sq, sq.predicatePatterns[i])
 	}
 
-	return &sitterQuery{
-		q:                 q,
-		stringValues:      stringValues,
-		captureNames:      captureNames,
-		predicatePatterns: predicatePatterns,
-		matchers:          matchers,
-	}, nil
+	return sq, nil
 }
 
 // Cached query data accessors mirroring the tree-sitter Query signatures.
@@ -88,3 +105,8 @@ func (q *sitterQuery) PredicatesForPattern(patternIndex uint32) [][]sitter.Query
 func (q *sitterQuery) MatcherForId(id uint32) common.BytesMatcher {
 	return q.matchers[id]
 }
+
+// The properties set by the #set! directives of a pattern, nil if none.
+func (q *sitterQuery) MetadataForPattern(patternIndex uint32) map[string]string {
+	return q.metadata[patternIndex]
+}
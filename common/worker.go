@@ -1,52 +1,30 @@
 package common
 
-import (
-	"math"
-	"sync"
-)
-
 const (
 	// MaxWorkerCount is the maximum number of parallel workers
+	//
+	// Deprecated: parallelism is determined by the SharedPool, see SetJobs.
 	MaxWorkerCount = 12
 )
 
-// Parallelize an action over a set of string values.
+// Parallelize an action over a set of string values using the SharedPool.
 // Returns a channel that emits results as they are produced.
 func Parallelize[T any](values []string, process func(string) T) chan T {
-	// The number of workers. Don't create more workers than necessary.
-	workerCount := int(math.Min(MaxWorkerCount, float64(1+len(values)/2)))
-
-	// The channel of inputs
-	valuesCh := make(chan string, workerCount)
+	// The channel of outputs, large enough to never block workers.
+	resultsCh := make(chan T, len(values))
 
-	// The channel of outputs.
-	resultsCh := make(chan T, workerCount)
-
-	// Start the worker goroutines.
-	var wg sync.WaitGroup
-	for range workerCount {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-
-			for value := range valuesCh {
-				resultsCh <- process(value)
-			}
-		}()
-	}
-
-	// Send values to the workers.
+	// Submit values to the shared pool, possibly blocked by back-pressure.
 	go func() {
+		g := SharedPool().NewGroup(PriorityHigh)
 		for _, value := range values {
-			valuesCh <- value
+			g.Go(func() error {
+				resultsCh <- process(value)
+				return nil
+			})
 		}
 
-		close(valuesCh)
-	}()
-
-	// Wait for all workers to finish.
-	go func() {
-		wg.Wait()
+		// Wait for all workers to finish.
+		g.Wait()
 		close(resultsCh)
 	}()
 
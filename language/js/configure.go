@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	common "github.com/aspect-build/aspect-gazelle/common"
+	"github.com/aspect-build/aspect-gazelle/common/bazel"
 	BazelLog "github.com/aspect-build/aspect-gazelle/common/logger"
 	"github.com/bazelbuild/bazel-gazelle/config"
 	"github.com/bazelbuild/bazel-gazelle/label"
@@ -29,6 +30,11 @@ func (ts *typeScriptLang) RegisterFlags(fs *flag.FlagSet, cmd string, c *config.
 // This is called once with the root configuration when Gazelle starts.
 // CheckFlags may set default values in flags or make implied changes.
 func (ts *typeScriptLang) CheckFlags(fs *flag.FlagSet, c *config.Config) error {
+	moduleFile, err := bazel.LoadModuleFile(c.RepoRoot)
+	if err != nil {
+		return err
+	}
+	ts.moduleFile = moduleFile
 	return nil
 }
 
@@ -78,7 +84,8 @@ func (ts *typeScriptLang) KnownDirectives() []string {
 // f is the build file for the current directory or nil if there is no
 // existing build file.
 func (ts *typeScriptLang) Configure(c *config.Config, rel string, f *rule.File) {
-	BazelLog.Tracef("Configure(%s): %s", LanguageName, rel)
+	log := configureLog.With(BazelLog.Fields{Package: rel})
+	log.Tracef("Configure(%s): %s", LanguageName, rel)
 
 	// Create the root config.
 	if cfg, exists := c.Exts[LanguageName]; !exists {
@@ -88,19 +95,19 @@ func (ts *typeScriptLang) Configure(c *config.Config, rel string, f *rule.File)
 	}
 
 	if f != nil {
-		ts.readDirectives(c, rel, f)
+		ts.readDirectives(log, c, rel, f)
 	}
 
-	ts.readConfigurations(c, rel)
+	ts.readConfigurations(log, c, rel)
 }
 
-func (ts *typeScriptLang) readConfigurations(c *config.Config, rel string) {
+func (ts *typeScriptLang) readConfigurations(log *BazelLog.Logger, c *config.Config, rel string) {
 	config := c.Exts[LanguageName].(*JsGazelleConfig)
 
 	// pnpm
 	if rel == config.pnpmLockRel {
 		if common.WalkHasPath(config.pnpmLockRel, config.pnpmLockPath) {
-			ts.addPnpmLockfile(c, config, path.Join(config.pnpmLockRel, config.pnpmLockPath))
+			ts.addPnpmLockfile(log, c, config, path.Join(config.pnpmLockRel, config.pnpmLockPath))
 		}
 	}
 
@@ -117,7 +124,7 @@ func (ts *typeScriptLang) readConfigurations(c *config.Config, rel string) {
 	}
 }
 
-func (ts *typeScriptLang) readDirectives(c *config.Config, rel string, f *rule.File) {
+func (ts *typeScriptLang) readDirectives(log *BazelLog.Logger, c *config.Config, rel string, f *rule.File) {
 	config := c.Exts[LanguageName].(*JsGazelleConfig)
 
 	for _, d := range f.Directives {
@@ -144,7 +151,7 @@ func (ts *typeScriptLang) readDirectives(c *config.Config, rel string, f *rule.F
 			case string(ProtoModeAspect):
 				config.SetProtoMode(ProtoModeAspect)
 			default:
-				BazelLog.Fatalf("Invalid %s value: %q (expected enabled, disabled, or aspect)", Directive_TypeScriptProtoExtension, d.Value)
+				log.Fatalf("Invalid %s value: %q (expected enabled, disabled, or aspect)", Directive_TypeScriptProtoExtension, d.Value)
 			}
 		case Directive_NpmPackageExtension:
 			if strings.TrimSpace(d.Value) == string(NpmPackageReferencedMode) {
@@ -173,7 +180,7 @@ func (ts *typeScriptLang) readDirectives(c *config.Config, rel string, f *rule.F
 
 			err := config.SetVisibility(group, visLabels)
 			if err != nil {
-				common.MisconfiguredErrorf(c, "Invalid %s: %v", Directive_Visibility, err)
+				common.ReportDiagnostic(c, common.NewDiagnostic(DiagInvalidDirective, "Invalid %s: %v", Directive_Visibility, err).WithDirective(f, d.Key))
 				return
 			}
 
@@ -205,15 +212,15 @@ func (ts *typeScriptLang) readDirectives(c *config.Config, rel string, f *rule.F
 		case Directive_Resolve:
 			globTarget := strings.Split(value, " ")
 			if len(globTarget) != 2 {
-				common.MisconfiguredErrorf(c, "invalid value for directive %q: %s: value must be filename/glob + label",
-					Directive_Resolve, d.Value)
+				common.ReportDiagnostic(c, common.NewDiagnostic(DiagInvalidDirective, "invalid value for directive %q: %s: value must be filename/glob + label",
+					Directive_Resolve, d.Value).WithDirective(f, d.Key))
 				return
 			}
 
 			label, labelErr := label.Parse(strings.TrimSpace(globTarget[1]))
 			if labelErr != nil {
-				common.MisconfiguredErrorf(c, "invalid label for directive %q: %s - %v",
-					Directive_Resolve, label, labelErr)
+				common.ReportDiagnostic(c, common.NewDiagnostic(DiagInvalidDirective, "invalid label for directive %q: %s - %v",
+					Directive_Resolve, label, labelErr).WithDirective(f, d.Key))
 				return
 			}
 
@@ -227,7 +234,7 @@ func (ts *typeScriptLang) readDirectives(c *config.Config, rel string, f *rule.F
 			case "off":
 				config.SetValidateImportStatements(ValidationOff)
 			default:
-				common.MisconfiguredErrorf(c, "invalid value for directive %q: %s", Directive_ValidateImportStatements, d.Value)
+				common.ReportDiagnostic(c, common.NewDiagnostic(DiagInvalidDirective, "invalid value for directive %q: %s", Directive_ValidateImportStatements, d.Value).WithDirective(f, d.Key))
 				return
 			}
 		case Directive_ProtoNamingConvention:
@@ -245,33 +252,33 @@ func (ts *typeScriptLang) readDirectives(c *config.Config, rel string, f *rule.F
 			case NpmPackageKind:
 				config.packageTargetKind = NpmPackageKind
 			default:
-				common.MisconfiguredErrorf(c, "invalid value for directive %q: %s", Directive_PackageRuleKind, d.Value)
+				common.ReportDiagnostic(c, common.NewDiagnostic(DiagInvalidDirective, "invalid value for directive %q: %s", Directive_PackageRuleKind, d.Value).WithDirective(f, d.Key))
 				return
 			}
 		case Directive_LibraryFiles:
 			group, groupGlob := config.parseGroupGlob(value, DefaultLibraryName)
 
 			if err := config.addTargetGlob(group, groupGlob, false); err != nil {
-				common.MisconfiguredErrorf(c, "directive %q: %s", Directive_LibraryFiles, err)
+				common.ReportDiagnostic(c, common.NewDiagnostic(DiagInvalidDirective, "directive %q: %s", Directive_LibraryFiles, err).WithDirective(f, d.Key))
 				return
 			}
 		case Directive_TestFiles:
 			group, groupGlob := config.parseGroupGlob(value, DefaultTestsName)
 
 			if err := config.addTargetGlob(group, groupGlob, true); err != nil {
-				common.MisconfiguredErrorf(c, "directive %q: %s", Directive_TestFiles, err)
+				common.ReportDiagnostic(c, common.NewDiagnostic(DiagInvalidDirective, "directive %q: %s", Directive_TestFiles, err).WithDirective(f, d.Key))
 				return
 			}
 		case Directive_AssetFiles:
 			group, groupGlob := config.parseGroupGlob(value, DefaultLibraryName)
 
 			if err := config.addTargetAssetGlob(group, groupGlob); err != nil {
-				common.MisconfiguredErrorf(c, "directive %q: %s", Directive_AssetFiles, err)
+				common.ReportDiagnostic(c, common.NewDiagnostic(DiagInvalidDirective, "directive %q: %s", Directive_AssetFiles, err).WithDirective(f, d.Key))
 				return
 			}
 		case Directive_Assets:
 			if value == "" {
-				common.MisconfiguredErrorf(c, "invalid value for directive %q: %s", Directive_Assets, d.Value)
+				common.ReportDiagnostic(c, common.NewDiagnostic(DiagInvalidDirective, "invalid value for directive %q: %s", Directive_Assets, d.Value).WithDirective(f, d.Key))
 				return
 			}
 
@@ -291,7 +298,7 @@ func (ts *typeScriptLang) readDirectives(c *config.Config, rel string, f *rule.F
 					case string(ImportKindURL):
 						assetKinds = append(assetKinds, ImportKindURL)
 					default:
-						common.MisconfiguredErrorf(c, "invalid value for directive %q: %s", Directive_Assets, d.Value)
+						common.ReportDiagnostic(c, common.NewDiagnostic(DiagInvalidDirective, "invalid value for directive %q: %s", Directive_Assets, d.Value).WithDirective(f, d.Key))
 						return
 					}
 				}
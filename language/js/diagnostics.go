@@ -0,0 +1,13 @@
+package gazelle
+
+import "github.com/aspect-build/aspect-gazelle/common"
+
+// Diagnostic codes reported by the JS/TS language.
+var (
+	DiagUnresolvedImport   = common.DiagnosticCode{ID: "JS001", Name: "unresolved-import"}
+	DiagInvalidDirective   = common.DiagnosticCode{ID: "JS002", Name: "invalid-directive"}
+	DiagInvalidPackageJson = common.DiagnosticCode{ID: "JS003", Name: "invalid-package-json"}
+	DiagInvalidLockfile    = common.DiagnosticCode{ID: "JS004", Name: "invalid-lockfile"}
+	DiagSourceGeneration   = common.DiagnosticCode{ID: "JS005", Name: "source-generation"}
+	DiagProtoImports       = common.DiagnosticCode{ID: "JS006", Name: "proto-imports"}
+)
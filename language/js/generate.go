@@ -40,7 +40,6 @@ import (
 	"github.com/bazelbuild/bazel-gazelle/language"
 	"github.com/bazelbuild/bazel-gazelle/resolve"
 	"github.com/bazelbuild/bazel-gazelle/rule"
-	bzl "github.com/bazelbuild/buildtools/build"
 	"github.com/emirpasic/gods/v2/sets/treeset"
 )
 
@@ -87,26 +86,28 @@ func (ts *typeScriptLang) GenerateRules(args language.GenerateArgs) language.Gen
 
 	cfg := args.Config.Exts[LanguageName].(*JsGazelleConfig)
 
+	log := generateLog.With(BazelLog.Fields{Package: args.Rel})
+
 	// Collect any labels that could be imported
-	ts.collectFileLabels(args)
+	ts.collectFileLabels(log, args)
 
 	// When we return empty, we mean that we don't generate anything, but this
 	// still triggers the indexing for all the TypeScript targets in this package.
 	if !cfg.GenerationEnabled() {
-		BazelLog.Tracef("GenerateRules(%s) disabled: %s", LanguageName, args.Rel)
+		log.Tracef("GenerateRules(%s) disabled: %s", LanguageName, args.Rel)
 		return language.GenerateResult{}
 	}
 
-	BazelLog.Tracef("GenerateRules(%s): %s", LanguageName, args.Rel)
+	log.Tracef("GenerateRules(%s): %s", LanguageName, args.Rel)
 
 	var result language.GenerateResult
 
-	ts.addPackageRules(cfg, args, &result)
-	ts.addSourceRules(cfg, args, &result)
-	ts.addTsConfigRules(cfg, args, &result)
+	ts.addPackageRules(log, cfg, args, &result)
+	ts.addSourceRules(log, cfg, args, &result)
+	ts.addTsConfigRules(log, cfg, args, &result)
 
 	if cfg.ProtoTsTargetGenerationEnabled() {
-		ts.addTsProtoRules(cfg, args, &result)
+		ts.addTsProtoRules(log, cfg, args, &result)
 	}
 
 	return result
@@ -211,7 +212,7 @@ func newTargetGlobClassifier(cfg *JsGazelleConfig, groupTsconfigs map[string]gro
 	}
 }
 
-func (ts *typeScriptLang) addSourceRules(cfg *JsGazelleConfig, args language.GenerateArgs, result *language.GenerateResult) {
+func (ts *typeScriptLang) addSourceRules(log *BazelLog.Logger, cfg *JsGazelleConfig, args language.GenerateArgs, result *language.GenerateResult) {
 	targets := cfg.GetSourceTargets()
 
 	// Resolve the tsconfig for each target for quick-access in per-file loops below.
@@ -245,8 +246,8 @@ func (ts *typeScriptLang) addSourceRules(cfg *JsGazelleConfig, args language.Gen
 		if isSourceFileExt(fileExt) || isDataFileExt(fileExt) {
 			if target := classifyFile(file); target != nil {
 				// Source files belonging to a target group.
-				if BazelLog.IsTraceEnabled() {
-					BazelLog.Tracef("add '%s' src '%s/%s'", target.name, args.Rel, file)
+				if log.IsTraceEnabled() {
+					log.Tracef("add '%s' src '%s/%s'", target.name, args.Rel, file)
 				}
 
 				g, hasGroup := groups[target.name]
@@ -262,8 +263,8 @@ func (ts *typeScriptLang) addSourceRules(cfg *JsGazelleConfig, args language.Gen
 		// Files not collected as a source may be collected as an asset
 		// of a target group via custom asset globs.
 		if target := classifyAsset(file); target != nil {
-			if BazelLog.IsTraceEnabled() {
-				BazelLog.Tracef("add '%s' asset '%s/%s'", target.name, args.Rel, file)
+			if log.IsTraceEnabled() {
+				log.Tracef("add '%s' asset '%s/%s'", target.name, args.Rel, file)
 			}
 
 			g, hasGroup := assetFileGroups[target.name]
@@ -277,8 +278,8 @@ func (ts *typeScriptLang) addSourceRules(cfg *JsGazelleConfig, args language.Gen
 
 		// Not collected by any target group, but may still be considered "data"
 		// of other source-importing targets such as npm package targets.
-		if BazelLog.IsTraceEnabled() {
-			BazelLog.Tracef("add data file '%s/%s'", args.Rel, file)
+		if log.IsTraceEnabled() {
+			log.Tracef("add data file '%s/%s'", args.Rel, file)
 		}
 		dataFiles = append(dataFiles, file)
 	}
@@ -317,13 +318,13 @@ func (ts *typeScriptLang) addSourceRules(cfg *JsGazelleConfig, args language.Gen
 
 		existing := ruleUtils.GetFileRuleByName(args, ruleName)
 		existingIsManaged := existing != nil && sourceRuleKinds.Contains(existing.Kind())
-		pinned := existingIsManaged && srcsArePinned(existing)
+		pinned := existingIsManaged && ruleUtils.IsAttrPinned(existing, "srcs")
 
 		// If the existing rule's srcs are pinned, parse and use that list as-is.
 		if pinned {
 			customSrcs, err := ruleUtils.ExpandSrcs(args.RegularFiles, existing.Attr("srcs"))
 			if err != nil {
-				BazelLog.Infof("Failed to expand custom srcs %s:%s - %v", args.Rel, existing.Name(), err)
+				log.Infof("Failed to expand custom srcs %s:%s - %v", args.Rel, existing.Name(), err)
 			}
 
 			for _, s := range customSrcs {
@@ -338,7 +339,7 @@ func (ts *typeScriptLang) addSourceRules(cfg *JsGazelleConfig, args language.Gen
 			// Include any `# keep`'d entries from a list-form srcs so their imports
 			// and `declare module` statements are still parsed/indexed (issue #156).
 			if existingIsManaged {
-				for kept := range keptSrcs(existing.Attr("srcs")) {
+				for kept := range ruleUtils.KeptListValues(existing.Attr("srcs")) {
 					if _, ok := srcSet[kept]; ok {
 						continue
 					}
@@ -369,6 +370,7 @@ func (ts *typeScriptLang) addSourceRules(cfg *JsGazelleConfig, args language.Gen
 			gt := groupTsconfigs[group.name]
 			// Add or edit/merge a rule for this source group.
 			srcRule, srcGenErr := ts.addProjectRule(
+				log,
 				cfg,
 				gt.rel,
 				gt.config,
@@ -382,7 +384,7 @@ func (ts *typeScriptLang) addSourceRules(cfg *JsGazelleConfig, args language.Gen
 				result,
 			)
 			if srcGenErr != nil {
-				common.GenerationErrorf(args.Config, "Source rule generation error: %v", srcGenErr)
+				common.ReportDiagnostic(args.Config, common.NewDiagnostic(DiagSourceGeneration, "Source rule generation error: %v", srcGenErr).WithBuildFile(args.File))
 				return
 			}
 
@@ -404,11 +406,11 @@ func (ts *typeScriptLang) addSourceRules(cfg *JsGazelleConfig, args language.Gen
 			}
 		}
 
-		ts.addPackageRule(cfg, args, packageName, dataFiles, srcLabel, result)
+		ts.addPackageRule(log, cfg, args, packageName, dataFiles, srcLabel, result)
 	}
 }
 
-func (ts *typeScriptLang) addPackageRule(cfg *JsGazelleConfig, args language.GenerateArgs, packageName string, dataFiles []string, srcLabel *label.Label, result *language.GenerateResult) {
+func (ts *typeScriptLang) addPackageRule(log *BazelLog.Logger, cfg *JsGazelleConfig, args language.GenerateArgs, packageName string, dataFiles []string, srcLabel *label.Label, result *language.GenerateResult) {
 	npmPackageInfo := newTsPackageInfo(srcLabel)
 
 	packageJsonPath := path.Join(args.Rel, NpmPackageFilename)
@@ -417,18 +419,18 @@ func (ts *typeScriptLang) addPackageRule(cfg *JsGazelleConfig, args language.Gen
 	var packageJsonEntries []string
 	var packageJsonFiles []string
 	if packageJson, err := ts.getPackageJson(args.Config, args.Rel); err != nil {
-		common.MisconfiguredErrorf(args.Config, "Failed to parse %q: %v", packageJsonPath, err)
+		common.ReportDiagnostic(args.Config, common.NewDiagnostic(DiagInvalidPackageJson, "Failed to parse %q: %v", packageJsonPath, err).WithSource(packageJsonPath, 0, 0))
 		return
 	} else if packageJson != nil {
 		packageJsonEntries = packageJson.Entries
 		packageJsonFiles = packageJson.Files
 	} else if slices.Contains(args.GenFiles, NpmPackageFilename) {
-		BazelLog.Debugf("Generated package.json for %q", args.Rel)
+		log.Debugf("Generated package.json for %q", args.Rel)
 	} else {
 		// A pnpm project with no walkable package.json: either deleted but still
 		// referenced by the lockfile, or excluded from the walk. Entries such as
 		// main/exports/types are not parsed in this case.
-		BazelLog.Warnf("No package.json found for pnpm project %q", args.Rel)
+		log.Warnf("No package.json found for pnpm project %q", args.Rel)
 	}
 
 	for _, impt := range packageJsonEntries {
@@ -440,7 +442,7 @@ func (ts *typeScriptLang) addPackageRule(cfg *JsGazelleConfig, args language.Gen
 			npmPackageInfo.sources.Add(impt)
 		} else {
 			if strings.Contains(impt, "*") {
-				BazelLog.Debugf("Wildcard import %q in %q not supported", impt, packageJsonPath)
+				log.Debugf("Wildcard import %q in %q not supported", impt, packageJsonPath)
 				continue
 			}
 
@@ -458,7 +460,7 @@ func (ts *typeScriptLang) addPackageRule(cfg *JsGazelleConfig, args language.Gen
 		}
 	}
 
-	ts.addPackageJsonFiles(cfg, args, packageJsonPath, packageJsonFiles, dataFiles, npmPackageInfo)
+	ts.addPackageJsonFiles(log, cfg, args, packageJsonPath, packageJsonFiles, dataFiles, npmPackageInfo)
 
 	// Add the package.json if not in the src
 	// BUG: if it was removed from 'dataFiles' and put in a target that the package does not depend on (such as a test ts_project())
@@ -485,7 +487,7 @@ func (ts *typeScriptLang) addPackageRule(cfg *JsGazelleConfig, args language.Gen
 	result.Imports = append(result.Imports, npmPackageInfo)
 	result.RelsToIndex = append(result.RelsToIndex, ts.tsPackageInfoToRelsToIndex(cfg, args, &npmPackageInfo.TsProjectInfo)...)
 
-	BazelLog.Infof("add rule '%s' '%s:%s'", cfg.packageTargetKind, args.Rel, packageTargetName)
+	log.Infof("add rule '%s' '%s:%s'", cfg.packageTargetKind, args.Rel, packageTargetName)
 }
 
 // addPackageJsonFiles ensures files published via the package.json 'files'
@@ -493,7 +495,7 @@ func (ts *typeScriptLang) addPackageRule(cfg *JsGazelleConfig, args language.Gen
 // as sources; exact paths that are not local data files (generated files,
 // files owned by targets in subdirectories) and matching generated files are
 // resolved the same way as package.json entries such as 'main' and 'exports'.
-func (ts *typeScriptLang) addPackageJsonFiles(cfg *JsGazelleConfig, args language.GenerateArgs, packageJsonPath string, patterns, dataFiles []string, npmPackageInfo *TsPackageInfo) {
+func (ts *typeScriptLang) addPackageJsonFiles(log *BazelLog.Logger, cfg *JsGazelleConfig, args language.GenerateArgs, packageJsonPath string, patterns, dataFiles []string, npmPackageInfo *TsPackageInfo) {
 	if len(patterns) == 0 {
 		return
 	}
@@ -551,7 +553,7 @@ func (ts *typeScriptLang) addPackageJsonFiles(cfg *JsGazelleConfig, args languag
 
 	filesMatch, err := common.ParseGlobExpressionsWithExcludes(includes, excludes)
 	if err != nil {
-		BazelLog.Warnf("Invalid %q files patterns: %v", packageJsonPath, err)
+		log.Warnf("Invalid %q files patterns: %v", packageJsonPath, err)
 		return
 	}
 
@@ -641,7 +643,7 @@ func (ts *typeScriptLang) collectTsConfigPackageJsonDeps(args language.GenerateA
 	return deps, hasLocal
 }
 
-func (ts *typeScriptLang) addTsConfigRules(cfg *JsGazelleConfig, args language.GenerateArgs, result *language.GenerateResult) {
+func (ts *typeScriptLang) addTsConfigRules(log *BazelLog.Logger, cfg *JsGazelleConfig, args language.GenerateArgs, result *language.GenerateResult) {
 	var packageJsonDeps []*label.Label
 	var hasLocalPackageJson bool
 	if cfg.GetTsConfigPackageDepsEnabled() {
@@ -682,7 +684,7 @@ func (ts *typeScriptLang) addTsConfigRules(cfg *JsGazelleConfig, args language.G
 		info.staticDeps = append(info.staticDeps, packageJsonDeps...)
 
 		if hasLocalTsconfigFile {
-			for _, impt := range ts.collectTsConfigImports(cfg, args, tsconfig) {
+			for _, impt := range ts.collectTsConfigImports(log, cfg, args, tsconfig) {
 				info.AddImport(impt)
 			}
 			src := path.Join(tsconfig.ConfigDir, tsconfig.ConfigName)
@@ -704,7 +706,7 @@ func (ts *typeScriptLang) addTsConfigRules(cfg *JsGazelleConfig, args language.G
 	}
 }
 
-func (ts *typeScriptLang) collectTsConfigImports(cfg *JsGazelleConfig, args language.GenerateArgs, tsconfig *typescript.TsConfig) []ImportStatement {
+func (ts *typeScriptLang) collectTsConfigImports(log *BazelLog.Logger, cfg *JsGazelleConfig, args language.GenerateArgs, tsconfig *typescript.TsConfig) []ImportStatement {
 	var imports []ImportStatement
 
 	SourcePath := path.Join(tsconfig.ConfigDir, tsconfig.ConfigName)
@@ -742,7 +744,7 @@ func (ts *typeScriptLang) collectTsConfigImports(cfg *JsGazelleConfig, args lang
 			// Process the discovered "types" imports to find any of their
 			// depednencies which should also be included
 			if isLocalRef {
-				parsed := ts.collectImports(cfg, cache.Get(args.Config), args.Config.RepoRoot, imp)
+				parsed := ts.collectImports(log, cfg, cache.Get(args.Config), args.Config.RepoRoot, imp)
 				imports = append(imports, parsed.Imports...)
 			}
 		}
@@ -770,13 +772,13 @@ func (ts *typeScriptLang) collectTsConfigImports(cfg *JsGazelleConfig, args lang
 	return imports
 }
 
-func (ts *typeScriptLang) addTsProtoRules(cfg *JsGazelleConfig, args language.GenerateArgs, result *language.GenerateResult) {
+func (ts *typeScriptLang) addTsProtoRules(log *BazelLog.Logger, cfg *JsGazelleConfig, args language.GenerateArgs, result *language.GenerateResult) {
 	protoLibraries, emptyLibraries := proto.GetProtoLibraries(args, result)
 
 	// Generate one ts_proto_library() per proto_library()
 	for _, protoLibrary := range protoLibraries {
 		ruleName := cfg.RenderTsProtoLibraryName(protoLibrary.Name())
-		ts.addTsProtoRule(cfg, args, protoLibrary, ruleName, result)
+		ts.addTsProtoRule(log, cfg, args, protoLibrary, ruleName, result)
 	}
 
 	// Remove any ts_proto_library() targets associated with now-empty proto_library() targets
@@ -786,7 +788,7 @@ func (ts *typeScriptLang) addTsProtoRules(cfg *JsGazelleConfig, args language.Ge
 	}
 }
 
-func (ts *typeScriptLang) addTsProtoRule(cfg *JsGazelleConfig, args language.GenerateArgs, protoLibrary *rule.Rule, ruleName string, result *language.GenerateResult) {
+func (ts *typeScriptLang) addTsProtoRule(log *BazelLog.Logger, cfg *JsGazelleConfig, args language.GenerateArgs, protoLibrary *rule.Rule, ruleName string, result *language.GenerateResult) {
 	protoRuleLabel := label.New("", args.Rel, protoLibrary.Name())
 	protoRuleLabelStr := protoRuleLabel.Rel("", args.Rel)
 
@@ -805,9 +807,9 @@ func (ts *typeScriptLang) addTsProtoRule(cfg *JsGazelleConfig, args language.Gen
 	// Persist the proto_library(srcs)
 	tsProtoLibrary.SetAttr("proto_srcs", sourceFiles)
 
-	protoImports, err := ts.collectProtoImports(cfg, args, sourceFiles)
+	protoImports, err := ts.collectProtoImports(log, cfg, args, sourceFiles)
 	if err != nil {
-		common.GenerationErrorf(args.Config, "Proto import collection error: %v", err)
+		common.ReportDiagnostic(args.Config, common.NewDiagnostic(DiagProtoImports, "Proto import collection error: %v", err).WithBuildFile(args.File))
 		return
 	}
 
@@ -826,7 +828,7 @@ func (ts *typeScriptLang) addTsProtoRule(cfg *JsGazelleConfig, args language.Gen
 	result.Imports = append(result.Imports, imports)
 	result.RelsToIndex = append(result.RelsToIndex, relsToIndex...)
 
-	BazelLog.Infof("add rule '%s' '%s:%s'", tsProtoLibrary.Kind(), args.Rel, tsProtoLibrary.Name())
+	log.Infof("add rule '%s' '%s:%s'", tsProtoLibrary.Kind(), args.Rel, tsProtoLibrary.Name())
 }
 
 func hasTranspiledSources(sourceFiles *treeset.Set[string]) bool {
@@ -846,7 +848,7 @@ func setToSlice(s map[string]struct{}) []string {
 	return out
 }
 
-func (ts *typeScriptLang) addProjectRule(cfg *JsGazelleConfig, tsconfigRel string, tsconfig *typescript.TsConfig, args language.GenerateArgs, group *TargetGroup, targetName string, sourceFiles, genFiles, assetFiles, dataFiles []string, result *language.GenerateResult) (*rule.Rule, error) {
+func (ts *typeScriptLang) addProjectRule(log *BazelLog.Logger, cfg *JsGazelleConfig, tsconfigRel string, tsconfig *typescript.TsConfig, args language.GenerateArgs, group *TargetGroup, targetName string, sourceFiles, genFiles, assetFiles, dataFiles []string, result *language.GenerateResult) (*rule.Rule, error) {
 	// Check for name-collisions with the rule being generated.
 	colError := ruleUtils.CheckCollisionErrors(targetName, TsProjectKind, sourceRuleKinds, args)
 	if colError != nil {
@@ -883,7 +885,7 @@ func (ts *typeScriptLang) addProjectRule(cfg *JsGazelleConfig, tsconfigRel strin
 	// Collect syntax errors and report them after the (parallel, unordered) parse
 	// completes, sorted by file, so the output is deterministic.
 	var syntaxErrors []string
-	for result := range ts.parseFiles(cfg, args, sourceFiles) {
+	for result := range ts.parseFiles(log, cfg, args, sourceFiles) {
 		if result.Error != nil {
 			return nil, result.Error
 		}
@@ -1185,7 +1187,7 @@ func (ts *typeScriptLang) addProjectRule(cfg *JsGazelleConfig, tsconfigRel strin
 	result.Imports = append(result.Imports, info)
 	result.RelsToIndex = append(result.RelsToIndex, ts.tsPackageInfoToRelsToIndex(cfg, args, info)...)
 
-	BazelLog.Infof("add rule '%s' '%s:%s'", sourceRule.Kind(), args.Rel, sourceRule.Name())
+	log.Infof("add rule '%s' '%s:%s'", sourceRule.Kind(), args.Rel, sourceRule.Name())
 
 	return sourceRule, nil
 }
@@ -1202,7 +1204,7 @@ type parseResult struct {
 	SyntaxErrors []string
 }
 
-func (ts *typeScriptLang) collectProtoImports(cfg *JsGazelleConfig, args language.GenerateArgs, sourceFiles []string) ([]ImportStatement, error) {
+func (ts *typeScriptLang) collectProtoImports(log *BazelLog.Logger, cfg *JsGazelleConfig, args language.GenerateArgs, sourceFiles []string) ([]ImportStatement, error) {
 	var results []ImportStatement
 
 	for _, sourceFile := range sourceFiles {
@@ -1213,15 +1215,15 @@ func (ts *typeScriptLang) collectProtoImports(cfg *JsGazelleConfig, args languag
 
 		for _, imp := range imports {
 			if proto.IsRulesTsProtoBuiltin(imp) {
-				if BazelLog.IsTraceEnabled() {
-					BazelLog.Tracef("Proto import builtin: %q", imp)
+				if log.IsTraceEnabled() {
+					log.Tracef("Proto import builtin: %q", imp)
 				}
 				continue
 			}
 
 			if cfg.IsImportIgnored(imp) {
-				if BazelLog.IsTraceEnabled() {
-					BazelLog.Tracef("Proto import ignored: %q", imp)
+				if log.IsTraceEnabled() {
+					log.Tracef("Proto import ignored: %q", imp)
 				}
 				continue
 			}
@@ -1244,7 +1246,7 @@ func (ts *typeScriptLang) collectProtoImports(cfg *JsGazelleConfig, args languag
 	return results, nil
 }
 
-func (ts *typeScriptLang) parseFiles(cfg *JsGazelleConfig, args language.GenerateArgs, sourceFiles []string) chan parseResult {
+func (ts *typeScriptLang) parseFiles(log *BazelLog.Logger, cfg *JsGazelleConfig, args language.GenerateArgs, sourceFiles []string) chan parseResult {
 	parserCache := cache.Get(args.Config)
 	rel := args.Rel
 	repoRoot := args.Config.RepoRoot
@@ -1258,12 +1260,12 @@ func (ts *typeScriptLang) parseFiles(cfg *JsGazelleConfig, args language.Generat
 	}
 
 	return common.Parallelize(parsableFiles, func(sourcePath string) parseResult {
-		return ts.collectImports(cfg, parserCache, repoRoot, joinPkg(rel, sourcePath))
+		return ts.collectImports(log, cfg, parserCache, repoRoot, joinPkg(rel, sourcePath))
 	})
 }
 
-func (ts *typeScriptLang) collectImports(cfg *JsGazelleConfig, parserCache cache.Cache, rootDir, sourcePath string) parseResult {
-	parseResults, err := parseSourceFile(parserCache, rootDir, sourcePath)
+func (ts *typeScriptLang) collectImports(log *BazelLog.Logger, cfg *JsGazelleConfig, parserCache cache.Cache, rootDir, sourcePath string) parseResult {
+	parseResults, err := parseSourceFile(log, parserCache, rootDir, sourcePath)
 
 	result := parseResult{
 		SourcePath:   sourcePath,
@@ -1281,8 +1283,8 @@ func (ts *typeScriptLang) collectImports(cfg *JsGazelleConfig, parserCache cache
 			}
 
 			if cfg.IsImportIgnored(importPath) {
-				if BazelLog.IsTraceEnabled() {
-					BazelLog.Tracef("%q (%s) import of %q ignored", sourcePath, LanguageName, importPath)
+				if log.IsTraceEnabled() {
+					log.Tracef("%q (%s) import of %q ignored", sourcePath, LanguageName, importPath)
 				}
 				continue
 			}
@@ -1300,8 +1302,8 @@ func (ts *typeScriptLang) collectImports(cfg *JsGazelleConfig, parserCache cache
 				Kind:       kind,
 			})
 
-			if BazelLog.IsTraceEnabled() {
-				BazelLog.Tracef("%q (%s) imports %q (via %q)", sourcePath, LanguageName, workspacePath, importPath)
+			if log.IsTraceEnabled() {
+				log.Tracef("%q (%s) imports %q (via %q)", sourcePath, LanguageName, workspacePath, importPath)
 			}
 		}
 	}
@@ -1328,8 +1330,8 @@ func (ts *typeScriptLang) collectImports(cfg *JsGazelleConfig, parserCache cache
 }
 
 // Parse the passed file for import statements.
-func parseSourceFile(parserCache cache.Cache, rootDir, filePath string) (parser.ParseResult, error) {
-	BazelLog.Tracef("ParseImports(%s): %s", LanguageName, filePath)
+func parseSourceFile(log *BazelLog.Logger, parserCache cache.Cache, rootDir, filePath string) (parser.ParseResult, error) {
+	log.Tracef("ParseImports(%s): %s", LanguageName, filePath)
 
 	var p parser.ParseResult
 	r, _, err := parserCache.LoadOrStoreFile(rootDir, filePath, "js.ParseSource", func(filePath string, content []byte) (any, error) {
@@ -1365,13 +1367,13 @@ func reportSyntaxErrors(syntaxErrors []string) {
 	}
 }
 
-func (ts *typeScriptLang) addFileLabel(importPath string, label *label.Label) {
+func (ts *typeScriptLang) addFileLabel(log *BazelLog.Logger, importPath string, label *label.Label) {
 	existing := ts.fileLabels[importPath]
 
 	if existing != nil && isDeclarationFileType(existing.Name) {
 		// Can not have two imports (such as .js and .d.ts) from different labels
 		if isDeclarationFileType(label.Name) && !existing.Equal(*label) {
-			BazelLog.Fatalf("Duplicate file label %q from %v and %v", importPath, existing, label)
+			log.Fatalf("Duplicate file label %q from %v and %v", importPath, existing, label)
 		}
 
 		// Prefer the non-declaration file
@@ -1492,7 +1494,7 @@ func toImportPaths(p string) iter.Seq[string] {
 }
 
 // Collect and persist all possible references to files that can be imported
-func (ts *typeScriptLang) collectFileLabels(args language.GenerateArgs) {
+func (ts *typeScriptLang) collectFileLabels(log *BazelLog.Logger, args language.GenerateArgs) {
 	// Generated files from rules such as genrule()
 	for _, f := range args.GenFiles {
 		// Label referencing that generated file
@@ -1503,7 +1505,7 @@ func (ts *typeScriptLang) collectFileLabels(args language.GenerateArgs) {
 		}
 
 		for importPath := range toImportPaths(joinPkg(args.Rel, f)) {
-			ts.addFileLabel(importPath, &genLabel)
+			ts.addFileLabel(log, importPath, &genLabel)
 		}
 	}
 
@@ -1514,7 +1516,7 @@ func (ts *typeScriptLang) collectFileLabels(args language.GenerateArgs) {
 			Pkg:  args.Rel,
 		}
 		for importPath := range toImportPaths(joinPkg(args.Rel, f)) {
-			ts.addFileLabel(importPath, &fileLabel)
+			ts.addFileLabel(log, importPath, &fileLabel)
 		}
 	}
 
@@ -1522,35 +1524,35 @@ func (ts *typeScriptLang) collectFileLabels(args language.GenerateArgs) {
 }
 
 // Add rules representing packages, node_modules etc
-func (ts *typeScriptLang) addPackageRules(cfg *JsGazelleConfig, args language.GenerateArgs, result *language.GenerateResult) {
+func (ts *typeScriptLang) addPackageRules(log *BazelLog.Logger, cfg *JsGazelleConfig, args language.GenerateArgs, result *language.GenerateResult) {
 	if ts.pnpmProjects.IsProject(args.Rel) {
-		addLinkAllPackagesRule(cfg, args, ts.pnpmProjects.GetProject(args.Rel), result)
+		addLinkAllPackagesRule(log, cfg, args, ts.pnpmProjects.GetProject(args.Rel), result)
 	}
 }
 
 // Add pnpm rules for a pnpm lockfile.
 // Collect pnpm projects and project dependencies from the lockfile.
-func (ts *typeScriptLang) addPnpmLockfile(c *config.Config, cfg *JsGazelleConfig, lockfileRel string) {
-	BazelLog.Infof("pnpm add %q", lockfileRel)
+func (ts *typeScriptLang) addPnpmLockfile(log *BazelLog.Logger, c *config.Config, cfg *JsGazelleConfig, lockfileRel string) {
+	log.Infof("pnpm add %q", lockfileRel)
 
 	parsedCache := cache.Get(c)
 	parsedLockfile, _, readErr := parsedCache.LoadOrStoreFile(c.RepoRoot, lockfileRel, "pnpm.ParsePnpmLockFile", func(filePath string, content []byte) (any, error) {
 		return pnpm.ParsePnpmLockFileDependencies(content)
 	})
 	if readErr != nil {
-		common.MisconfiguredErrorf(c, "failed to read lockfile %q: %v", lockfileRel, readErr)
+		common.ReportDiagnostic(c, common.NewDiagnostic(DiagInvalidLockfile, "failed to read lockfile %q: %v", lockfileRel, readErr).WithSource(lockfileRel, 0, 0))
 		return
 	}
 
 	pnpmWorkspace := ts.pnpmProjects.NewWorkspace(lockfileRel)
 
 	for project, packages := range parsedLockfile.(pnpm.WorkspacePackageVersionMap) {
-		BazelLog.Debugf("pnpm add %q: project %q ", lockfileRel, project)
+		log.Debugf("pnpm add %q: project %q ", lockfileRel, project)
 
 		pnpmProject := pnpmWorkspace.AddProject(project)
 
 		for pkg, version := range packages {
-			BazelLog.Tracef("pnpm add %q: project %q: package: %q", lockfileRel, project, pkg)
+			log.Tracef("pnpm add %q: project %q: package: %q", lockfileRel, project, pkg)
 
 			pnpmProject.AddPackage(pkg, version, &label.Label{
 				Repo:     c.RepoName,
@@ -1562,7 +1564,7 @@ func (ts *typeScriptLang) addPnpmLockfile(c *config.Config, cfg *JsGazelleConfig
 	}
 }
 
-func addLinkAllPackagesRule(cfg *JsGazelleConfig, args language.GenerateArgs, pnpmProject *pnpm.PnpmProject, result *language.GenerateResult) {
+func addLinkAllPackagesRule(log *BazelLog.Logger, cfg *JsGazelleConfig, args language.GenerateArgs, pnpmProject *pnpm.PnpmProject, result *language.GenerateResult) {
 	npmLinkAll := rule.NewRule(NpmLinkAllKind, cfg.npmLinkAllTargetName)
 
 	result.Gen = append(result.Gen, npmLinkAll)
@@ -1576,60 +1578,7 @@ func addLinkAllPackagesRule(cfg *JsGazelleConfig, args language.GenerateArgs, pn
 		result.RelsToIndex = append(result.RelsToIndex, rel)
 	}
 
-	BazelLog.Infof("add rule '%s' '%s:%s'", npmLinkAll.Kind(), args.Rel, npmLinkAll.Name())
-}
-
-// srcsArePinned reports whether the srcs attribute is user-pinned and gazelle will not overwrite it.
-func srcsArePinned(r *rule.Rule) bool {
-	srcs := r.Attr("srcs")
-	if srcs == nil {
-		return false
-	}
-	if _, ok := srcs.(*bzl.ListExpr); !ok {
-		return true
-	}
-	// Only honor AssignExpr-level `# keep`; RHS-placed keeps aren't round-tripped by gazelle.
-	return commentsHaveKeep(r.AttrComments("srcs"))
-}
-
-// commentsHaveKeep reports whether any `# keep` comment is present in c.
-func commentsHaveKeep(c *bzl.Comments) bool {
-	if c == nil {
-		return false
-	}
-	return hasKeepToken(c.Before) || hasKeepToken(c.Suffix)
-}
-
-func hasKeepToken(cs []bzl.Comment) bool {
-	for _, cm := range cs {
-		text := strings.TrimSpace(strings.TrimPrefix(cm.Token, "#"))
-		if text == "keep" || strings.HasPrefix(text, "keep: ") {
-			return true
-		}
-	}
-	return false
-}
-
-// keptSrcs yields string values in a srcs ListExpr that carry a `# keep` comment.
-func keptSrcs(expr bzl.Expr) iter.Seq[string] {
-	return func(yield func(string) bool) {
-		list, ok := expr.(*bzl.ListExpr)
-		if !ok {
-			return
-		}
-		for _, e := range list.List {
-			if !rule.ShouldKeep(e) {
-				continue
-			}
-			str, ok := e.(*bzl.StringExpr)
-			if !ok {
-				continue
-			}
-			if !yield(str.Value) {
-				return
-			}
-		}
-	}
+	log.Infof("add rule '%s' '%s:%s'", npmLinkAll.Kind(), args.Rel, npmLinkAll.Name())
 }
 
 // If the file is ts-compatible transpiled source code that may contain imports
@@ -1721,7 +1670,7 @@ func dtsExt(e string) (string, bool) {
 func toJsExt(e string) string {
 	js, known := jsExt(e)
 	if !known {
-		BazelLog.Errorf("Unknown extension %q, assuming it compiles to %q", e, js)
+		generateLog.Errorf("Unknown extension %q, assuming it compiles to %q", e, js)
 	}
 	return js
 }
@@ -1729,7 +1678,7 @@ func toJsExt(e string) string {
 func toDtsExt(e string) string {
 	dts, known := dtsExt(e)
 	if !known {
-		BazelLog.Errorf("Unknown extension %q, assuming it declares %q", e, dts)
+		generateLog.Errorf("Unknown extension %q, assuming it declares %q", e, dts)
 	}
 	return dts
 }
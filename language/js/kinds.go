@@ -160,6 +160,12 @@ func (h *typeScriptLang) ApparentLoads(moduleToApparentName func(string) string)
 		jsModName = RulesJsRepositoryName
 	}
 
+	// The repository created by npm_translate_lock()
+	npmRepoName := h.moduleFile.ExtensionRepo("npm", "npm_translate_lock")
+	if npmRepoName == "" {
+		npmRepoName = NpmRepositoryName
+	}
+
 	return []rule.LoadInfo{
 		{
 			Name: "@" + tsModName + "//ts:defs.bzl",
@@ -191,7 +197,7 @@ func (h *typeScriptLang) ApparentLoads(moduleToApparentName func(string) string)
 		},
 
 		{
-			Name: "@" + NpmRepositoryName + "//:defs.bzl",
+			Name: "@" + npmRepoName + "//:defs.bzl",
 			Symbols: []string{
 				NpmLinkAllKind,
 			},
@@ -1,6 +1,8 @@
 package gazelle
 
 import (
+	"github.com/aspect-build/aspect-gazelle/common/bazel"
+	BazelLog "github.com/aspect-build/aspect-gazelle/common/logger"
 	node "github.com/aspect-build/aspect-gazelle/language/js/node"
 	pnpm "github.com/aspect-build/aspect-gazelle/language/js/pnpm"
 	"github.com/aspect-build/aspect-gazelle/language/js/typescript"
@@ -10,6 +12,14 @@ import (
 
 const LanguageName = "js"
 
+// Loggers of each gazelle phase with the language context, see ASPECT_LOG_LEVEL.
+var (
+	configureLog = BazelLog.With(BazelLog.Fields{Language: LanguageName, Phase: "configure"})
+	generateLog  = BazelLog.With(BazelLog.Fields{Language: LanguageName, Phase: "generate"})
+	indexLog     = BazelLog.With(BazelLog.Fields{Language: LanguageName, Phase: "index"})
+	resolveLog   = BazelLog.With(BazelLog.Fields{Language: LanguageName, Phase: "resolve"})
+)
+
 var _ language.Language = (*typeScriptLang)(nil)
 
 // The Gazelle extension for TypeScript rules.
@@ -40,6 +50,9 @@ type typeScriptLang struct {
 
 	// TypeScript configuration across the workspace
 	tsconfig *typescript.TsWorkspace
+
+	// The root MODULE.bazel repository mapping, nil if the workspace has no MODULE.bazel
+	moduleFile *bazel.ModuleFile
 }
 
 var _ language.Language = (*typeScriptLang)(nil)
@@ -10,6 +10,9 @@ import (
 	"github.com/goexlib/jsonc"
 )
 
+// package.json files are parsed when generating BUILD files.
+var packageJsonLog = BazelLog.With(BazelLog.Fields{Language: "js", Phase: "generate"})
+
 type npmPackageJSON struct {
 	// name: https://nodejs.org/docs/latest-v22.x/api/packages.html#name
 	Name string `json:"name"`
@@ -116,7 +119,7 @@ func indexSubpaths(raw map[string][]string, field string) (map[string][]string,
 			}
 			exact[key] = targets
 		} else if strings.Contains(suffix, "*") {
-			BazelLog.Warnf("Invalid package.json %s key %q: multiple '*'s", field, key)
+			packageJsonLog.Warnf("Invalid package.json %s key %q: multiple '*'s", field, key)
 		} else {
 			patterns = append(patterns, SubpathPattern{Prefix: prefix, Suffix: suffix, Targets: targets})
 		}
@@ -188,7 +191,7 @@ func ParsePackageJson(packageJsonReader io.Reader) (PackageJson, error) {
 		// equivalent to the same pattern without the leading slash.
 		pattern = path.Clean(strings.TrimLeft(pattern, "/"))
 		if pattern == "." || pattern == ".." || strings.HasPrefix(pattern, "../") {
-			BazelLog.Warnf("Invalid package.json files pattern %q", f)
+			packageJsonLog.Warnf("Invalid package.json files pattern %q", f)
 			continue
 		}
 		if negated {
@@ -242,11 +245,11 @@ func ParsePackageJson(packageJsonReader io.Reader) (PackageJson, error) {
 						case string:
 							addExport(subpath, subE)
 						default:
-							BazelLog.Warnf("Unknown package.json exports.%s.%s type: %T", exportKey, subEKey, subE)
+							packageJsonLog.Warnf("Unknown package.json exports.%s.%s type: %T", exportKey, subEKey, subE)
 						}
 					}
 				default:
-					BazelLog.Warnf("Unknown package.json exports.%s type: %T", exportKey, export)
+					packageJsonLog.Warnf("Unknown package.json exports.%s type: %T", exportKey, export)
 				}
 			}
 		case []any:
@@ -256,11 +259,11 @@ func ParsePackageJson(packageJsonReader io.Reader) (PackageJson, error) {
 				case string:
 					addExport(".", subE)
 				default:
-					BazelLog.Warnf("Unknown package.json exports[%v] type: %T", i, subE)
+					packageJsonLog.Warnf("Unknown package.json exports[%v] type: %T", i, subE)
 				}
 			}
 		default:
-			BazelLog.Warnf("Unknown package.json exports type: %T", exports)
+			packageJsonLog.Warnf("Unknown package.json exports type: %T", exports)
 		}
 
 		// Index the raw mappings for resolution: exact subpaths split from
@@ -298,11 +301,11 @@ func ParsePackageJson(packageJsonReader io.Reader) (PackageJson, error) {
 						case string:
 							addImport(importKey, subI)
 						default:
-							BazelLog.Warnf("Unknown package.json imports.%s.%s type: %T", importKey, subIKey, subI)
+							packageJsonLog.Warnf("Unknown package.json imports.%s.%s type: %T", importKey, subIKey, subI)
 						}
 					}
 				default:
-					BazelLog.Warnf("Unknown package.json imports.%s type: %T", importKey, imprt)
+					packageJsonLog.Warnf("Unknown package.json imports.%s type: %T", importKey, imprt)
 				}
 			}
 
@@ -310,7 +313,7 @@ func ParsePackageJson(packageJsonReader io.Reader) (PackageJson, error) {
 			// from '*' patterns, targets sorted and patterns ordered by priority.
 			pkg.Imports, pkg.ImportPatterns = indexSubpaths(rawImports, "imports")
 		} else {
-			BazelLog.Warnf("Unknown package.json imports type: %T", c.Imports)
+			packageJsonLog.Warnf("Unknown package.json imports type: %T", c.Imports)
 		}
 	}
 
@@ -65,7 +65,7 @@ func (pm *PnpmProjectMap) NewWorkspace(lockfile string) *PnpmWorkspace {
 
 func (pm *PnpmProjectMap) addProject(project *PnpmProject) {
 	if existing := pm.projects[project.project]; existing != nil {
-		BazelLog.Fatalf("Project '%s' (workspace: '%s') already exists from '%s'", project.project, project.workspace.lockfile, existing.workspace.lockfile)
+		BazelLog.With(BazelLog.Fields{Language: "js", Phase: "generate"}).Fatalf("Project '%s' (workspace: '%s') already exists from '%s'", project.project, project.workspace.lockfile, existing.workspace.lockfile)
 	}
 
 	pm.projects[project.project] = project
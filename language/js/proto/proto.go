@@ -113,7 +113,7 @@ func unquoteProtoString(q []byte) string {
 
 	s, err := strconv.Unquote(string(q))
 	if err != nil {
-		BazelLog.Fatalf("unquoting string literal %s from proto: %v", q, err)
+		BazelLog.With(BazelLog.Fields{Language: "js", Phase: "generate"}).Fatalf("unquoting string literal %s from proto: %v", q, err)
 	}
 	return s
 }
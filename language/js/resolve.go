@@ -2,7 +2,6 @@ package gazelle
 
 import (
 	"fmt"
-	"os"
 	"path"
 	"strings"
 
@@ -46,7 +45,7 @@ func (*typeScriptLang) Name() string { return LanguageName }
 // Determine what rule (r) outputs which can be imported.
 // For TypeScript this is all the import-paths pointing to files within the rule.
 func (ts *typeScriptLang) Imports(c *config.Config, r *rule.Rule, f *rule.File) []resolve.ImportSpec {
-	BazelLog.Tracef("Imports(%s): //%s:%s", LanguageName, f.Pkg, r.Name())
+	indexLog.Tracef("Imports(%s): //%s:%s", LanguageName, f.Pkg, r.Name())
 
 	switch r.Kind() {
 	case TsProtoLibraryKind:
@@ -67,23 +66,23 @@ func (ts *typeScriptLang) sourceFileImports(c *config.Config, r *rule.Rule, f *r
 
 	info, _ := r.PrivateAttr("ts_project_info").(*TsProjectInfo)
 	if info != nil && info.sources != nil {
-		BazelLog.Debugf("Imports(%s): //%s:%s (generated %s)", LanguageName, f.Pkg, r.Name(), r.Kind())
+		indexLog.Debugf("Imports(%s): //%s:%s (generated %s)", LanguageName, f.Pkg, r.Name(), r.Kind())
 
 		srcs = make([]string, 0, info.sources.Size())
 		for it := info.sources.Iterator(); it.Next(); {
 			srcs = append(srcs, it.Value())
 		}
 	} else {
-		BazelLog.Debugf("Imports(%s): //%s:%s (non-generated %s)", LanguageName, f.Pkg, r.Name(), r.Kind())
+		indexLog.Debugf("Imports(%s): //%s:%s (non-generated %s)", LanguageName, f.Pkg, r.Name(), r.Kind())
 
 		sourceFiles, err := common.GetSourceRegularFiles(f.Pkg)
 		if err != nil {
-			BazelLog.Errorf("Failed to fetch source files %s:%s - %v", f.Pkg, r.Name(), err)
+			indexLog.Errorf("Failed to fetch source files %s:%s - %v", f.Pkg, r.Name(), err)
 		}
 
 		expandedSrcs, err := ruleUtils.ExpandSrcs(sourceFiles, r.Attr("srcs"))
 		if err != nil {
-			BazelLog.Debugf("Failed to expand srcs of %s:%s - %v", f.Pkg, r.Name(), err)
+			indexLog.Debugf("Failed to expand srcs of %s:%s - %v", f.Pkg, r.Name(), err)
 			return []resolve.ImportSpec{}
 		}
 
@@ -153,7 +152,7 @@ func toOutDirPath(rootDir, outDir, src string) string {
 }
 
 func (ts *typeScriptLang) tsconfigImports(r *rule.Rule, f *rule.File) []resolve.ImportSpec {
-	BazelLog.Debugf("Imports(%s): //%s:%s (%s)", LanguageName, f.Pkg, r.Name(), r.Kind())
+	indexLog.Debugf("Imports(%s): //%s:%s (%s)", LanguageName, f.Pkg, r.Name(), r.Kind())
 
 	// Only the tsconfig file itself is exposed.
 	// The output is the same as the ts_config(src) input.
@@ -174,7 +173,7 @@ func (ts *typeScriptLang) protoLibraryImports(r *rule.Rule, f *rule.File) []reso
 		return nil
 	}
 
-	BazelLog.Debugf("Imports(%s): //%s:%s (%s)", LanguageName, f.Pkg, r.Name(), r.Kind())
+	indexLog.Debugf("Imports(%s): //%s:%s (%s)", LanguageName, f.Pkg, r.Name(), r.Kind())
 
 	dtsOutputs := []string{}
 
@@ -195,7 +194,7 @@ func (ts *typeScriptLang) protoLibraryImports(r *rule.Rule, f *rule.File) []reso
 			for _, p := range ruleUtils.AttrMap(r, "gen_connect_query_service_mapping") {
 				protoKey, isString := p.Key.(*build.StringExpr)
 				if !isString {
-					BazelLog.Errorf("Expected ts_proto_library.gen_connect_query_service_mapping key to be a string, got %v", p.Key)
+					indexLog.Errorf("Expected ts_proto_library.gen_connect_query_service_mapping key to be a string, got %v", p.Key)
 					continue
 				}
 				protoName := strings.TrimSuffix(protoKey.Value, ".proto")
@@ -204,7 +203,7 @@ func (ts *typeScriptLang) protoLibraryImports(r *rule.Rule, f *rule.File) []reso
 					for _, service := range services.List {
 						serviceStr, isString := service.(*build.StringExpr)
 						if !isString {
-							BazelLog.Errorf("Expected ts_proto_library.gen_connect_query_service_mapping service to be a string, got %v", service)
+							indexLog.Errorf("Expected ts_proto_library.gen_connect_query_service_mapping service to be a string, got %v", service)
 							continue
 						}
 
@@ -214,7 +213,7 @@ func (ts *typeScriptLang) protoLibraryImports(r *rule.Rule, f *rule.File) []reso
 						dtsOutputs = append(dtsOutputs, path.Join(f.Pkg, serviceFile))
 					}
 				} else {
-					BazelLog.Errorf("Expected ts_proto_library.gen_connect_query_service_mapping to be a list of services, got %v", p.Value)
+					indexLog.Errorf("Expected ts_proto_library.gen_connect_query_service_mapping to be a list of services, got %v", p.Value)
 				}
 			}
 		}
@@ -250,7 +249,7 @@ func (ts *typeScriptLang) protoLibraryImports(r *rule.Rule, f *rule.File) []reso
 // the embedding rule will be indexed. The embedding rule will inherit
 // the imports of the embedded rule.
 func (ts *typeScriptLang) Embeds(r *rule.Rule, from label.Label) []label.Label {
-	BazelLog.Debugf("Embeds(%s): '//%s:%s'", LanguageName, from.Pkg, r.Name())
+	indexLog.Debugf("Embeds(%s): '//%s:%s'", LanguageName, from.Pkg, r.Name())
 
 	switch r.Kind() {
 	case TsProjectKind:
@@ -292,16 +291,17 @@ func (ts *typeScriptLang) CrossResolve(c *config.Config, ix *resolve.RuleIndex,
 	}
 
 	fromRel := c.Exts[configRelExtension].(string)
+	log := resolveLog.With(BazelLog.Fields{Package: fromRel})
 
 	results := []resolve.FindResult{}
 
 	if node.IsSubpathImport(imp.Imp) {
 		// Node-style subpath imports, mapped by the 'imports' field of the
 		// importing package.
-		results = append(results, ts.findSubpathImport(c, ix, fromRel, imp.Imp)...)
+		results = append(results, ts.findSubpathImport(log, c, ix, fromRel, imp.Imp)...)
 	} else if impPkg, impSubpath := node.ParseImportPath(imp.Imp); impPkg != "" {
 		// Imports of npm packages.
-		results = append(results, ts.findPackageImport(c, ix, fromRel, impPkg, impSubpath)...)
+		results = append(results, ts.findPackageImport(log, c, ix, fromRel, impPkg, impSubpath)...)
 	}
 
 	// proto_library() targets when js_proto=aspect. The proto plugin owns the
@@ -355,7 +355,8 @@ func (ts *typeScriptLang) Resolve(
 	importData interface{},
 	from label.Label,
 ) {
-	BazelLog.Debugf("Resolve(%s): //%s:%s", LanguageName, from.Pkg, r.Name())
+	log := resolveLog.With(BazelLog.Fields{Package: from.Pkg})
+	log.Debugf("Resolve(%s): //%s:%s", LanguageName, from.Pkg, r.Name())
 
 	// TsProject imports are resolved as deps
 	switch r.Kind() {
@@ -373,7 +374,7 @@ func (ts *typeScriptLang) Resolve(
 		} else if pi, isProjectInfo := importData.(*TsProjectInfo); isProjectInfo {
 			projectInfo = pi
 		} else {
-			BazelLog.Infof("%s //%s:%s with no/unknown package info", r.Kind(), from.Pkg, r.Name())
+			log.Infof("%s //%s:%s with no/unknown package info", r.Kind(), from.Pkg, r.Name())
 			break
 		}
 
@@ -386,9 +387,9 @@ func (ts *typeScriptLang) Resolve(
 			fileDeps = common.NewLabelSet(from)
 		}
 
-		err := ts.resolveImports(c, ix, deps, fileDeps, projectInfo.imports, from, projectInfo.groupName)
+		err := ts.resolveImports(log, c, ix, r, deps, fileDeps, projectInfo.imports, from, projectInfo.groupName)
 		if err != nil {
-			common.ImportErrorf(c, "Resolution Error: %v", err)
+			common.ReportDiagnostic(c, common.NewDiagnostic(DiagUnresolvedImport, "Resolution Error: %v", err).WithRule(c, from, r))
 			return
 		}
 
@@ -397,7 +398,7 @@ func (ts *typeScriptLang) Resolve(
 		}
 
 		if r.Kind() == TsProjectKind {
-			ts.addTsLib(c, ix, deps, from, projectInfo.groupName)
+			ts.addTsLib(log, c, ix, deps, from, projectInfo.groupName)
 		}
 
 		if isPackageInfo {
@@ -416,16 +417,16 @@ func (ts *typeScriptLang) Resolve(
 	case NpmPackageKind:
 		packageInfo, isPackageInfo := importData.(*TsPackageInfo)
 		if !isPackageInfo {
-			BazelLog.Infof("%s //%s:%s with no/unknown package info", r.Kind(), from.Pkg, r.Name())
+			log.Infof("%s //%s:%s with no/unknown package info", r.Kind(), from.Pkg, r.Name())
 			break
 		}
 
 		srcs := packageInfo.sources.Values()
 
 		deps := common.NewLabelSet(from)
-		err := ts.resolveImports(c, ix, deps, nil, packageInfo.imports, from, packageInfo.groupName)
+		err := ts.resolveImports(log, c, ix, r, deps, nil, packageInfo.imports, from, packageInfo.groupName)
 		if err != nil {
-			common.ImportErrorf(c, "Resolution Error: %v", err)
+			common.ReportDiagnostic(c, common.NewDiagnostic(DiagUnresolvedImport, "Resolution Error: %v", err).WithRule(c, from, r))
 			return
 		}
 
@@ -443,6 +444,7 @@ func (ts *typeScriptLang) Resolve(
 	}
 }
 func (ts *typeScriptLang) addTsLib(
+	log *BazelLog.Logger,
 	c *config.Config,
 	ix *resolve.RuleIndex,
 	deps *common.LabelSet,
@@ -451,7 +453,7 @@ func (ts *typeScriptLang) addTsLib(
 ) {
 	_, _, tsconfig := ts.tsconfig.FindConfig(from.Pkg, groupName)
 	if tsconfig != nil && tsconfig.ImportHelpers {
-		if tslibLabel := ts.findPackage(from.Pkg, "tslib"); tslibLabel != nil {
+		if tslibLabel := ts.findPackage(log, from.Pkg, "tslib"); tslibLabel != nil {
 			deps.Add(tslibLabel)
 		}
 	}
@@ -462,8 +464,10 @@ func (ts *typeScriptLang) addTsLib(
 // not distinguish files from libraries (all deps in one attribute) pass a nil
 // fileDeps so every resolution lands in deps.
 func (ts *typeScriptLang) resolveImports(
+	log *BazelLog.Logger,
 	c *config.Config,
 	ix *resolve.RuleIndex,
+	r *rule.Rule,
 	deps *common.LabelSet,
 	fileDeps *common.LabelSet,
 	imports *treeset.Set[ImportStatement],
@@ -490,7 +494,7 @@ func (ts *typeScriptLang) resolveImports(
 			continue
 		}
 
-		resolutionType, resolved, err := ts.resolveImport(c, ix, from, imp, groupName)
+		resolutionType, resolved, err := ts.resolveImport(log, c, ix, from, imp, groupName)
 		if err != nil {
 			return err
 		}
@@ -513,9 +517,9 @@ func (ts *typeScriptLang) resolveImports(
 		// Neither the import or a type definition was found.
 		if resolutionType == Resolution_NotFound && len(types) == 0 {
 			if imp.Optional {
-				BazelLog.Infof("Optional import %q for target %v not found", imp.ImportPath, from)
+				log.Infof("Optional import %q for target %v not found", imp.ImportPath, from)
 			} else if cfg.ValidateImportStatements() != ValidationOff {
-				BazelLog.Debugf("import %q for target %v not found", imp.ImportPath, from)
+				log.Debugf("import %q for target %v not found", imp.ImportPath, from)
 
 				notFound := fmt.Errorf(
 					"Import %q from %q is an unknown %s dependency",
@@ -537,9 +541,13 @@ func (ts *typeScriptLang) resolveImports(
 
 		switch cfg.ValidateImportStatements() {
 		case ValidationError:
-			common.ImportErrorf(c, "Failed to validate dependencies for target %q:%v\n", from, joinedErrs)
+			common.ReportDiagnostic(c, common.NewDiagnostic(DiagUnresolvedImport, "Failed to validate dependencies for target %q:%v", from, joinedErrs).
+				WithRule(c, from, r).
+				WithFix("add the missing dependencies or a `# gazelle:%s` directive", Directive_Resolve))
 		case ValidationWarn:
-			fmt.Fprintf(os.Stderr, "Warning: Failed to validate dependencies for target %q:%v\n", from, joinedErrs)
+			common.ReportDiagnostic(c, common.NewDiagnostic(DiagUnresolvedImport, "Failed to validate dependencies for target %q:%v", from, joinedErrs).
+				WithRule(c, from, r).
+				AsWarning())
 		}
 	}
 
@@ -547,6 +555,7 @@ func (ts *typeScriptLang) resolveImports(
 }
 
 func (ts *typeScriptLang) resolveImport(
+	log *BazelLog.Logger,
 	c *config.Config,
 	ix *resolve.RuleIndex,
 	from label.Label,
@@ -556,7 +565,7 @@ func (ts *typeScriptLang) resolveImport(
 	imp := impStm.ImportSpec
 
 	// Gazelle rule index
-	if resolution, match, err := ts.resolveExplicitImportFromIndex(c, ix, from, impStm); resolution != Resolution_NotFound {
+	if resolution, match, err := ts.resolveExplicitImportFromIndex(log, c, ix, from, impStm); resolution != Resolution_NotFound {
 		return resolution, match, err
 	}
 
@@ -573,7 +582,7 @@ func (ts *typeScriptLang) resolveImport(
 				ImportPath: impStm.ImportPath,
 				Optional:   impStm.Optional,
 			}
-			if resolution, match, err := ts.resolveExplicitImportFromIndex(c, ix, from, pImp); resolution != Resolution_NotFound {
+			if resolution, match, err := ts.resolveExplicitImportFromIndex(log, c, ix, from, pImp); resolution != Resolution_NotFound {
 				return resolution, match, err
 			}
 		}
@@ -593,6 +602,7 @@ func (ts *typeScriptLang) resolveImport(
 }
 
 func (ts *typeScriptLang) resolveExplicitImportFromIndex(
+	log *BazelLog.Logger,
 	c *config.Config,
 	ix *resolve.RuleIndex,
 	from label.Label,
@@ -625,7 +635,7 @@ func (ts *typeScriptLang) resolveExplicitImportFromIndex(
 		results[i] = &matches[i].Label
 	}
 
-	BazelLog.Tracef("resolve %q import %q as %v", from, impStm.Imp, results)
+	log.Tracef("resolve %q import %q as %v", from, impStm.Imp, results)
 
 	return Resolution_Label, results, nil
 }
@@ -634,7 +644,7 @@ func (ts *typeScriptLang) resolveExplicitImportFromIndex(
 // import mapped to files within the package or to external packages by the
 // 'imports' field of the importing package.
 // See https://nodejs.org/api/packages.html#subpath-imports
-func (ts *typeScriptLang) findSubpathImport(c *config.Config, ix *resolve.RuleIndex, from, imp string) []resolve.FindResult {
+func (ts *typeScriptLang) findSubpathImport(log *BazelLog.Logger, c *config.Config, ix *resolve.RuleIndex, from, imp string) []resolve.FindResult {
 	packageDir, hasPackage := ts.packageScopeDir(from)
 	if !hasPackage {
 		return nil
@@ -657,7 +667,7 @@ func (ts *typeScriptLang) findSubpathImport(c *config.Config, ix *resolve.RuleIn
 			results = append(results, ix.FindRulesByImport(fileSpec, LanguageName)...)
 		} else if impPkg, impSubpath := node.ParseImportPath(target); impPkg != "" {
 			// External packages, including self-references through 'exports'.
-			results = append(results, ts.findPackageImport(c, ix, from, impPkg, impSubpath)...)
+			results = append(results, ts.findPackageImport(log, c, ix, from, impPkg, impSubpath)...)
 		}
 	}
 
@@ -667,11 +677,11 @@ func (ts *typeScriptLang) findSubpathImport(c *config.Config, ix *resolve.RuleIn
 // findPackageImport resolves a bare package specifier to its label(s),
 // preferring a self-reference through 'exports' over a node_modules package,
 // aligning with the node resolution algorithm.
-func (ts *typeScriptLang) findPackageImport(c *config.Config, ix *resolve.RuleIndex, from, impPkg, impSubpath string) []resolve.FindResult {
+func (ts *typeScriptLang) findPackageImport(log *BazelLog.Logger, c *config.Config, ix *resolve.RuleIndex, from, impPkg, impSubpath string) []resolve.FindResult {
 	if selfRefs := ts.findPackageSelfReference(c, ix, from, impPkg, impSubpath); len(selfRefs) > 0 {
 		return selfRefs
 	}
-	if pkg := ts.findPackage(from, impPkg); pkg != nil {
+	if pkg := ts.findPackage(log, from, impPkg); pkg != nil {
 		return []resolve.FindResult{{Label: *pkg}}
 	}
 	return nil
@@ -702,20 +712,20 @@ func (ts *typeScriptLang) findPackageSelfReference(c *config.Config, ix *resolve
 	return results
 }
 
-func (ts *typeScriptLang) findPackage(from string, impPkg string) *label.Label {
+func (ts *typeScriptLang) findPackage(log *BazelLog.Logger, from string, impPkg string) *label.Label {
 	fromProject := ts.pnpmProjects.GetProject(from)
 	if fromProject == nil {
-		BazelLog.Tracef("resolve %q import %q project not found", from, impPkg)
+		log.Tracef("resolve %q import %q project not found", from, impPkg)
 		return nil
 	}
 
 	impPkgLabel := fromProject.Get(impPkg)
 	if impPkgLabel == nil {
-		BazelLog.Tracef("resolve %q import %q not found", from, impPkg)
+		log.Tracef("resolve %q import %q not found", from, impPkg)
 		return nil
 	}
 
-	BazelLog.Tracef("resolve %q import %q to %q", from, impPkg, impPkgLabel)
+	log.Tracef("resolve %q import %q to %q", from, impPkg, impPkgLabel)
 
 	return impPkgLabel
 }
@@ -12,6 +12,14 @@ import (
 	pnpm "github.com/aspect-build/aspect-gazelle/language/js/pnpm"
 )
 
+// tsconfig files are declared when configuring, parsed when generating and
+// their paths expanded when resolving.
+var (
+	configureLog = BazelLog.With(BazelLog.Fields{Language: "js", Phase: "configure"})
+	generateLog  = BazelLog.With(BazelLog.Fields{Language: "js", Phase: "generate"})
+	resolveLog   = BazelLog.With(BazelLog.Fields{Language: "js", Phase: "resolve"})
+)
+
 type workspacePath struct {
 	root     string
 	rel      string
@@ -61,7 +69,7 @@ func (tc *TsWorkspace) SetTsConfigFile(root, rel, groupName, fileName string) {
 		return
 	}
 
-	BazelLog.Debugf("Declaring tsconfig file %s: %s", rel, fileName)
+	configureLog.Debugf("Declaring tsconfig file %s: %s", rel, fileName)
 
 	tc.cm.configFiles[rel][groupName] = &workspacePath{
 		root:     root,
@@ -26,7 +26,6 @@ import (
 	"sort"
 	"strings"
 
-	BazelLog "github.com/aspect-build/aspect-gazelle/common/logger"
 	"github.com/goexlib/jsonc"
 )
 
@@ -365,7 +364,7 @@ func parseTsConfigJSONFile(parsed map[string]*TsConfig, resolver TsConfigResolve
 
 	config, err := parseTsConfigJSON(parsed, resolver, root, tsconfig, tsconfigFile)
 	if config != nil {
-		BazelLog.Debugf("Parsed tsconfig file %s", tsconfig)
+		generateLog.Debugf("Parsed tsconfig file %s", tsconfig)
 
 		parsed[tsconfig] = config
 	}
@@ -400,12 +399,12 @@ func parseTsConfigJSON(parsed map[string]*TsConfig, resolver TsConfigResolver, r
 		for _, potential := range resolver(configDir, ext) {
 			// Existing entry pointing to `InvalidTsconfig` implies recursion via extends.
 			if parsed[potential] == &InvalidTsconfig {
-				BazelLog.Warnf("Recursive tsconfig file extension: %q", potential)
+				generateLog.Warnf("Recursive tsconfig file extension: %q", potential)
 				continue
 			}
 			base, err := parseTsConfigJSONFile(parsed, resolver, root, potential)
 			if err != nil {
-				BazelLog.Warnf("Failed to load base tsconfig file %q from %q: %v", ext, tsconfig, err)
+				generateLog.Warnf("Failed to load base tsconfig file %q from %q: %v", ext, tsconfig, err)
 			} else if base != nil {
 				bases = append(bases, base)
 				break
@@ -439,7 +438,7 @@ func parseTsConfigJSON(parsed map[string]*TsConfig, resolver TsConfigResolver, r
 	if baseConfig != nil {
 		rel, relErr := filepath.Rel(configDir, baseConfig.ConfigDir)
 		if relErr != nil {
-			BazelLog.Warnf("Failed to resolve relative path from %s to %s: %v", configDir, baseConfig.ConfigDir, relErr)
+			generateLog.Warnf("Failed to resolve relative path from %s to %s: %v", configDir, baseConfig.ConfigDir, relErr)
 		} else {
 			baseConfigRel = rel
 		}
@@ -685,8 +684,8 @@ func (c TsConfig) ExpandPaths(from, p string) []string {
 
 	// Check for exact 'paths' matches first
 	if exact := pathMap[p]; len(exact) > 0 {
-		if BazelLog.IsTraceEnabled() {
-			BazelLog.Tracef("TsConfig.paths exact matches for %q: %v", p, exact)
+		if resolveLog.IsTraceEnabled() {
+			resolveLog.Tracef("TsConfig.paths exact matches for %q: %v", p, exact)
 		}
 
 		for _, m := range exact {
@@ -714,8 +713,8 @@ func (c TsConfig) ExpandPaths(from, p string) []string {
 		// Sort the 'paths' pattern matches by priority
 		sort.Sort(possibleMatches)
 
-		if BazelLog.IsTraceEnabled() {
-			BazelLog.Tracef("TsConfig.paths glob matches for %q: %v", p, possibleMatches)
+		if resolveLog.IsTraceEnabled() {
+			resolveLog.Tracef("TsConfig.paths glob matches for %q: %v", p, possibleMatches)
 		}
 
 		// Expand and add the pattern matches
@@ -736,8 +735,8 @@ func (c TsConfig) ExpandPaths(from, p string) []string {
 	if !isRelativePath(p) {
 		baseUrlPath := c.expandBaseUrl(p)
 
-		if BazelLog.IsTraceEnabled() {
-			BazelLog.Tracef("TsConfig.baseUrl match for %q: %v", p, baseUrlPath)
+		if resolveLog.IsTraceEnabled() {
+			resolveLog.Tracef("TsConfig.baseUrl match for %q: %v", p, baseUrlPath)
 		}
 
 		possible = append(possible, baseUrlPath)
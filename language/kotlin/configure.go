@@ -4,6 +4,7 @@ import (
 	"flag"
 
 	common "github.com/aspect-build/aspect-gazelle/common"
+	"github.com/aspect-build/aspect-gazelle/common/bazel"
 	BazelLog "github.com/aspect-build/aspect-gazelle/common/logger"
 	"github.com/aspect-build/aspect-gazelle/language/kotlin/kotlinconfig"
 	jvm_javaconfig "github.com/bazel-contrib/rules_jvm/java/gazelle/javaconfig"
@@ -24,15 +25,23 @@ func (kt *kotlinLang) KnownDirectives() []string {
 
 func (kc *kotlinLang) initRootConfig(c *config.Config) kotlinconfig.Configs {
 	if _, exists := c.Exts[LanguageName]; !exists {
+		root := kotlinconfig.New(c.RepoRoot)
+
+		// Default to the maven repository declared in MODULE.bazel
+		if mavenRepoName := kc.moduleFile.ExtensionRepo("maven", "install"); mavenRepoName != "" {
+			root.SetMavenRepositoryName(mavenRepoName)
+		}
+
 		c.Exts[LanguageName] = kotlinconfig.Configs{
-			"": kotlinconfig.New(c.RepoRoot),
+			"": root,
 		}
 	}
 	return c.Exts[LanguageName].(kotlinconfig.Configs)
 }
 
 func (kt *kotlinLang) Configure(c *config.Config, rel string, f *rule.File) {
-	BazelLog.Tracef("Configure(%s): %s", LanguageName, rel)
+	log := configureLog.With(BazelLog.Fields{Package: rel})
+	log.Tracef("Configure(%s): %s", LanguageName, rel)
 
 	// Create the KotlinConfig for this package
 	cfgs := kt.initRootConfig(c)
@@ -60,7 +69,7 @@ func (kt *kotlinLang) Configure(c *config.Config, rel string, f *rule.File) {
 	}
 
 	if kt.mavenResolver == nil {
-		BazelLog.Tracef("Creating Maven resolver: %s", cfg.MavenInstallFile())
+		log.Tracef("Creating Maven resolver: %s", cfg.MavenInstallFile())
 
 		// TODO: better zerolog configuration
 		logger := zerolog.New(BazelLog.GetOutput()).Level(zerolog.TraceLevel)
@@ -70,7 +79,7 @@ func (kt *kotlinLang) Configure(c *config.Config, rel string, f *rule.File) {
 			jvm_maven.WithLogger(logger),
 		)
 		if err != nil {
-			BazelLog.Fatalf("error creating Maven resolver: %s", err.Error())
+			log.Fatalf("error creating Maven resolver: %s", err.Error())
 		}
 		kt.mavenResolver = &resolver
 	}
@@ -81,5 +90,10 @@ func (kc *kotlinLang) RegisterFlags(fs *flag.FlagSet, cmd string, c *config.Conf
 }
 
 func (kc *kotlinLang) CheckFlags(fs *flag.FlagSet, c *config.Config) error {
+	moduleFile, err := bazel.LoadModuleFile(c.RepoRoot)
+	if err != nil {
+		return err
+	}
+	kc.moduleFile = moduleFile
 	return nil
 }
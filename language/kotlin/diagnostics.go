@@ -0,0 +1,9 @@
+package gazelle
+
+import "github.com/aspect-build/aspect-gazelle/common"
+
+// Diagnostic codes reported by the Kotlin language.
+var (
+	DiagUnresolvedImport = common.DiagnosticCode{ID: "KT001", Name: "unresolved-import"}
+	DiagSourceGeneration = common.DiagnosticCode{ID: "KT002", Name: "source-generation"}
+)
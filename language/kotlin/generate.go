@@ -28,24 +28,26 @@ func (kt *kotlinLang) GenerateRules(args language.GenerateArgs) language.Generat
 
 	cfg := args.Config.Exts[LanguageName].(kotlinconfig.Configs)[args.Rel]
 
+	log := generateLog.With(BazelLog.Fields{Package: args.Rel})
+
 	// When we return empty, we mean that we don't generate anything, but this
 	// still triggers the indexing for all the Kotlin targets in this package.
 	if !cfg.GenerationEnabled() {
-		BazelLog.Tracef("GenerateRules(%s) disabled: %s", LanguageName, args.Rel)
+		log.Tracef("GenerateRules(%s) disabled: %s", LanguageName, args.Rel)
 		return language.GenerateResult{}
 	}
 
-	BazelLog.Tracef("GenerateRules(%s): %s", LanguageName, args.Rel)
+	log.Tracef("GenerateRules(%s): %s", LanguageName, args.Rel)
 
 	// Collect all source files.
-	sourceFiles := kt.collectSourceFiles(cfg, args)
+	sourceFiles := kt.collectSourceFiles(log, cfg, args)
 
 	// TODO: multiple library targets (lib, test, ...)
 	libTarget := NewKotlinLibTarget()
 	binTargets := treemap.NewWith[string, *KotlinBinTarget](strings.Compare)
 
 	// Parse all source files and group information into target(s)
-	for p := range kt.parseFiles(args, sourceFiles) {
+	for p := range kt.parseFiles(log, args, sourceFiles) {
 		// A nil result means the file could not be read (the error was already
 		// printed by the parse worker); skip it rather than panic.
 		if p == nil {
@@ -85,14 +87,14 @@ func (kt *kotlinLang) GenerateRules(args language.GenerateArgs) language.Generat
 
 	libTargetName := toDefaultTargetName(args, "root")
 
-	srcGenErr := kt.addLibraryRule(libTargetName, libTarget, args, false, &result)
+	srcGenErr := kt.addLibraryRule(log, libTargetName, libTarget, args, false, &result)
 	if srcGenErr != nil {
-		common.GenerationErrorf(args.Config, "Source rule generation error: %v", srcGenErr)
+		common.ReportDiagnostic(args.Config, common.NewDiagnostic(DiagSourceGeneration, "Source rule generation error: %v", srcGenErr).WithBuildFile(args.File))
 	}
 
 	for _, binTarget := range binTargets.Values() {
 		binTargetName := toBinaryTargetName(binTarget.File)
-		kt.addBinaryRule(binTargetName, binTarget, args, &result)
+		kt.addBinaryRule(log, binTargetName, binTarget, args, &result)
 	}
 
 	return result
@@ -111,7 +113,7 @@ func toDefaultTargetName(args language.GenerateArgs, defaultRootName string) str
 	return path.Base(args.Dir)
 }
 
-func (kt *kotlinLang) addLibraryRule(targetName string, target *KotlinLibTarget, args language.GenerateArgs, isTestRule bool, result *language.GenerateResult) error {
+func (kt *kotlinLang) addLibraryRule(log *BazelLog.Logger, targetName string, target *KotlinLibTarget, args language.GenerateArgs, isTestRule bool, result *language.GenerateResult) error {
 	// Check for name-collisions with the rule being generated.
 	colError := ruleUtils.CheckCollisionErrors(targetName, KtJvmLibrary, sourceRuleKinds, args)
 	if colError != nil {
@@ -126,6 +128,11 @@ func (kt *kotlinLang) addLibraryRule(targetName string, target *KotlinLibTarget,
 
 		for _, r := range args.File.Rules {
 			if r.Name() == targetName && r.Kind() == KtJvmLibrary {
+				// Never remove kept rules or rules with user-pinned sources
+				if ruleUtils.IsRuleKept(r) || ruleUtils.IsAttrPinned(r, "srcs") {
+					return nil
+				}
+
 				emptyRule := rule.NewRule(KtJvmLibrary, targetName)
 				result.Empty = append(result.Empty, emptyRule)
 				return nil
@@ -138,6 +145,7 @@ func (kt *kotlinLang) addLibraryRule(targetName string, target *KotlinLibTarget,
 	ktLibrary := rule.NewRule(KtJvmLibrary, targetName)
 	ktLibrary.SetAttr("srcs", target.Files.Values())
 	ktLibrary.SetPrivateAttr(packagesKey, target)
+	ruleUtils.PreservePinnedAttrs(ruleUtils.GetFileRuleByName(args, targetName), ktLibrary)
 
 	if isTestRule {
 		ktLibrary.SetAttr("testonly", true)
@@ -146,11 +154,11 @@ func (kt *kotlinLang) addLibraryRule(targetName string, target *KotlinLibTarget,
 	result.Gen = append(result.Gen, ktLibrary)
 	result.Imports = append(result.Imports, target)
 
-	BazelLog.Infof("add rule '%s' '%s:%s'", ktLibrary.Kind(), args.Rel, ktLibrary.Name())
+	log.Infof("add rule '%s' '%s:%s'", ktLibrary.Kind(), args.Rel, ktLibrary.Name())
 	return nil
 }
 
-func (kt *kotlinLang) addBinaryRule(targetName string, target *KotlinBinTarget, args language.GenerateArgs, result *language.GenerateResult) {
+func (kt *kotlinLang) addBinaryRule(log *BazelLog.Logger, targetName string, target *KotlinBinTarget, args language.GenerateArgs, result *language.GenerateResult) {
 	main_class := strings.TrimSuffix(target.File, ".kt")
 	if target.Package != "" {
 		main_class = target.Package + "." + main_class
@@ -160,20 +168,21 @@ func (kt *kotlinLang) addBinaryRule(targetName string, target *KotlinBinTarget,
 	ktBinary.SetAttr("srcs", []string{target.File})
 	ktBinary.SetAttr("main_class", main_class)
 	ktBinary.SetPrivateAttr(packagesKey, target)
+	ruleUtils.PreservePinnedAttrs(ruleUtils.GetFileRuleByName(args, targetName), ktBinary)
 
 	result.Gen = append(result.Gen, ktBinary)
 	result.Imports = append(result.Imports, target)
 
-	BazelLog.Infof("add rule '%s' '%s:%s'", ktBinary.Kind(), args.Rel, ktBinary.Name())
+	log.Infof("add rule '%s' '%s:%s'", ktBinary.Kind(), args.Rel, ktBinary.Name())
 }
 
-func (kt *kotlinLang) parseFiles(args language.GenerateArgs, sources []string) chan *parser.ParseResult {
+func (kt *kotlinLang) parseFiles(log *BazelLog.Logger, args language.GenerateArgs, sources []string) chan *parser.ParseResult {
 	parserCache := cache.Get(args.Config)
 	rootDir := args.Config.RepoRoot
 	rel := args.Rel
 
 	return common.Parallelize(sources, func(sourcePath string) *parser.ParseResult {
-		r, err := parseFile(parserCache, rootDir, rel, sourcePath)
+		r, err := parseFile(log, parserCache, rootDir, rel, sourcePath)
 
 		// Output errors to stdout
 		if err != nil {
@@ -191,8 +200,8 @@ func (kt *kotlinLang) parseFiles(args language.GenerateArgs, sources []string) c
 }
 
 // Parse the passed file for import statements, caching the result
-func parseFile(parserCache cache.Cache, rootDir, rel, sourcePath string) (*parser.ParseResult, error) {
-	BazelLog.Tracef("ParseImports(%s): %s", LanguageName, sourcePath)
+func parseFile(log *BazelLog.Logger, parserCache cache.Cache, rootDir, rel, sourcePath string) (*parser.ParseResult, error) {
+	log.Tracef("ParseImports(%s): %s", LanguageName, sourcePath)
 
 	var result *parser.ParseResult
 	r, _, err := parserCache.LoadOrStoreFile(rootDir, path.Join(rel, sourcePath), "kotlin.Parse", func(_ string, content []byte) (any, error) {
@@ -209,7 +218,7 @@ func parseFile(parserCache cache.Cache, rootDir, rel, sourcePath string) (*parse
 	return result, err
 }
 
-func (kt *kotlinLang) collectSourceFiles(cfg *kotlinconfig.KotlinConfig, args language.GenerateArgs) []string {
+func (kt *kotlinLang) collectSourceFiles(log *BazelLog.Logger, cfg *kotlinconfig.KotlinConfig, args language.GenerateArgs) []string {
 	sourceFiles := []string{}
 
 	// TODO: "module" targets similar to java?
@@ -217,7 +226,7 @@ func (kt *kotlinLang) collectSourceFiles(cfg *kotlinconfig.KotlinConfig, args la
 	for _, f := range args.RegularFiles {
 		// Otherwise the file is either source or potentially importable.
 		if isSourceFileType(f) {
-			BazelLog.Tracef("SourceFile: %s", f)
+			log.Tracef("SourceFile: %s", f)
 
 			sourceFiles = append(sourceFiles, f)
 		}
@@ -3,6 +3,8 @@ package gazelle
 import (
 	"strings"
 
+	"github.com/aspect-build/aspect-gazelle/common/bazel"
+	BazelLog "github.com/aspect-build/aspect-gazelle/common/logger"
 	jvm_maven "github.com/bazel-contrib/rules_jvm/java/gazelle/private/maven"
 	"github.com/bazelbuild/bazel-gazelle/config"
 	"github.com/bazelbuild/bazel-gazelle/language"
@@ -12,6 +14,14 @@ import (
 
 const LanguageName = "kotlin"
 
+// Loggers of each gazelle phase with the language context, see ASPECT_LOG_LEVEL.
+var (
+	configureLog = BazelLog.With(BazelLog.Fields{Language: LanguageName, Phase: "configure"})
+	generateLog  = BazelLog.With(BazelLog.Fields{Language: LanguageName, Phase: "generate"})
+	indexLog     = BazelLog.With(BazelLog.Fields{Language: LanguageName, Phase: "index"})
+	resolveLog   = BazelLog.With(BazelLog.Fields{Language: LanguageName, Phase: "resolve"})
+)
+
 const (
 	KtJvmLibrary              = "kt_jvm_library"
 	KtJvmBinary               = "kt_jvm_binary"
@@ -27,6 +37,9 @@ var sourceRuleKinds = treeset.NewWith(strings.Compare, KtJvmLibrary)
 type kotlinLang struct {
 	// TODO: extend rules_jvm extension instead of duplicating?
 	mavenResolver *jvm_maven.Resolver
+
+	// The root MODULE.bazel repository mapping, nil if the workspace has no MODULE.bazel
+	moduleFile *bazel.ModuleFile
 }
 
 var _ language.Language = (*kotlinLang)(nil)
@@ -226,7 +226,7 @@ func (p *treeSitterParser) Parse(filePath string, sourceCode []byte) (ParseResul
 
 	q, err := treeutils.GetQuery(lang, parserQuery)
 	if err != nil {
-		BazelLog.Fatalf("Failed to create kotlin 'parserQuery': %v", err)
+		BazelLog.With(BazelLog.Fields{Language: "kotlin", Phase: "generate", File: filePath}).Fatalf("Failed to create kotlin 'parserQuery': %v", err)
 	}
 
 	for caps := range tree.Query(q) {
@@ -7,6 +7,7 @@ import (
 
 	common "github.com/aspect-build/aspect-gazelle/common"
 	BazelLog "github.com/aspect-build/aspect-gazelle/common/logger"
+	ruleUtils "github.com/aspect-build/aspect-gazelle/common/rule"
 	"github.com/aspect-build/aspect-gazelle/language/kotlin/kotlinconfig"
 	"github.com/bazelbuild/bazel-gazelle/config"
 	"github.com/bazelbuild/bazel-gazelle/label"
@@ -36,7 +37,7 @@ func (*kotlinLang) Name() string {
 
 // Determine what rule (r) outputs which can be imported.
 func (kt *kotlinLang) Imports(c *config.Config, r *rule.Rule, f *rule.File) []resolve.ImportSpec {
-	BazelLog.Debugf("Imports(%s): '%s:%s'", LanguageName, f.Pkg, r.Name())
+	indexLog.Debugf("Imports(%s): '%s:%s'", LanguageName, f.Pkg, r.Name())
 
 	target, isLib := r.PrivateAttr(packagesKey).(*KotlinLibTarget)
 	if !isLib {
@@ -63,7 +64,8 @@ func (kt *kotlinLang) Embeds(r *rule.Rule, from label.Label) []label.Label {
 }
 
 func (kt *kotlinLang) Resolve(c *config.Config, ix *resolve.RuleIndex, rc *repo.RemoteCache, r *rule.Rule, importData interface{}, from label.Label) {
-	BazelLog.Debugf("Resolve(%s): //%s:%s", LanguageName, from.Pkg, r.Name())
+	log := resolveLog.With(BazelLog.Fields{Package: from.Pkg})
+	log.Debugf("Resolve(%s): //%s:%s", LanguageName, from.Pkg, r.Name())
 
 	if r.Kind() == KtJvmLibrary || r.Kind() == KtJvmBinary {
 		var target KotlinTarget
@@ -74,19 +76,21 @@ func (kt *kotlinLang) Resolve(c *config.Config, ix *resolve.RuleIndex, rc *repo.
 			target = importData.(*KotlinBinTarget).KotlinTarget
 		}
 
-		deps, err := kt.resolveImports(c, ix, target.Imports, from)
+		deps, err := kt.resolveImports(log, c, ix, target.Imports, from)
 		if err != nil {
-			common.ImportErrorf(c, "Resolution error %v\n", err)
+			common.ReportDiagnostic(c, common.NewDiagnostic(DiagUnresolvedImport, "Resolution error %v", err).WithRule(c, from, r))
 			return
 		}
 
-		if !deps.Empty() {
+		// Pinned deps of the existing rule have been preserved
+		if !deps.Empty() && !ruleUtils.IsPinnedAttr(r, "deps") {
 			r.SetAttr("deps", deps)
 		}
 	}
 }
 
 func (kt *kotlinLang) resolveImports(
+	log *BazelLog.Logger,
 	c *config.Config,
 	ix *resolve.RuleIndex,
 	imports *treeset.Set[ImportStatement],
@@ -100,13 +104,13 @@ func (kt *kotlinLang) resolveImports(
 	for it.Next() {
 		mod := it.Value()
 
-		resolutionType, dep, err := kt.resolveImport(c, ix, mod, from)
+		resolutionType, dep, err := kt.resolveImport(log, c, ix, mod, from)
 		if err != nil {
 			return nil, err
 		}
 
 		if resolutionType == Resolution_NotFound {
-			BazelLog.Debugf("import %q for target %v not found", mod.Imp, from)
+			log.Debugf("import %q for target %v not found", mod.Imp, from)
 
 			notFound := fmt.Errorf(
 				"Import %q from %q is an unknown %s dependency",
@@ -130,6 +134,7 @@ func (kt *kotlinLang) resolveImports(
 }
 
 func (kt *kotlinLang) resolveImport(
+	log *BazelLog.Logger,
 	c *config.Config,
 	ix *resolve.RuleIndex,
 	impt ImportStatement,
@@ -186,7 +191,7 @@ func (kt *kotlinLang) resolveImport(
 		if l, mavenError := (*mavenResolver).Resolve(jvm_import, cfg.ExcludedArtifacts(), cfg.MavenRepositoryName()); mavenError == nil {
 			return Resolution_Label, &l, nil
 		} else {
-			BazelLog.Debugf("Maven resolution failed: %v", mavenError)
+			log.Debugf("Maven resolution failed: %v", mavenError)
 		}
 	}
 
@@ -199,7 +204,7 @@ func (kt *kotlinLang) resolveImport(
 	}
 	parentImportSpec := impt
 	parentImportSpec.Imp = importParent.String()
-	return kt.resolveImport(c, ix, parentImportSpec, from)
+	return kt.resolveImport(log, c, ix, parentImportSpec, from)
 }
 
 // targetListFromLabels returns a string with the human-readable list of
@@ -1,14 +1,22 @@
 package gazelle
 
 import (
+	"fmt"
 	"iter"
+	"path"
+	"slices"
+	"strings"
+	"sync"
 
+	"github.com/aspect-build/aspect-gazelle/common/bazel"
+	"github.com/aspect-build/aspect-gazelle/common/treesitter"
 	plugin "github.com/aspect-build/aspect-gazelle/language/orion/plugin"
 )
 
 type BUILDConfig struct {
 	// Shared across all
-	repoName string
+	repoName   string
+	moduleFile *bazel.ModuleFile
 
 	// This config
 	rel    string
@@ -20,21 +28,30 @@ type BUILDConfig struct {
 	// All directives of this BUILD
 	directiveRawValues map[string][]string
 
+	// Grammars configured via `# gazelle:orion_grammar`, inherited by subdirectories.
+	grammars *treesitter.GrammarMapping
+
 	// Plugin specific config
 	pluginPrepareResults map[plugin.PluginId]pluginConfig
 
 	// Plugin-private inherited data (ctx.data), local to this directory; reads walk the parent chain.
 	pluginData map[plugin.PluginId]map[string]any
+
+	// Workspace files read by the plugins of this BUILD via ctx.read_file and variants.
+	readFiles *sync.Map
 }
 
-func NewRootConfig(repoName string) *BUILDConfig {
+func NewRootConfig(repoName string, moduleFile *bazel.ModuleFile) *BUILDConfig {
 	return &BUILDConfig{
-		repoName: repoName,
-		rel:      "",
+		repoName:   repoName,
+		moduleFile: moduleFile,
+		rel:        "",
 
 		directiveRawValues: make(map[string][]string),
 
 		pluginPrepareResults: make(map[string]pluginConfig),
+
+		readFiles: &sync.Map{},
 	}
 }
 
@@ -51,6 +68,7 @@ func (c *BUILDConfig) NewChildConfig(rel string) *BUILDConfig {
 
 	// Local plugin data; inherited values are reached via the parent chain.
 	cCopy.pluginData = nil
+	cCopy.readFiles = &sync.Map{}
 
 	// Non-inherited that require cloning
 	// TODO: verify these should not be inherited
@@ -63,6 +81,22 @@ func (p *BUILDConfig) appendDirectiveValue(key, value string) {
 	p.directiveRawValues[key] = append(p.directiveRawValues[key], value)
 }
 
+// addGrammarDirective adds a `{pattern} {grammar}` directive value to the grammars
+// of this BUILD and its subdirectories.
+func (c *BUILDConfig) addGrammarDirective(value string) error {
+	parts := strings.Fields(value)
+	if len(parts) != 2 {
+		return fmt.Errorf("expected '{pattern} {grammar}', got %q", value)
+	}
+
+	grammars, err := c.grammars.Add(c.rel, parts[0], treesitter.LanguageGrammar(parts[1]))
+	if err != nil {
+		return err
+	}
+	c.grammars = grammars
+	return nil
+}
+
 func (c *BUILDConfig) IsPluginEnabled(pluginId plugin.PluginId) bool {
 	if val, exists, _ := c.getRawValue(string(pluginId)); exists {
 		return val[len(val)-1] == "enabled"
@@ -111,6 +145,42 @@ func (c *BUILDConfig) getPluginData(pluginId plugin.PluginId, key string) (any,
 	return nil, false
 }
 
+// addReadFile records a workspace file read by the plugins of this BUILD.
+func (c *BUILDConfig) addReadFile(p string) {
+	c.readFiles.Store(p, struct{}{})
+}
+
+// getReadFiles returns the sorted workspace files read by the plugins of this BUILD so far.
+func (c *BUILDConfig) getReadFiles() []string {
+	var files []string
+	c.readFiles.Range(func(k, _ any) bool {
+		files = append(files, k.(string))
+		return true
+	})
+	slices.Sort(files)
+	return files
+}
+
+// fileGrammar returns the grammar configured for the source file f relative to
+// this BUILD for AstQuery queries of the given plugins.
+//
+// Directives take precedence over plugin configured grammars, returning "" if
+// neither map the file so the file extension default applies.
+func (c *BUILDConfig) fileGrammar(f string, pluginIds []plugin.PluginId) treesitter.LanguageGrammar {
+	if g, found := c.grammars.Lookup(path.Join(c.rel, f)); found {
+		return g
+	}
+
+	// Sorted to ensure deterministic results regardless of plugin order
+	for _, pluginId := range slices.Sorted(slices.Values(pluginIds)) {
+		if g, found := c.pluginPrepareResults[pluginId].Grammars.Lookup(f); found {
+			return g
+		}
+	}
+
+	return ""
+}
+
 // An extension of PrepareContext+Result to add internal utils
 type pluginConfig struct {
 	plugin.PrepareContext
@@ -6,18 +6,21 @@ import (
 	"sync"
 
 	common "github.com/aspect-build/aspect-gazelle/common"
+	"github.com/aspect-build/aspect-gazelle/common/bazel"
 	BazelLog "github.com/aspect-build/aspect-gazelle/common/logger"
 	"github.com/aspect-build/aspect-gazelle/language/orion/plugin"
 	"github.com/bazelbuild/bazel-gazelle/config"
 	"github.com/bazelbuild/bazel-gazelle/rule"
-	"golang.org/x/sync/errgroup"
 )
 
 var _ config.Configurer = (*GazelleHost)(nil)
 
+// Map files to a tree-sitter grammar for AstQuery queries: `# gazelle:orion_grammar {pattern} {grammar}`
+const Directive_Grammar = "orion_grammar"
+
 func (c *GazelleHost) KnownDirectives() []string {
 	if c.gazelleDirectives == nil {
-		c.gazelleDirectives = []string{}
+		c.gazelleDirectives = []string{Directive_Grammar}
 
 		// TODO: verify no collisions with other plugins/globals
 
@@ -36,12 +39,13 @@ func (c *GazelleHost) KnownDirectives() []string {
 }
 
 func (configurer *GazelleHost) Configure(c *config.Config, rel string, f *rule.File) {
-	BazelLog.Tracef("Configure(%s): %s", GazelleLanguageName, rel)
+	log := configureLog.With(BazelLog.Fields{Package: rel})
+	log.Tracef("Configure(%s): %s", GazelleLanguageName, rel)
 
 	// Generate hierarchical configuration.
 	var config *BUILDConfig
 	if rel == "" {
-		config = NewRootConfig(c.RepoName)
+		config = NewRootConfig(c.RepoName, configurer.moduleFile)
 	} else {
 		config = c.Exts[GazelleLanguageName].(*BUILDConfig).NewChildConfig(rel)
 	}
@@ -51,11 +55,16 @@ func (configurer *GazelleHost) Configure(c *config.Config, rel string, f *rule.F
 	if f != nil {
 		for _, d := range f.Directives {
 			config.appendDirectiveValue(d.Key, d.Value)
+
+			if d.Key == Directive_Grammar {
+				if err := config.addGrammarDirective(d.Value); err != nil {
+					common.ReportDiagnostic(c, common.NewDiagnostic(DiagInvalidGrammar, "directive %q: %v", Directive_Grammar, err).WithDirective(f, d.Key))
+				}
+			}
 		}
 	}
 
-	eg := errgroup.Group{}
-	eg.SetLimit(10)
+	eg := common.SharedPool().NewGroup(common.PriorityNormal)
 
 	var prepResultMutex sync.Mutex
 
@@ -66,6 +75,17 @@ func (configurer *GazelleHost) Configure(c *config.Config, rel string, f *rule.F
 		return common.WalkHasPath(rel, name)
 	}
 
+	// ctx.read_file and variants: read files of the workspace relative to this directory.
+	// Files read are recorded to invalidate the persisted symbols of the package.
+	readFile := func(name string, format plugin.QueryType) (any, error) {
+		p, err := workspaceFilePath(c.RepoRoot, rel, name)
+		if err != nil {
+			return nil, err
+		}
+		config.addReadFile(p)
+		return readWorkspaceFile(c, p, format)
+	}
+
 	// Prepare the plugins for this configuration.
 	for k, p := range configurer.plugins {
 		if !config.IsPluginEnabled(k) {
@@ -76,8 +96,13 @@ func (configurer *GazelleHost) Configure(c *config.Config, rel string, f *rule.F
 		k := k
 		p := p
 		eg.Go(func() error {
-			prepContext := configToPrepareContext(p, config)
+			prepContext := configToPrepareContext(log, p, config)
 			prepContext.HasFile = hasFile
+			prepContext.ReadFile = readFile
+			prepContext.Report = func(d common.Diagnostic) {
+				d.Code = DiagPlugin
+				common.ReportDiagnostic(c, d.WithBuildFile(f))
+			}
 
 			// ctx.data: plugin-private inherited store. Writable during prepare,
 			// reads fall through to the nearest ancestor that wrote the key.
@@ -118,15 +143,16 @@ func (configurer *GazelleHost) Configure(c *config.Config, rel string, f *rule.F
 	}
 
 	if err := eg.Wait(); err != nil {
-		BazelLog.Errorf("Configure(%s) plugin error: %v", GazelleLanguageName, err)
+		log.Errorf("Configure(%s) plugin error: %v", GazelleLanguageName, err)
 	}
 }
 
-func configToPrepareContext(p plugin.Plugin, cfg *BUILDConfig) plugin.PrepareContext {
+func configToPrepareContext(log *BazelLog.Logger, p plugin.Plugin, cfg *BUILDConfig) plugin.PrepareContext {
 	props := p.Properties()
 	ctx := plugin.PrepareContext{
 		RepoName: cfg.repoName,
 		Rel:      cfg.rel,
+		Repos:    plugin.RepoMapping{ModuleFile: cfg.moduleFile},
 		// Defaults are resolved lazily at read time; only record directive-set values.
 		Properties: plugin.NewPropertyValues(props),
 	}
@@ -142,8 +168,8 @@ func configToPrepareContext(p plugin.Plugin, cfg *BUILDConfig) plugin.PrepareCon
 		// A present-but-unparseable directive keeps the default value, but the
 		// property still counts as set (and local) at this directory.
 		value := p.Default
-		if parsedValue, parseErr := parsePropertyValue(p, v); parseErr != nil {
-			BazelLog.Warnf("Failed to parse property %q: %v", p.Name, parseErr)
+		if parsedValue, parseErr := parsePropertyValue(log, p, v); parseErr != nil {
+			log.Warnf("Failed to parse property %q: %v", p.Name, parseErr)
 		} else {
 			value = parsedValue
 		}
@@ -157,37 +183,37 @@ func configToPrepareContext(p plugin.Plugin, cfg *BUILDConfig) plugin.PrepareCon
 func getBUILDConfig(c *config.Config, rel string) *BUILDConfig {
 	cfg, ok := c.Exts[GazelleLanguageName].(*BUILDConfig)
 	if !ok || cfg == nil {
-		BazelLog.Fatalf("Expected BUILDConfig in config.Exts[%q], got %T", GazelleLanguageName, c.Exts[GazelleLanguageName])
+		configureLog.Fatalf("Expected BUILDConfig in config.Exts[%q], got %T", GazelleLanguageName, c.Exts[GazelleLanguageName])
 	}
 	if cfg.rel != rel {
-		BazelLog.Fatalf("Mismatched BUILDConfig rel:%q, expected:%q", cfg.rel, rel)
+		configureLog.Fatalf("Mismatched BUILDConfig rel:%q, expected:%q", cfg.rel, rel)
 	}
 	return cfg
 }
 
-func parsePropertyValue(p plugin.Property, values []string) (interface{}, error) {
+func parsePropertyValue(log *BazelLog.Logger, p plugin.Property, values []string) (interface{}, error) {
 	switch p.PropertyType {
 	case plugin.PropertyType_String:
-		return onlyValue(p, values), nil
+		return onlyValue(log, p, values), nil
 	case plugin.PropertyType_Strings:
 		return values, nil
 	case plugin.PropertyType_Bool:
-		return onlyValue(p, values) == "true", nil
+		return onlyValue(log, p, values) == "true", nil
 	case plugin.PropertyType_Number:
-		return strconv.ParseInt(onlyValue(p, values), 10, 0)
+		return strconv.ParseInt(onlyValue(log, p, values), 10, 0)
 	}
 
 	panic("unhandled property type: " + p.PropertyType)
 }
 
-func onlyValue(p plugin.Property, value []string) string {
+func onlyValue(log *BazelLog.Logger, p plugin.Property, value []string) string {
 	c := len(value)
 
 	if c == 0 {
-		BazelLog.Fatalf("expected exactly one value, got none")
+		log.Fatalf("expected exactly one value, got none")
 		return ""
 	} else if c > 1 {
-		BazelLog.Warnf("expected exactly one value for %q, got %d", p.Name, c)
+		log.Warnf("expected exactly one value for %q, got %d", p.Name, c)
 	}
 
 	return value[c-1]
@@ -197,5 +223,10 @@ func (c *GazelleHost) RegisterFlags(fs *flag.FlagSet, cmd string, cfg *config.Co
 }
 
 func (c *GazelleHost) CheckFlags(fs *flag.FlagSet, cfg *config.Config) error {
+	moduleFile, err := bazel.LoadModuleFile(cfg.RepoRoot)
+	if err != nil {
+		return err
+	}
+	c.moduleFile = moduleFile
 	return nil
 }
@@ -0,0 +1,18 @@
+package gazelle
+
+import "github.com/aspect-build/aspect-gazelle/common"
+
+// Diagnostic codes reported by the orion language and its plugins.
+var (
+	DiagUnresolvedImport = common.DiagnosticCode{ID: "ORN001", Name: "unresolved-import"}
+	DiagAmbiguousImport  = common.DiagnosticCode{ID: "ORN002", Name: "ambiguous-import"}
+	DiagQueryError       = common.DiagnosticCode{ID: "ORN003", Name: "query-error"}
+	DiagAnalyzeError     = common.DiagnosticCode{ID: "ORN004", Name: "analyze-error"}
+	DiagDeclareError     = common.DiagnosticCode{ID: "ORN005", Name: "declare-error"}
+	DiagSourceGeneration = common.DiagnosticCode{ID: "ORN006", Name: "source-generation"}
+	DiagInvalidGrammar   = common.DiagnosticCode{ID: "ORN007", Name: "invalid-grammar"}
+	DiagSyntaxError      = common.DiagnosticCode{ID: "ORN008", Name: "syntax-error"}
+	DiagResolveError     = common.DiagnosticCode{ID: "ORN009", Name: "resolve-error"}
+	DiagPlugin           = common.DiagnosticCode{ID: "ORN010", Name: "plugin-diagnostic"}
+	DiagFixConflict      = common.DiagnosticCode{ID: "ORN011", Name: "fix-conflict"}
+)
@@ -0,0 +1,68 @@
+package gazelle
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/aspect-build/aspect-gazelle/common/cache"
+	"github.com/aspect-build/aspect-gazelle/language/orion/plugin"
+	queryRunner "github.com/aspect-build/aspect-gazelle/language/orion/queries"
+	"github.com/bazelbuild/bazel-gazelle/config"
+)
+
+// The cache key prefix of files read by plugins, suffixed with the format.
+const readFileCacheKey = "orion-read-file:"
+
+// readWorkspaceFile reads and parses the file at the workspace relative path p for
+// ctx.read_file and variants. Results are memoized by the gazelle cache which
+// invalidates them when the file changes.
+//
+// Returns nil if the file does not exist.
+func readWorkspaceFile(c *config.Config, p string, format plugin.QueryType) (any, error) {
+	v, _, err := cache.Get(c).LoadOrStoreFile(c.RepoRoot, p, readFileCacheKey+string(format), func(p string, content []byte) (any, error) {
+		return queryRunner.ParseFile(p, format, content)
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return v, err
+}
+
+// workspaceFilePath returns the workspace relative path of a file relative to the
+// directory rel, or an error if the file is outside the workspace.
+func workspaceFilePath(repoRoot, rel, name string) (string, error) {
+	if path.IsAbs(name) {
+		return "", fmt.Errorf("path %q must be relative", name)
+	}
+
+	p := path.Join(rel, name)
+	if isOutsideDir(p) {
+		return "", fmt.Errorf("path %q is outside the workspace", name)
+	}
+
+	// Symlinks within the workspace may also point outside of it
+	realPath, err := filepath.EvalSymlinks(filepath.Join(repoRoot, p))
+	if errors.Is(err, fs.ErrNotExist) {
+		return p, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	realRoot, err := filepath.EvalSymlinks(repoRoot)
+	if err != nil {
+		return "", err
+	}
+	if realRel, err := filepath.Rel(realRoot, realPath); err != nil || isOutsideDir(filepath.ToSlash(realRel)) {
+		return "", fmt.Errorf("path %q links outside the workspace", name)
+	}
+
+	return p, nil
+}
+
+func isOutsideDir(rel string) bool {
+	return rel == ".." || strings.HasPrefix(rel, "../")
+}
@@ -0,0 +1,122 @@
+package gazelle
+
+import (
+	common "github.com/aspect-build/aspect-gazelle/common"
+	ruleUtils "github.com/aspect-build/aspect-gazelle/common/rule"
+	"github.com/aspect-build/aspect-gazelle/language/orion/plugin"
+	"github.com/bazelbuild/bazel-gazelle/config"
+	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+)
+
+// Fix runs the fix stage of the enabled plugins on an existing BUILD file before
+// rules are generated, applying the edits of each plugin in turn.
+//
+// The edits rename or delete existing rules and attributes so they are only
+// applied when c.ShouldFix is true, otherwise the pending edits are logged.
+func (host *GazelleHost) Fix(c *config.Config, f *rule.File) {
+	if f == nil {
+		return
+	}
+
+	cfg := getBUILDConfig(c, f.Pkg)
+
+	// Iterate over the pluginIds[] in a deterministic order
+	for _, pluginId := range host.pluginIds {
+		fixer, isFixer := host.plugins[pluginId].(plugin.RuleFixer)
+		if !isFixer || !cfg.IsPluginEnabled(pluginId) {
+			continue
+		}
+
+		prep, found := cfg.pluginPrepareResults[pluginId]
+		if !found {
+			prep.PrepareContext = plugin.PrepareContext{RepoName: cfg.repoName, Rel: f.Pkg}
+		}
+
+		// Each plugin sees the fixes of the plugins before it
+		ctx := plugin.NewFixContext(prep.PrepareContext, plugin.NewRules(f.Rules), plugin.NewFixActions())
+		result := fixer.Fix(ctx)
+		if len(result.Actions) == 0 {
+			continue
+		}
+
+		if !c.ShouldFix {
+			fixLog.Warnf("%s: %d fixes of the %s plugin skipped, run 'gazelle fix' to apply them", f.Path, len(result.Actions), pluginId)
+			continue
+		}
+
+		for _, action := range result.Actions {
+			applyFixAction(c, f, pluginId, action)
+		}
+
+		// Drop deleted rules from f.Rules before the next plugin and generation
+		f.Sync()
+	}
+}
+
+func applyFixAction(c *config.Config, f *rule.File, pluginId plugin.PluginId, action plugin.FixAction) {
+	var name string
+	switch a := action.(type) {
+	case plugin.RenameKindAction:
+		name = a.Name
+	case plugin.RenameAttrAction:
+		name = a.Name
+	case plugin.DeleteAttrAction:
+		name = a.Name
+	case plugin.DeleteRuleAction:
+		name = a.Name
+	default:
+		fixLog.Fatalf("Unknown plugin fix action type: %T", action)
+	}
+
+	r := findRule(f, name)
+	if r == nil {
+		// Fixes of rules already migrated or removed are no-ops
+		fixLog.Debugf("Fix(%s) %s: no rule %q in %q", GazelleLanguageName, pluginId, name, f.Path)
+		return
+	}
+	if ruleUtils.IsRuleKept(r) {
+		fixLog.Debugf("Fix(%s) %s: skipping kept rule %q", GazelleLanguageName, pluginId, name)
+		return
+	}
+
+	switch a := action.(type) {
+	case plugin.RenameKindAction:
+		fixLog.Debugf("Fix(%s) %s: rename kind of %q from %q to %q", GazelleLanguageName, pluginId, name, r.Kind(), a.Kind)
+		r.SetKind(a.Kind)
+	case plugin.RenameAttrAction:
+		value := r.Attr(a.Attr)
+		if value == nil || ruleUtils.IsAttrKept(r, a.Attr) {
+			return
+		}
+		if r.Attr(a.To) != nil {
+			d := common.NewDiagnostic(DiagFixConflict, "%s: cannot rename attribute %q of %q to %q, the attribute already exists", pluginId, a.Attr, name, a.To).
+				AsWarning().
+				WithRule(c, label.New(c.RepoName, f.Pkg, name), r).
+				WithFix("remove one of the %q and %q attributes", a.Attr, a.To)
+			common.ReportDiagnostic(c, d)
+			return
+		}
+		fixLog.Debugf("Fix(%s) %s: rename attribute %q of %q to %q", GazelleLanguageName, pluginId, a.Attr, name, a.To)
+		r.DelAttr(a.Attr)
+		r.SetAttr(a.To, value)
+	case plugin.DeleteAttrAction:
+		if ruleUtils.IsAttrKept(r, a.Attr) {
+			return
+		}
+		fixLog.Debugf("Fix(%s) %s: delete attribute %q of %q", GazelleLanguageName, pluginId, a.Attr, name)
+		r.DelAttr(a.Attr)
+	case plugin.DeleteRuleAction:
+		fixLog.Debugf("Fix(%s) %s: delete rule %q", GazelleLanguageName, pluginId, name)
+		r.Delete()
+	}
+}
+
+func findRule(f *rule.File, name string) *rule.Rule {
+	for _, r := range f.Rules {
+		if r.Name() == name {
+			return r
+		}
+	}
+	return nil
+}
@@ -14,18 +14,13 @@ import (
 	"github.com/aspect-build/aspect-gazelle/common/cache"
 	BazelLog "github.com/aspect-build/aspect-gazelle/common/logger"
 	ruleUtils "github.com/aspect-build/aspect-gazelle/common/rule"
+	"github.com/aspect-build/aspect-gazelle/common/treesitter"
 	"github.com/aspect-build/aspect-gazelle/language/orion/plugin"
 	queryRunner "github.com/aspect-build/aspect-gazelle/language/orion/queries"
 	"github.com/bazelbuild/bazel-gazelle/config"
 	gazelleLabel "github.com/bazelbuild/bazel-gazelle/label"
 	gazelleLanguage "github.com/bazelbuild/bazel-gazelle/language"
 	gazelleRule "github.com/bazelbuild/bazel-gazelle/rule"
-	"golang.org/x/sync/errgroup"
-)
-
-const (
-	// TODO: move to common
-	MaxWorkerCount = 12
 )
 
 const (
@@ -38,17 +33,18 @@ const (
 //   - which rules to delete (GenerateResult.Empty)
 //   - which rules to create (or merge with existing) and their associated metadata (GenerateResult.Gen + GenerateResult.Imports)
 func (host *GazelleHost) GenerateRules(args gazelleLanguage.GenerateArgs) gazelleLanguage.GenerateResult {
-	BazelLog.Tracef("GenerateRules(%s): %s", GazelleLanguageName, args.Rel)
+	log := generateLog.With(BazelLog.Fields{Package: args.Rel})
+	log.Tracef("GenerateRules(%s): %s", GazelleLanguageName, args.Rel)
 
 	cfg := getBUILDConfig(args.Config, args.Rel)
 
 	// Mark this BUILDConfig as generated since it is having real rules generated.
 	cfg.generated = true
 
-	return host.generateRules(cfg, args)
+	return host.generateRules(log, cfg, args)
 }
 
-func (host *GazelleHost) generateRules(cfg *BUILDConfig, args gazelleLanguage.GenerateArgs) gazelleLanguage.GenerateResult {
+func (host *GazelleHost) generateRules(log *BazelLog.Logger, cfg *BUILDConfig, args gazelleLanguage.GenerateArgs) gazelleLanguage.GenerateResult {
 	queryCache := cache.Get(args.Config)
 
 	// Stage 1:
@@ -58,10 +54,10 @@ func (host *GazelleHost) generateRules(cfg *BUILDConfig, args gazelleLanguage.Ge
 	//  - iterating over source files by plugin file group
 	pluginSourceFiles, sourceFilePlugins, pluginSourceGroupFiles := host.collectSourceFilesByPlugin(cfg, args.Config, args.RegularFiles)
 
-	// Run queries on source files. Cap concurrency at MaxWorkerCount: more
-	// in-flight blocking opens just churn the scheduler spinning up OS threads.
-	eg := errgroup.Group{}
-	eg.SetLimit(MaxWorkerCount)
+	// Run queries on source files using the shared pool: more in-flight
+	// blocking opens than workers just churn the scheduler spinning up OS threads.
+	// The current package has priority over any other queued work.
+	eg := common.SharedPool().NewGroup(common.PriorityHigh)
 
 	// Stage 2:
 	// Parse and query source files and collect results
@@ -69,6 +65,9 @@ func (host *GazelleHost) generateRules(cfg *BUILDConfig, args gazelleLanguage.Ge
 	sourceFileQueryResults := make(map[string]plugin.QueryResults, len(sourceFilePlugins))
 	sourceFileQueryResultsLock := sync.Mutex{}
 
+	// The plugins parsing each source file for AstQuery queries
+	sourceFileAstPlugins := make(map[string][]plugin.PluginId)
+
 	// Parse and query source files
 	for sourceFile, pluginIds := range sourceFilePlugins {
 		// Collect all queries for this source file from all plugins
@@ -78,28 +77,57 @@ func (host *GazelleHost) generateRules(cfg *BUILDConfig, args gazelleLanguage.Ge
 		for _, pluginId := range pluginIds {
 			prep := cfg.pluginPrepareResults[pluginId]
 			hasMatch := false
+			hasAstQuery := false
 			for queryId, query := range prep.getQueriesForFile(sourceFile) {
 				queries[pluginId+"|"+queryId] = query
 				hasMatch = true
+				hasAstQuery = hasAstQuery || query.QueryType() == plugin.QueryTypeAst
 			}
 			if hasMatch {
 				pluginHashes = append(pluginHashes, prep.queriesHash)
 			}
+			if hasAstQuery {
+				sourceFileAstPlugins[sourceFile] = append(sourceFileAstPlugins[sourceFile], pluginId)
+			}
 		}
 
 		if len(queries) == 0 {
 			continue
 		}
 
+		p := joinPkg(args.Rel, sourceFile)
+
+		// The grammar to parse the file as for AstQuery queries. AstQuery queries relying
+		// on the file extension are dropped when the extension is unknown, other queries still run.
+		grammar := cfg.fileGrammar(sourceFile, pluginIds)
+		if grammar == "" && hasDefaultGrammarQuery(queries) {
+			if _, found := treesitter.LookupPathLanguage(sourceFile); !found {
+				common.ReportDiagnostic(args.Config, common.NewDiagnostic(DiagInvalidGrammar, "No tree-sitter grammar for AstQuery on %q", p).
+					AsWarning().
+					WithSource(p, 0, 0).
+					WithBuildFile(args.File).
+					WithFix("map the file to a grammar with '# gazelle:%s {pattern} {grammar}' or aspect.PrepareResult(grammars)", Directive_Grammar))
+
+				maps.DeleteFunc(queries, func(_ string, q plugin.QueryDefinition) bool {
+					return isDefaultGrammarQuery(q)
+				})
+				if len(queries) == 0 {
+					continue
+				}
+			}
+		}
+
 		// Joined queriesHash for all plugins with queries on this file
 		slices.Sort(pluginHashes) // Sorted to ensure deterministic cache keys regardless of plugin order
+		if grammar != "" {
+			pluginHashes = append(pluginHashes, "grammar="+string(grammar))
+		}
 		queriesHash := strings.Join(pluginHashes, "|")
 
 		// Capture loop variables for goroutine
 		sourceFile := sourceFile
 		eg.Go(func() error {
-			p := joinPkg(args.Rel, sourceFile)
-			queryResults, err := host.runSourceQueries(queryCache, queries, queriesHash, args.Config.RepoRoot, p)
+			queryResults, err := host.runSourceQueries(queryCache, queries, grammar, queriesHash, args.Config.RepoRoot, p)
 			if err != nil {
 				return fmt.Errorf("Querying source file %q: %v", p, err)
 			}
@@ -112,7 +140,7 @@ func (host *GazelleHost) generateRules(cfg *BUILDConfig, args gazelleLanguage.Ge
 	}
 
 	if err := eg.Wait(); err != nil {
-		common.GenerationErrorf(args.Config, "Plugin source query error: %v", err)
+		common.ReportDiagnostic(args.Config, common.NewDiagnostic(DiagQueryError, "Plugin source query error: %v", err).WithBuildFile(args.File))
 		return gazelleLanguage.GenerateResult{}
 	}
 
@@ -135,14 +163,23 @@ func (host *GazelleHost) generateRules(cfg *BUILDConfig, args gazelleLanguage.Ge
 	// Assign the file query results to the correct plugin TargetSources.QueryResults.
 	for f, results := range sourceFileQueryResults {
 		for key, queryResult := range results {
+			if key == plugin.SyntaxErrorsKey {
+				continue
+			}
 			pluginId, queryId, _ := strings.Cut(key, "|")
 			pluginTargetSources[pluginId][f].QueryResults[queryId] = queryResult
 		}
 	}
 
+	// Plugins failing on syntax errors are not analyzed or declared
+	failedPlugins := assignSyntaxErrors(cfg, args, sourceFileQueryResults, sourceFileAstPlugins, pluginTargetSources)
+
 	// Stage 3:
 	// Analyze each plugin source file.
 	for pluginId, prep := range cfg.pluginPrepareResults {
+		if failedPlugins[pluginId] {
+			continue
+		}
 		for _, src := range pluginTargetSources[pluginId] {
 			// Capture loop variables for goroutine
 			pluginId := pluginId
@@ -160,15 +197,25 @@ func (host *GazelleHost) generateRules(cfg *BUILDConfig, args gazelleLanguage.Ge
 	}
 
 	if err := eg.Wait(); err != nil {
-		common.GenerationErrorf(args.Config, "Plugin source analysis error: %v", err)
+		common.ReportDiagnostic(args.Config, common.NewDiagnostic(DiagAnalyzeError, "Plugin source analysis error: %v", err).WithBuildFile(args.File))
 		return gazelleLanguage.GenerateResult{}
 	}
 
 	// Stage 4:
 	// Generate target actions for each plugin
+	var existingRules []plugin.Rule
+	if args.File != nil {
+		existingRules = plugin.NewRules(args.File.Rules)
+	}
+	generatedRules := plugin.NewRules(args.OtherGen)
+
 	pluginTargetActions := make(map[plugin.PluginId][]plugin.TargetAction, len(cfg.pluginPrepareResults))
 	pluginTargetsLock := sync.Mutex{}
 	for pluginId, prep := range cfg.pluginPrepareResults {
+		if failedPlugins[pluginId] {
+			continue
+		}
+
 		// Capture loop variables for goroutine
 		pluginId := pluginId
 		prep := prep
@@ -192,7 +239,7 @@ func (host *GazelleHost) generateRules(cfg *BUILDConfig, args gazelleLanguage.Ge
 			}
 
 			// Use the collected sources and analysis to generate rules
-			actions := host.generateTargets(pluginId, prep, pluginTargetGroups)
+			actions := host.generateTargets(pluginId, prep, pluginTargetGroups, existingRules, generatedRules)
 
 			// Lock for the assignment into the cross-thread pluginTargets
 			pluginTargetsLock.Lock()
@@ -204,13 +251,13 @@ func (host *GazelleHost) generateRules(cfg *BUILDConfig, args gazelleLanguage.Ge
 	}
 
 	if err := eg.Wait(); err != nil {
-		common.GenerationErrorf(args.Config, "Plugin target generation error: %v", err)
+		common.ReportDiagnostic(args.Config, common.NewDiagnostic(DiagDeclareError, "Plugin target generation error: %v", err).WithBuildFile(args.File))
 		return gazelleLanguage.GenerateResult{}
 	}
 
 	// Stage 5:
 	// Apply plugin actions
-	return host.convertPlugActionsToGenerateResult(pluginTargetActions, args)
+	return host.convertPlugActionsToGenerateResult(log, pluginTargetActions, args)
 }
 
 func applyRemoveAction(args gazelleLanguage.GenerateArgs, result *gazelleLanguage.GenerateResult, rm plugin.RemoveTargetAction) *gazelleRule.Rule {
@@ -220,6 +267,11 @@ func applyRemoveAction(args gazelleLanguage.GenerateArgs, result *gazelleLanguag
 
 	for _, r := range args.File.Rules {
 		if r.Name() == rm.Name {
+			// Never remove rules the user has marked with `# keep`
+			if ruleUtils.IsRuleKept(r) {
+				return nil
+			}
+
 			kind := rm.Kind
 			if rm.Kind == "" {
 				kind = r.Kind() // TODO: need to reverse map_kind?
@@ -231,46 +283,55 @@ func applyRemoveAction(args gazelleLanguage.GenerateArgs, result *gazelleLanguag
 	return nil
 }
 
-func (host *GazelleHost) convertPlugActionsToGenerateResult(pluginActions map[string][]plugin.TargetAction, args gazelleLanguage.GenerateArgs) gazelleLanguage.GenerateResult {
+func (host *GazelleHost) convertPlugActionsToGenerateResult(log *BazelLog.Logger, pluginActions map[string][]plugin.TargetAction, args gazelleLanguage.GenerateArgs) gazelleLanguage.GenerateResult {
 	var result gazelleLanguage.GenerateResult
 
 	// Iterate over the pluginIds[] in a deterministic order
 	// instead of iterating over the plugins[] or pluginActions[pluginId] map
 	for _, pluginId := range host.pluginIds {
 		for _, action := range pluginActions[pluginId] {
-			host.applyPluginAction(args, pluginId, action, &result)
+			host.applyPluginAction(log, args, pluginId, action, &result)
 		}
 	}
 
 	return result
 }
 
-func (host *GazelleHost) applyPluginAction(args gazelleLanguage.GenerateArgs, pluginId plugin.PluginId, action plugin.TargetAction, result *gazelleLanguage.GenerateResult) {
+func (host *GazelleHost) applyPluginAction(log *BazelLog.Logger, args gazelleLanguage.GenerateArgs, pluginId plugin.PluginId, action plugin.TargetAction, result *gazelleLanguage.GenerateResult) {
 	switch a := action.(type) {
 	case plugin.RemoveTargetAction:
 		// If marked for removal simply add to the empty list and continue
 		if removed := applyRemoveAction(args, result, a); removed != nil {
-			BazelLog.Debugf("GenerateRules remove target: %s %s(%q)", args.Rel, removed.Kind(), removed.Name())
+			log.Debugf("GenerateRules remove target: %s %s(%q)", args.Rel, removed.Kind(), removed.Name())
 		}
 	case plugin.AddTargetAction:
 		// Check for name-collisions with the rule being generated.
 		target := a.TargetDeclaration
 		colError := ruleUtils.CheckCollisionErrors(target.Name, target.Kind, host.sourceRuleKinds, args)
 		if colError != nil {
-			common.GenerationErrorf(args.Config, "Source rule generation error: %v", colError)
+			common.ReportDiagnostic(args.Config, common.NewDiagnostic(DiagSourceGeneration, "Source rule generation error: %v", colError).WithBuildFile(args.File))
 			return
 		}
 
 		// Generate the gazelle Rule to be added/merged into the BUILD file.
-		rule, attrs := convertPluginTargetDeclaration(args.Rel, pluginId, target)
+		rule, attrs, err := convertPluginTargetDeclaration(args.Rel, pluginId, target)
+		if err != nil {
+			common.ReportDiagnostic(args.Config, common.NewDiagnostic(DiagSourceGeneration, "Source rule generation error: %v", err).WithBuildFile(args.File))
+			return
+		}
+
+		// Preserve user-pinned attributes of the existing rule, never resolving them.
+		for _, attr := range preservePinnedAttrs(ruleUtils.GetFileRuleByName(args, target.Name), rule) {
+			delete(attrs, attr)
+		}
 
 		result.Gen = append(result.Gen, rule)
 		result.Imports = append(result.Imports, attrs)
 		result.RelsToIndex = append(result.RelsToIndex, targetAttributesToRelsToImport(args.Rel, attrs)...)
 
-		BazelLog.Tracef("GenerateRules(%s) add target: %s %s(%q)", GazelleLanguageName, args.Rel, target.Kind, target.Name)
+		log.Tracef("GenerateRules(%s) add target: %s %s(%q)", GazelleLanguageName, args.Rel, target.Kind, target.Name)
 	default:
-		BazelLog.Fatalf("Unknown plugin action type: %T", action)
+		log.Fatalf("Unknown plugin action type: %T", action)
 	}
 }
 
@@ -278,9 +339,109 @@ type attributeValue struct {
 	singleton bool
 	values    []interface{}
 	imports   []plugin.TargetImport
+
+	// The values of each condition of a select(), following the values common to all conditions.
+	selects map[string]*attributeValue
+
+	// The values of each entry of a dict.
+	entries map[string]*attributeValue
+}
+
+// isStructured reports whether the value is a select() or dict where each
+// condition or entry is resolved individually.
+func (v *attributeValue) isStructured() bool {
+	return v.selects != nil || v.entries != nil
+}
+
+// allImports returns the imports of the value including those of each select()
+// condition or dict entry.
+func (v *attributeValue) allImports() []plugin.TargetImport {
+	imports := slices.Clip(v.imports)
+	for _, c := range v.selects {
+		imports = append(imports, c.allImports()...)
+	}
+	for _, e := range v.entries {
+		imports = append(imports, e.allImports()...)
+	}
+	return imports
+}
+
+// buildStructured returns the select() or dict value with the value of each condition
+// or entry returned by valueOf, nil values being omitted. Returns nil for a dict of
+// only omitted entries.
+func (v *attributeValue) buildStructured(valueOf func(v *attributeValue) (interface{}, error)) (interface{}, error) {
+	if v.entries != nil {
+		dict := make(map[string]interface{}, len(v.entries))
+		for key, entry := range v.entries {
+			var value interface{}
+			var err error
+			if entry.isStructured() {
+				value, err = entry.buildStructured(valueOf)
+			} else {
+				value, err = valueOf(entry)
+			}
+			if err != nil {
+				return nil, err
+			}
+			if value != nil {
+				dict[key] = value
+			}
+		}
+		if len(dict) == 0 && len(v.entries) > 0 {
+			return nil, nil
+		}
+		return dictValue(dict), nil
+	}
+
+	conditions := make(gazelleRule.SelectStringListValue, len(v.selects))
+	for condition, c := range v.selects {
+		value, err := valueOf(c)
+		if err != nil {
+			return nil, err
+		}
+		if conditions[condition], err = toStringList(value); err != nil {
+			return nil, fmt.Errorf("select() condition %q: %w", condition, err)
+		}
+	}
+
+	values, err := valueOf(v)
+	if err != nil {
+		return nil, err
+	}
+	list, _ := values.([]interface{})
+	return selectValue{values: list, conditions: conditions}, nil
+}
+
+// toStringList returns the string and label values of a select() condition.
+func toStringList(value interface{}) ([]string, error) {
+	values, _ := value.([]interface{})
+	strs := make([]string, 0, len(values))
+	for _, v := range values {
+		switch v := v.(type) {
+		case string:
+			strs = append(strs, v)
+		case gazelleLabel.Label:
+			strs = append(strs, v.String())
+		default:
+			return nil, fmt.Errorf("expected string or label values, got %T", v)
+		}
+	}
+	return strs, nil
 }
 
-func convertPluginTargetDeclaration(pkg string, pluginId plugin.PluginId, target plugin.TargetDeclaration) (*gazelleRule.Rule, map[string]*attributeValue) {
+// unresolvedValue returns the value of a select() condition or dict entry before
+// import resolution, nil if only imports were specified.
+func unresolvedValue(v *attributeValue) (interface{}, error) {
+	if len(v.values) == 0 {
+		return nil, nil
+	}
+	if v.singleton {
+		return v.values[0], nil
+	}
+	return v.values, nil
+}
+
+func convertPluginTargetDeclaration(pkg string, pluginId plugin.PluginId, target plugin.TargetDeclaration) (*gazelleRule.Rule, map[string]*attributeValue, error) {
 	targetRule := gazelleRule.NewRule(target.Kind, target.Name)
 
 	ruleAttrs := make(map[string]*attributeValue, len(target.Attrs))
@@ -290,6 +451,20 @@ func convertPluginTargetDeclaration(pkg string, pluginId plugin.PluginId, target
 	targetRule.SetPrivateAttr(targetAttrValues, ruleAttrs)
 
 	for attr, val := range target.Attrs {
+		// A select() or dict, set with the values known before resolution
+		if structured := convertStructuredAttribute(pkg, val); structured != nil {
+			ruleAttrs[attr] = structured
+
+			value, err := structured.buildStructured(unresolvedValue)
+			if err != nil {
+				return nil, nil, fmt.Errorf("attribute %q of %s: %w", attr, target.Name, err)
+			}
+			if value != nil {
+				targetRule.SetAttr(attr, value)
+			}
+			continue
+		}
+
 		attrValue, attrImports, isArray := convertPluginAttribute(pkg, val)
 
 		// TODO: verify 'attr' is resolveable if len(attrImports) > 0
@@ -314,7 +489,7 @@ func convertPluginTargetDeclaration(pkg string, pluginId plugin.PluginId, target
 		}
 	}
 
-	return targetRule, ruleAttrs
+	return targetRule, ruleAttrs, nil
 }
 
 func targetAttributesToRelsToImport(pkg string, attrs map[string]*attributeValue) []string {
@@ -324,7 +499,7 @@ func targetAttributesToRelsToImport(pkg string, attrs map[string]*attributeValue
 	// TODO: provide hooks for plugins to override this behavior.
 
 	for _, attrVal := range attrs {
-		for _, imp := range attrVal.imports {
+		for _, imp := range attrVal.allImports() {
 			rel := imp.Id
 			rel = strings.Trim(rel, "/")
 
@@ -353,6 +528,10 @@ func convertPluginAttribute(pkg string, val interface{}) ([]interface{}, []plugi
 		return nil, []plugin.TargetImport{targetImport}, false
 	}
 
+	if g, isGlob := val.(plugin.Glob); isGlob {
+		return []interface{}{globValue{gazelleRule.GlobValue{Patterns: g.Include, Excludes: g.Exclude}}}, nil, false
+	}
+
 	// Convert plugin.Label to a gazelle Label
 	if l, isLabel := val.(plugin.Label); isLabel {
 		val = gazelleLabel.New(l.Repo, l.Pkg, l.Name)
@@ -367,6 +546,49 @@ func convertPluginAttribute(pkg string, val interface{}) ([]interface{}, []plugi
 	return []interface{}{val}, nil, false
 }
 
+// convertStructuredAttribute converts a select() or dict attribute value, each
+// condition or entry potentially containing imports. Returns nil for other values.
+func convertStructuredAttribute(pkg string, val interface{}) *attributeValue {
+	switch v := val.(type) {
+	case plugin.Select:
+		values, imports, _ := convertPluginAttribute(pkg, v.Values)
+		attrValue := &attributeValue{
+			values:  values,
+			imports: imports,
+			selects: make(map[string]*attributeValue, len(v.Conditions)),
+		}
+		for condition, conditionValues := range v.Conditions {
+			values, imports, _ := convertPluginAttribute(pkg, conditionValues)
+			attrValue.selects[condition] = &attributeValue{
+				values:  values,
+				imports: imports,
+			}
+		}
+		return attrValue
+
+	case map[string]interface{}:
+		attrValue := &attributeValue{
+			singleton: true,
+			entries:   make(map[string]*attributeValue, len(v)),
+		}
+		for key, entryValue := range v {
+			if structured := convertStructuredAttribute(pkg, entryValue); structured != nil {
+				attrValue.entries[key] = structured
+				continue
+			}
+			values, imports, isArray := convertPluginAttribute(pkg, entryValue)
+			attrValue.entries[key] = &attributeValue{
+				singleton: !isArray,
+				values:    values,
+				imports:   imports,
+			}
+		}
+		return attrValue
+	}
+
+	return nil
+}
+
 func computeQueriesCacheKey(queries plugin.NamedQueries) string {
 	cacheDigest := crypto.MD5.New()
 
@@ -379,11 +601,25 @@ func computeQueriesCacheKey(queries plugin.NamedQueries) string {
 	e := gob.NewEncoder(cacheDigest)
 	for _, key := range keys {
 		if err := e.Encode(key); err != nil {
-			BazelLog.Fatalf("Failed to encode query key %q: %v", key, err)
+			generateLog.Fatalf("Failed to encode query key %q: %v", key, err)
 		}
 		q := queries[key]
 		if err := e.Encode(q.QueryType()); err != nil {
-			BazelLog.Fatalf("Failed to encode query type value %q: %v", q, err)
+			generateLog.Fatalf("Failed to encode query type value %q: %v", q, err)
+		}
+		// gob encodes map entries in a random order, the namespaces are encoded
+		// separately as pairs sorted by prefix.
+		if xq, isXml := q.(*plugin.XmlQuery); isXml && len(xq.Namespaces) > 0 {
+			namespaces := make([]string, 0, 2*len(xq.Namespaces))
+			for _, prefix := range slices.Sorted(maps.Keys(xq.Namespaces)) {
+				namespaces = append(namespaces, prefix, xq.Namespaces[prefix])
+			}
+			if err := e.Encode(namespaces); err != nil {
+				generateLog.Fatalf("Failed to encode query namespaces %v: %v", namespaces, err)
+			}
+			xqCopy := *xq
+			xqCopy.Namespaces = nil
+			q = &xqCopy
 		}
 		// Note: gob flattens the pointer and encodes q as its concrete *Query
 		// struct (no gob.Register needed: the stream is never decoded and the
@@ -391,18 +627,18 @@ func computeQueriesCacheKey(queries plugin.NamedQueries) string {
 		// QueryBase.FilterExpr are ignored by gob like unexported fields; the
 		// Filter string patterns are sufficient for cache key purposes.
 		if err := e.Encode(q); err != nil {
-			BazelLog.Fatalf("Failed to encode query value %q: %v", q, err)
+			generateLog.Fatalf("Failed to encode query value %q: %v", q, err)
 		}
 	}
 
 	return hex.EncodeToString(cacheDigest.Sum(nil))
 }
 
-func (host *GazelleHost) runSourceQueries(queryCache cache.Cache, queries plugin.NamedQueries, queriesHash, baseDir, f string) (plugin.QueryResults, error) {
+func (host *GazelleHost) runSourceQueries(queryCache cache.Cache, queries plugin.NamedQueries, grammar treesitter.LanguageGrammar, queriesHash, baseDir, f string) (plugin.QueryResults, error) {
 	var qr plugin.QueryResults
 
 	r, _, err := queryCache.LoadOrStoreFile(baseDir, f, queriesHash, func(p string, sourceCode []byte) (any, error) {
-		return queryRunner.RunQueries(f, sourceCode, queries)
+		return queryRunner.RunQueries(f, grammar, sourceCode, queries)
 	})
 
 	if r != nil {
@@ -412,6 +648,83 @@ func (host *GazelleHost) runSourceQueries(queryCache cache.Cache, queries plugin
 	return qr, err
 }
 
+// assignSyntaxErrors adds the syntax errors of each file to the TargetSource of the
+// plugins parsing the file and applies the policy of each of those plugins.
+//
+// The errors of a file are reported once, as errors if any plugin parsing the file
+// chose "error" and otherwise as warnings if any chose "warn". Returns the plugins
+// that chose "error" for a file with syntax errors.
+func assignSyntaxErrors(cfg *BUILDConfig, args gazelleLanguage.GenerateArgs, sourceFileQueryResults map[string]plugin.QueryResults, sourceFileAstPlugins map[string][]plugin.PluginId, pluginTargetSources map[plugin.PluginId]map[string]plugin.TargetSource) map[plugin.PluginId]bool {
+	var failed map[plugin.PluginId]bool
+
+	// Sorted for deterministic reporting
+	for _, f := range slices.Sorted(maps.Keys(sourceFileQueryResults)) {
+		syntaxErrors, _ := sourceFileQueryResults[f][plugin.SyntaxErrorsKey].(plugin.SyntaxErrors)
+		if len(syntaxErrors) == 0 {
+			continue
+		}
+
+		isError, isWarning := false, false
+		for _, pluginId := range sourceFileAstPlugins[f] {
+			src := pluginTargetSources[pluginId][f]
+			src.Errors = syntaxErrors
+			pluginTargetSources[pluginId][f] = src
+
+			switch cfg.pluginPrepareResults[pluginId].SyntaxErrors {
+			case plugin.SyntaxErrorsError:
+				if failed == nil {
+					failed = make(map[plugin.PluginId]bool)
+				}
+				failed[pluginId] = true
+				isError = true
+			case plugin.SyntaxErrorsWarn:
+				isWarning = true
+			}
+		}
+
+		if !isError && !isWarning {
+			continue
+		}
+
+		p := joinPkg(args.Rel, f)
+		for _, e := range syntaxErrors {
+			d := common.NewDiagnostic(DiagSyntaxError, "%s:%d:%d: syntax error: %s", p, e.Line, e.Column, strings.TrimSpace(e.Text)).
+				WithSource(p, e.Line, e.Column).
+				WithBuildFile(args.File)
+			if !isError {
+				d = d.AsWarning()
+			}
+			common.ReportDiagnostic(args.Config, d)
+		}
+	}
+
+	return failed
+}
+
+// hasDefaultGrammarQuery reports whether any query relies on the file grammar.
+func hasDefaultGrammarQuery(queries plugin.NamedQueries) bool {
+	for _, q := range queries {
+		if isDefaultGrammarQuery(q) {
+			return true
+		}
+	}
+	return false
+}
+
+// isDefaultGrammarQuery reports whether q is an AstQuery relying on the file grammar
+// as opposed to declaring an explicit grammar, including injection queries
+// locating the regions of an embedded language.
+func isDefaultGrammarQuery(q plugin.QueryDefinition) bool {
+	astQuery, isAst := q.(*plugin.AstQuery)
+	if !isAst {
+		return false
+	}
+	if inj := astQuery.Injection; inj != nil {
+		return inj.Query != "" && inj.Grammar == ""
+	}
+	return astQuery.Grammar == ""
+}
+
 // Collect source files managed by this BUILD and batch them by plugins interested in them.
 func (host *GazelleHost) collectSourceFilesByPlugin(cfg *BUILDConfig, c *config.Config, files []string) (map[plugin.PluginId][]string, map[string][]plugin.PluginId, map[plugin.PluginId]map[string][]string) {
 	pluginSourceFiles := make(map[plugin.PluginId][]string, len(cfg.pluginPrepareResults))
@@ -456,11 +769,13 @@ func (host *GazelleHost) collectSourceFilesByPlugin(cfg *BUILDConfig, c *config.
 }
 
 // Let plugins declare any targets they want to generate for the target sources.
-func (host *GazelleHost) generateTargets(pluginId plugin.PluginId, prep pluginConfig, sources plugin.TargetSources) []plugin.TargetAction {
+func (host *GazelleHost) generateTargets(pluginId plugin.PluginId, prep pluginConfig, sources plugin.TargetSources, existingRules, generatedRules []plugin.Rule) []plugin.TargetAction {
 	ctx := plugin.NewDeclareTargetsContext(
 		prep.PrepareContext,
 		sources,
 		plugin.NewDeclareTargetActions(),
+		existingRules,
+		generatedRules,
 		host.database,
 	)
 
@@ -1,9 +1,14 @@
 package gazelle
 
 import (
+	"context"
+	"maps"
 	"testing"
 
+	common "github.com/aspect-build/aspect-gazelle/common"
 	"github.com/aspect-build/aspect-gazelle/language/orion/plugin"
+	"github.com/bazelbuild/bazel-gazelle/config"
+	gazelleLanguage "github.com/bazelbuild/bazel-gazelle/language"
 )
 
 // QueryDefinition is satisfiable only by pointers to the *Query structs,
@@ -13,6 +18,10 @@ var _ plugin.QueryDefinition = (*plugin.RegexQuery)(nil)
 var _ plugin.QueryDefinition = (*plugin.JsonQuery)(nil)
 var _ plugin.QueryDefinition = (*plugin.YamlQuery)(nil)
 var _ plugin.QueryDefinition = (*plugin.TomlQuery)(nil)
+var _ plugin.QueryDefinition = (*plugin.XmlQuery)(nil)
+var _ plugin.QueryDefinition = (*plugin.ProtoQuery)(nil)
+var _ plugin.QueryDefinition = (*plugin.StarlarkQuery)(nil)
+var _ plugin.QueryDefinition = (*plugin.KeyValueQuery)(nil)
 var _ plugin.QueryDefinition = (*plugin.RawQuery)(nil)
 
 func queryBase(filter ...string) plugin.QueryBase {
@@ -29,6 +38,10 @@ func allQueryTypes() plugin.NamedQueries {
 		"json":  &plugin.JsonQuery{QueryBase: queryBase("*.json"), Query: ".dependencies"},
 		"yaml":  &plugin.YamlQuery{QueryBase: queryBase("*.yaml"), Query: ".jobs"},
 		"toml":  &plugin.TomlQuery{QueryBase: queryBase("*.toml"), Query: ".project"},
+		"xml":   &plugin.XmlQuery{QueryBase: queryBase("*.xml"), Query: "/project"},
+		"proto": &plugin.ProtoQuery{QueryBase: queryBase("*.proto")},
+		"bzl":   &plugin.StarlarkQuery{QueryBase: queryBase("*.bzl")},
+		"kv":    &plugin.KeyValueQuery{QueryBase: queryBase("*.properties"), Query: "server.port"},
 		"raw":   &plugin.RawQuery{QueryBase: queryBase("*.svg")},
 	}
 }
@@ -123,6 +136,41 @@ func TestComputeQueriesCacheKey(t *testing.T) {
 		}
 	})
 
+	t.Run("deterministic with xml namespaces", func(t *testing.T) {
+		namespaced := func(namespaces map[string]string) plugin.NamedQueries {
+			return plugin.NamedQueries{
+				"q": &plugin.XmlQuery{QueryBase: queryBase("*.xml"), Query: "/a:x/b:y", Namespaces: namespaces},
+			}
+		}
+		namespaces := map[string]string{
+			"a": "urn:a",
+			"b": "urn:b",
+			"c": "urn:c",
+			"d": "urn:d",
+			"e": "urn:e",
+		}
+
+		k := computeQueriesCacheKey(namespaced(namespaces))
+		for range 20 {
+			if k2 := computeQueriesCacheKey(namespaced(maps.Clone(namespaces))); k2 != k {
+				t.Fatalf("cache key not deterministic with namespaces: %q != %q", k2, k)
+			}
+		}
+
+		changed := maps.Clone(namespaces)
+		changed["e"] = "urn:changed"
+		if k2 := computeQueriesCacheKey(namespaced(changed)); k2 == k {
+			t.Error("cache key did not change with a namespace uri")
+		}
+
+		renamed := maps.Clone(namespaces)
+		delete(renamed, "e")
+		renamed["f"] = "urn:e"
+		if k2 := computeQueriesCacheKey(namespaced(renamed)); k2 == k {
+			t.Error("cache key did not change with a namespace prefix")
+		}
+	})
+
 	t.Run("ignores the parsed regex expression", func(t *testing.T) {
 		// A NewRegexQuery-built query (parsed expression populated) must hash
 		// the same as a struct literal (parsed expression nil).
@@ -163,3 +211,43 @@ func TestNewRegexQuery(t *testing.T) {
 		}
 	})
 }
+
+func TestAssignSyntaxErrors(t *testing.T) {
+	c := config.New()
+	common.SetupCancellableContext(c, context.Background())
+	var reported []common.Diagnostic
+	common.SetDiagnosticReporter(c, func(d common.Diagnostic) {
+		reported = append(reported, d)
+	})
+
+	cfg := NewRootConfig("", nil)
+	policies := map[plugin.PluginId]plugin.SyntaxErrorPolicy{
+		"lenient": plugin.SyntaxErrorsIgnore,
+		"strict":  plugin.SyntaxErrorsError,
+	}
+	targetSources := make(map[plugin.PluginId]map[string]plugin.TargetSource)
+	for pluginId, policy := range policies {
+		cfg.pluginPrepareResults[pluginId] = pluginConfig{PrepareResult: plugin.PrepareResult{SyntaxErrors: policy}}
+		targetSources[pluginId] = map[string]plugin.TargetSource{"a.py": {Path: "a.py"}}
+	}
+
+	syntaxErrors := plugin.SyntaxErrors{{Line: 2, Column: 1, Text: ")"}}
+	queryResults := map[string]plugin.QueryResults{
+		"a.py": {plugin.SyntaxErrorsKey: syntaxErrors},
+	}
+	astPlugins := map[string][]plugin.PluginId{"a.py": {"lenient", "strict"}}
+
+	failed := assignSyntaxErrors(cfg, gazelleLanguage.GenerateArgs{Config: c}, queryResults, astPlugins, targetSources)
+
+	if !failed["strict"] || failed["lenient"] {
+		t.Errorf("only the plugin choosing the error policy should fail, got %v", failed)
+	}
+	for pluginId := range policies {
+		if len(targetSources[pluginId]["a.py"].Errors) != 1 {
+			t.Errorf("%s: expected the syntax errors assigned to its source", pluginId)
+		}
+	}
+	if len(reported) != 1 || reported[0].Severity != common.SeverityError {
+		t.Errorf("expected a single error diagnostic, got %v", reported)
+	}
+}
@@ -13,11 +13,11 @@ import (
 	"slices"
 	"strings"
 
+	"github.com/aspect-build/aspect-gazelle/common/bazel"
 	"github.com/aspect-build/aspect-gazelle/common/bazel/workspace"
 	BazelLog "github.com/aspect-build/aspect-gazelle/common/logger"
 	plugin "github.com/aspect-build/aspect-gazelle/language/orion/plugin"
 	starzelle "github.com/aspect-build/aspect-gazelle/language/orion/starzelle"
-	"github.com/bazelbuild/bazel-gazelle/config"
 	"github.com/bazelbuild/bazel-gazelle/label"
 	gazelleLanguage "github.com/bazelbuild/bazel-gazelle/language"
 	"github.com/bazelbuild/bazel-gazelle/rule"
@@ -26,6 +26,16 @@ import (
 
 const GazelleLanguageName = "orion"
 
+// Loggers of each gazelle phase with the language context, see ASPECT_LOG_LEVEL.
+var (
+	loadLog      = BazelLog.With(BazelLog.Fields{Language: GazelleLanguageName, Phase: "load"})
+	configureLog = BazelLog.With(BazelLog.Fields{Language: GazelleLanguageName, Phase: "configure"})
+	fixLog       = BazelLog.With(BazelLog.Fields{Language: GazelleLanguageName, Phase: "fix"})
+	generateLog  = BazelLog.With(BazelLog.Fields{Language: GazelleLanguageName, Phase: "generate"})
+	indexLog     = BazelLog.With(BazelLog.Fields{Language: GazelleLanguageName, Phase: "index"})
+	resolveLog   = BazelLog.With(BazelLog.Fields{Language: GazelleLanguageName, Phase: "resolve"})
+)
+
 // A gazelle
 type GazelleHost struct {
 	database *plugin.Database
@@ -39,6 +49,9 @@ type GazelleHost struct {
 	kinds           map[string]plugin.RuleKind
 	sourceRuleKinds *treeset.Set[string]
 
+	// The root MODULE.bazel repository mapping, nil if the workspace has no MODULE.bazel
+	moduleFile *bazel.ModuleFile
+
 	// Lazy loaded from plugins
 	gazelleDirectives []string
 	gazelleLoadInfo   []rule.LoadInfo
@@ -75,18 +88,18 @@ func (h *GazelleHost) loadStarzellePlugins(plugins []string) {
 
 	wd, cwdErr := os.Getwd()
 	if cwdErr != nil {
-		BazelLog.Fatalf("Failed to find CWD: %v", cwdErr)
+		loadLog.Fatalf("Failed to find CWD: %v", cwdErr)
 		return
 	}
 
 	// Load starzelle plugins configured in the aspect-cli config.yaml
 	wr, wrErr := workspace.DefaultFinder.Find(wd)
 	if wrErr != nil {
-		BazelLog.Fatalf("Failed to find bazel workspace: %v", wrErr)
+		loadLog.Fatalf("Failed to find bazel workspace: %v", wrErr)
 		return
 	}
 
-	BazelLog.Infof("Loading %v orion plugins from %q: %v", len(plugins), wd, plugins)
+	loadLog.Infof("Loading %v orion plugins from %q: %v", len(plugins), wd, plugins)
 
 	for _, plugin := range plugins {
 		h.LoadPlugin(wr, plugin)
@@ -119,12 +132,12 @@ func (h *GazelleHost) loadEnvStarzellePlugins() {
 		}
 		builtinDirPlugins, err := filepath.Glob(path.Join(builtinPluginSubdir, "*.axl"))
 		if err != nil {
-			BazelLog.Fatalf("Failed to find builtin plugins: %v", err)
+			loadLog.Fatalf("Failed to find builtin plugins: %v", err)
 			return
 		}
 
 		if len(builtinDirPlugins) == 0 {
-			BazelLog.Warnf("No orion plugins found in %q", builtinPluginDir)
+			loadLog.Warnf("No orion plugins found in %q", builtinPluginDir)
 		}
 
 		// Sort to ensure a consistent order not dependent on the fs or glob ordering.
@@ -148,7 +161,7 @@ func (h *GazelleHost) loadEnvStarzellePlugins() {
 		}
 	}
 
-	BazelLog.Infof("Loading %v orion env plugins from %q: %v", len(builtinPlugins), builtinPluginDir, builtinPlugins)
+	loadLog.Infof("Loading %v orion env plugins from %q: %v", len(builtinPlugins), builtinPluginDir, builtinPlugins)
 
 	for _, p := range builtinPlugins {
 		h.LoadPlugin(builtinPluginDir, p)
@@ -157,13 +170,13 @@ func (h *GazelleHost) loadEnvStarzellePlugins() {
 func (h *GazelleHost) LoadPlugin(pluginDir, pluginPath string) {
 	// Can not add new plugins after configuration/data-collection has started
 	if h.gazelleKindInfo != nil || h.gazelleLoadInfo != nil {
-		BazelLog.Fatalf("Cannot add plugin %q after configuration has started", pluginPath)
+		loadLog.Fatalf("Cannot add plugin %q after configuration has started", pluginPath)
 		return
 	}
 
 	err := starzelle.LoadProxy(h, pluginDir, pluginPath)
 	if err != nil {
-		BazelLog.Infof("Failed to load orion plugin %v\n", err)
+		loadLog.Infof("Failed to load orion plugin %v\n", err)
 
 		// Try to remove the `parentDir` from the error message to align paths
 		// with the user's workspace relative paths, and to remove sandbox paths
@@ -177,10 +190,10 @@ func (h *GazelleHost) LoadPlugin(pluginDir, pluginPath string) {
 
 func (h *GazelleHost) AddPlugin(plugin plugin.Plugin) {
 	if _, exists := h.plugins[plugin.Name()]; exists {
-		BazelLog.Errorf("Duplicate plugin %q", plugin.Name())
+		loadLog.Errorf("Duplicate plugin %q", plugin.Name())
 	}
 
-	BazelLog.Infof("Plugin added: %q", plugin.Name())
+	loadLog.Infof("Plugin added: %q", plugin.Name())
 	h.pluginIds = append(h.pluginIds, plugin.Name())
 	h.plugins[plugin.Name()] = plugin
 }
@@ -198,7 +211,7 @@ func (h *GazelleHost) AddKind(k plugin.RuleKind) {
 		fmt.Printf("WARN: gazelle_rule_kind(%q) registered by %q overrides existing registration by %q\n", k.Name, from, existingFrom)
 	}
 
-	BazelLog.Infof("Kind added: %q", k.Name)
+	loadLog.Infof("Kind added: %q", k.Name)
 	h.kinds[k.Name] = k
 
 	// Clear cached plugin.RuleKind => gazelle mapping.
@@ -252,7 +265,7 @@ func (h *GazelleHost) ApparentLoads(moduleToApparentName func(string) string) []
 
 			from, err := label.Parse(r.From)
 			if err != nil {
-				BazelLog.Errorf("Failed to parse label %q: %v", r.From, err)
+				loadLog.Errorf("Failed to parse label %q: %v", r.From, err)
 				fmt.Printf("Invalid rule 'From' label %q: %v", r.From, err)
 				continue
 			}
@@ -260,6 +273,12 @@ func (h *GazelleHost) ApparentLoads(moduleToApparentName func(string) string) []
 			// Map external repo names to apparent names
 			if from.Repo != "" {
 				apparentName := moduleToApparentName(from.Repo)
+				if apparentName == "" {
+					apparentName = h.moduleFile.ApparentName(from.Repo)
+				}
+				if apparentName == "" {
+					apparentName = r.WorkspaceRepo
+				}
 				if apparentName != "" {
 					from.Repo = apparentName
 				}
@@ -286,10 +305,6 @@ func (h *GazelleHost) ApparentLoads(moduleToApparentName func(string) string) []
 	return h.gazelleLoadInfo
 }
 
-func (*GazelleHost) Fix(c *config.Config, f *rule.File) {
-	// Unsupported
-}
-
 // PluginRegisteredKinds returns plugin-registered kinds keyed by name,
 // excluding the seeded builtinKinds.
 func (h *GazelleHost) PluginRegisteredKinds() map[string]plugin.RuleKind {
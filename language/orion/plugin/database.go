@@ -1,6 +1,10 @@
 package plugin
 
-import "sync"
+import (
+	"maps"
+	"slices"
+	"sync"
+)
 
 // TODO: move to its own package
 
@@ -30,3 +34,27 @@ func (d *Database) LookupSymbols(id string) []TargetSymbol {
 
 	return d.symbols[id]
 }
+
+// AddSymbols adds symbols previously returned by PackageSymbols, such as from a cache.
+func (d *Database) AddSymbols(symbols []TargetSymbol) {
+	for _, s := range symbols {
+		d.AddSymbol(s.Label, s.Symbol)
+	}
+}
+
+// PackageSymbols returns all symbols registered with a label in the package of
+// the main repository.
+func (d *Database) PackageSymbols(repoName, pkg string) []TargetSymbol {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var symbols []TargetSymbol
+	for _, id := range slices.Sorted(maps.Keys(d.symbols)) {
+		for _, s := range d.symbols[id] {
+			if s.Label.Pkg == pkg && (s.Label.Repo == "" || s.Label.Repo == repoName) {
+				symbols = append(symbols, s)
+			}
+		}
+	}
+	return symbols
+}
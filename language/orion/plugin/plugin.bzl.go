@@ -1,6 +1,8 @@
 package plugin
 
 import (
+	"strconv"
+
 	"github.com/bazelbuild/bazel-gazelle/rule"
 	bzl "github.com/bazelbuild/buildtools/build"
 )
@@ -12,3 +14,68 @@ var _ rule.BzlExprValue = (*TargetSource)(nil)
 func (ts TargetSource) BzlExpr() bzl.Expr {
 	return &bzl.StringExpr{Value: ts.Path}
 }
+
+// ---------------- Rule
+
+// NewRules creates read-only views of the gazelle rules.
+func NewRules(rules []*rule.Rule) []Rule {
+	views := make([]Rule, 0, len(rules))
+	for _, r := range rules {
+		attrs := make(map[string]any, len(r.AttrKeys()))
+		for _, k := range r.AttrKeys() {
+			attrs[k] = ExprValue(r.Attr(k))
+		}
+
+		views = append(views, Rule{
+			Kind:  r.Kind(),
+			Name:  r.Name(),
+			Attrs: attrs,
+		})
+	}
+	return views
+}
+
+// ExprValue converts literal expressions to go values, other expressions to
+// their formatted source.
+func ExprValue(e bzl.Expr) any {
+	switch e := e.(type) {
+	case *bzl.StringExpr:
+		return e.Value
+	case *bzl.Ident:
+		switch e.Name {
+		case "True":
+			return true
+		case "False":
+			return false
+		case "None":
+			return nil
+		}
+	case *bzl.LiteralExpr:
+		if i, err := strconv.Atoi(e.Token); err == nil {
+			return i
+		}
+	case *bzl.ListExpr:
+		return exprValues(e.List)
+	case *bzl.TupleExpr:
+		return exprValues(e.List)
+	case *bzl.DictExpr:
+		m := make(map[string]any, len(e.List))
+		for _, kv := range e.List {
+			k, isString := kv.Key.(*bzl.StringExpr)
+			if !isString {
+				return bzl.FormatString(e)
+			}
+			m[k.Value] = ExprValue(kv.Value)
+		}
+		return m
+	}
+	return bzl.FormatString(e)
+}
+
+func exprValues(exprs []bzl.Expr) []any {
+	values := make([]any, 0, len(exprs))
+	for _, v := range exprs {
+		values = append(values, ExprValue(v))
+	}
+	return values
+}
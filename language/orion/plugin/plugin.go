@@ -3,10 +3,13 @@ package plugin
 import (
 	"encoding/gob"
 	"fmt"
+	"io"
 	"slices"
 	"strings"
 
 	common "github.com/aspect-build/aspect-gazelle/common"
+	"github.com/aspect-build/aspect-gazelle/common/bazel"
+	"github.com/aspect-build/aspect-gazelle/common/treesitter"
 )
 
 type PluginId = string
@@ -16,6 +19,13 @@ type PluginHost interface {
 	AddPlugin(plugin Plugin)
 }
 
+// OutputHost is a PluginHost receiving the output of its plugins, such as print(),
+// instead of it being written to stdout.
+type OutputHost interface {
+	PluginHost
+	Stdout() io.Writer
+}
+
 // TODO: change the interface into a factory method (at least in starzelle)
 type Plugin interface {
 	// Static plugin metadata
@@ -42,6 +52,10 @@ type RuleKind struct {
 	Name string
 	From string
 
+	// WorkspaceRepo is the repository of From when the root module has no
+	// bazel_dep() on the From module, such as a legacy WORKSPACE repository name.
+	WorkspaceRepo string
+
 	// RegisteredFrom is the orion plugin file that called gazelle_rule_kind.
 	RegisteredFrom string
 }
@@ -171,6 +185,27 @@ type PrepareContext struct {
 	// may be a plain filename or a `sub/dir/file` relative path resolved against
 	// this directory (ctx.has_file). Set by the host; nil if unavailable.
 	HasFile func(name string) bool
+
+	// ReadFile reads the file at a path relative to this directory, which must be
+	// within the workspace, parsed in the format of a raw, json, yaml or toml query
+	// (ctx.read_file, ctx.read_json, ...). Returns nil if the file does not exist.
+	// Set by the host; nil if unavailable.
+	ReadFile func(name string, format QueryType) (any, error)
+
+	// Repos resolves the apparent repository names of the root module (ctx.repos).
+	Repos RepoMapping
+
+	// Report reports a diagnostic of the plugin (aspect.diagnostic). Set by the
+	// host, which assigns the diagnostic code; nil if unavailable.
+	Report func(d common.Diagnostic)
+}
+
+// RepoMapping resolves repository names as visible to the root module, as
+// declared by bazel_dep() and use_repo() in MODULE.bazel.
+//
+// A nil ModuleFile, such as in a workspace without a MODULE.bazel, resolves nothing.
+type RepoMapping struct {
+	ModuleFile *bazel.ModuleFile
 }
 
 // The result of an extension preparing for generating targets.
@@ -193,6 +228,14 @@ type PrepareContext struct {
 type PrepareResult struct {
 	Sources map[string][]SourceFilter
 	Queries NamedQueries
+
+	// Grammars of files queried by an AstQuery, overriding the default file
+	// extension mapping. Patterns containing a `/` are relative to the prepared directory.
+	Grammars *treesitter.GrammarMapping
+
+	// How files with syntax errors when parsed for AstQuery queries are reported,
+	// ignored by default.
+	SyntaxErrors SyntaxErrorPolicy
 }
 
 type SourceFilter interface {
@@ -299,8 +342,14 @@ type TargetSourceList []TargetSource
 // query name to result.
 type DeclareTargetsContext struct {
 	PrepareContext
-	Sources  TargetSources
-	Targets  DeclareTargetActions
+	Sources TargetSources
+	Targets DeclareTargetActions
+
+	// The rules of the existing BUILD file, and those generated by the languages
+	// run before orion. Shared and read-only.
+	ExistingRules  []Rule
+	GeneratedRules []Rule
+
 	database *Database
 }
 
@@ -308,11 +357,13 @@ func (d DeclareTargetsContext) AddSymbol(label Label, symbol Symbol) {
 	d.database.AddSymbol(label, symbol)
 }
 
-func NewDeclareTargetsContext(prep PrepareContext, sources TargetSources, targets DeclareTargetActions, database *Database) DeclareTargetsContext {
+func NewDeclareTargetsContext(prep PrepareContext, sources TargetSources, targets DeclareTargetActions, existingRules, generatedRules []Rule, database *Database) DeclareTargetsContext {
 	return DeclareTargetsContext{
 		PrepareContext: prep,
 		Sources:        sources,
 		Targets:        targets,
+		ExistingRules:  existingRules,
+		GeneratedRules: generatedRules,
 		database:       database,
 	}
 }
@@ -354,9 +405,175 @@ type DeclareTargetsResult struct {
 	Actions []TargetAction
 }
 
+// An optional Plugin stage resolving the imports of the targets declared by the plugin.
+type ImportResolver interface {
+	Resolve(ctx ResolveContext) (ResolveResult, error)
+}
+
+// The context for an extension to resolve an import of one of its targets.
+type ResolveContext struct {
+	PrepareContext
+
+	// The target containing the import and the attribute the import is assigned to
+	Target    Label
+	Kind      string
+	Attribute string
+
+	Import TargetImport
+
+	// The labels providing the import according to the rule index and symbol
+	// database, excluding the target itself. Nearest ancestors first for Ancestor imports.
+	Candidates []Label
+
+	database *Database
+}
+
+// LookupSymbols returns all symbols registered with the given id.
+func (ctx ResolveContext) LookupSymbols(id string) []TargetSymbol {
+	return ctx.database.LookupSymbols(id)
+}
+
+func NewResolveContext(prep PrepareContext, target Label, kind, attr string, imp TargetImport, candidates []Label, database *Database) ResolveContext {
+	return ResolveContext{
+		PrepareContext: prep,
+		Target:         target,
+		Kind:           kind,
+		Attribute:      attr,
+		Import:         imp,
+		Candidates:     candidates,
+		database:       database,
+	}
+}
+
+// The result of an extension resolving an import.
+type ResolveResult struct {
+	// If the import was resolved by the plugin, otherwise the default resolution is used.
+	Resolved bool
+
+	// The labels the import resolves to, possibly none.
+	Labels []Label
+}
+
+// An import resolution failure reported by a plugin.
+type ResolveError struct {
+	Message string
+
+	// An optional suggestion to fix the error
+	Fix string
+}
+
+func (e *ResolveError) Error() string {
+	return e.Message
+}
+
+// An optional Plugin interface versioning the plugin implementation, such as a digest
+// of its source files. Results of a plugin persisted across runs are invalidated
+// when its version changes.
+type VersionedPlugin interface {
+	Version() string
+}
+
+// An optional Plugin stage migrating the rules of existing BUILD files before
+// targets are generated, such as renaming a macro or a deprecated attribute.
+type RuleFixer interface {
+	Fix(ctx FixContext) FixResult
+}
+
+// The context for an extension to fix the rules of an existing BUILD file.
+type FixContext struct {
+	PrepareContext
+
+	// The rules of the existing BUILD file, including the fixes of the plugins
+	// run before. Shared and read-only.
+	Rules []Rule
+	Fixes FixActions
+}
+
+func NewFixContext(prep PrepareContext, rules []Rule, fixes FixActions) FixContext {
+	return FixContext{
+		PrepareContext: prep,
+		Rules:          rules,
+		Fixes:          fixes,
+	}
+}
+
+type FixActions interface {
+	RenameKind(name, kind string)
+	RenameAttr(name, attr, to string)
+	DeleteAttr(name, attr string)
+	Delete(name string)
+	Actions() []FixAction
+}
+
+var _ FixActions = (*fixActionsImpl)(nil)
+
+type fixActionsImpl struct {
+	actions []FixAction
+}
+
+func NewFixActions() FixActions {
+	return &fixActionsImpl{
+		actions: []FixAction{},
+	}
+}
+func (f *fixActionsImpl) Actions() []FixAction {
+	return f.actions
+}
+func (f *fixActionsImpl) RenameKind(name, kind string) {
+	f.actions = append(f.actions, RenameKindAction{Name: name, Kind: kind})
+}
+func (f *fixActionsImpl) RenameAttr(name, attr, to string) {
+	f.actions = append(f.actions, RenameAttrAction{Name: name, Attr: attr, To: to})
+}
+func (f *fixActionsImpl) DeleteAttr(name, attr string) {
+	f.actions = append(f.actions, DeleteAttrAction{Name: name, Attr: attr})
+}
+func (f *fixActionsImpl) Delete(name string) {
+	f.actions = append(f.actions, DeleteRuleAction{Name: name})
+}
+
+// The result of fixing the rules of a BUILD file.
+type FixResult struct {
+	Actions []FixAction
+}
+
 type TargetSource struct {
 	Path         string
 	QueryResults QueryResults
+
+	// Syntax errors of the file when parsed for the plugin AstQuery queries
+	Errors SyntaxErrors
+}
+
+// A syntax error of a source file parsed by tree-sitter.
+type SyntaxError struct {
+	// The 1-based line and column of the error
+	Line, Column int
+
+	// The source line containing the error
+	Text string
+}
+
+type SyntaxErrors []SyntaxError
+
+// The QueryResults key of the SyntaxErrors of the file, not a valid "plugin|query" key.
+const SyntaxErrorsKey = "|syntax_errors"
+
+// How a plugin treats source files with syntax errors.
+type SyntaxErrorPolicy string
+
+const (
+	SyntaxErrorsIgnore SyntaxErrorPolicy = "ignore"
+	SyntaxErrorsWarn   SyntaxErrorPolicy = "warn"
+	SyntaxErrorsError  SyntaxErrorPolicy = "error"
+)
+
+func ParseSyntaxErrorPolicy(s string) (SyntaxErrorPolicy, error) {
+	switch p := SyntaxErrorPolicy(s); p {
+	case SyntaxErrorsIgnore, SyntaxErrorsWarn, SyntaxErrorsError:
+		return p, nil
+	}
+	return "", fmt.Errorf("invalid syntax error policy %q, expected %q, %q or %q", s, SyntaxErrorsIgnore, SyntaxErrorsWarn, SyntaxErrorsError)
 }
 
 func init() {
@@ -366,4 +583,5 @@ func init() {
 	gob.Register(QueryMatch{})
 	gob.Register(QueryCapture{})
 	gob.Register(QueryProcessorResult{})
+	gob.Register(SyntaxErrors{})
 }
@@ -153,6 +153,32 @@ var prepareContextHasFile = starlark.NewBuiltin("has_file", func(_ *starlark.Thr
 	return starlark.Bool(ctx.HasFile(name)), nil
 })
 
+// The ctx.read_* methods reading files in the format of a query type.
+var prepareContextReadFormats = map[string]QueryType{
+	"read_file": QueryTypeRaw,
+	"read_json": QueryTypeJson,
+	"read_yaml": QueryTypeYaml,
+	"read_toml": QueryTypeToml,
+}
+
+func prepareContextReadFile(format QueryType) func(*starlark.Thread, *starlark.Builtin, starlark.Tuple, []starlark.Tuple) (starlark.Value, error) {
+	return func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		var name string
+		if err := starlark.UnpackArgs(b.Name(), args, kwargs, "path", &name); err != nil {
+			return nil, err
+		}
+		ctx := b.Receiver().(PrepareContext)
+		if ctx.ReadFile == nil {
+			return nil, fmt.Errorf("%s is not available in this context", b.Name())
+		}
+		v, err := ctx.ReadFile(name, format)
+		if err != nil {
+			return nil, err
+		}
+		return starUtils.Write(v), nil
+	}
+}
+
 func (ctx PrepareContext) String() string {
 	return fmt.Sprintf("PrepareContext{repo_name: %q, rel: %q, properties: %v}", ctx.RepoName, ctx.Rel, ctx.Properties)
 }
@@ -180,12 +206,64 @@ func (ctx PrepareContext) Attr(name string) (starlark.Value, error) {
 		return ctx.Data, nil
 	case "has_file":
 		return prepareContextHasFile.BindReceiver(ctx), nil
+	case "read_file", "read_json", "read_yaml", "read_toml":
+		return starlark.NewBuiltin(name, prepareContextReadFile(prepareContextReadFormats[name])).BindReceiver(ctx), nil
+	case "repos":
+		return ctx.Repos, nil
 	}
 
 	return nil, fmt.Errorf("no such attribute: %s on %s", name, ctx.Type())
 }
 func (ctx PrepareContext) AttrNames() []string {
-	return []string{"repo_name", "rel", "properties", "data", "has_file"}
+	return []string{"repo_name", "rel", "properties", "data", "has_file", "read_file", "read_json", "read_yaml", "read_toml", "repos"}
+}
+
+// ---------------- RepoMapping
+
+var _ starlark.Value = (*RepoMapping)(nil)
+var _ starlark.HasAttrs = (*RepoMapping)(nil)
+
+func (r RepoMapping) String() string        { return "RepoMapping" }
+func (r RepoMapping) Type() string          { return "RepoMapping" }
+func (r RepoMapping) Freeze()               {}
+func (r RepoMapping) Truth() starlark.Bool  { return starlark.True }
+func (r RepoMapping) Hash() (uint32, error) { return 0, fmt.Errorf("unhashable: %s", r.Type()) }
+
+// A repository name or None if the repository is not visible to the root module.
+func repoNameOrNone(name string) starlark.Value {
+	if name == "" {
+		return starlark.None
+	}
+	return starlark.String(name)
+}
+
+var repoMappingApparentName = starlark.NewBuiltin("apparent_name", func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var name string
+	if err := starlark.UnpackArgs("apparent_name", args, kwargs, "name", &name); err != nil {
+		return nil, err
+	}
+	return repoNameOrNone(b.Receiver().(RepoMapping).ModuleFile.ApparentName(name)), nil
+})
+
+var repoMappingExtensionRepo = starlark.NewBuiltin("extension_repo", func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var extension, tag string
+	if err := starlark.UnpackArgs("extension_repo", args, kwargs, "extension", &extension, "tag", &tag); err != nil {
+		return nil, err
+	}
+	return repoNameOrNone(b.Receiver().(RepoMapping).ModuleFile.ExtensionRepo(extension, tag)), nil
+})
+
+func (r RepoMapping) Attr(name string) (starlark.Value, error) {
+	switch name {
+	case "apparent_name":
+		return repoMappingApparentName.BindReceiver(r), nil
+	case "extension_repo":
+		return repoMappingExtensionRepo.BindReceiver(r), nil
+	}
+	return nil, fmt.Errorf("no such attribute: %s on %s", name, r.Type())
+}
+func (r RepoMapping) AttrNames() []string {
+	return []string{"apparent_name", "extension_repo"}
 }
 
 // ---------------- DeclareTargetsContext
@@ -203,6 +281,10 @@ func (ctx DeclareTargetsContext) Attr(name string) (starlark.Value, error) {
 		return ctx.Targets.(*declareTargetActionsImpl), nil
 	case "add_symbol":
 		return contextAddSymbol.BindReceiver(ctx), nil
+	case "existing_rules":
+		return rulesTuple(ctx.ExistingRules), nil
+	case "generated_rules":
+		return rulesTuple(ctx.GeneratedRules), nil
 	}
 
 	return ctx.PrepareContext.Attr(name)
@@ -211,10 +293,105 @@ func (ctx DeclareTargetsContext) String() string {
 	return fmt.Sprintf("DeclareTargetsContext{PrepareContext: %v, sources: %v, targets: %v}", ctx.PrepareContext, ctx.Sources, ctx.Targets)
 }
 func (ctx DeclareTargetsContext) AttrNames() []string {
-	return append(ctx.PrepareContext.AttrNames(), "sources", "targets", "add_symbol")
+	return append(ctx.PrepareContext.AttrNames(), "sources", "targets", "add_symbol", "existing_rules", "generated_rules")
 }
 func (ctx DeclareTargetsContext) Type() string { return "DeclareTargetsContext" }
 
+// ---------------- FixContext
+
+var _ starlark.Value = (*FixContext)(nil)
+var _ starlark.HasAttrs = (*FixContext)(nil)
+
+func (ctx FixContext) Attr(name string) (starlark.Value, error) {
+	switch name {
+	case "rules":
+		return rulesTuple(ctx.Rules), nil
+	case "fixes":
+		return ctx.Fixes.(*fixActionsImpl), nil
+	}
+
+	return ctx.PrepareContext.Attr(name)
+}
+func (ctx FixContext) String() string {
+	return fmt.Sprintf("FixContext{PrepareContext: %v, rules: %v, fixes: %v}", ctx.PrepareContext, len(ctx.Rules), ctx.Fixes)
+}
+func (ctx FixContext) AttrNames() []string {
+	return append(ctx.PrepareContext.AttrNames(), "rules", "fixes")
+}
+func (ctx FixContext) Type() string { return "FixContext" }
+
+// ---------------- fixActionsImpl
+
+var _ starlark.Value = (*fixActionsImpl)(nil)
+var _ starlark.HasAttrs = (*fixActionsImpl)(nil)
+
+func (f *fixActionsImpl) String() string {
+	return fmt.Sprintf("fixActionsImpl{%v}", f.actions)
+}
+func (f *fixActionsImpl) Type() string         { return "fixActionsImpl" }
+func (f *fixActionsImpl) Freeze()              {}
+func (f *fixActionsImpl) Truth() starlark.Bool { return starlark.True }
+func (f *fixActionsImpl) Hash() (uint32, error) {
+	return 0, fmt.Errorf("unhashable: %s", f.Type())
+}
+func (f *fixActionsImpl) Attr(name string) (starlark.Value, error) {
+	switch name {
+	case "rename_kind":
+		return fixRenameKind.BindReceiver(f), nil
+	case "rename_attr":
+		return fixRenameAttr.BindReceiver(f), nil
+	case "delete_attr":
+		return fixDeleteAttr.BindReceiver(f), nil
+	case "delete":
+		return fixDelete.BindReceiver(f), nil
+	}
+
+	return nil, fmt.Errorf("no such attribute: %s on %s", name, f.Type())
+}
+func (*fixActionsImpl) AttrNames() []string {
+	return []string{"delete", "delete_attr", "rename_attr", "rename_kind"}
+}
+
+var fixRenameKind = starlark.NewBuiltin("rename_kind", func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var name, kind starlark.String
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "name", &name, "kind", &kind); err != nil {
+		return nil, err
+	}
+
+	b.Receiver().(*fixActionsImpl).RenameKind(name.GoString(), kind.GoString())
+	return starlark.None, nil
+})
+
+var fixRenameAttr = starlark.NewBuiltin("rename_attr", func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var name, attr, to starlark.String
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "name", &name, "attr", &attr, "to", &to); err != nil {
+		return nil, err
+	}
+
+	b.Receiver().(*fixActionsImpl).RenameAttr(name.GoString(), attr.GoString(), to.GoString())
+	return starlark.None, nil
+})
+
+var fixDeleteAttr = starlark.NewBuiltin("delete_attr", func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var name, attr starlark.String
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "name", &name, "attr", &attr); err != nil {
+		return nil, err
+	}
+
+	b.Receiver().(*fixActionsImpl).DeleteAttr(name.GoString(), attr.GoString())
+	return starlark.None, nil
+})
+
+var fixDelete = starlark.NewBuiltin("delete", func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var name starlark.String
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "name", &name); err != nil {
+		return nil, err
+	}
+
+	b.Receiver().(*fixActionsImpl).Delete(name.GoString())
+	return starlark.None, nil
+})
+
 // ---------------- TargetSourceList
 var _ starlark.Value = (*TargetSourceList)(nil)
 
@@ -430,12 +607,44 @@ func (ctx TargetSource) Attr(name string) (starlark.Value, error) {
 		return starlark.String(ctx.Path), nil
 	case "query_results":
 		return ctx.QueryResults, nil
+	case "errors":
+		return starUtils.WriteList(ctx.Errors, func(e SyntaxError) starlark.Value { return e }), nil
 	}
 
 	return nil, fmt.Errorf("no such attribute: %s on %s", name, ctx.Type())
 }
 func (ctx TargetSource) AttrNames() []string {
-	return []string{"path", "query_results"}
+	return []string{"path", "query_results", "errors"}
+}
+
+// ---------------- SyntaxError
+
+var _ starlark.Value = (*SyntaxError)(nil)
+var _ starlark.HasAttrs = (*SyntaxError)(nil)
+
+func (e SyntaxError) String() string {
+	return fmt.Sprintf("SyntaxError{line: %d, column: %d, text: %q}", e.Line, e.Column, e.Text)
+}
+func (e SyntaxError) Type() string         { return "SyntaxError" }
+func (e SyntaxError) Freeze()              {}
+func (e SyntaxError) Truth() starlark.Bool { return starlark.True }
+func (e SyntaxError) Hash() (uint32, error) {
+	return 0, fmt.Errorf("unhashable: %s", e.Type())
+}
+func (e SyntaxError) Attr(name string) (starlark.Value, error) {
+	switch name {
+	case "line":
+		return starlark.MakeInt(e.Line), nil
+	case "column":
+		return starlark.MakeInt(e.Column), nil
+	case "text":
+		return starlark.String(e.Text), nil
+	default:
+		return nil, starlark.NoSuchAttrError(name)
+	}
+}
+func (e SyntaxError) AttrNames() []string {
+	return []string{"line", "column", "text"}
 }
 
 // ---------------- Property
@@ -636,6 +845,80 @@ func addSymbol(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tupl
 	return starlark.None, nil
 }
 
+// ---------------- ResolveContext
+
+var _ starlark.Value = (*ResolveContext)(nil)
+var _ starlark.HasAttrs = (*ResolveContext)(nil)
+
+var resolveContextLookupSymbols = starlark.NewBuiltin("lookup_symbols", func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var id string
+	if err := starlark.UnpackArgs("lookup_symbols", args, kwargs, "id", &id); err != nil {
+		return nil, err
+	}
+
+	ctx := b.Receiver().(ResolveContext)
+	return starUtils.WriteList(ctx.LookupSymbols(id), func(s TargetSymbol) starlark.Value { return s }), nil
+})
+
+func (ctx ResolveContext) Attr(name string) (starlark.Value, error) {
+	switch name {
+	case "target":
+		return ctx.Target, nil
+	case "kind":
+		return starlark.String(ctx.Kind), nil
+	case "attr":
+		return starlark.String(ctx.Attribute), nil
+	case "imp":
+		return ctx.Import, nil
+	case "candidates":
+		return starUtils.WriteList(ctx.Candidates, func(l Label) starlark.Value { return l }), nil
+	case "lookup_symbols":
+		return resolveContextLookupSymbols.BindReceiver(ctx), nil
+	}
+	return ctx.PrepareContext.Attr(name)
+}
+
+func (ctx ResolveContext) AttrNames() []string {
+	return append(ctx.PrepareContext.AttrNames(), "target", "kind", "attr", "imp", "candidates", "lookup_symbols")
+}
+func (ctx ResolveContext) Freeze() {}
+func (ctx ResolveContext) Hash() (uint32, error) {
+	return 0, fmt.Errorf("unhashable: %s", ctx.Type())
+}
+func (ctx ResolveContext) String() string {
+	return fmt.Sprintf("ResolveContext{target: %v, attr: %q, imp: %v}", ctx.Target, ctx.Attribute, ctx.Import)
+}
+func (ctx ResolveContext) Truth() starlark.Bool { return starlark.True }
+func (ctx ResolveContext) Type() string         { return "ResolveContext" }
+
+// ---------------- ResolveError
+
+var _ starlark.Value = (*ResolveError)(nil)
+var _ starlark.HasAttrs = (*ResolveError)(nil)
+
+func (e *ResolveError) String() string {
+	return fmt.Sprintf("ResolveError{message: %q, fix: %q}", e.Message, e.Fix)
+}
+func (e *ResolveError) Type() string         { return "ResolveError" }
+func (e *ResolveError) Freeze()              {}
+func (e *ResolveError) Truth() starlark.Bool { return starlark.True }
+func (e *ResolveError) Hash() (uint32, error) {
+	return 0, fmt.Errorf("unhashable: %s", e.Type())
+}
+func (e *ResolveError) Attr(name string) (starlark.Value, error) {
+	switch name {
+	case "message":
+		return starlark.String(e.Message), nil
+	case "fix":
+		return starlark.String(e.Fix), nil
+	default:
+		return nil, starlark.NoSuchAttrError(name)
+	}
+}
+func (e *ResolveError) AttrNames() []string {
+	return []string{"message", "fix"}
+}
+
 // ---------------- Gazelle Label
 
 var _ starlark.Value = (*Label)(nil)
@@ -1,6 +1,7 @@
 package plugin
 
 import (
+	"fmt"
 	"regexp"
 
 	common "github.com/aspect-build/aspect-gazelle/common"
@@ -18,12 +19,16 @@ type QueryProcessorResult struct {
 type QueryType = string
 
 const (
-	QueryTypeAst   QueryType = "ast"
-	QueryTypeRegex QueryType = "regex"
-	QueryTypeJson  QueryType = "json"
-	QueryTypeYaml  QueryType = "yaml"
-	QueryTypeToml  QueryType = "toml"
-	QueryTypeRaw   QueryType = "raw"
+	QueryTypeAst      QueryType = "ast"
+	QueryTypeRegex    QueryType = "regex"
+	QueryTypeJson     QueryType = "json"
+	QueryTypeYaml     QueryType = "yaml"
+	QueryTypeToml     QueryType = "toml"
+	QueryTypeXml      QueryType = "xml"
+	QueryTypeProto    QueryType = "proto"
+	QueryTypeStarlark QueryType = "starlark"
+	QueryTypeKeyValue QueryType = "keyvalue"
+	QueryTypeRaw      QueryType = "raw"
 )
 
 // A query to run on source files.
@@ -68,10 +73,63 @@ type AstQuery struct {
 	QueryBase
 	Grammar string
 	Query   string
+
+	// The regions of an embedded language to query, nil to query the whole file.
+	Injection *Injection
 }
 
 func (AstQuery) QueryType() QueryType { return QueryTypeAst }
 
+// Locates the regions of an embedded language within a file, such as <script>
+// blocks of a Vue SFC or fenced code blocks in Markdown.
+//
+// Regions are located by either a tree-sitter query capturing `@injection.content`
+// or a regular expression.
+//
+// Create via NewInjection so the expression is parsed (and validated) once.
+type Injection struct {
+	// The grammar of the file to run Query on, empty for the grammar of the file.
+	Grammar string
+	Query   string
+
+	// A regular expression where the `content` named group, first group or
+	// whole match is a region.
+	Expression string
+
+	// The parsed Expression.
+	expressionRe *regexp.Regexp
+}
+
+func NewInjection(grammar, query, expression string) (*Injection, error) {
+	if (query == "") == (expression == "") {
+		return nil, fmt.Errorf("exactly one of an injection query or regex is required")
+	}
+
+	inj := &Injection{
+		Grammar:    grammar,
+		Query:      query,
+		Expression: expression,
+	}
+
+	if expression != "" {
+		re, err := common.ParseRegex(expression)
+		if err != nil {
+			return nil, err
+		}
+		inj.expressionRe = re
+	}
+
+	return inj, nil
+}
+
+// The parsed Expression, only available when created via NewInjection.
+func (inj *Injection) ExpressionRe() *regexp.Regexp { return inj.expressionRe }
+
+// Key uniquely identifies the regions located by the injection.
+func (inj *Injection) Key() string {
+	return inj.Grammar + "\x00" + inj.Query + "\x00" + inj.Expression
+}
+
 // A regular expression query on the source text.
 //
 // Create via NewRegexQuery so the expression is parsed (and validated) once.
@@ -125,6 +183,44 @@ type TomlQuery struct {
 
 func (TomlQuery) QueryType() QueryType { return QueryTypeToml }
 
+// An XPath 1.0 query on an XML document.
+type XmlQuery struct {
+	QueryBase
+	Query string
+
+	// The namespace URIs of the prefixes used in Query.
+	Namespaces map[string]string
+}
+
+func (XmlQuery) QueryType() QueryType { return QueryTypeXml }
+
+// A query returning the declarations of a .proto file.
+type ProtoQuery struct {
+	QueryBase
+}
+
+func (ProtoQuery) QueryType() QueryType { return QueryTypeProto }
+
+// A query returning the load() statements, functions and calls of a BUILD,
+// .bzl or MODULE.bazel file.
+type StarlarkQuery struct {
+	QueryBase
+}
+
+func (StarlarkQuery) QueryType() QueryType { return QueryTypeStarlark }
+
+// A path selection on a key/value document such as an INI, .properties or
+// dotenv file.
+type KeyValueQuery struct {
+	QueryBase
+	Query string
+
+	// The document format, or empty to infer it from the file name.
+	Format string
+}
+
+func (KeyValueQuery) QueryType() QueryType { return QueryTypeKeyValue }
+
 // A query returning the raw source text.
 type RawQuery struct {
 	QueryBase
@@ -145,6 +241,9 @@ type QueryCapture map[string]string
 type QueryMatch struct {
 	Result   any
 	Captures QueryCapture
+
+	// Properties set by tree-sitter #set! directives of the matching pattern
+	Metadata map[string]string
 }
 
 func NewQueryMatch(captures QueryCapture, result any) QueryMatch {
@@ -47,12 +47,14 @@ func (q *QueryMatch) Attr(name string) (starlark.Value, error) {
 		return starUtils.Write(q.Result), nil
 	case "captures":
 		return &q.Captures, nil
+	case "metadata":
+		return starUtils.WriteMap(q.Metadata, starUtils.WriteString), nil
 	default:
 		return nil, starlark.NoSuchAttrError(name)
 	}
 }
 func (q *QueryMatch) AttrNames() []string {
-	return []string{"result", "captures"}
+	return []string{"result", "captures", "metadata"}
 }
 
 func (q *QueryMatch) String() string {
@@ -138,6 +140,14 @@ var _ starlark.Value = (*YamlQuery)(nil)
 var _ starlark.HasAttrs = (*YamlQuery)(nil)
 var _ starlark.Value = (*TomlQuery)(nil)
 var _ starlark.HasAttrs = (*TomlQuery)(nil)
+var _ starlark.Value = (*XmlQuery)(nil)
+var _ starlark.HasAttrs = (*XmlQuery)(nil)
+var _ starlark.Value = (*ProtoQuery)(nil)
+var _ starlark.HasAttrs = (*ProtoQuery)(nil)
+var _ starlark.Value = (*StarlarkQuery)(nil)
+var _ starlark.HasAttrs = (*StarlarkQuery)(nil)
+var _ starlark.Value = (*KeyValueQuery)(nil)
+var _ starlark.HasAttrs = (*KeyValueQuery)(nil)
 var _ starlark.Value = (*RawQuery)(nil)
 var _ starlark.HasAttrs = (*RawQuery)(nil)
 
@@ -178,12 +188,45 @@ func (qd AstQuery) Attr(name string) (starlark.Value, error) {
 		return starlark.String(qd.Grammar), nil
 	case "query":
 		return starlark.String(qd.Query), nil
+	case "injection":
+		if qd.Injection == nil {
+			return starlark.None, nil
+		}
+		return qd.Injection, nil
 	default:
 		return qd.QueryBase.Attr(name)
 	}
 }
 func (qd AstQuery) AttrNames() []string {
-	return []string{"content_filter", "filter", "grammar", "query"}
+	return []string{"content_filter", "filter", "grammar", "injection", "query"}
+}
+
+// ---------------- Injection
+
+var _ starlark.Value = (*Injection)(nil)
+var _ starlark.HasAttrs = (*Injection)(nil)
+
+func (inj *Injection) String() string {
+	return fmt.Sprintf("Injection{grammar: %q, query: %q, regex: %q}", inj.Grammar, inj.Query, inj.Expression)
+}
+func (inj *Injection) Type() string          { return "Injection" }
+func (inj *Injection) Freeze()               {}
+func (inj *Injection) Truth() starlark.Bool  { return starlark.True }
+func (inj *Injection) Hash() (uint32, error) { return unhashable(inj) }
+func (inj *Injection) Attr(name string) (starlark.Value, error) {
+	switch name {
+	case "grammar":
+		return starlark.String(inj.Grammar), nil
+	case "query":
+		return starlark.String(inj.Query), nil
+	case "regex":
+		return starlark.String(inj.Expression), nil
+	default:
+		return nil, starlark.NoSuchAttrError(name)
+	}
+}
+func (inj *Injection) AttrNames() []string {
+	return []string{"grammar", "query", "regex"}
 }
 
 func (qd RegexQuery) Type() string          { return "RegexQuery" }
@@ -242,6 +285,44 @@ func (qd TomlQuery) AttrNames() []string {
 	return []string{"content_filter", "filter", "query"}
 }
 
+func (qd XmlQuery) Type() string          { return "XmlQuery" }
+func (qd XmlQuery) Hash() (uint32, error) { return unhashable(qd) }
+func (qd XmlQuery) Attr(name string) (starlark.Value, error) {
+	switch name {
+	case "query":
+		return starlark.String(qd.Query), nil
+	case "namespaces":
+		return starUtils.WriteMap(qd.Namespaces, starUtils.WriteString), nil
+	default:
+		return qd.QueryBase.Attr(name)
+	}
+}
+func (qd XmlQuery) AttrNames() []string {
+	return []string{"content_filter", "filter", "namespaces", "query"}
+}
+
+func (qd ProtoQuery) Type() string          { return "ProtoQuery" }
+func (qd ProtoQuery) Hash() (uint32, error) { return unhashable(qd) }
+
+func (qd StarlarkQuery) Type() string          { return "StarlarkQuery" }
+func (qd StarlarkQuery) Hash() (uint32, error) { return unhashable(qd) }
+
+func (qd KeyValueQuery) Type() string          { return "KeyValueQuery" }
+func (qd KeyValueQuery) Hash() (uint32, error) { return unhashable(qd) }
+func (qd KeyValueQuery) Attr(name string) (starlark.Value, error) {
+	switch name {
+	case "query":
+		return starlark.String(qd.Query), nil
+	case "format":
+		return starlark.String(qd.Format), nil
+	default:
+		return qd.QueryBase.Attr(name)
+	}
+}
+func (qd KeyValueQuery) AttrNames() []string {
+	return []string{"content_filter", "filter", "format", "query"}
+}
+
 func (qd RawQuery) Type() string          { return "RawQuery" }
 func (qd RawQuery) Hash() (uint32, error) { return unhashable(qd) }
 
@@ -45,6 +45,21 @@ type TargetDeclaration struct {
 	Symbols []Symbol
 }
 
+// A select() of list attribute values by condition label, such as platform specific
+// dependencies. Values are strings, labels or imports.
+type Select struct {
+	// Values common to all conditions, such as `[...] + select({...})`
+	Values []any
+
+	Conditions map[string][]any
+}
+
+// A glob() of file patterns relative to the BUILD file.
+type Glob struct {
+	Include []string
+	Exclude []string
+}
+
 type TargetAction any
 
 type AddTargetAction struct {
@@ -55,3 +70,37 @@ type RemoveTargetAction struct {
 	Name string
 	Kind string
 }
+
+// An edit of an existing rule, applied in the fix stage.
+type FixAction any
+
+type RenameKindAction struct {
+	Name string
+	Kind string
+}
+
+type RenameAttrAction struct {
+	Name string
+	Attr string
+	To   string
+}
+
+type DeleteAttrAction struct {
+	Name string
+	Attr string
+}
+
+type DeleteRuleAction struct {
+	Name string
+}
+
+// A read-only view of a rule in a BUILD file, either existing in the BUILD file
+// or generated by another language.
+type Rule struct {
+	Kind string
+	Name string
+
+	// Attribute values as strings, bools, ints, lists and dicts. Other
+	// expressions such as select() or glob() are the formatted expression.
+	Attrs map[string]any
+}
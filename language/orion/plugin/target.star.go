@@ -2,10 +2,12 @@ package plugin
 
 import (
 	"fmt"
+	"slices"
 
 	starUtils "github.com/aspect-build/aspect-gazelle/language/orion/starlark/utils"
 
 	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
 )
 
 // ---------------- Symbol
@@ -105,6 +107,165 @@ func (te TargetSymbol) AttrNames() []string {
 	return []string{"id", "provider", "label"}
 }
 
+// ---------------- Select
+
+var _ starlark.Value = (*Select)(nil)
+var _ starlark.HasAttrs = (*Select)(nil)
+var _ starlark.HasBinary = (*Select)(nil)
+
+func (s Select) String() string {
+	return fmt.Sprintf("Select{values: %v, conditions: %v}", s.Values, s.Conditions)
+}
+func (s Select) Type() string         { return "Select" }
+func (s Select) Freeze()              {}
+func (s Select) Truth() starlark.Bool { return starlark.True }
+func (s Select) Hash() (uint32, error) {
+	return 0, fmt.Errorf("unhashable: %s", s.Type())
+}
+
+func (s Select) Attr(name string) (starlark.Value, error) {
+	switch name {
+	case "values":
+		return starUtils.Write(s.Values), nil
+	case "conditions":
+		return starUtils.WriteMap(s.Conditions, func(v []any) starlark.Value { return starUtils.Write(v) }), nil
+	}
+
+	return nil, fmt.Errorf("no such attribute: %s on %s", name, s.Type())
+}
+func (s Select) AttrNames() []string {
+	return []string{"values", "conditions"}
+}
+
+// Binary supports `[...] + Select(...)` and `Select(...) + [...]` adding values
+// common to all conditions.
+func (s Select) Binary(op syntax.Token, y starlark.Value, side starlark.Side) (starlark.Value, error) {
+	if op != syntax.PLUS {
+		return nil, nil
+	}
+
+	l, isList := y.(*starlark.List)
+	if !isList {
+		return nil, nil
+	}
+
+	values, err := ReadSelectValues(l)
+	if err != nil {
+		return nil, err
+	}
+
+	if side == starlark.Left {
+		values = append(slices.Clone(s.Values), values...)
+	} else {
+		values = append(values, s.Values...)
+	}
+
+	return Select{
+		Values:     values,
+		Conditions: s.Conditions,
+	}, nil
+}
+
+// ReadSelectValues reads a list of select() values: strings, labels or imports.
+func ReadSelectValues(v starlark.Value) ([]any, error) {
+	return starUtils.ReadList(v, func(v starlark.Value) (any, error) {
+		switch v := v.(type) {
+		case TargetImport:
+			return v, nil
+		case Label:
+			return v, nil
+		case starlark.String:
+			return v.GoString(), nil
+		}
+		return nil, fmt.Errorf("expected string, Label or Import in select() values, got %s", v.Type())
+	})
+}
+
+// ---------------- Glob
+
+var _ starlark.Value = (*Glob)(nil)
+var _ starlark.HasAttrs = (*Glob)(nil)
+
+func (g Glob) String() string {
+	return fmt.Sprintf("Glob{include: %v, exclude: %v}", g.Include, g.Exclude)
+}
+func (g Glob) Type() string         { return "Glob" }
+func (g Glob) Freeze()              {}
+func (g Glob) Truth() starlark.Bool { return starlark.True }
+func (g Glob) Hash() (uint32, error) {
+	return 0, fmt.Errorf("unhashable: %s", g.Type())
+}
+
+func (g Glob) Attr(name string) (starlark.Value, error) {
+	switch name {
+	case "include":
+		return starUtils.WriteList(g.Include, starUtils.WriteString), nil
+	case "exclude":
+		return starUtils.WriteList(g.Exclude, starUtils.WriteString), nil
+	}
+
+	return nil, fmt.Errorf("no such attribute: %s on %s", name, g.Type())
+}
+func (g Glob) AttrNames() []string {
+	return []string{"include", "exclude"}
+}
+
+// ---------------- Rule
+
+var _ starlark.Value = (*Rule)(nil)
+var _ starlark.HasAttrs = (*Rule)(nil)
+
+var ruleAttr = starlark.NewBuiltin("attr", func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var name string
+	var fallback starlark.Value = starlark.None
+	if err := starlark.UnpackArgs("attr", args, kwargs, "name", &name, "default?", &fallback); err != nil {
+		return nil, err
+	}
+
+	r := b.Receiver().(Rule)
+	if v, found := r.Attrs[name]; found {
+		return starUtils.Write(v), nil
+	}
+	return fallback, nil
+})
+
+func (r Rule) String() string {
+	return fmt.Sprintf("Rule{kind: %q, name: %q}", r.Kind, r.Name)
+}
+func (r Rule) Type() string         { return "Rule" }
+func (r Rule) Freeze()              {}
+func (r Rule) Truth() starlark.Bool { return starlark.True }
+func (r Rule) Hash() (uint32, error) {
+	return 0, fmt.Errorf("unhashable: %s", r.Type())
+}
+
+func (r Rule) Attr(name string) (starlark.Value, error) {
+	switch name {
+	case "kind":
+		return starlark.String(r.Kind), nil
+	case "name":
+		return starlark.String(r.Name), nil
+	case "attrs":
+		return starUtils.WriteMap(r.Attrs, starUtils.Write), nil
+	case "attr":
+		return ruleAttr.BindReceiver(r), nil
+	}
+
+	return nil, fmt.Errorf("no such attribute: %s on %s", name, r.Type())
+}
+func (r Rule) AttrNames() []string {
+	return []string{"kind", "name", "attrs", "attr"}
+}
+
+// rulesTuple returns the rules as an immutable starlark tuple.
+func rulesTuple(rules []Rule) starlark.Tuple {
+	t := make(starlark.Tuple, 0, len(rules))
+	for _, r := range rules {
+		t = append(t, r)
+	}
+	return t
+}
+
 // ---------------- utils
 
 func readSymbol(v starlark.Value) (Symbol, error) {
@@ -124,6 +285,10 @@ func readTargetAttributeValue(v starlark.Value) (any, error) {
 		return v, nil
 	case TargetSource:
 		return v, nil
+	case Select:
+		return v, nil
+	case Glob:
+		return v, nil
 	}
 
 	return starUtils.ReadRecurse(v, readTargetAttributeValue)
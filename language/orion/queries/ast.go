@@ -1,103 +1,256 @@
 package queries
 
 import (
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+
 	BazelLog "github.com/aspect-build/aspect-gazelle/common/logger"
 	"github.com/aspect-build/aspect-gazelle/common/treesitter"
 	treeutils "github.com/aspect-build/aspect-gazelle/common/treesitter"
 	"github.com/aspect-build/aspect-gazelle/language/orion/plugin"
+	"github.com/aspect-build/aspect-gazelle/treesitter/bash"
+	"github.com/aspect-build/aspect-gazelle/treesitter/c"
+	"github.com/aspect-build/aspect-gazelle/treesitter/cpp"
+	"github.com/aspect-build/aspect-gazelle/treesitter/csharp"
 	"github.com/aspect-build/aspect-gazelle/treesitter/golang"
 	"github.com/aspect-build/aspect-gazelle/treesitter/hcl"
 	"github.com/aspect-build/aspect-gazelle/treesitter/java"
 	"github.com/aspect-build/aspect-gazelle/treesitter/json"
 	"github.com/aspect-build/aspect-gazelle/treesitter/kotlin"
+	"github.com/aspect-build/aspect-gazelle/treesitter/protobuf"
 	"github.com/aspect-build/aspect-gazelle/treesitter/python"
 	"github.com/aspect-build/aspect-gazelle/treesitter/ruby"
 	"github.com/aspect-build/aspect-gazelle/treesitter/rust"
+	"github.com/aspect-build/aspect-gazelle/treesitter/scala"
 	"github.com/aspect-build/aspect-gazelle/treesitter/starlark"
+	"github.com/aspect-build/aspect-gazelle/treesitter/swift"
 	"github.com/aspect-build/aspect-gazelle/treesitter/tsx"
 	"github.com/aspect-build/aspect-gazelle/treesitter/typescript"
 )
 
-func runPluginTreeQueries(fileName string, sourceCode []byte, queries plugin.NamedQueries) (plugin.QueryResults, error) {
-	lang := toTreeLanguage(fileName, queries)
+func runPluginTreeQueries(fileName string, grammar treesitter.LanguageGrammar, sourceCode []byte, queries plugin.NamedQueries) (plugin.QueryResults, error) {
+	// Queries on the whole file, and on the regions of each embedded language
+	fileQueries := make(plugin.NamedQueries, len(queries))
+	injections := make(map[string]*plugin.Injection)
+	injectionQueries := make(map[string]plugin.NamedQueries)
+	for key, query := range queries {
+		inj := query.(*plugin.AstQuery).Injection
+		if inj == nil {
+			fileQueries[key] = query
+			continue
+		}
+
+		// Regions differing only by the embedded language are parsed separately
+		injKey := query.(*plugin.AstQuery).Grammar + "\x00" + inj.Key()
+		if injectionQueries[injKey] == nil {
+			injections[injKey] = inj
+			injectionQueries[injKey] = make(plugin.NamedQueries)
+		}
+		injectionQueries[injKey][key] = query
+	}
+
+	results := make(plugin.QueryResults, len(queries))
+
+	if len(fileQueries) > 0 {
+		lang, err := toTreeLanguage(fileName, grammar, fileQueries)
+		if err != nil {
+			return nil, err
+		}
+
+		ast, err := treeutils.ParseSourceCode(lang, fileName, sourceCode)
+		if err != nil {
+			return nil, err
+		}
+		defer ast.Close()
+
+		if err := queryTree(fileName, lang, ast, fileQueries, results); err != nil {
+			return nil, err
+		}
+	}
+
+	for injKey, injQueries := range injectionQueries {
+		if err := runInjectionQueries(fileName, grammar, sourceCode, injections[injKey], injQueries, results); err != nil {
+			return nil, err
+		}
+	}
+
+	return results, nil
+}
+
+// runInjectionQueries runs the queries on the regions of an embedded language.
+func runInjectionQueries(fileName string, grammar treesitter.LanguageGrammar, sourceCode []byte, inj *plugin.Injection, queries plugin.NamedQueries, results plugin.QueryResults) error {
+	ranges, err := injectionRanges(fileName, grammar, sourceCode, inj)
+	if err != nil {
+		return err
+	}
+
+	// No regions of the embedded language
+	if len(ranges) == 0 {
+		for key := range queries {
+			results[key] = plugin.QueryMatches(nil)
+		}
+		return nil
+	}
+
+	// The grammar of the embedded language, required for all injection queries
+	lang, err := toTreeLanguage(fileName, "", queries)
+	if err != nil {
+		return err
+	}
+
+	ast, err := treeutils.ParseSourceRanges(lang, fileName, sourceCode, ranges)
+	if err != nil {
+		return err
+	}
+	defer ast.Close()
+
+	return queryTree(fileName, lang, ast, queries, results)
+}
+
+// injectionRanges locates the regions of an embedded language within the file.
+func injectionRanges(fileName string, grammar treesitter.LanguageGrammar, sourceCode []byte, inj *plugin.Injection) ([]treesitter.Range, error) {
+	if inj.Query == "" {
+		return treeutils.RegexRanges(inj.ExpressionRe(), sourceCode), nil
+	}
+
+	hostGrammar := treesitter.LanguageGrammar(inj.Grammar)
+	if hostGrammar == "" {
+		hostGrammar = grammar
+	}
+
+	lang, err := toTreeLanguage(fileName, hostGrammar, nil)
+	if err != nil {
+		return nil, err
+	}
+
 	ast, err := treeutils.ParseSourceCode(lang, fileName, sourceCode)
 	if err != nil {
 		return nil, err
 	}
 	defer ast.Close()
 
-	// Parse errors. Only log them due to many false positives.
-	// TODO: what false positives? See js plugin where this is from
-	if BazelLog.IsTraceEnabled() {
-		treeErrors := ast.QueryErrors()
-		if treeErrors != nil {
-			BazelLog.Tracef("TreeSitter query errors: %v", treeErrors)
+	treeQuery, err := treeutils.GetQuery(lang, inj.Query)
+	if err != nil {
+		return nil, fmt.Errorf("injection query: %w", err)
+	}
+
+	return slices.Collect(ast.CaptureRanges(treeQuery, treeutils.InjectionContentCapture)), nil
+}
+
+// queryTree runs the queries on the AST, adding the matches to results.
+func queryTree(fileName string, lang treesitter.Language, ast treesitter.AST, queries plugin.NamedQueries, results plugin.QueryResults) error {
+	// Parse errors, reported according to the policy of each plugin.
+	if treeErrors := ast.QueryErrors(); treeErrors != nil {
+		if queryLog.IsTraceEnabled() {
+			queryLog.With(BazelLog.Fields{File: fileName}).Tracef("TreeSitter query errors: %v", treeErrors)
+		}
+
+		syntaxErrors, _ := results[plugin.SyntaxErrorsKey].(plugin.SyntaxErrors)
+		for _, treeErr := range treeErrors {
+			var syntaxErr *treesitter.SyntaxError
+			if errors.As(treeErr, &syntaxErr) {
+				syntaxErrors = append(syntaxErrors, plugin.SyntaxError{
+					Line:   syntaxErr.Line,
+					Column: syntaxErr.Column,
+					Text:   syntaxErr.SourceLine,
+				})
+			}
 		}
+		results[plugin.SyntaxErrorsKey] = syntaxErrors
 	}
 
 	// Queries must run sequentially on the same AST because go-tree-sitter's
 	// Tree.cachedNode uses a plain map that is not safe for concurrent access.
 	// The unsafe write happens inside QueryCursor.NextMatch (it caches a *Node
 	// per capture), so deferring capture collection would not make it safe.
-	results := make(plugin.QueryResults, len(queries))
 	for key, query := range queries {
 		treeQuery, err := treeutils.GetQuery(lang, query.(*plugin.AstQuery).Query)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
 		matches := plugin.QueryMatches(nil)
-		for captures := range ast.Query(treeQuery) {
-			matches = append(matches, plugin.NewQueryMatch(captures, nil))
+		for m := range ast.QueryMatches(treeQuery) {
+			match := plugin.NewQueryMatch(m.Captures, nil)
+			match.Metadata = m.Metadata
+			matches = append(matches, match)
 		}
 
 		results[key] = matches
 	}
 
-	return results, nil
+	return nil
 }
 
-func toTreeLanguage(fileName string, queries plugin.NamedQueries) treesitter.Language {
-	lang := toTreeGrammar(fileName, queries)
+func toTreeLanguage(fileName string, grammar treesitter.LanguageGrammar, queries plugin.NamedQueries) (treesitter.Language, error) {
+	lang, err := toTreeGrammar(fileName, grammar, queries)
+	if err != nil {
+		return nil, err
+	}
 
 	switch lang {
+	case treesitter.Bash:
+		return treesitter.NewLanguage(treesitter.Bash, bash.LanguagePtr()), nil
+	case treesitter.C:
+		return treesitter.NewLanguage(treesitter.C, c.LanguagePtr()), nil
+	case treesitter.Cpp:
+		return treesitter.NewLanguage(treesitter.Cpp, cpp.LanguagePtr()), nil
+	case treesitter.CSharp:
+		return treesitter.NewLanguage(treesitter.CSharp, csharp.LanguagePtr()), nil
 	case treesitter.Go:
-		return treesitter.NewLanguage(treesitter.Go, golang.LanguagePtr())
+		return treesitter.NewLanguage(treesitter.Go, golang.LanguagePtr()), nil
 	case treesitter.HCL:
-		return treesitter.NewLanguage(treesitter.HCL, hcl.LanguagePtr())
+		return treesitter.NewLanguage(treesitter.HCL, hcl.LanguagePtr()), nil
 	case treesitter.Java:
-		return treesitter.NewLanguage(treesitter.Java, java.LanguagePtr())
+		return treesitter.NewLanguage(treesitter.Java, java.LanguagePtr()), nil
 	case treesitter.JSON:
-		return treesitter.NewLanguage(treesitter.JSON, json.LanguagePtr())
+		return treesitter.NewLanguage(treesitter.JSON, json.LanguagePtr()), nil
 	case treesitter.Kotlin:
-		return treesitter.NewLanguage(treesitter.Kotlin, kotlin.LanguagePtr())
+		return treesitter.NewLanguage(treesitter.Kotlin, kotlin.LanguagePtr()), nil
+	case treesitter.Protobuf:
+		return treesitter.NewLanguage(treesitter.Protobuf, protobuf.LanguagePtr()), nil
 	case treesitter.Python:
-		return treesitter.NewLanguage(treesitter.Python, python.LanguagePtr())
+		return treesitter.NewLanguage(treesitter.Python, python.LanguagePtr()), nil
 	case treesitter.Ruby:
-		return treesitter.NewLanguage(treesitter.Ruby, ruby.LanguagePtr())
+		return treesitter.NewLanguage(treesitter.Ruby, ruby.LanguagePtr()), nil
 	case treesitter.Rust:
-		return treesitter.NewLanguage(treesitter.Rust, rust.LanguagePtr())
+		return treesitter.NewLanguage(treesitter.Rust, rust.LanguagePtr()), nil
+	case treesitter.Scala:
+		return treesitter.NewLanguage(treesitter.Scala, scala.LanguagePtr()), nil
 	case treesitter.Starlark:
-		return treesitter.NewLanguage(treesitter.Starlark, starlark.LanguagePtr())
+		return treesitter.NewLanguage(treesitter.Starlark, starlark.LanguagePtr()), nil
+	case treesitter.Swift:
+		return treesitter.NewLanguage(treesitter.Swift, swift.LanguagePtr()), nil
 	case treesitter.Typescript:
-		return treesitter.NewLanguage(treesitter.Typescript, typescript.LanguagePtr())
+		return treesitter.NewLanguage(treesitter.Typescript, typescript.LanguagePtr()), nil
 	case treesitter.TypescriptX:
-		return treesitter.NewLanguage(treesitter.TypescriptX, tsx.LanguagePtr())
+		return treesitter.NewLanguage(treesitter.TypescriptX, tsx.LanguagePtr()), nil
 	}
 
-	BazelLog.Fatalf("Unknown LanguageGrammar %q", lang)
-	return nil
+	return nil, fmt.Errorf("unknown grammar %q", lang)
 }
 
-func toTreeGrammar(fileName string, queries plugin.NamedQueries) treeutils.LanguageGrammar {
+// toTreeGrammar returns the grammar to parse the file as: an explicit AstQuery
+// grammar, otherwise the configured grammar or the file extension default.
+func toTreeGrammar(fileName string, grammar treesitter.LanguageGrammar, queries plugin.NamedQueries) (treeutils.LanguageGrammar, error) {
 	// TODO: fail if queries on the same file use different languages?
 
 	for _, q := range queries {
-		grammar := q.(*plugin.AstQuery).Grammar
-		if grammar != "" {
-			return treeutils.LanguageGrammar(grammar)
+		queryGrammar := q.(*plugin.AstQuery).Grammar
+		if queryGrammar != "" {
+			return treeutils.LanguageGrammar(queryGrammar), nil
 		}
 	}
 
-	return treeutils.PathToLanguage(fileName)
+	if grammar != "" {
+		return grammar, nil
+	}
+
+	if lang, found := treeutils.LookupPathLanguage(fileName); found {
+		return lang, nil
+	}
+
+	return "", fmt.Errorf("no tree-sitter grammar for file extension %q", path.Ext(fileName))
 }
@@ -0,0 +1,52 @@
+package queries
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/goexlib/jsonc"
+	"github.com/mikefarah/yq/v4/pkg/yqlib"
+
+	"github.com/aspect-build/aspect-gazelle/language/orion/plugin"
+)
+
+// ParseFile parses the content of a file in the format of a query type,
+// returning the entire document as go values or the raw content as a string.
+func ParseFile(fileName string, format plugin.QueryType, content []byte) (any, error) {
+	switch format {
+	case plugin.QueryTypeRaw:
+		return string(content), nil
+	case plugin.QueryTypeJson:
+		var doc any
+		if err := json.Unmarshal(jsonc.Strip(content), &doc); err != nil {
+			return nil, fmt.Errorf("failed to parse JSON file %q: %w", fileName, err)
+		}
+		return doc, nil
+	case plugin.QueryTypeYaml:
+		return decodeYqFile(fileName, yqlib.NewYamlDecoder(yqlib.ConfiguredYamlPreferences), content)
+	case plugin.QueryTypeToml:
+		return decodeYqFile(fileName, yqlib.NewTomlDecoder(), content)
+	default:
+		return nil, fmt.Errorf("unsupported file format %q", format)
+	}
+}
+
+func decodeYqFile(fileName string, decoder yqlib.Decoder, content []byte) (any, error) {
+	if err := decoder.Init(bytes.NewReader(content)); err != nil {
+		return nil, err
+	}
+
+	node, err := decoder.Decode()
+	if errors.Is(err, io.EOF) {
+		// An empty document
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse file %q: %w", fileName, err)
+	}
+
+	return convertYqNodeToValue(node), nil
+}
@@ -7,7 +7,6 @@ import (
 	"github.com/goexlib/jsonc"
 	"github.com/itchyny/gojq"
 
-	BazelLog "github.com/aspect-build/aspect-gazelle/common/logger"
 	"github.com/aspect-build/aspect-gazelle/language/orion/plugin"
 )
 
@@ -16,7 +15,7 @@ func runJsonQueries(fileName string, sourceCode []byte, queries plugin.NamedQuer
 	// no results rather than aborting the run.
 	var doc interface{}
 	if err := json.Unmarshal(jsonc.Strip(sourceCode), &doc); err != nil {
-		BazelLog.Warnf("ignoring unparseable JSON file %q: %v", fileName, err)
+		queryLog.Warnf("ignoring unparseable JSON file %q: %v", fileName, err)
 	}
 
 	results := make(plugin.QueryResults, len(queries))
@@ -0,0 +1,39 @@
+package queries
+
+import (
+	"github.com/aspect-build/aspect-gazelle/language/orion/plugin"
+	"github.com/aspect-build/aspect-gazelle/language/orion/queries/keyvalue"
+)
+
+func runKeyValueQueries(fileName string, sourceCode []byte, queries plugin.NamedQueries) (plugin.QueryResults, error) {
+	// Documents by format, queries of a file may parse it as different formats.
+	docs := make(map[keyvalue.Format]map[string]any, 1)
+
+	results := make(plugin.QueryResults, len(queries))
+	for key, q := range queries {
+		kvq := q.(*plugin.KeyValueQuery)
+
+		format := kvq.Format
+		if format == "" {
+			format = keyvalue.FormatOf(fileName)
+		}
+
+		doc, parsed := docs[format]
+		if !parsed {
+			var err error
+			if doc, err = keyvalue.Parse(format, sourceCode); err != nil {
+				queryLog.Warnf("ignoring unparseable %s file %q: %v", format, fileName, err)
+			}
+			docs[format] = doc
+		}
+
+		// Like yq a list of the single match, or empty if not found.
+		results[key] = make([]interface{}, 0, 1)
+		if doc != nil {
+			if v, found := keyvalue.Select(doc, kvq.Query); found {
+				results[key] = []interface{}{v}
+			}
+		}
+	}
+	return results, nil
+}
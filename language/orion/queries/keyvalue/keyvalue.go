@@ -0,0 +1,86 @@
+// Package keyvalue parses key/value documents such as INI, Java .properties
+// and dotenv files into maps.
+package keyvalue
+
+import (
+	"path"
+	"strings"
+
+	"github.com/mikefarah/yq/v4/pkg/yqlib"
+)
+
+type Format = string
+
+const (
+	// INI files such as setup.cfg, tox.ini or .editorconfig, keys before the
+	// first section are at the root of the document and sections are nested maps.
+	FormatIni Format = "ini"
+
+	// Java .properties files such as Spring or Gradle configuration.
+	FormatProperties Format = "properties"
+
+	// Environment files such as .env or .env.local.
+	FormatEnv Format = "env"
+)
+
+var Formats = []Format{FormatIni, FormatProperties, FormatEnv}
+
+// FormatOf returns the format of a file based on its name, INI if unknown.
+func FormatOf(fileName string) Format {
+	base := path.Base(fileName)
+	switch {
+	case strings.HasSuffix(base, ".properties"):
+		return FormatProperties
+	case base == ".env" || strings.HasPrefix(base, ".env.") || strings.HasSuffix(base, ".env"):
+		return FormatEnv
+	default:
+		return FormatIni
+	}
+}
+
+// Parse parses the document in the format to a map of string values, or of
+// nested maps for INI sections and dot separated .properties keys.
+//
+// INI and .properties documents are decoded by yq, dotenv documents are parsed
+// as they are not supported by yq.
+func Parse(format Format, src []byte) (map[string]any, error) {
+	switch format {
+	case FormatProperties:
+		return decodeYq(yqlib.NewPropertiesDecoder(), src)
+	case FormatEnv:
+		return parseEnv(string(src))
+	default:
+		return decodeYq(yqlib.NewINIDecoder(yqlib.ConfiguredINIPreferences), src)
+	}
+}
+
+// Select returns the value at the dot separated path within the document, or
+// the document itself for an empty path.
+//
+// Keys may contain dots such as "spring.datasource.url" or "*.py", the longest
+// key matching the start of the path is selected at each level.
+func Select(doc map[string]any, p string) (any, bool) {
+	p = strings.TrimPrefix(p, ".")
+	if p == "" {
+		return doc, true
+	}
+
+	if v, found := doc[p]; found {
+		return v, true
+	}
+
+	for i := len(p) - 1; i > 0; i-- {
+		if p[i] != '.' {
+			continue
+		}
+		section, isSection := doc[p[:i]].(map[string]any)
+		if !isSection {
+			continue
+		}
+		if v, found := Select(section, p[i+1:]); found {
+			return v, true
+		}
+	}
+
+	return nil, false
+}
@@ -0,0 +1,174 @@
+package keyvalue
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFormatOf(t *testing.T) {
+	for fileName, expected := range map[string]Format{
+		"setup.cfg":                  FormatIni,
+		"a/tox.ini":                  FormatIni,
+		".editorconfig":              FormatIni,
+		"src/application.properties": FormatProperties,
+		".env":                       FormatEnv,
+		"app/.env.local":             FormatEnv,
+		"prod.env":                   FormatEnv,
+		"environment.txt":            FormatIni,
+	} {
+		if actual := FormatOf(fileName); actual != expected {
+			t.Errorf("FormatOf(%q) = %q, expected %q", fileName, actual, expected)
+		}
+	}
+}
+
+func TestParseIni(t *testing.T) {
+	doc, err := Parse(FormatIni, []byte(`root = true
+; a comment
+
+[metadata]
+name = my-package
+version: 1.0
+
+[options]
+python_requires = >=3.8
+
+[*.{js,py}]
+indent_style = space
+
+[metadata]
+license = MIT
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]any{
+		"root": "true",
+		"metadata": map[string]any{
+			"name":    "my-package",
+			"version": "1.0",
+			"license": "MIT",
+		},
+		"options": map[string]any{
+			"python_requires": ">=3.8",
+		},
+		"*.{js,py}": map[string]any{
+			"indent_style": "space",
+		},
+	}
+	if !reflect.DeepEqual(doc, expected) {
+		t.Errorf("unexpected ini document: %v", doc)
+	}
+
+	if _, err := Parse(FormatIni, []byte("[broken\nkey = value")); err == nil {
+		t.Error("expected an unterminated section error")
+	}
+}
+
+func TestParseProperties(t *testing.T) {
+	doc, err := Parse(FormatProperties, []byte(`# comment
+! another comment
+spring.datasource.url=jdbc:h2:mem:test
+server.port : 8080
+name  value with spaces
+escaped\ key\:x = tab\there
+unicode=café
+multi = one, \
+        two, \
+        three
+empty
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Dot separated keys are nested maps
+	expected := map[string]any{
+		"spring": map[string]any{
+			"datasource": map[string]any{
+				"url": "jdbc:h2:mem:test",
+			},
+		},
+		"server": map[string]any{
+			"port": "8080",
+		},
+		"name":          "value with spaces",
+		"escaped key:x": "tab\there",
+		"unicode":       "café",
+		"multi":         "one, two, three",
+		"empty":         "",
+	}
+	if !reflect.DeepEqual(doc, expected) {
+		t.Errorf("unexpected properties document: %v", doc)
+	}
+
+	if v, found := Select(doc, "spring.datasource.url"); !found || v != "jdbc:h2:mem:test" {
+		t.Errorf("unexpected spring.datasource.url: %v", v)
+	}
+}
+
+func TestParseEnv(t *testing.T) {
+	doc, err := Parse(FormatEnv, []byte(`# comment
+export API_URL=https://example.com # the api
+SINGLE='literal \n $HOME'
+DOUBLE="line\nbreak \"quoted\""
+MULTI="first
+second"
+EMPTY=
+HASH=a#b
+export DECLARED
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]any{
+		"API_URL": "https://example.com",
+		"SINGLE":  `literal \n $HOME`,
+		"DOUBLE":  "line\nbreak \"quoted\"",
+		"MULTI":   "first\nsecond",
+		"EMPTY":   "",
+		"HASH":    "a#b",
+	}
+	if !reflect.DeepEqual(doc, expected) {
+		t.Errorf("unexpected env document: %v", doc)
+	}
+
+	if _, err := Parse(FormatEnv, []byte(`KEY="open`)); err == nil {
+		t.Error("expected an unterminated value error")
+	}
+}
+
+func TestSelect(t *testing.T) {
+	doc := map[string]any{
+		"spring.datasource.url": "jdbc",
+		"root":                  "true",
+		"*.py": map[string]any{
+			"indent_style": "space",
+		},
+		"tool": map[string]any{
+			"a.b": "nested",
+		},
+	}
+
+	for path, expected := range map[string]any{
+		"":                      doc,
+		".root":                 "true",
+		"spring.datasource.url": "jdbc",
+		"*.py.indent_style":     "space",
+		"tool.a.b":              "nested",
+		"*.py":                  doc["*.py"],
+	} {
+		actual, found := Select(doc, path)
+		if !found || !reflect.DeepEqual(actual, expected) {
+			t.Errorf("Select(%q) = %v, %v, expected %v", path, actual, found, expected)
+		}
+	}
+
+	for _, path := range []string{"missing", "root.x", "spring.datasource", "tool.a"} {
+		if v, found := Select(doc, path); found {
+			t.Errorf("Select(%q) = %v, expected not found", path, v)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package keyvalue
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mikefarah/yq/v4/pkg/yqlib"
+)
+
+// Splits the source into lines, removing any \r of \r\n line endings.
+func splitLines(src string) []string {
+	lines := strings.Split(src, "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimSuffix(l, "\r")
+	}
+	return lines
+}
+
+// ---------------- INI and Properties
+
+// Decodes a document using a yq decoder, keeping scalar values as strings.
+func decodeYq(decoder yqlib.Decoder, src []byte) (map[string]any, error) {
+	if err := decoder.Init(bytes.NewReader(src)); err != nil {
+		return nil, err
+	}
+
+	node, err := decoder.Decode()
+	if errors.Is(err, io.EOF) {
+		// An empty document
+		return map[string]any{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	doc, _ := yqNodeToValue(node).(map[string]any)
+	if doc == nil {
+		doc = map[string]any{}
+	}
+	return doc, nil
+}
+
+func yqNodeToValue(node *yqlib.CandidateNode) any {
+	switch node.Kind {
+	case yqlib.MappingNode:
+		m := make(map[string]any, len(node.Content)/2)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			m[node.Content[i].Value] = yqNodeToValue(node.Content[i+1])
+		}
+		return m
+	case yqlib.SequenceNode:
+		s := make([]any, 0, len(node.Content))
+		for _, n := range node.Content {
+			s = append(s, yqNodeToValue(n))
+		}
+		return s
+	case yqlib.AliasNode:
+		return yqNodeToValue(node.Alias)
+	default:
+		return node.Value
+	}
+}
+
+// ---------------- Dotenv
+
+// Parses KEY=value lines with an optional "export" prefix. Single quoted values
+// are literal, double quoted values support escapes and span lines, unquoted
+// values end at a " #" comment. Variables are not expanded.
+func parseEnv(src string) (map[string]any, error) {
+	doc := make(map[string]any)
+
+	lines := splitLines(src)
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		line = strings.TrimPrefix(line, "export ")
+		sep := strings.IndexByte(line, '=')
+		if sep < 0 {
+			// A declaration without a value such as "export KEY"
+			continue
+		}
+
+		key := strings.TrimSpace(line[:sep])
+		value := strings.TrimSpace(line[sep+1:])
+
+		switch {
+		case value == "":
+		case value[0] == '\'':
+			end := strings.IndexByte(value[1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated quoted value of %q", i+1, key)
+			}
+			value = value[1 : end+1]
+		case value[0] == '"':
+			startLine := i
+			raw := value[1:]
+			end := closingQuote(raw)
+			for end < 0 && i+1 < len(lines) {
+				i++
+				raw += "\n" + lines[i]
+				end = closingQuote(raw)
+			}
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated quoted value of %q", startLine+1, key)
+			}
+			value = unescapeEnv(raw[:end])
+		default:
+			if comment := strings.Index(value, " #"); comment >= 0 {
+				value = strings.TrimSpace(value[:comment])
+			}
+		}
+
+		doc[key] = value
+	}
+
+	return doc, nil
+}
+
+// Returns the index of the first unescaped double quote, or -1.
+func closingQuote(s string) int {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+func unescapeEnv(s string) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			sb.WriteByte(s[i])
+			continue
+		}
+
+		i++
+		switch s[i] {
+		case 'n':
+			sb.WriteByte('\n')
+		case 'r':
+			sb.WriteByte('\r')
+		case 't':
+			sb.WriteByte('\t')
+		case '"', '\\', '$', '`':
+			sb.WriteByte(s[i])
+		default:
+			sb.WriteByte('\\')
+			sb.WriteByte(s[i])
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,140 @@
+package queries
+
+import (
+	"github.com/aspect-build/aspect-gazelle/language/orion/plugin"
+	"github.com/aspect-build/aspect-gazelle/language/orion/queries/proto"
+)
+
+func runProtoQueries(fileName string, sourceCode []byte, queries plugin.NamedQueries) (plugin.QueryResults, error) {
+	f, err := proto.Parse(sourceCode)
+	if err != nil {
+		queryLog.Warnf("ignoring unparseable proto file %q: %v", fileName, err)
+		f = &proto.File{}
+	}
+
+	descriptor := convertProtoFile(f)
+
+	results := make(plugin.QueryResults, len(queries))
+	for key := range queries {
+		results[key] = descriptor
+	}
+	return results, nil
+}
+
+func convertProtoFile(f *proto.File) map[string]interface{} {
+	imports := make([]interface{}, 0, len(f.Imports))
+	for _, i := range f.Imports {
+		imports = append(imports, map[string]interface{}{
+			"path":   i.Path,
+			"public": i.Public,
+			"weak":   i.Weak,
+		})
+	}
+
+	services := make([]interface{}, 0, len(f.Services))
+	for _, s := range f.Services {
+		methods := make([]interface{}, 0, len(s.Methods))
+		for _, m := range s.Methods {
+			methods = append(methods, map[string]interface{}{
+				"name":             m.Name,
+				"input_type":       m.InputType,
+				"output_type":      m.OutputType,
+				"client_streaming": m.ClientStreaming,
+				"server_streaming": m.ServerStreaming,
+				"options":          convertProtoOptions(m.Options),
+			})
+		}
+		services = append(services, map[string]interface{}{
+			"name":      s.Name,
+			"full_name": s.FullName,
+			"methods":   methods,
+			"options":   convertProtoOptions(s.Options),
+		})
+	}
+
+	extends := make([]interface{}, 0, len(f.Extends))
+	for _, e := range f.Extends {
+		extends = append(extends, e)
+	}
+
+	return map[string]interface{}{
+		"syntax":   f.Syntax,
+		"edition":  f.Edition,
+		"package":  f.Package,
+		"imports":  imports,
+		"options":  convertProtoOptions(f.Options),
+		"messages": convertProtoMessages(f.Messages),
+		"enums":    convertProtoEnums(f.Enums),
+		"services": services,
+		"extends":  extends,
+	}
+}
+
+func convertProtoMessages(messages []*proto.Message) []interface{} {
+	r := make([]interface{}, 0, len(messages))
+	for _, m := range messages {
+		fields := make([]interface{}, 0, len(m.Fields))
+		for _, f := range m.Fields {
+			field := map[string]interface{}{
+				"name":    f.Name,
+				"number":  f.Number,
+				"type":    f.Type,
+				"label":   f.Label,
+				"oneof":   f.Oneof,
+				"options": convertProtoOptions(f.Options),
+			}
+			if f.KeyType != "" {
+				field["key_type"] = f.KeyType
+				field["value_type"] = f.ValueType
+			}
+			fields = append(fields, field)
+		}
+
+		oneofs := make([]interface{}, 0, len(m.Oneofs))
+		for _, o := range m.Oneofs {
+			oneofs = append(oneofs, o)
+		}
+
+		r = append(r, map[string]interface{}{
+			"name":      m.Name,
+			"full_name": m.FullName,
+			"fields":    fields,
+			"oneofs":    oneofs,
+			"messages":  convertProtoMessages(m.Messages),
+			"enums":     convertProtoEnums(m.Enums),
+			"options":   convertProtoOptions(m.Options),
+		})
+	}
+	return r
+}
+
+func convertProtoEnums(enums []*proto.Enum) []interface{} {
+	r := make([]interface{}, 0, len(enums))
+	for _, e := range enums {
+		values := make([]interface{}, 0, len(e.Values))
+		for _, v := range e.Values {
+			values = append(values, map[string]interface{}{
+				"name":   v.Name,
+				"number": v.Number,
+			})
+		}
+		r = append(r, map[string]interface{}{
+			"name":      e.Name,
+			"full_name": e.FullName,
+			"values":    values,
+			"options":   convertProtoOptions(e.Options),
+		})
+	}
+	return r
+}
+
+// Options are keyed by name. Options set more than once, such as repeated custom
+// options, are the last value: the parser does not know the option types to
+// reject the duplicates of singular options as protoc does.
+func convertProtoOptions(options []proto.Option) map[string]interface{} {
+	r := make(map[string]interface{}, len(options))
+	for _, o := range options {
+		r[o.Name] = o.Value
+	}
+	return r
+}
@@ -0,0 +1,1000 @@
+package proto
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Parse parses the declarations of a .proto file.
+func Parse(src []byte) (*File, error) {
+	p := &parser{lexer: lexer{src: string(src), line: 1}, f: &File{}}
+	if err := p.parseFile(); err != nil {
+		return nil, err
+	}
+	return p.f, nil
+}
+
+// ---------------- Lexer
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokSymbol
+)
+
+type token struct {
+	kind tokenKind
+
+	// The text of identifiers, numbers and symbols, or the unquoted string.
+	val  string
+	line int
+}
+
+type lexer struct {
+	src  string
+	pos  int
+	line int
+
+	// Tokens peeked or pushed back, in order.
+	buf []token
+}
+
+func (l *lexer) peek() (token, error) {
+	if len(l.buf) == 0 {
+		t, err := l.lex()
+		if err != nil {
+			return token{}, err
+		}
+		l.buf = append(l.buf, t)
+	}
+	return l.buf[0], nil
+}
+
+func (l *lexer) next() (token, error) {
+	t, err := l.peek()
+	if err == nil {
+		l.buf = l.buf[1:]
+	}
+	return t, err
+}
+
+// Pushes back tokens to be returned before any remaining tokens.
+func (l *lexer) unread(tokens ...token) {
+	l.buf = append(tokens, l.buf...)
+}
+
+func (l *lexer) skipSpaceAndComments() error {
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == '\n':
+			l.line++
+			l.pos++
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f' || c == '\v':
+			l.pos++
+		case strings.HasPrefix(l.src[l.pos:], "//"):
+			end := strings.IndexByte(l.src[l.pos:], '\n')
+			if end < 0 {
+				l.pos = len(l.src)
+			} else {
+				l.pos += end
+			}
+		case strings.HasPrefix(l.src[l.pos:], "/*"):
+			end := strings.Index(l.src[l.pos+2:], "*/")
+			if end < 0 {
+				return fmt.Errorf("line %d: unterminated comment", l.line)
+			}
+			l.line += strings.Count(l.src[l.pos:l.pos+2+end], "\n")
+			l.pos += end + 4
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+func (l *lexer) lex() (token, error) {
+	if err := l.skipSpaceAndComments(); err != nil {
+		return token{}, err
+	}
+	if l.pos >= len(l.src) {
+		return token{kind: tokEOF, line: l.line}, nil
+	}
+
+	start := l.pos
+	c := l.src[l.pos]
+
+	switch {
+	case isLetter(c):
+		for l.pos < len(l.src) && (isLetter(l.src[l.pos]) || isDigit(l.src[l.pos])) {
+			l.pos++
+		}
+		return token{tokIdent, l.src[start:l.pos], l.line}, nil
+
+	case isDigit(c) || (c == '.' && l.pos+1 < len(l.src) && isDigit(l.src[l.pos+1])):
+		for l.pos < len(l.src) {
+			c := l.src[l.pos]
+			// Exponent signs such as 1e-5
+			isExponentSign := (c == '-' || c == '+') && (l.src[l.pos-1] == 'e' || l.src[l.pos-1] == 'E') && !strings.HasPrefix(strings.ToLower(l.src[start:]), "0x")
+			if !isLetter(c) && !isDigit(c) && c != '.' && !isExponentSign {
+				break
+			}
+			l.pos++
+		}
+		return token{tokNumber, l.src[start:l.pos], l.line}, nil
+
+	case c == '"' || c == '\'':
+		return l.lexString(c)
+	}
+
+	l.pos++
+	return token{tokSymbol, string(c), l.line}, nil
+}
+
+func (l *lexer) lexString(quote byte) (token, error) {
+	line := l.line
+	var sb strings.Builder
+
+	l.pos++
+	for {
+		if l.pos >= len(l.src) || l.src[l.pos] == '\n' {
+			return token{}, fmt.Errorf("line %d: unterminated string", line)
+		}
+
+		c := l.src[l.pos]
+		if c == quote {
+			l.pos++
+			return token{tokString, sb.String(), line}, nil
+		}
+		if c != '\\' {
+			sb.WriteByte(c)
+			l.pos++
+			continue
+		}
+
+		// Escapes, see https://protobuf.dev/reference/protobuf/proto3-spec/#string_literals
+		value, _, tail, err := strconv.UnquoteChar(l.src[l.pos:], quote)
+		if err != nil {
+			return token{}, fmt.Errorf("line %d: invalid string escape: %w", line, err)
+		}
+		sb.WriteRune(value)
+		l.pos = len(l.src) - len(tail)
+	}
+}
+
+func isLetter(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+// ---------------- Parser
+
+type parser struct {
+	lexer
+	f *File
+}
+
+func (p *parser) errorf(t token, format string, args ...any) error {
+	return fmt.Errorf("line %d: %s", t.line, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) unexpected(t token, expected string) error {
+	found := fmt.Sprintf("%q", t.val)
+	if t.kind == tokEOF {
+		found = "end of file"
+	} else if t.kind == tokString {
+		found = strconv.Quote(t.val)
+	}
+	return p.errorf(t, "expected %s, found %s", expected, found)
+}
+
+// Consumes the next token if it is the symbol or keyword.
+func (p *parser) accept(val string) (bool, error) {
+	t, err := p.peek()
+	if err != nil {
+		return false, err
+	}
+	if (t.kind == tokSymbol || t.kind == tokIdent) && t.val == val {
+		p.next()
+		return true, nil
+	}
+	return false, nil
+}
+
+func (p *parser) expect(val string) error {
+	t, err := p.next()
+	if err != nil {
+		return err
+	}
+	if (t.kind != tokSymbol && t.kind != tokIdent) || t.val != val {
+		return p.unexpected(t, strconv.Quote(val))
+	}
+	return nil
+}
+
+func (p *parser) expectIdent() (string, error) {
+	t, err := p.next()
+	if err != nil {
+		return "", err
+	}
+	if t.kind != tokIdent {
+		return "", p.unexpected(t, "an identifier")
+	}
+	return t.val, nil
+}
+
+// A string literal, adjacent literals are concatenated.
+func (p *parser) expectString() (string, error) {
+	t, err := p.next()
+	if err != nil {
+		return "", err
+	}
+	if t.kind != tokString {
+		return "", p.unexpected(t, "a string")
+	}
+
+	s := t.val
+	for {
+		t, err := p.peek()
+		if err != nil {
+			return "", err
+		}
+		if t.kind != tokString {
+			return s, nil
+		}
+		p.next()
+		s += t.val
+	}
+}
+
+func (p *parser) expectInt() (int64, error) {
+	t, err := p.next()
+	if err != nil {
+		return 0, err
+	}
+
+	negative := t.kind == tokSymbol && t.val == "-"
+	if negative {
+		if t, err = p.next(); err != nil {
+			return 0, err
+		}
+	}
+	if t.kind != tokNumber {
+		return 0, p.unexpected(t, "an integer")
+	}
+
+	n, err := strconv.ParseInt(t.val, 0, 64)
+	if err != nil {
+		return 0, p.errorf(t, "invalid integer %q", t.val)
+	}
+	if negative {
+		n = -n
+	}
+	return n, nil
+}
+
+// A possibly fully qualified dotted identifier, such as "foo.Bar" or ".foo.Bar".
+func (p *parser) parseTypeName() (string, error) {
+	name := ""
+	if isDot, err := p.accept("."); err != nil {
+		return "", err
+	} else if isDot {
+		name = "."
+	}
+
+	for {
+		ident, err := p.expectIdent()
+		if err != nil {
+			return "", err
+		}
+		name += ident
+
+		if isDot, err := p.accept("."); err != nil || !isDot {
+			return name, err
+		}
+		name += "."
+	}
+}
+
+func (p *parser) parseFile() error {
+	for {
+		t, err := p.next()
+		if err != nil {
+			return err
+		}
+		if t.kind == tokEOF {
+			return nil
+		}
+
+		switch {
+		case t.kind == tokSymbol && t.val == ";":
+			// Empty statement
+		case t.kind != tokIdent:
+			return p.unexpected(t, "a declaration")
+		case t.val == "syntax" || t.val == "edition":
+			if err := p.expect("="); err != nil {
+				return err
+			}
+			v, err := p.expectString()
+			if err != nil {
+				return err
+			}
+			if t.val == "syntax" {
+				p.f.Syntax = v
+			} else {
+				p.f.Edition = v
+			}
+			if err := p.expect(";"); err != nil {
+				return err
+			}
+		case t.val == "package":
+			if p.f.Package, err = p.parseTypeName(); err != nil {
+				return err
+			}
+			if err := p.expect(";"); err != nil {
+				return err
+			}
+		case t.val == "import":
+			imp := Import{}
+			if imp.Public, err = p.accept("public"); err != nil {
+				return err
+			}
+			if !imp.Public {
+				if imp.Weak, err = p.accept("weak"); err != nil {
+					return err
+				}
+			}
+			if imp.Path, err = p.expectString(); err != nil {
+				return err
+			}
+			if err := p.expect(";"); err != nil {
+				return err
+			}
+			p.f.Imports = append(p.f.Imports, imp)
+		case t.val == "option":
+			o, err := p.parseOption()
+			if err != nil {
+				return err
+			}
+			p.f.Options = append(p.f.Options, o)
+		case t.val == "message":
+			m, err := p.parseMessage(p.f.Package)
+			if err != nil {
+				return err
+			}
+			p.f.Messages = append(p.f.Messages, m)
+		case t.val == "enum":
+			e, err := p.parseEnum(p.f.Package)
+			if err != nil {
+				return err
+			}
+			p.f.Enums = append(p.f.Enums, e)
+		case t.val == "service":
+			s, err := p.parseService()
+			if err != nil {
+				return err
+			}
+			p.f.Services = append(p.f.Services, s)
+		case t.val == "extend":
+			if err := p.parseExtend(); err != nil {
+				return err
+			}
+		default:
+			return p.unexpected(t, "a declaration")
+		}
+	}
+}
+
+func fullName(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+// Parses `name = value;` after the `option` keyword.
+func (p *parser) parseOption() (Option, error) {
+	o, err := p.parseOptionAssignment()
+	if err != nil {
+		return o, err
+	}
+	return o, p.expect(";")
+}
+
+func (p *parser) parseOptionAssignment() (Option, error) {
+	var name strings.Builder
+	for {
+		t, err := p.next()
+		if err != nil {
+			return Option{}, err
+		}
+		switch {
+		case t.kind == tokIdent || (t.kind == tokSymbol && (t.val == "." || t.val == "(" || t.val == ")")):
+			name.WriteString(t.val)
+			continue
+		case t.kind == tokSymbol && t.val == "=" && name.Len() > 0:
+			v, err := p.parseConstant()
+			return Option{Name: name.String(), Value: v}, err
+		}
+		return Option{}, p.unexpected(t, "an option name")
+	}
+}
+
+// Parses an option value: a scalar constant, identifier or `{...}` aggregate.
+func (p *parser) parseConstant() (any, error) {
+	t, err := p.peek()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t.kind {
+	case tokString:
+		return p.expectString()
+	case tokIdent:
+		p.next()
+		switch t.val {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "inf":
+			return t.val, nil
+		}
+		// Enum values such as SPEED or full identifiers
+		name := t.val
+		for {
+			if isDot, err := p.accept("."); err != nil || !isDot {
+				return name, err
+			}
+			ident, err := p.expectIdent()
+			if err != nil {
+				return nil, err
+			}
+			name += "." + ident
+		}
+	case tokSymbol:
+		switch t.val {
+		case "{":
+			return p.parseAggregate()
+		case "-", "+":
+			p.next()
+			v, err := p.parseNumber()
+			if n, isInt := v.(int64); isInt && t.val == "-" {
+				return -n, err
+			}
+			if f, isFloat := v.(float64); isFloat && t.val == "-" {
+				return -f, err
+			}
+			if s, isString := v.(string); isString && t.val == "-" {
+				return "-" + s, err
+			}
+			return v, err
+		}
+	case tokNumber:
+		return p.parseNumber()
+	}
+
+	return nil, p.unexpected(t, "a constant")
+}
+
+func (p *parser) parseNumber() (any, error) {
+	t, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if t.kind == tokIdent && (t.val == "inf" || t.val == "nan") {
+		return t.val, nil
+	}
+	if t.kind != tokNumber {
+		return nil, p.unexpected(t, "a number")
+	}
+	if n, err := strconv.ParseInt(t.val, 0, 64); err == nil {
+		return n, nil
+	}
+	if n, err := strconv.ParseFloat(t.val, 64); err == nil {
+		return n, nil
+	}
+	return nil, p.errorf(t, "invalid number %q", t.val)
+}
+
+// Returns the text between the braces of an aggregate option value.
+func (p *parser) parseAggregate() (string, error) {
+	if err := p.expect("{"); err != nil {
+		return "", err
+	}
+	start := p.pos
+	end, err := p.skipBlock()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(p.src[start:end]), nil
+}
+
+// Skips to the end of a block, returning the offset of the closing brace.
+func (p *parser) skipBlock() (int, error) {
+	depth := 1
+	for {
+		t, err := p.next()
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case t.kind == tokEOF:
+			return 0, p.unexpected(t, `"}"`)
+		case t.kind == tokSymbol && t.val == "{":
+			depth++
+		case t.kind == tokSymbol && t.val == "}":
+			depth--
+			if depth == 0 {
+				return p.pos - 1, nil
+			}
+		}
+	}
+}
+
+// Parses the `[name = value, ...]` options of a field or enum value, if any.
+func (p *parser) parseFieldOptions() ([]Option, error) {
+	if isOpen, err := p.accept("["); err != nil || !isOpen {
+		return nil, err
+	}
+
+	var options []Option
+	for {
+		o, err := p.parseOptionAssignment()
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, o)
+
+		if isComma, err := p.accept(","); err != nil {
+			return nil, err
+		} else if !isComma {
+			return options, p.expect("]")
+		}
+	}
+}
+
+// Parses a message after the `message` keyword.
+func (p *parser) parseMessage(scope string) (*Message, error) {
+	name, err := p.expectIdent()
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Message{Name: name, FullName: fullName(scope, name)}
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	return m, p.parseMessageBody(m, "")
+}
+
+func (p *parser) parseMessageBody(m *Message, oneof string) error {
+	for {
+		t, err := p.peek()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case t.kind == tokSymbol && t.val == "}":
+			p.next()
+			return nil
+		case t.kind == tokSymbol && t.val == ";":
+			p.next()
+			continue
+		case t.kind == tokEOF:
+			return p.unexpected(t, `"}"`)
+		}
+
+		// Keywords may also be field types or names, a declaration is only a
+		// keyword when followed by what the declaration requires.
+		if t.kind == tokIdent && oneof == "" {
+			handled, err := p.parseMessageDeclaration(m, t)
+			if err != nil {
+				return err
+			}
+			if handled {
+				continue
+			}
+		} else if t.kind == tokIdent && t.val == "option" {
+			p.next()
+			if _, err := p.parseOption(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		field, err := p.parseField(m)
+		if err != nil {
+			return err
+		}
+		if field != nil {
+			field.Oneof = oneof
+			m.Fields = append(m.Fields, field)
+		}
+	}
+}
+
+// Parses a non-field declaration of a message body, returning false if the
+// statement is a field.
+func (p *parser) parseMessageDeclaration(m *Message, t token) (bool, error) {
+	switch t.val {
+	case "message", "enum", "oneof", "extend", "option", "reserved", "extensions":
+	default:
+		return false, nil
+	}
+
+	// A field of a type named like a keyword is followed by the field name, and
+	// fields named like a keyword are followed by "="
+	p.next()
+	next, err := p.peek()
+	if err != nil {
+		return false, err
+	}
+	isKeyword := next.kind == tokIdent || (t.val == "option" && next.kind == tokSymbol && next.val == "(") || ((t.val == "reserved" || t.val == "extensions") && next.kind != tokSymbol) || (t.val == "extend" && next.kind == tokSymbol && next.val == ".")
+	if t.val == "oneof" || t.val == "message" || t.val == "enum" {
+		// `message foo = 1;` would be a field named like a keyword, which is not valid
+		isKeyword = next.kind == tokIdent
+	}
+	if !isKeyword {
+		// Restore the keyword as the field type or name
+		p.unread(t)
+		return false, nil
+	}
+
+	switch t.val {
+	case "message":
+		nested, err := p.parseMessage(m.FullName)
+		if err != nil {
+			return false, err
+		}
+		m.Messages = append(m.Messages, nested)
+	case "enum":
+		e, err := p.parseEnum(m.FullName)
+		if err != nil {
+			return false, err
+		}
+		m.Enums = append(m.Enums, e)
+	case "oneof":
+		name, err := p.expectIdent()
+		if err != nil {
+			return false, err
+		}
+		if err := p.expect("{"); err != nil {
+			return false, err
+		}
+		m.Oneofs = append(m.Oneofs, name)
+		if err := p.parseMessageBody(m, name); err != nil {
+			return false, err
+		}
+	case "extend":
+		if err := p.parseExtend(); err != nil {
+			return false, err
+		}
+	case "option":
+		o, err := p.parseOption()
+		if err != nil {
+			return false, err
+		}
+		m.Options = append(m.Options, o)
+	case "reserved", "extensions":
+		if err := p.skipStatement(); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// Parses a field, returning nil for a group.
+func (p *parser) parseField(m *Message) (*Field, error) {
+	f := &Field{}
+
+	t, err := p.peek()
+	if err != nil {
+		return nil, err
+	}
+	if t.kind == tokIdent && (t.val == "optional" || t.val == "repeated" || t.val == "required") {
+		// A field of a type named like a label would be followed by a name then "="
+		p.next()
+		f.Label = t.val
+	}
+
+	if isMap, err := p.accept("map"); err != nil {
+		return nil, err
+	} else if isMap {
+		if isOpen, err := p.accept("<"); err != nil {
+			return nil, err
+		} else if !isOpen {
+			// A type or field named "map"
+			return p.parseFieldRest(f, "map")
+		}
+		if f.KeyType, err = p.parseTypeName(); err != nil {
+			return nil, err
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+		if f.ValueType, err = p.parseTypeName(); err != nil {
+			return nil, err
+		}
+		if err := p.expect(">"); err != nil {
+			return nil, err
+		}
+		return p.parseFieldRest(f, fmt.Sprintf("map<%s, %s>", f.KeyType, f.ValueType))
+	}
+
+	typeName, err := p.parseTypeName()
+	if err != nil {
+		return nil, err
+	}
+
+	// proto2 groups declare a nested message and field of the same name
+	if typeName == "group" {
+		name, err := p.expectIdent()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("="); err != nil {
+			return nil, err
+		}
+		number, err := p.expectInt()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.parseFieldOptions(); err != nil {
+			return nil, err
+		}
+		if err := p.expect("{"); err != nil {
+			return nil, err
+		}
+		group := &Message{Name: name, FullName: fullName(m.FullName, name)}
+		if err := p.parseMessageBody(group, ""); err != nil {
+			return nil, err
+		}
+		m.Messages = append(m.Messages, group)
+		m.Fields = append(m.Fields, &Field{Name: strings.ToLower(name), Number: number, Type: name, Label: f.Label})
+		return nil, nil
+	}
+
+	return p.parseFieldRest(f, typeName)
+}
+
+// Parses `name = number [options];` of a field of the type.
+func (p *parser) parseFieldRest(f *Field, typeName string) (*Field, error) {
+	var err error
+	f.Type = typeName
+	if f.Name, err = p.expectIdent(); err != nil {
+		return nil, err
+	}
+	if err := p.expect("="); err != nil {
+		return nil, err
+	}
+	if f.Number, err = p.expectInt(); err != nil {
+		return nil, err
+	}
+	if f.Options, err = p.parseFieldOptions(); err != nil {
+		return nil, err
+	}
+	return f, p.expect(";")
+}
+
+// Skips to the end of a statement such as `reserved 1, 2 to 5;`.
+func (p *parser) skipStatement() error {
+	for {
+		t, err := p.next()
+		if err != nil {
+			return err
+		}
+		switch {
+		case t.kind == tokEOF:
+			return p.unexpected(t, `";"`)
+		case t.kind == tokSymbol && t.val == ";":
+			return nil
+		case t.kind == tokSymbol && t.val == "[":
+			// Options of extension ranges
+			for {
+				t, err := p.next()
+				if err != nil {
+					return err
+				}
+				if t.kind == tokEOF {
+					return p.unexpected(t, `"]"`)
+				}
+				if t.kind == tokSymbol && t.val == "]" {
+					break
+				}
+			}
+		}
+	}
+}
+
+// Parses an enum after the `enum` keyword.
+func (p *parser) parseEnum(scope string) (*Enum, error) {
+	name, err := p.expectIdent()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+
+	e := &Enum{Name: name, FullName: fullName(scope, name)}
+	for {
+		t, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case t.kind == tokSymbol && t.val == "}":
+			return e, nil
+		case t.kind == tokSymbol && t.val == ";":
+			continue
+		case t.kind != tokIdent:
+			return nil, p.unexpected(t, "an enum value")
+		}
+
+		next, err := p.peek()
+		if err != nil {
+			return nil, err
+		}
+		isValue := next.kind == tokSymbol && next.val == "="
+
+		switch {
+		case t.val == "option" && !isValue:
+			o, err := p.parseOption()
+			if err != nil {
+				return nil, err
+			}
+			e.Options = append(e.Options, o)
+		case t.val == "reserved" && !isValue:
+			if err := p.skipStatement(); err != nil {
+				return nil, err
+			}
+		default:
+			if err := p.expect("="); err != nil {
+				return nil, err
+			}
+			number, err := p.expectInt()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.parseFieldOptions(); err != nil {
+				return nil, err
+			}
+			if err := p.expect(";"); err != nil {
+				return nil, err
+			}
+			e.Values = append(e.Values, EnumValue{Name: t.val, Number: number})
+		}
+	}
+}
+
+// Parses a service after the `service` keyword.
+func (p *parser) parseService() (*Service, error) {
+	name, err := p.expectIdent()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+
+	s := &Service{Name: name, FullName: fullName(p.f.Package, name)}
+	for {
+		t, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case t.kind == tokSymbol && t.val == "}":
+			return s, nil
+		case t.kind == tokSymbol && t.val == ";":
+		case t.kind == tokIdent && t.val == "option":
+			o, err := p.parseOption()
+			if err != nil {
+				return nil, err
+			}
+			s.Options = append(s.Options, o)
+		case t.kind == tokIdent && t.val == "rpc":
+			m, err := p.parseMethod()
+			if err != nil {
+				return nil, err
+			}
+			s.Methods = append(s.Methods, m)
+		default:
+			return nil, p.unexpected(t, "an rpc")
+		}
+	}
+}
+
+// Parses `Name (stream Input) returns (stream Output) {options}` after the `rpc` keyword.
+func (p *parser) parseMethod() (*Method, error) {
+	var err error
+	m := &Method{}
+	if m.Name, err = p.expectIdent(); err != nil {
+		return nil, err
+	}
+
+	parseType := func() (string, bool, error) {
+		if err := p.expect("("); err != nil {
+			return "", false, err
+		}
+		isStream, err := p.accept("stream")
+		if err != nil {
+			return "", false, err
+		}
+		if isStream {
+			// A message type named "stream"
+			if isClose, err := p.accept(")"); err != nil {
+				return "", false, err
+			} else if isClose {
+				return "stream", false, nil
+			}
+		}
+		typeName, err := p.parseTypeName()
+		if err != nil {
+			return "", false, err
+		}
+		return typeName, isStream, p.expect(")")
+	}
+
+	if m.InputType, m.ClientStreaming, err = parseType(); err != nil {
+		return nil, err
+	}
+	if err := p.expect("returns"); err != nil {
+		return nil, err
+	}
+	if m.OutputType, m.ServerStreaming, err = parseType(); err != nil {
+		return nil, err
+	}
+
+	if isBody, err := p.accept("{"); err != nil {
+		return nil, err
+	} else if !isBody {
+		return m, p.expect(";")
+	}
+
+	for {
+		t, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case t.kind == tokSymbol && t.val == "}":
+			return m, nil
+		case t.kind == tokSymbol && t.val == ";":
+		case t.kind == tokIdent && t.val == "option":
+			o, err := p.parseOption()
+			if err != nil {
+				return nil, err
+			}
+			m.Options = append(m.Options, o)
+		default:
+			return nil, p.unexpected(t, "an option")
+		}
+	}
+}
+
+// Parses an extend block after the `extend` keyword, recording the extended type.
+func (p *parser) parseExtend() error {
+	typeName, err := p.parseTypeName()
+	if err != nil {
+		return err
+	}
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+	p.f.Extends = append(p.f.Extends, typeName)
+
+	// Extension fields are not recorded
+	_, err = p.skipBlock()
+	return err
+}
@@ -0,0 +1,93 @@
+package proto
+
+// The parsed declarations of a .proto file, similar to a FileDescriptorProto.
+//
+// Type names are as written in the file and are not resolved.
+type File struct {
+	// The syntax such as "proto3", or the edition such as "2023".
+	Syntax  string
+	Edition string
+
+	Package  string
+	Imports  []Import
+	Options  []Option
+	Messages []*Message
+	Enums    []*Enum
+	Services []*Service
+
+	// The type names extended by extend blocks.
+	Extends []string
+}
+
+type Import struct {
+	Path   string
+	Public bool
+	Weak   bool
+}
+
+// An option with the name as written, such as "go_package" or "(my.ext).field".
+//
+// Values are strings, int64, float64, bool or the identifier of an enum value as a
+// string. Aggregate values are the text between the braces.
+type Option struct {
+	Name  string
+	Value any
+}
+
+type Message struct {
+	Name     string
+	FullName string
+
+	Fields   []*Field
+	Oneofs   []string
+	Messages []*Message
+	Enums    []*Enum
+	Options  []Option
+}
+
+type Field struct {
+	Name   string
+	Number int64
+
+	// The field type such as "string", "Foo" or "map<string, Foo>".
+	Type string
+
+	// The label, one of "optional", "repeated", "required" or "".
+	Label string
+
+	// The oneof containing the field, if any.
+	Oneof string
+
+	// The key and value types of map fields.
+	KeyType, ValueType string
+
+	Options []Option
+}
+
+type Enum struct {
+	Name     string
+	FullName string
+	Values   []EnumValue
+	Options  []Option
+}
+
+type EnumValue struct {
+	Name   string
+	Number int64
+}
+
+type Service struct {
+	Name     string
+	FullName string
+	Methods  []*Method
+	Options  []Option
+}
+
+type Method struct {
+	Name            string
+	InputType       string
+	OutputType      string
+	ClientStreaming bool
+	ServerStreaming bool
+	Options         []Option
+}
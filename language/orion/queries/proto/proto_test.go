@@ -0,0 +1,224 @@
+package proto
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testFile = `
+// A service
+syntax = "proto3";
+
+package example.v1;
+
+import "google/protobuf/timestamp.proto";
+import public "example/v1/common.proto";
+import weak "example/v1/legacy.proto";
+
+option go_package = "github.com/example/gen/v1;examplev1";
+option java_package = "com.example" ".v1";
+option java_multiple_files = true;
+option optimize_for = SPEED;
+option (my.ext).opt = { name: "x" nested { a: 1 } };
+
+/* Messages */
+message Greeting {
+  option deprecated = true;
+
+  string message = 1;
+  repeated string tags = 2 [deprecated = true, (my.field) = -3];
+  map<string, .example.v1.Value> values = 3;
+  optional google.protobuf.Timestamp time = 4;
+  reserved 5, 6 to 10;
+  reserved "old";
+
+  oneof kind {
+    string text = 11;
+    int64 number = 12;
+  }
+
+  message Nested {
+    enum Level { LOW = 0; HIGH = 0x1; }
+  }
+  Nested nested = 13;
+}
+
+enum Status {
+  option allow_alias = true;
+  STATUS_UNSPECIFIED = 0;
+  STATUS_NEGATIVE = -1 [deprecated = true];
+}
+
+service Greeter {
+  option deprecated = false;
+  rpc Greet (Greeting) returns (Greeting);
+  rpc Stream (stream Greeting) returns (stream .example.v1.Greeting) {
+    option idempotency_level = NO_SIDE_EFFECTS;
+  }
+}
+
+extend google.protobuf.FieldOptions {
+  string my_option = 50000;
+}
+`
+
+func TestParse(t *testing.T) {
+	f, err := Parse([]byte(testFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if f.Syntax != "proto3" || f.Package != "example.v1" {
+		t.Errorf("unexpected syntax or package: %q, %q", f.Syntax, f.Package)
+	}
+
+	expectedImports := []Import{
+		{Path: "google/protobuf/timestamp.proto"},
+		{Path: "example/v1/common.proto", Public: true},
+		{Path: "example/v1/legacy.proto", Weak: true},
+	}
+	if !reflect.DeepEqual(f.Imports, expectedImports) {
+		t.Errorf("unexpected imports: %v", f.Imports)
+	}
+
+	expectedOptions := []Option{
+		{"go_package", "github.com/example/gen/v1;examplev1"},
+		{"java_package", "com.example.v1"},
+		{"java_multiple_files", true},
+		{"optimize_for", "SPEED"},
+		{"(my.ext).opt", `name: "x" nested { a: 1 }`},
+	}
+	if !reflect.DeepEqual(f.Options, expectedOptions) {
+		t.Errorf("unexpected options: %v", f.Options)
+	}
+
+	if len(f.Messages) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(f.Messages))
+	}
+	m := f.Messages[0]
+	if m.FullName != "example.v1.Greeting" || !reflect.DeepEqual(m.Options, []Option{{"deprecated", true}}) {
+		t.Errorf("unexpected message: %q %v", m.FullName, m.Options)
+	}
+
+	expectedFields := []*Field{
+		{Name: "message", Number: 1, Type: "string"},
+		{Name: "tags", Number: 2, Type: "string", Label: "repeated", Options: []Option{{"deprecated", true}, {"(my.field)", int64(-3)}}},
+		{Name: "values", Number: 3, Type: "map<string, .example.v1.Value>", KeyType: "string", ValueType: ".example.v1.Value"},
+		{Name: "time", Number: 4, Type: "google.protobuf.Timestamp", Label: "optional"},
+		{Name: "text", Number: 11, Type: "string", Oneof: "kind"},
+		{Name: "number", Number: 12, Type: "int64", Oneof: "kind"},
+		{Name: "nested", Number: 13, Type: "Nested"},
+	}
+	if !reflect.DeepEqual(m.Fields, expectedFields) {
+		for _, f := range m.Fields {
+			t.Logf("%+v", *f)
+		}
+		t.Errorf("unexpected fields")
+	}
+	if !reflect.DeepEqual(m.Oneofs, []string{"kind"}) {
+		t.Errorf("unexpected oneofs: %v", m.Oneofs)
+	}
+
+	if len(m.Messages) != 1 || m.Messages[0].FullName != "example.v1.Greeting.Nested" {
+		t.Fatalf("unexpected nested messages: %v", m.Messages)
+	}
+	nestedEnums := m.Messages[0].Enums
+	if len(nestedEnums) != 1 || nestedEnums[0].FullName != "example.v1.Greeting.Nested.Level" || !reflect.DeepEqual(nestedEnums[0].Values, []EnumValue{{"LOW", 0}, {"HIGH", 1}}) {
+		t.Errorf("unexpected nested enums: %v", nestedEnums)
+	}
+
+	if len(f.Enums) != 1 {
+		t.Fatalf("expected 1 enum, got %d", len(f.Enums))
+	}
+	e := f.Enums[0]
+	if e.FullName != "example.v1.Status" || !reflect.DeepEqual(e.Values, []EnumValue{{"STATUS_UNSPECIFIED", 0}, {"STATUS_NEGATIVE", -1}}) || !reflect.DeepEqual(e.Options, []Option{{"allow_alias", true}}) {
+		t.Errorf("unexpected enum: %+v", e)
+	}
+
+	if len(f.Services) != 1 {
+		t.Fatalf("expected 1 service, got %d", len(f.Services))
+	}
+	s := f.Services[0]
+	expectedMethods := []*Method{
+		{Name: "Greet", InputType: "Greeting", OutputType: "Greeting"},
+		{Name: "Stream", InputType: "Greeting", OutputType: ".example.v1.Greeting", ClientStreaming: true, ServerStreaming: true, Options: []Option{{"idempotency_level", "NO_SIDE_EFFECTS"}}},
+	}
+	if s.FullName != "example.v1.Greeter" || !reflect.DeepEqual(s.Methods, expectedMethods) || !reflect.DeepEqual(s.Options, []Option{{"deprecated", false}}) {
+		t.Errorf("unexpected service: %+v", s)
+	}
+
+	if !reflect.DeepEqual(f.Extends, []string{"google.protobuf.FieldOptions"}) {
+		t.Errorf("unexpected extends: %v", f.Extends)
+	}
+}
+
+func TestParseProto2(t *testing.T) {
+	f, err := Parse([]byte(`
+syntax = 'proto2';
+message Legacy {
+  required int32 id = 1;
+  optional group Result = 2 {
+    optional string url = 3;
+  }
+  extensions 100 to max [declaration = {number: 100}];
+  extend Legacy { optional int32 ext = 100; }
+  optional float ratio = 4 [default = -inf];
+  optional double scale = 5 [default = 1.5e-3];
+  optional string escaped = 6 [default = "a\"b\x41\n"];
+}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := f.Messages[0]
+	expectedFields := []*Field{
+		{Name: "id", Number: 1, Type: "int32", Label: "required"},
+		{Name: "result", Number: 2, Type: "Result", Label: "optional"},
+		{Name: "ratio", Number: 4, Type: "float", Label: "optional", Options: []Option{{"default", "-inf"}}},
+		{Name: "scale", Number: 5, Type: "double", Label: "optional", Options: []Option{{"default", 1.5e-3}}},
+		{Name: "escaped", Number: 6, Type: "string", Label: "optional", Options: []Option{{"default", "a\"bA\n"}}},
+	}
+	if !reflect.DeepEqual(m.Fields, expectedFields) {
+		for _, f := range m.Fields {
+			t.Logf("%+v", *f)
+		}
+		t.Errorf("unexpected fields")
+	}
+	if len(m.Messages) != 1 || m.Messages[0].FullName != "Legacy.Result" || len(m.Messages[0].Fields) != 1 {
+		t.Errorf("unexpected group message: %v", m.Messages)
+	}
+	if !reflect.DeepEqual(f.Extends, []string{"Legacy"}) {
+		t.Errorf("unexpected extends: %v", f.Extends)
+	}
+}
+
+func TestParseEdition(t *testing.T) {
+	f, err := Parse([]byte(`edition = "2023"; message M { reserved foo, bar; string option = 1; }`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Edition != "2023" || f.Syntax != "" {
+		t.Errorf("unexpected edition: %q, %q", f.Edition, f.Syntax)
+	}
+	if fields := f.Messages[0].Fields; len(fields) != 1 || fields[0].Name != "option" {
+		t.Errorf("unexpected fields: %v", fields)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for src, expected := range map[string]string{
+		`syntax = proto3;`:           `line 1: expected a string, found "proto3"`,
+		"message M {\n  string = 1;": `line 2: expected an identifier, found "="`,
+		`message M { string s = 1;`:  `expected "}", found end of file`,
+		`/* open`:                    `line 1: unterminated comment`,
+		`import "unterminated;`:      `line 1: unterminated string`,
+		`foo bar;`:                   `line 1: expected a declaration, found "foo"`,
+	} {
+		_, err := Parse([]byte(src))
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("Parse(%q) error = %v, expected %q", src, err, expected)
+		}
+	}
+}
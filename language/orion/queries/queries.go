@@ -4,10 +4,20 @@ import (
 	"maps"
 
 	BazelLog "github.com/aspect-build/aspect-gazelle/common/logger"
+	"github.com/aspect-build/aspect-gazelle/common/treesitter"
 	"github.com/aspect-build/aspect-gazelle/language/orion/plugin"
+	"github.com/aspect-build/aspect-gazelle/language/orion/queries/proto"
+	bzl "github.com/bazelbuild/buildtools/build"
 )
 
-func RunQueries(fileName string, sourceCode []byte, queries plugin.NamedQueries) (plugin.QueryResults, error) {
+// Queries are run by orion when generating BUILD files.
+var queryLog = BazelLog.With(BazelLog.Fields{Language: "orion", Phase: "query"})
+
+// RunQueries runs the queries on the source code of fileName.
+//
+// The grammar is used to parse the source for AstQuery queries without an
+// explicit grammar, if empty the grammar is based on the file extension.
+func RunQueries(fileName string, grammar treesitter.LanguageGrammar, sourceCode []byte, queries plugin.NamedQueries) (plugin.QueryResults, error) {
 	// Content gate: a query with a ContentFilter only runs if the source matches
 	// it. Queries gated out get an empty result; that may leave a type with no
 	// active queries, skipping its handler (and its parse) entirely. Surviving
@@ -31,7 +41,7 @@ func RunQueries(fileName string, sourceCode []byte, queries plugin.NamedQueries)
 
 	var results plugin.QueryResults
 	for queryType, active := range activeByType {
-		batch, err := runQueryBatch(queryType, fileName, sourceCode, active)
+		batch, err := runQueryBatch(queryType, fileName, grammar, sourceCode, active)
 		if err != nil {
 			return nil, err
 		}
@@ -56,10 +66,15 @@ func RunQueries(fileName string, sourceCode []byte, queries plugin.NamedQueries)
 }
 
 // runQueryBatch runs the active queries of a single type.
-func runQueryBatch(queryType plugin.QueryType, fileName string, sourceCode []byte, active plugin.NamedQueries) (plugin.QueryResults, error) {
+//
+// A JSON, key/value, XML, proto or starlark file that fails to parse is skipped
+// with a warning and empty results rather than aborting the run. A result converted
+// once per file, such as a proto or starlark descriptor, is shared by all queries
+// of the batch and must not be mutated.
+func runQueryBatch(queryType plugin.QueryType, fileName string, grammar treesitter.LanguageGrammar, sourceCode []byte, active plugin.NamedQueries) (plugin.QueryResults, error) {
 	switch queryType {
 	case plugin.QueryTypeAst:
-		return runPluginTreeQueries(fileName, sourceCode, active)
+		return runPluginTreeQueries(fileName, grammar, sourceCode, active)
 	case plugin.QueryTypeRegex:
 		return runRegexQueries(sourceCode, active)
 	case plugin.QueryTypeJson:
@@ -68,10 +83,18 @@ func runQueryBatch(queryType plugin.QueryType, fileName string, sourceCode []byt
 		return runYamlQueries(sourceCode, active)
 	case plugin.QueryTypeToml:
 		return runTomlQueries(sourceCode, active)
+	case plugin.QueryTypeKeyValue:
+		return runKeyValueQueries(fileName, sourceCode, active)
+	case plugin.QueryTypeXml:
+		return runXmlQueries(fileName, sourceCode, active)
+	case plugin.QueryTypeProto:
+		return runProtoQueries(fileName, sourceCode, active)
+	case plugin.QueryTypeStarlark:
+		return runStarlarkQueries(fileName, sourceCode, active)
 	case plugin.QueryTypeRaw:
 		return runRawQueries(sourceCode, active)
 	default:
-		BazelLog.Fatalf("Unknown query type: %v", queryType)
+		queryLog.Fatalf("Unknown query type: %v", queryType)
 		return nil, nil
 	}
 }
@@ -82,7 +105,11 @@ func emptyResult(queryType plugin.QueryType) any {
 	switch queryType {
 	case plugin.QueryTypeRaw:
 		return ""
-	case plugin.QueryTypeJson, plugin.QueryTypeYaml, plugin.QueryTypeToml:
+	case plugin.QueryTypeProto:
+		return convertProtoFile(&proto.File{})
+	case plugin.QueryTypeStarlark:
+		return convertStarlarkFile(&bzl.File{})
+	case plugin.QueryTypeJson, plugin.QueryTypeYaml, plugin.QueryTypeToml, plugin.QueryTypeXml, plugin.QueryTypeKeyValue:
 		return []interface{}{}
 	default: // ast, regex
 		return plugin.QueryMatches(nil)
@@ -0,0 +1,202 @@
+package queries
+
+import (
+	"strings"
+
+	"github.com/aspect-build/aspect-gazelle/language/orion/plugin"
+	bzl "github.com/bazelbuild/buildtools/build"
+)
+
+func runStarlarkQueries(fileName string, sourceCode []byte, queries plugin.NamedQueries) (plugin.QueryResults, error) {
+	f, err := bzl.Parse(fileName, sourceCode)
+	if err != nil {
+		queryLog.Warnf("ignoring unparseable starlark file %q: %v", fileName, err)
+		f = &bzl.File{}
+	}
+
+	result := convertStarlarkFile(f)
+
+	results := make(plugin.QueryResults, len(queries))
+	for key := range queries {
+		results[key] = result
+	}
+	return results, nil
+}
+
+// Converts the top-level statements of a file to maps of the loads, functions,
+// rule/macro calls and module extension usages.
+func convertStarlarkFile(f *bzl.File) map[string]interface{} {
+	loads := make([]interface{}, 0)
+	functions := make([]interface{}, 0)
+	calls := make([]interface{}, 0)
+
+	// Module extension usages in declaration order, and by proxy variable name.
+	extensions := make([]interface{}, 0)
+	proxies := make(map[string]map[string]interface{})
+
+	for _, stmt := range f.Stmt {
+		switch s := stmt.(type) {
+		case *bzl.LoadStmt:
+			symbols := make(map[string]interface{}, len(s.To))
+			for i, to := range s.To {
+				symbols[to.Name] = s.From[i].Name
+			}
+			loads = append(loads, map[string]interface{}{
+				"module":  s.Module.Value,
+				"symbols": symbols,
+				"line":    startLine(s),
+			})
+
+		case *bzl.DefStmt:
+			functions = append(functions, convertStarlarkFunction(s))
+
+		case *bzl.AssignExpr:
+			// proxy = use_extension("@repo//:extensions.bzl", "name", dev_dependency = True)
+			lhs, isIdent := s.LHS.(*bzl.Ident)
+			call, isCall := s.RHS.(*bzl.CallExpr)
+			if !isIdent || !isCall {
+				continue
+			}
+			if callName(call) != "use_extension" {
+				// my_rule = rule(...)
+				calls = append(calls, convertStarlarkCall(call, lhs.Name))
+				continue
+			}
+			args, kwargs := callArgs(call)
+			ext := map[string]interface{}{
+				"name":           lhs.Name,
+				"file":           argValue(args, kwargs, 0, "extension_bzl_file"),
+				"extension":      argValue(args, kwargs, 1, "extension_name"),
+				"dev_dependency": kwargs["dev_dependency"] == true,
+				"tags":           make([]interface{}, 0),
+				"repos":          make(map[string]interface{}),
+				"line":           startLine(s),
+			}
+			proxies[lhs.Name] = ext
+			extensions = append(extensions, ext)
+
+		case *bzl.CallExpr:
+			// proxy.tag(...) of a module extension
+			if dot, isDot := s.X.(*bzl.DotExpr); isDot {
+				if ident, isIdent := dot.X.(*bzl.Ident); isIdent && proxies[ident.Name] != nil {
+					_, kwargs := callArgs(s)
+					ext := proxies[ident.Name]
+					ext["tags"] = append(ext["tags"].([]interface{}), map[string]interface{}{
+						"name":  dot.Name,
+						"attrs": kwargs,
+						"line":  startLine(s),
+					})
+					continue
+				}
+			}
+
+			// use_repo(proxy, "name", apparent = "name")
+			if callName(s) == "use_repo" && len(s.List) > 0 {
+				if ident, isIdent := s.List[0].(*bzl.Ident); isIdent && proxies[ident.Name] != nil {
+					repos := proxies[ident.Name]["repos"].(map[string]interface{})
+					for _, arg := range s.List[1:] {
+						if assign, isAssign := arg.(*bzl.AssignExpr); isAssign {
+							if lhs, isIdent := assign.LHS.(*bzl.Ident); isIdent {
+								repos[lhs.Name] = plugin.ExprValue(assign.RHS)
+							}
+						} else if name, isString := arg.(*bzl.StringExpr); isString {
+							repos[name.Value] = name.Value
+						}
+					}
+					continue
+				}
+			}
+
+			calls = append(calls, convertStarlarkCall(s, ""))
+		}
+	}
+
+	return map[string]interface{}{
+		"loads":      loads,
+		"functions":  functions,
+		"calls":      calls,
+		"extensions": extensions,
+	}
+}
+
+// Converts a call, optionally assigned to a variable, to a map of the called
+// function and arguments.
+func convertStarlarkCall(call *bzl.CallExpr, assign string) map[string]interface{} {
+	args, kwargs := callArgs(call)
+	return map[string]interface{}{
+		"kind":   bzl.FormatString(call.X),
+		"args":   args,
+		"attrs":  kwargs,
+		"assign": assign,
+		"line":   startLine(call),
+	}
+}
+
+func convertStarlarkFunction(def *bzl.DefStmt) map[string]interface{} {
+	params := make([]interface{}, 0, len(def.Params))
+	for _, p := range def.Params {
+		switch p := p.(type) {
+		case *bzl.Ident:
+			params = append(params, p.Name)
+		case *bzl.AssignExpr:
+			// A parameter with a default value
+			params = append(params, bzl.FormatString(p.LHS))
+		default:
+			// *args, **kwargs or the bare * separator
+			params = append(params, bzl.FormatString(p))
+		}
+	}
+
+	doc := ""
+	if len(def.Body) > 0 {
+		if s, isString := def.Body[0].(*bzl.StringExpr); isString {
+			doc = strings.TrimSpace(s.Value)
+		}
+	}
+
+	return map[string]interface{}{
+		"name":   def.Name,
+		"params": params,
+		"doc":    doc,
+		"line":   startLine(def),
+	}
+}
+
+// Returns the positional and keyword arguments of a call.
+func callArgs(call *bzl.CallExpr) ([]interface{}, map[string]interface{}) {
+	args := make([]interface{}, 0)
+	kwargs := make(map[string]interface{})
+	for _, arg := range call.List {
+		if assign, isAssign := arg.(*bzl.AssignExpr); isAssign {
+			if lhs, isIdent := assign.LHS.(*bzl.Ident); isIdent {
+				kwargs[lhs.Name] = plugin.ExprValue(assign.RHS)
+				continue
+			}
+		}
+		args = append(args, plugin.ExprValue(arg))
+	}
+	return args, kwargs
+}
+
+// Returns the keyword argument or, if not set, the positional argument at pos.
+func argValue(args []interface{}, kwargs map[string]interface{}, pos int, name string) interface{} {
+	if v, isSet := kwargs[name]; isSet {
+		return v
+	}
+	if pos < len(args) {
+		return args[pos]
+	}
+	return nil
+}
+
+func callName(call *bzl.CallExpr) string {
+	if ident, isIdent := call.X.(*bzl.Ident); isIdent {
+		return ident.Name
+	}
+	return ""
+}
+
+func startLine(e bzl.Expr) int {
+	start, _ := e.Span()
+	return start.Line
+}
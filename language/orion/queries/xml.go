@@ -0,0 +1,145 @@
+package queries
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/antchfx/xmlquery"
+	"github.com/antchfx/xpath"
+	"github.com/aspect-build/aspect-gazelle/language/orion/plugin"
+)
+
+func runXmlQueries(fileName string, sourceCode []byte, queries plugin.NamedQueries) (plugin.QueryResults, error) {
+	doc, err := xmlquery.Parse(bytes.NewReader(sourceCode))
+	if err != nil {
+		queryLog.Warnf("ignoring unparseable XML file %q: %v", fileName, err)
+	}
+
+	results := make(plugin.QueryResults, len(queries))
+	for key, q := range queries {
+		if doc == nil {
+			results[key] = make([]interface{}, 0)
+			continue
+		}
+		r, err := runXmlQuery(doc, q.(*plugin.XmlQuery))
+		if err != nil {
+			return nil, err
+		}
+		results[key] = r
+	}
+	return results, nil
+}
+
+func runXmlQuery(doc *xmlquery.Node, q *plugin.XmlQuery) (result interface{}, err error) {
+	expr, err := parseXmlQuery(q.Query, q.Namespaces)
+	if err != nil {
+		return nil, err
+	}
+
+	// xpath reports errors of function arguments by panicking when evaluated
+	defer func() {
+		if r := recover(); r != nil {
+			result, err = nil, fmt.Errorf("XmlQuery %q: %v", q.Query, r)
+		}
+	}()
+
+	v := expr.Evaluate(xmlquery.CreateXPathNavigator(doc))
+
+	// A list of each node, or a single string, number or boolean.
+	nodes, isNodes := v.(*xpath.NodeIterator)
+	if !isNodes {
+		return []interface{}{v}, nil
+	}
+
+	matches := make([]interface{}, 0)
+	for nodes.MoveNext() {
+		matches = append(matches, convertXmlNodeToValue(nodes.Current().(*xmlquery.NodeNavigator)))
+	}
+	return matches, nil
+}
+
+// Elements are converted to maps of the name, attributes, text and child elements,
+// documents to the map of the root element and other nodes to their text value.
+func convertXmlNodeToValue(nav *xmlquery.NodeNavigator) interface{} {
+	n := nav.Current()
+
+	switch nav.NodeType() {
+	case xpath.RootNode:
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == xmlquery.ElementNode {
+				return convertXmlElementToValue(c)
+			}
+		}
+		return nil
+	case xpath.ElementNode:
+		return convertXmlElementToValue(n)
+	case xpath.TextNode:
+		// Including CDATA sections, which have no NodeNavigator.Value()
+		return n.Data
+	default:
+		return nav.Value()
+	}
+}
+
+func convertXmlElementToValue(n *xmlquery.Node) map[string]interface{} {
+	attrs := make(map[string]interface{}, len(n.Attr))
+	for _, a := range n.Attr {
+		// Namespace declarations are not attributes of the XPath data model
+		if a.Name.Space == "xmlns" || (a.Name.Space == "" && a.Name.Local == "xmlns") {
+			continue
+		}
+		attrs[qualifiedXmlName(a.Name.Space, a.Name.Local)] = a.Value
+	}
+
+	var text strings.Builder
+	children := make([]interface{}, 0)
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		switch c.Type {
+		case xmlquery.TextNode, xmlquery.CharDataNode:
+			text.WriteString(c.Data)
+		case xmlquery.ElementNode:
+			children = append(children, convertXmlElementToValue(c))
+		}
+	}
+
+	return map[string]interface{}{
+		"name":     qualifiedXmlName(n.Prefix, n.Data),
+		"attrs":    attrs,
+		"text":     strings.TrimSpace(text.String()),
+		"children": children,
+	}
+}
+
+func qualifiedXmlName(prefix, local string) string {
+	if prefix == "" {
+		return local
+	}
+	return prefix + ":" + local
+}
+
+var xpathQueryCache = sync.Map{}
+
+// parseXmlQuery compiles an XPath expression with the namespace URIs of its prefixes.
+func parseXmlQuery(query string, namespaces map[string]string) (*xpath.Expr, error) {
+	// Keyed by the query and namespaces, sorted by prefix
+	var key strings.Builder
+	key.WriteString(query)
+	for _, prefix := range slices.Sorted(maps.Keys(namespaces)) {
+		fmt.Fprintf(&key, "\x00%s\x00%s", prefix, namespaces[prefix])
+	}
+
+	expr, loaded := xpathQueryCache.Load(key.String())
+	if !loaded {
+		e, err := xpath.CompileWithNS(query, namespaces)
+		if err != nil {
+			return nil, err
+		}
+		expr, _ = xpathQueryCache.LoadOrStore(key.String(), e)
+	}
+
+	return expr.(*xpath.Expr), nil
+}
@@ -0,0 +1,136 @@
+package queries
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aspect-build/aspect-gazelle/language/orion/plugin"
+)
+
+const testPom = `<?xml version="1.0" encoding="UTF-8"?>
+<project xmlns="http://maven.apache.org/POM/4.0.0">
+  <!-- the artifact -->
+  <artifactId>app</artifactId>
+  <packaging attr="x">jar</packaging>
+  <dependencies>
+    <dependency scope="test">
+      <artifactId>junit</artifactId>
+      <version>4.13</version>
+    </dependency>
+    <dependency>
+      <artifactId>guava</artifactId>
+      <version>33.0</version>
+    </dependency>
+  </dependencies>
+  <manifest xmlns:android="http://schemas.android.com/apk/res/android" android:name="main" name="plain">
+    <text><![CDATA[a < b]]></text>
+  </manifest>
+</project>`
+
+func runTestXmlQuery(t *testing.T, query string, namespaces map[string]string) interface{} {
+	t.Helper()
+
+	results, err := runXmlQueries("pom.xml", []byte(testPom), plugin.NamedQueries{
+		"q": &plugin.XmlQuery{Query: query, Namespaces: namespaces},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return results["q"]
+}
+
+func TestXmlQuery(t *testing.T) {
+	pom := map[string]string{"pom": "http://maven.apache.org/POM/4.0.0"}
+	android := map[string]string{"a": "http://schemas.android.com/apk/res/android"}
+
+	for _, tc := range []struct {
+		query      string
+		namespaces map[string]string
+		expected   []interface{}
+	}{
+		{"/project/artifactId/text()", nil, []interface{}{"app"}},
+		{"//dependency[not(@scope = 'test')]/artifactId/text()", nil, []interface{}{"guava"}},
+		{"/pom:project/pom:artifactId/text()", pom, []interface{}{"app"}},
+		{"//pom:dependency/pom:version/text()", pom, []interface{}{"4.13", "33.0"}},
+		{"//manifest/@a:name", android, []interface{}{"main"}},
+		{"//manifest/@android:name", nil, []interface{}{"main"}},
+		{"//manifest/@name", nil, []interface{}{"plain"}},
+		{"//manifest/text/text()", nil, []interface{}{"a < b"}},
+		{"/project/comment()", nil, []interface{}{" the artifact "}},
+		{"//nothing", nil, []interface{}{}},
+		{"count(//dependency)", nil, []interface{}{2.0}},
+		{"string(//dependency[2]/artifactId)", nil, []interface{}{"guava"}},
+		{"boolean(//nothing)", nil, []interface{}{false}},
+		{"/project/packaging", nil, []interface{}{
+			map[string]interface{}{
+				"name":     "packaging",
+				"attrs":    map[string]interface{}{"attr": "x"},
+				"text":     "jar",
+				"children": []interface{}{},
+			},
+		}},
+		{"//manifest", nil, []interface{}{
+			map[string]interface{}{
+				"name":  "manifest",
+				"attrs": map[string]interface{}{"android:name": "main", "name": "plain"},
+				"text":  "",
+				"children": []interface{}{
+					map[string]interface{}{
+						"name":     "text",
+						"attrs":    map[string]interface{}{},
+						"text":     "a < b",
+						"children": []interface{}{},
+					},
+				},
+			},
+		}},
+	} {
+		t.Run(tc.query, func(t *testing.T) {
+			if actual := runTestXmlQuery(t, tc.query, tc.namespaces); !reflect.DeepEqual(actual, tc.expected) {
+				t.Errorf("got %#v, expected %#v", actual, tc.expected)
+			}
+		})
+	}
+}
+
+func TestXmlQueryErrors(t *testing.T) {
+	t.Run("undeclared prefix", func(t *testing.T) {
+		if _, err := parseXmlQuery("/x:project", map[string]string{"pom": "urn:pom"}); err == nil {
+			t.Error("expected an error for a prefix not declared in the namespaces")
+		}
+	})
+
+	t.Run("invalid function argument", func(t *testing.T) {
+		_, err := runXmlQueries("pom.xml", []byte(testPom), plugin.NamedQueries{
+			"q": &plugin.XmlQuery{Query: "sum('a')"},
+		})
+		if err == nil {
+			t.Error("expected an error evaluating an invalid function argument")
+		}
+	})
+
+	t.Run("unparseable document", func(t *testing.T) {
+		results, err := runXmlQueries("pom.xml", []byte("<project>"), plugin.NamedQueries{
+			"q": &plugin.XmlQuery{Query: "//project"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r := results["q"]; !reflect.DeepEqual(r, []interface{}{}) {
+			t.Errorf("expected no results, got %#v", r)
+		}
+	})
+}
+
+func TestParseXmlQueryCache(t *testing.T) {
+	a, err := parseXmlQuery("//a:x", map[string]string{"a": "urn:a", "b": "urn:b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := parseXmlQuery("//a:x", map[string]string{"b": "urn:b", "a": "urn:a"}); b != a {
+		t.Error("expected the compiled query to be reused for equal namespaces")
+	}
+	if c, _ := parseXmlQuery("//a:x", map[string]string{"a": "urn:other"}); c == a {
+		t.Error("expected a query with other namespaces to be compiled again")
+	}
+}
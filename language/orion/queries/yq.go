@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"fmt"
 
-	BazelLog "github.com/aspect-build/aspect-gazelle/common/logger"
 	"github.com/aspect-build/aspect-gazelle/language/orion/plugin"
 	"github.com/mikefarah/yq/v4/pkg/yqlib"
 )
@@ -76,7 +75,7 @@ func convertYqNodeToValue(node *yqlib.CandidateNode) interface{} {
 		}
 		return val
 	default:
-		BazelLog.Fatalf("Unknown yq node kind: %v", node.Kind)
+		queryLog.Fatalf("Unknown yq node kind: %v", node.Kind)
 		return nil
 	}
 }
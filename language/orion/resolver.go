@@ -4,7 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"iter"
-	"path"
+	"slices"
 	"strings"
 
 	common "github.com/aspect-build/aspect-gazelle/common"
@@ -12,7 +12,6 @@ import (
 	plugin "github.com/aspect-build/aspect-gazelle/language/orion/plugin"
 	"github.com/bazelbuild/bazel-gazelle/config"
 	"github.com/bazelbuild/bazel-gazelle/label"
-	gazelleLanguage "github.com/bazelbuild/bazel-gazelle/language"
 	"github.com/bazelbuild/bazel-gazelle/repo"
 	"github.com/bazelbuild/bazel-gazelle/resolve"
 	"github.com/bazelbuild/bazel-gazelle/rule"
@@ -41,33 +40,17 @@ func symbolToImportSpec(symbol plugin.Symbol) resolve.ImportSpec {
 	}
 }
 
-const extGeneratedRules = "__starzelle_generated_rules"
-const extGeneratedRulesPkg = "__starzelle_generated_rules_pkg"
-
-func (re *GazelleHost) importsGenerateRules(cfg *BUILDConfig, c *config.Config, f *rule.File) gazelleLanguage.GenerateResult {
-	regularFiles, err := common.GetSourceRegularFiles(f.Pkg)
-	if err != nil {
-		BazelLog.Fatalf("Error getting regular files for %s: %v", f.Pkg, err)
-	}
-
-	return re.generateRules(cfg, gazelleLanguage.GenerateArgs{
-		File:   f,
-		Rel:    f.Pkg,
-		Dir:    path.Join(c.RepoRoot, f.Pkg),
-		Config: c,
-
-		// The RegularFiles are processed by the GazelleHost and passed to plugins.
-		RegularFiles: regularFiles,
-	})
-}
+const extPackageSymbols = "__starzelle_package_symbols"
+const extPackageSymbolsPkg = "__starzelle_package_symbols_pkg"
 
 // Determine what rule (r) outputs which can be imported.
 func (re *GazelleHost) Imports(c *config.Config, r *rule.Rule, f *rule.File) []resolve.ImportSpec {
-	BazelLog.Tracef("Imports(%s): //%s:%s", GazelleLanguageName, f.Pkg, r.Name())
+	log := indexLog.With(BazelLog.Fields{Package: f.Pkg})
+	log.Tracef("Imports(%s): //%s:%s", GazelleLanguageName, f.Pkg, r.Name())
 
 	// This rule was generated by this gazelle extension.
 	if declaration := r.PrivateAttr(targetDeclarationKey); declaration != nil {
-		BazelLog.Debugf("Imports(%s): //%s:%s (generated %s)", GazelleLanguageName, f.Pkg, r.Name(), r.Kind())
+		log.Debugf("Imports(%s): //%s:%s (generated %s)", GazelleLanguageName, f.Pkg, r.Name(), r.Kind())
 		return symbolToImportSpecList(declaration.(plugin.TargetDeclaration).Symbols)
 	}
 
@@ -84,25 +67,22 @@ func (re *GazelleHost) Imports(c *config.Config, r *rule.Rule, f *rule.File) []r
 	// a separate Import-extraction phase.
 	//
 	// When running partial generations this means we must manually invoke GenerateRules() if it
-	// was not invoked by gazelle as part of the partial run.
-	if c.Exts[extGeneratedRulesPkg] != f.Pkg {
-		BazelLog.Debugf("Imports.GenerateRules(%s): //%s", GazelleLanguageName, f.Pkg)
-		c.Exts[extGeneratedRules] = re.importsGenerateRules(cfg, c, f)
-		c.Exts[extGeneratedRulesPkg] = f.Pkg
+	// was not invoked by gazelle as part of the partial run, or load the symbols persisted by
+	// a previous run if the package has not changed.
+	if c.Exts[extPackageSymbolsPkg] != f.Pkg {
+		c.Exts[extPackageSymbols] = re.importsPackageSymbols(log, cfg, c, f)
+		c.Exts[extPackageSymbolsPkg] = f.Pkg
 	}
 
 	// Find this rule in the host-generated rules
-	genResult := c.Exts[extGeneratedRules].(gazelleLanguage.GenerateResult)
-	for _, g := range genResult.Gen {
-		if g.Kind() == r.Kind() && g.Name() == r.Name() {
+	pkgSymbols := c.Exts[extPackageSymbols].(packageSymbols)
+	for _, t := range pkgSymbols.Targets {
+		if t.Kind == r.Kind() && t.Name == r.Name() {
 			// TODO: what if the rule generation is different and not what is expected?
 			// ... it is possible this directory is not being updated, and if it were updated
 			// the result would be different.
-			if declaration := g.PrivateAttr(targetDeclarationKey); declaration != nil {
-				BazelLog.Debugf("Imports(%s): //%s:%s (not generated %s)", GazelleLanguageName, f.Pkg, g.Name(), g.Kind())
-				return symbolToImportSpecList(declaration.(plugin.TargetDeclaration).Symbols)
-			}
-			break
+			log.Debugf("Imports(%s): //%s:%s (not generated %s)", GazelleLanguageName, f.Pkg, t.Name, t.Kind)
+			return symbolToImportSpecList(t.Symbols)
 		}
 	}
 
@@ -124,7 +104,8 @@ func (re *GazelleHost) Embeds(r *rule.Rule, f label.Label) []label.Label {
 
 // Resolve the dependencies of a rule and apply them to the necessary rule attributes.
 func (re *GazelleHost) Resolve(c *config.Config, ix *resolve.RuleIndex, rc *repo.RemoteCache, r *rule.Rule, importData interface{}, from label.Label) {
-	BazelLog.Debugf("Resolve(%s): //%s:%s", GazelleLanguageName, from.Pkg, r.Name())
+	log := resolveLog.With(BazelLog.Fields{Package: from.Pkg})
+	log.Debugf("Resolve(%s): //%s:%s", GazelleLanguageName, from.Pkg, r.Name())
 
 	pluginIdAttr := r.PrivateAttr(targetPluginKey)
 	if pluginIdAttr == nil {
@@ -137,14 +118,19 @@ func (re *GazelleHost) Resolve(c *config.Config, ix *resolve.RuleIndex, rc *repo
 	attrValues := importData.(map[string]*attributeValue)
 
 	for attr, attrValue := range attrValues {
+		if attrValue.isStructured() {
+			re.resolveStructuredAttr(log, c, ix, pluginId, r, attr, attrValue, from)
+			continue
+		}
+
 		// The attribute is only constants (no imports) and needs no resolution.
 		if len(attrValue.imports) == 0 {
 			continue
 		}
 
-		importLabels, err := re.resolveImports(c, ix, pluginId, attrValue.imports, from)
+		importLabels, err := re.resolveImports(log, c, ix, pluginId, r, attr, attrValue.imports, from)
 		if err != nil {
-			common.ImportErrorf(c, "Resolution Error: %v", err)
+			common.ReportDiagnostic(c, common.NewDiagnostic(DiagUnresolvedImport, "Resolution Error: %v", err).WithRule(c, from, r))
 			continue
 		}
 
@@ -160,7 +146,9 @@ func (re *GazelleHost) Resolve(c *config.Config, ix *resolve.RuleIndex, rc *repo
 					r.SetAttr(attr, dep)
 				}
 			default:
-				common.GenerationErrorf(c, "Attribute %q on %s has resolved to multiple values: %v", attr, r.Name(), importLabels)
+				common.ReportDiagnostic(c, common.NewDiagnostic(DiagAmbiguousImport, "Attribute %q on %s has resolved to multiple values: %v", attr, r.Name(), importLabels).
+					WithRule(c, from, r).
+					WithFix("add a `# gazelle:resolve` directive to select a single target"))
 			}
 		} else {
 			value := attrValue.values
@@ -176,25 +164,100 @@ func (re *GazelleHost) Resolve(c *config.Config, ix *resolve.RuleIndex, rc *repo
 	}
 }
 
+// resolveStructuredAttr resolves the imports of each condition of a select() or entry of a dict.
+func (re *GazelleHost) resolveStructuredAttr(log *BazelLog.Logger, c *config.Config, ix *resolve.RuleIndex, pluginId plugin.PluginId, r *rule.Rule, attr string, attrValue *attributeValue, from label.Label) {
+	// The attribute is only constants (no imports) and needs no resolution.
+	if len(attrValue.allImports()) == 0 {
+		return
+	}
+
+	value, err := attrValue.buildStructured(func(v *attributeValue) (interface{}, error) {
+		importLabels, err := re.resolveImports(log, c, ix, pluginId, r, attr, v.imports, from)
+		if err != nil {
+			return nil, err
+		}
+
+		if v.singleton {
+			switch importLabels.Size() {
+			case 0:
+				return unresolvedValue(v)
+			case 1:
+				for dep := range importLabels.Labels() {
+					return dep, nil
+				}
+			}
+			return nil, fmt.Errorf("attribute %q on %s has resolved to multiple values: %v", attr, r.Name(), importLabels)
+		}
+
+		values := slices.Clip(v.values)
+		for l := range importLabels.Labels() {
+			values = append(values, l)
+		}
+		if len(values) == 0 {
+			return nil, nil
+		}
+		return values, nil
+	})
+	if err != nil {
+		common.ReportDiagnostic(c, common.NewDiagnostic(DiagUnresolvedImport, "Resolution Error: %v", err).WithRule(c, from, r))
+		return
+	}
+
+	if value != nil {
+		r.SetAttr(attr, value)
+	} else {
+		r.DelAttr(attr)
+	}
+}
+
 func (re *GazelleHost) resolveImports(
+	log *BazelLog.Logger,
 	c *config.Config,
 	ix *resolve.RuleIndex,
 	pluginId plugin.PluginId,
+	r *rule.Rule,
+	attr string,
 	imports []plugin.TargetImport,
 	from label.Label,
 ) (*common.LabelSet, error) {
 	deps := common.NewLabelSet(from)
 
+	// The plugin resolve stage, if implemented, before the default resolution.
+	resolver, _ := re.plugins[pluginId].(plugin.ImportResolver)
+
 	var errs []error
 
 	for _, imp := range imports {
+		if resolver != nil {
+			result, err := re.pluginResolveImport(c, ix, resolver, pluginId, r, attr, imp, from)
+			if err != nil {
+				diag := common.NewDiagnostic(DiagResolveError, "Import %q from %q (%s) failed to resolve: %v", imp.Id, imp.From, pluginId, err).
+					WithRule(c, from, r)
+
+				var resolveErr *plugin.ResolveError
+				if errors.As(err, &resolveErr) && resolveErr.Fix != "" {
+					diag = diag.WithFix("%s", resolveErr.Fix)
+				}
+				common.ReportDiagnostic(c, diag)
+				continue
+			}
+
+			if result.Resolved {
+				for _, l := range result.Labels {
+					resolved := pluginLabel(l)
+					deps.Add(&resolved)
+				}
+				continue
+			}
+		}
+
 		resolutionType, resolved, err := re.resolveImport(c, ix, pluginId, imp, from)
 		if err != nil {
 			return nil, err
 		}
 
 		if resolutionType == Resolution_NotFound {
-			BazelLog.Debugf("import %q for target %v not found", imp.Id, from)
+			log.Debugf("import %q for target %v not found", imp.Id, from)
 
 			if !imp.Optional {
 				notFound := fmt.Errorf("Import %q (provider %q) from %q is an unknown dependency",
@@ -218,6 +281,78 @@ func (re *GazelleHost) resolveImports(
 	return deps, errors.Join(errs...)
 }
 
+// pluginResolveImport invokes the plugin resolve stage for an import not
+// overridden by a `# gazelle:resolve` directive.
+func (host *GazelleHost) pluginResolveImport(
+	c *config.Config,
+	ix *resolve.RuleIndex,
+	resolver plugin.ImportResolver,
+	pluginId plugin.PluginId,
+	r *rule.Rule,
+	attr string,
+	impt plugin.TargetImport,
+	from label.Label,
+) (plugin.ResolveResult, error) {
+	for importSpec := range importSpecsToTry(impt, from.Pkg) {
+		if _, ok := resolve.FindRuleWithOverride(c, importSpec, GazelleLanguageName); ok {
+			return plugin.ResolveResult{}, nil
+		}
+		if _, ok := resolve.FindRuleWithOverride(c, importSpec, impt.Provider); ok {
+			return plugin.ResolveResult{}, nil
+		}
+	}
+
+	cfg := getBUILDConfig(c, from.Pkg)
+	prep, found := cfg.pluginPrepareResults[pluginId]
+	if !found {
+		prep.PrepareContext = plugin.PrepareContext{RepoName: cfg.repoName, Rel: from.Pkg}
+	}
+
+	// Diagnostics of the resolve stage point at the rule being resolved, including
+	// packages the plugin was not prepared for.
+	prep.PrepareContext.Report = func(d common.Diagnostic) {
+		d.Code = DiagPlugin
+		common.ReportDiagnostic(c, d.WithRule(c, from, r))
+	}
+
+	target := plugin.Label{Repo: from.Repo, Pkg: from.Pkg, Name: from.Name}
+	candidates := host.importCandidates(c, ix, impt, from)
+
+	return resolver.Resolve(plugin.NewResolveContext(prep.PrepareContext, target, r.Kind(), attr, impt, candidates, host.database))
+}
+
+// importCandidates returns the labels providing an import according to the rule
+// index and the symbol db, excluding self imports.
+func (host *GazelleHost) importCandidates(c *config.Config, ix *resolve.RuleIndex, impt plugin.TargetImport, from label.Label) []plugin.Label {
+	var candidates []plugin.Label
+	seen := make(map[label.Label]bool)
+
+	add := func(l label.Label) {
+		l = l.Abs(from.Repo, from.Pkg)
+		if l == from || seen[l] {
+			return
+		}
+		seen[l] = true
+		candidates = append(candidates, plugin.Label{Repo: l.Repo, Pkg: l.Pkg, Name: l.Name})
+	}
+
+	for importSpec := range importSpecsToTry(impt, from.Pkg) {
+		for _, match := range ix.FindRulesByImportWithConfig(c, importSpec, GazelleLanguageName) {
+			if !match.IsSelfImport(from) {
+				add(match.Label)
+			}
+		}
+	}
+
+	for _, s := range host.database.LookupSymbols(impt.Id) {
+		if s.Provider == impt.Provider {
+			add(symbolLabel(s))
+		}
+	}
+
+	return candidates
+}
+
 func (host *GazelleHost) resolveImport(
 	c *config.Config,
 	ix *resolve.RuleIndex,
@@ -360,10 +495,15 @@ func isPkgAncestor(anc, pkg string) bool {
 
 // symbolLabel converts a symbol-db entry's label into an absolute label.
 func symbolLabel(s plugin.TargetSymbol) label.Label {
+	return pluginLabel(s.Label)
+}
+
+// pluginLabel converts a plugin label into an absolute label.
+func pluginLabel(l plugin.Label) label.Label {
 	return label.Label{
-		Repo:     s.Label.Repo,
-		Pkg:      s.Label.Pkg,
-		Name:     s.Label.Name,
+		Repo:     l.Repo,
+		Pkg:      l.Pkg,
+		Name:     l.Name,
 		Relative: false,
 	}
 }
@@ -2,7 +2,9 @@ package stareval
 
 import (
 	"fmt"
+	"io"
 	"maps"
+	"os"
 	"path"
 
 	"github.com/bazelbuild/bazel-gazelle/label"
@@ -16,6 +18,21 @@ import (
 // The signature for a starlark module loader (see starlark.Thread.Load)
 type moduleLoader = func(thread *starlark.Thread, module string) (starlark.StringDict, error)
 
+// The signature for loading a starlark file within the repository at repoDir.
+type fileLoader = func(thread *starlark.Thread, file, repoDir string) (starlark.StringDict, error)
+
+// The thread local of the repository directory of the file being executed,
+// which repository-relative load() labels are resolved against.
+const repoDirKey = "$repoDir$"
+
+// The thread local of a *[]string recording the path of each file load()ed by
+// the file being executed, such as to compute a digest of all plugin sources.
+const LoadedFilesKey = "$loadedFiles$"
+
+// The thread local of an io.Writer receiving the print() output of the file being
+// executed and of the threads derived from it, os.Stdout if unset.
+const StdoutKey = "$stdout$"
+
 // Remain simple and strict like bazel starlark.
 var opts = &syntax.FileOptions{
 	TopLevelControl: true,
@@ -24,9 +41,11 @@ var opts = &syntax.FileOptions{
 
 // Copy of go.starlark.net/repl.MakeLoadOptions with the following changes:
 // * Add and passthru ExecFileOptions `src interface{}, predeclared starlark.StringDict`
+// * Record the repository of the loaded file in the thread executing it
+// * Record the loaded file in the LoadedFilesKey thread local, if set
 //
 // See https://github.com/google/starlark-go/blob/0d3f41d403af5d6607cdf241f12b7e0572f2cb58/repl/repl.go#L171-L200
-func makeLoadOptions(opts *syntax.FileOptions, predeclared starlark.StringDict) moduleLoader {
+func makeLoadOptions(opts *syntax.FileOptions, predeclared starlark.StringDict) fileLoader {
 	type entry struct {
 		globals starlark.StringDict
 		err     error
@@ -34,7 +53,7 @@ func makeLoadOptions(opts *syntax.FileOptions, predeclared starlark.StringDict)
 
 	var cache = make(map[string]*entry)
 
-	return func(thread *starlark.Thread, module string) (starlark.StringDict, error) {
+	return func(thread *starlark.Thread, module, repoDir string) (starlark.StringDict, error) {
 		e, ok := cache[module]
 		if e == nil {
 			if ok {
@@ -46,8 +65,13 @@ func makeLoadOptions(opts *syntax.FileOptions, predeclared starlark.StringDict)
 			cache[module] = nil
 
 			// Load it.
-			thread := &starlark.Thread{Name: "exec " + module, Load: thread.Load}
-			globals, err := starlark.ExecFileOptions(opts, thread, module, nil, predeclared)
+			loadThread := &starlark.Thread{Name: "exec " + module, Load: thread.Load}
+			loadThread.SetLocal(repoDirKey, repoDir)
+			if loadedFiles, isSet := thread.Local(LoadedFilesKey).(*[]string); isSet {
+				loadThread.SetLocal(LoadedFilesKey, loadedFiles)
+				*loadedFiles = append(*loadedFiles, module)
+			}
+			globals, err := starlark.ExecFileOptions(opts, loadThread, module, nil, predeclared)
 			e = &entry{globals, err}
 
 			// Update the cache.
@@ -57,28 +81,41 @@ func makeLoadOptions(opts *syntax.FileOptions, predeclared starlark.StringDict)
 	}
 }
 
-// Wrap a `moduleLoader` and add support for load()ing similar to bazel rulesets.
-func createRepoLoader(rootDir string, loader moduleLoader) moduleLoader {
+// Wrap a `fileLoader` and add support for load()ing similar to bazel rulesets.
+//
+// Labels without a repository are relative to the repository of the file being
+// executed, which is rootDir unless loaded from an external repository.
+func createRepoLoader(rootDir string, repos *repoResolver, loader fileLoader) moduleLoader {
 	return func(thread *starlark.Thread, module string) (starlark.StringDict, error) {
 		moduleLabel, err := label.Parse(module)
 		if err != nil {
 			return nil, fmt.Errorf("invalid load() label: %s", module)
 		}
 
+		repoDir := rootDir
+		if dir, isSet := thread.Local(repoDirKey).(string); isSet {
+			repoDir = dir
+		}
+
 		if moduleLabel.Repo != "" {
-			// FUTURE: loading from external repositories, local repository by name.
-			return nil, fmt.Errorf("repository load() unsupported: %s", module)
+			repoDir, err = repos.dir(moduleLabel.Repo)
+			if err != nil {
+				return nil, fmt.Errorf("load(%q): %w", module, err)
+			}
 		}
 
-		modulePath := path.Join(rootDir, moduleLabel.Pkg, moduleLabel.Name)
+		modulePath := path.Join(repoDir, moduleLabel.Pkg, moduleLabel.Name)
 
-		return loader(thread, modulePath)
+		return loader(thread, modulePath, repoDir)
 	}
 }
 
-func threadPrint(t *starlark.Thread, msg string) {
-	// TODO: stdout? log?
-	fmt.Printf("%s: %s\n", t.Name, msg)
+// print() writes to stdout as-is for debugging, aspect.log provides leveled
+// logging with the plugin context.
+func threadPrint(w io.Writer) func(t *starlark.Thread, msg string) {
+	return func(t *starlark.Thread, msg string) {
+		fmt.Fprintf(w, "%s: %s\n", t.Name, msg)
+	}
 }
 
 func Eval(rootDir, starpath string, libs starlark.StringDict, locals map[string]any) (starlark.StringDict, error) {
@@ -92,13 +129,17 @@ func Eval(rootDir, starpath string, libs starlark.StringDict, locals map[string]
 
 	maps.Copy(predeclared, libs)
 
-	loader := makeLoadOptions(opts, predeclared)
-	loader = createRepoLoader(rootDir, loader)
+	loader := createRepoLoader(rootDir, newRepoResolver(rootDir), makeLoadOptions(opts, predeclared))
+
+	stdout, isSet := locals[StdoutKey].(io.Writer)
+	if !isSet {
+		stdout = os.Stdout
+	}
 
 	thread := starlark.Thread{
 		Name:  "AspectConfigure",
 		Load:  loader,
-		Print: threadPrint,
+		Print: threadPrint(stdout),
 	}
 	for localName, local := range locals {
 		thread.SetLocal(localName, local)
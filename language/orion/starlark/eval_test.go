@@ -3,6 +3,8 @@ package stareval
 import (
 	"os"
 	"path"
+	"slices"
+	"strings"
 	"testing"
 
 	"go.starlark.net/starlark"
@@ -80,3 +82,113 @@ y = "s"
 		}
 	})
 }
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		p := path.Join(dir, name)
+		if err := os.MkdirAll(path.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestStarlarkRepoLoad(t *testing.T) {
+	// A repository loading files relative to itself
+	repoFiles := map[string]string{
+		"gazelle/helpers.star": `load("//gazelle:version.star", "version")
+greeting = "hello " + version`,
+		"gazelle/version.star": `version = "v1"`,
+	}
+
+	t.Run("override", func(t *testing.T) {
+		rootDir := t.TempDir()
+		repoDir := t.TempDir()
+		writeFiles(t, repoDir, repoFiles)
+		writeFiles(t, rootDir, map[string]string{
+			"plugin.star": `load("@my_rules//gazelle:helpers.star", "greeting")
+x = greeting`,
+		})
+		t.Setenv(repoOverridesEnv, "my_rules="+repoDir)
+
+		res, err := Eval(rootDir, "plugin.star", starlark.StringDict{}, map[string]any{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if x, _ := starlark.AsString(res["x"]); x != "hello v1" {
+			t.Errorf("Expected 'hello v1', got %v", res["x"])
+		}
+	})
+
+	t.Run("bzlmod output base", func(t *testing.T) {
+		rootDir := t.TempDir()
+		outputBase := t.TempDir()
+		writeFiles(t, path.Join(outputBase, "external", "rules_my+"), repoFiles)
+		writeFiles(t, outputBase, map[string]string{"execroot/_main/bazel-out/.keep": ""})
+		writeFiles(t, rootDir, map[string]string{
+			"MODULE.bazel": `bazel_dep(name = "rules_my", version = "1.0.0", repo_name = "my_rules")`,
+			"plugin.star": `load("@my_rules//gazelle:helpers.star", "greeting")
+x = greeting`,
+		})
+		if err := os.Symlink(path.Join(outputBase, "execroot/_main/bazel-out"), path.Join(rootDir, "bazel-out")); err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv(repoOverridesEnv, "")
+
+		res, err := Eval(rootDir, "plugin.star", starlark.StringDict{}, map[string]any{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if x, _ := starlark.AsString(res["x"]); x != "hello v1" {
+			t.Errorf("Expected 'hello v1', got %v", res["x"])
+		}
+	})
+
+	t.Run("unknown repository", func(t *testing.T) {
+		t.Setenv(repoOverridesEnv, "")
+		if _, err := run(t, `load("@unknown//:lib.star", "x")`); err == nil {
+			t.Error("Expected an error loading from an unknown repository")
+		}
+	})
+}
+
+func TestStarlarkLoadedFiles(t *testing.T) {
+	rootDir := t.TempDir()
+	writeFiles(t, rootDir, map[string]string{
+		"plugin.star": `load("//lib:a.star", "a")
+load("//lib:b.star", "b")
+x = a + b`,
+		"lib/a.star": `load("//lib:b.star", "b")
+a = b`,
+		"lib/b.star": `b = 1`,
+	})
+
+	loadedFiles := []string{}
+	if _, err := Eval(rootDir, "plugin.star", starlark.StringDict{}, map[string]any{LoadedFilesKey: &loadedFiles}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each file once, in the order first loaded
+	expected := []string{path.Join(rootDir, "lib/a.star"), path.Join(rootDir, "lib/b.star")}
+	if !slices.Equal(loadedFiles, expected) {
+		t.Errorf("Expected loaded files %v, got %v", expected, loadedFiles)
+	}
+}
+
+func TestStarlarkStdout(t *testing.T) {
+	rootDir := t.TempDir()
+	writeFiles(t, rootDir, map[string]string{
+		"plugin.star": `print("hello")`,
+	})
+
+	var stdout strings.Builder
+	if _, err := Eval(rootDir, "plugin.star", starlark.StringDict{}, map[string]any{StdoutKey: &stdout}); err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := "AspectConfigure: hello\n"; stdout.String() != expected {
+		t.Errorf("Expected stdout %q, got %q", expected, stdout.String())
+	}
+}
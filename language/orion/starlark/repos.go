@@ -0,0 +1,144 @@
+package stareval
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aspect-build/aspect-gazelle/common/bazel"
+	"github.com/bazelbuild/bazel-gazelle/label"
+)
+
+// Comma-separated list of name=path repository directories to load() from
+// instead of the repositories fetched by bazel, similar to --override_repository.
+// Relative paths are relative to the root directory of the plugins.
+const repoOverridesEnv = "ORION_REPO_OVERRIDES"
+
+// The bzlmod canonical repository name separator and name of the root module:
+// "+" and "" since Bazel 8, "~" and "_main" in Bazel 7.
+var canonicalNameFormats = []struct{ sep, root string }{
+	{"+", ""},
+	{"~", "_main"},
+}
+
+// Resolves the directories of external repositories for load() labels.
+type repoResolver struct {
+	rootDir string
+
+	loaded      bool
+	overrides   map[string]string
+	module      *bazel.ModuleFile
+	externalDir string
+}
+
+func newRepoResolver(rootDir string) *repoResolver {
+	return &repoResolver{rootDir: rootDir}
+}
+
+func (r *repoResolver) load() error {
+	if r.loaded {
+		return nil
+	}
+	r.loaded = true
+
+	overrides, err := parseRepoOverrides(r.rootDir, os.Getenv(repoOverridesEnv))
+	if err != nil {
+		return err
+	}
+	r.overrides = overrides
+
+	module, err := bazel.LoadModuleFile(r.rootDir)
+	if err != nil {
+		return err
+	}
+	r.module = module
+
+	// The bazel-out convenience symlink links to <output_base>/execroot/<workspace>/bazel-out
+	if out, err := filepath.EvalSymlinks(filepath.Join(r.rootDir, "bazel-out")); err == nil {
+		r.externalDir = filepath.Join(out, "..", "..", "..", "external")
+	}
+
+	return nil
+}
+
+func parseRepoOverrides(rootDir, overrides string) (map[string]string, error) {
+	m := make(map[string]string)
+	if overrides == "" {
+		return m, nil
+	}
+
+	for _, o := range strings.Split(overrides, ",") {
+		name, dir, isValid := strings.Cut(o, "=")
+		if !isValid || name == "" || dir == "" {
+			return nil, fmt.Errorf("invalid %s entry %q, expected name=path", repoOverridesEnv, o)
+		}
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(rootDir, dir)
+		}
+		m[strings.TrimLeft(name, "@")] = dir
+	}
+	return m, nil
+}
+
+// dir returns the directory of the repository with the apparent name.
+func (r *repoResolver) dir(name string) (string, error) {
+	if err := r.load(); err != nil {
+		return "", err
+	}
+
+	if dir, isOverridden := r.overrides[name]; isOverridden {
+		return dir, nil
+	}
+
+	if r.module != nil && (name == r.module.Name || name == r.module.RepoName) {
+		return r.rootDir, nil
+	}
+
+	if r.externalDir == "" {
+		return "", fmt.Errorf("repository @%s not found: no bazel output base found in %q and no %s override", name, r.rootDir, repoOverridesEnv)
+	}
+
+	for _, canonicalName := range r.canonicalNames(name) {
+		dir := filepath.Join(r.externalDir, canonicalName)
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return dir, nil
+		}
+	}
+
+	return "", fmt.Errorf("repository @%s not found in %q, it may not have been fetched by bazel", name, r.externalDir)
+}
+
+// canonicalNames returns the possible directory names of a repository within the
+// output base, the bzlmod canonical names and the WORKSPACE name.
+func (r *repoResolver) canonicalNames(name string) []string {
+	names := []string{}
+
+	for _, f := range canonicalNameFormats {
+		if module := r.module.ModuleName(name); module != "" {
+			names = append(names, module+f.sep)
+		}
+
+		for _, repo := range r.module.ExtensionRepos() {
+			if repo.ApparentName != name {
+				continue
+			}
+
+			extensionLabel, err := label.Parse(repo.ExtensionFile)
+			if err != nil {
+				continue
+			}
+
+			module := f.root
+			if extensionLabel.Repo != "" {
+				if extensionModule := r.module.ModuleName(extensionLabel.Repo); extensionModule != "" {
+					module = extensionModule + f.sep
+				}
+			}
+
+			names = append(names, module+f.sep+repo.Extension+f.sep+repo.Name)
+		}
+	}
+
+	return append(names, name)
+}
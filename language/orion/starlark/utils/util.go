@@ -39,7 +39,7 @@ func Write(v any) starlark.Value {
 		return WriteMap(v, Write)
 	}
 
-	BazelLog.Fatalf("Failed to write value %v of type %T", v, v)
+	BazelLog.With(BazelLog.Fields{Language: "orion"}).Fatalf("Failed to write value %v of type %T", v, v)
 	return nil
 }
 
@@ -0,0 +1,123 @@
+package starzelle
+
+/**
+ * Logging and diagnostics of starzelle plugins: aspect.log and aspect.diagnostic.
+ */
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	common "github.com/aspect-build/aspect-gazelle/common"
+	BazelLog "github.com/aspect-build/aspect-gazelle/common/logger"
+	"github.com/aspect-build/aspect-gazelle/language/orion/plugin"
+	starUtils "github.com/aspect-build/aspect-gazelle/language/orion/starlark/utils"
+	"go.starlark.net/starlark"
+)
+
+// The thread local of the plugin stage being invoked.
+var stageStateKey = "$starzelleStage$"
+
+type stageState struct {
+	log *BazelLog.Logger
+	ctx plugin.PrepareContext
+
+	// The source file being analyzed, the default file of diagnostics
+	source string
+}
+
+// stageLogger returns the logger of the stage running in the thread, or a
+// logger without plugin context when loading plugins.
+func stageLogger(t *starlark.Thread) *BazelLog.Logger {
+	if state, isStage := t.Local(stageStateKey).(*stageState); isStage {
+		return state.log
+	}
+	return BazelLog.With(BazelLog.Fields{Language: orionLanguageName})
+}
+
+func logBuiltin(logf func(l *BazelLog.Logger, format string, args ...any)) starUtils.ModuleFunction {
+	return func(t *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		if len(kwargs) > 0 {
+			return nil, fmt.Errorf("%s: unexpected keyword arguments", b.Name())
+		}
+
+		// Format the arguments like print()
+		parts := make([]string, 0, len(args))
+		for _, a := range args {
+			if s, isString := starlark.AsString(a); isString {
+				parts = append(parts, s)
+			} else {
+				parts = append(parts, a.String())
+			}
+		}
+
+		logf(stageLogger(t), "%s", strings.Join(parts, " "))
+		return starlark.None, nil
+	}
+}
+
+var logModule = starUtils.CreateModule(
+	"log",
+	map[string]starUtils.ModuleFunction{
+		"debug": logBuiltin((*BazelLog.Logger).Debugf),
+		"info":  logBuiltin((*BazelLog.Logger).Infof),
+		"warn":  logBuiltin((*BazelLog.Logger).Warnf),
+		"error": logBuiltin((*BazelLog.Logger).Errorf),
+	},
+	map[string]starlark.Value{},
+)
+
+var diagnosticSeverities = map[string]common.Severity{
+	"error":   common.SeverityError,
+	"warning": common.SeverityWarning,
+	"info":    common.SeverityInfo,
+}
+
+func reportDiagnostic(t *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var message, file, fix string
+	var line, column int
+	severity := "warning"
+
+	err := starlark.UnpackArgs(
+		"diagnostic",
+		args,
+		kwargs,
+		"message", &message,
+		"severity?", &severity,
+		"file?", &file,
+		"line?", &line,
+		"column?", &column,
+		"fix?", &fix,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	sev, isValid := diagnosticSeverities[severity]
+	if !isValid {
+		return nil, fmt.Errorf("invalid diagnostic severity %q, expected \"error\", \"warning\" or \"info\"", severity)
+	}
+
+	state, isStage := t.Local(stageStateKey).(*stageState)
+	if !isStage || state.ctx.Report == nil {
+		return nil, fmt.Errorf("aspect.diagnostic() can only be called within an extension stage")
+	}
+
+	d := common.Diagnostic{
+		Severity:     sev,
+		Message:      message,
+		SuggestedFix: fix,
+	}
+
+	// Files are relative to the BUILD file, defaulting to the analyzed source file
+	if file == "" {
+		file = state.source
+	}
+	if file != "" {
+		d = d.WithSource(path.Join(state.ctx.Rel, file), line, column)
+	}
+
+	state.ctx.Report(d)
+	return starlark.None, nil
+}
@@ -5,18 +5,26 @@ package starzelle
  */
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"os"
+	"path"
 
 	BazelLog "github.com/aspect-build/aspect-gazelle/common/logger"
 	"github.com/aspect-build/aspect-gazelle/language/orion/plugin"
 	stareval "github.com/aspect-build/aspect-gazelle/language/orion/starlark"
 	starUtils "github.com/aspect-build/aspect-gazelle/language/orion/starlark/utils"
+	"github.com/bazelbuild/bazel-gazelle/label"
 	"go.starlark.net/starlark"
 )
 
 var proxyStateKey = "$starzelleState$"
 
+// The gazelle language name of the orion host, used for contextual logging.
+const orionLanguageName = "orion"
+
 var EmptyPrepareResult = plugin.PrepareResult{
 	Sources: make(map[string][]plugin.SourceFilter),
 	Queries: plugin.NamedQueries{},
@@ -27,10 +35,13 @@ var EmptyDeclareTargetsResult = plugin.DeclareTargetsResult{}
 type starzelleState struct {
 	pluginPath string
 	host       plugin.PluginHost
+
+	// A digest of the plugin file and the files it load()s
+	version string
 }
 
 func LoadProxy(host plugin.PluginHost, pluginDir, pluginPath string) error {
-	BazelLog.Infof("Evaluate orion plugin: %q", pluginPath)
+	BazelLog.With(BazelLog.Fields{Language: orionLanguageName, Phase: "load"}).Infof("Evaluate orion plugin: %q", pluginPath)
 
 	state := starzelleState{
 		pluginPath: pluginPath,
@@ -43,12 +54,35 @@ func LoadProxy(host plugin.PluginHost, pluginDir, pluginPath string) error {
 		"aspect": aspectModule,
 	}
 
+	// The plugin file and every file it load()s, versioning the plugin
+	sources := []string{path.Join(pluginDir, pluginPath)}
+	evalState[stareval.LoadedFilesKey] = &sources
+
+	if out, isOutputHost := host.(plugin.OutputHost); isOutputHost {
+		evalState[stareval.StdoutKey] = out.Stdout()
+	}
+
 	_, err := stareval.Eval(pluginDir, pluginPath, libs, evalState)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	state.version, err = digestFiles(sources)
+	return err
+}
+
+// digestFiles returns a digest of the content of the files.
+func digestFiles(files []string) (string, error) {
+	digest := sha256.New()
+	for _, f := range files {
+		content, err := os.ReadFile(f)
+		if err != nil {
+			return "", fmt.Errorf("failed to read plugin source %q: %w", f, err)
+		}
+		fmt.Fprintf(digest, "%d:", len(content))
+		digest.Write(content)
+	}
+	return hex.EncodeToString(digest.Sum(nil)), nil
 }
 
 func (s *starzelleState) addKind(_ *starlark.Thread, name starlark.String, attributes *starlark.Dict) error {
@@ -61,7 +95,7 @@ func (s *starzelleState) addKind(_ *starlark.Thread, name starlark.String, attri
 	return nil
 }
 
-func (s *starzelleState) addPlugin(t *starlark.Thread, pluginId starlark.String, properties *starlark.Dict, prepare, analyze, declare *starlark.Function) error {
+func (s *starzelleState) addPlugin(t *starlark.Thread, pluginId starlark.String, properties *starlark.Dict, prepare, analyze, declare, resolve, fix *starlark.Function) error {
 	var pluginProperties map[string]plugin.Property
 	var err error
 
@@ -79,15 +113,24 @@ func (s *starzelleState) addPlugin(t *starlark.Thread, pluginId starlark.String,
 		Print: t.Print,
 	}
 
-	s.host.AddPlugin(starzellePluginProxy{
+	proxy := starzellePluginProxy{
 		t:          pluginThread,
 		name:       pluginId.GoString(),
 		pluginPath: s.pluginPath,
+		version:    &s.version,
 		properties: pluginProperties,
 		prepare:    prepare,
 		analyze:    analyze,
 		declare:    declare,
-	})
+		fix:        fix,
+	}
+
+	// Only plugins with a resolve stage implement plugin.ImportResolver
+	if resolve != nil {
+		s.host.AddPlugin(starzelleResolverProxy{starzellePluginProxy: proxy, resolve: resolve})
+	} else {
+		s.host.AddPlugin(proxy)
+	}
 
 	return nil
 }
@@ -110,8 +153,12 @@ type starzellePluginProxy struct {
 	pluginPath                string
 	properties                map[string]plugin.Property
 	prepare, analyze, declare *starlark.Function
+	fix                       *starlark.Function
 
-	// The thread this plugin is running in.
+	// The version of the plugin sources, set once the plugin file has been loaded.
+	version *string
+
+	// The thread template of the plugin, each stage invocation runs in a new thread.
 	t *starlark.Thread
 }
 
@@ -123,19 +170,27 @@ func (p starzellePluginProxy) Properties() map[string]plugin.Property {
 	return p.properties
 }
 
+var _ plugin.VersionedPlugin = (*starzellePluginProxy)(nil)
+
+func (p starzellePluginProxy) Version() string {
+	return *p.version
+}
+
 func (p starzellePluginProxy) Prepare(ctx plugin.PrepareContext) plugin.PrepareResult {
 	if p.prepare == nil {
 		return EmptyPrepareResult
 	}
 
-	v, err := starlark.Call(p.t, p.prepare, starlark.Tuple{ctx}, starUtils.EmptyKwArgs)
+	log := p.logger("prepare", ctx.Rel)
+
+	v, err := starlark.Call(p.thread(log, ctx, ""), p.prepare, starlark.Tuple{ctx}, starUtils.EmptyKwArgs)
 	if err != nil {
 		errStr := starUtils.ErrorStr(fmt.Sprintf("Failed to invoke %s:Prepare()", p.name), err)
 		fmt.Print(errStr)
 		if isFatal(err) {
-			BazelLog.Fatal(errStr)
+			log.Fatalf("%s", errStr)
 		} else {
-			BazelLog.Error(errStr)
+			log.Errorf("%s", errStr)
 		}
 		return EmptyPrepareResult
 	}
@@ -145,12 +200,12 @@ func (p starzellePluginProxy) Prepare(ctx plugin.PrepareContext) plugin.PrepareR
 		return EmptyPrepareResult
 	}
 
-	BazelLog.Debugf("%s:prepare(%q): %v\n", p.name, ctx.Rel, v)
+	log.Debugf("%s:prepare(%q): %v", p.name, ctx.Rel, v)
 
 	pr, isPR := v.(plugin.PrepareResult)
 	if !isPR {
 		errStr := fmt.Sprintf("Prepare %v is not a PrepareResult", v)
-		BazelLog.Error(errStr)
+		log.Errorf("%s", errStr)
 		fmt.Print(errStr)
 		return EmptyPrepareResult
 	}
@@ -163,14 +218,16 @@ func (p starzellePluginProxy) Analyze(ctx plugin.AnalyzeContext) error {
 	if p.analyze == nil {
 		return nil
 	}
-	_, err := starlark.Call(p.t, p.analyze, starlark.Tuple{&ctx}, starUtils.EmptyKwArgs)
+	log := p.logger("analyze", ctx.Rel).With(BazelLog.Fields{File: path.Join(ctx.Rel, ctx.Source.Path)})
+
+	_, err := starlark.Call(p.thread(log, ctx.PrepareContext, ctx.Source.Path), p.analyze, starlark.Tuple{&ctx}, starUtils.EmptyKwArgs)
 	if err != nil {
 		errStr := starUtils.ErrorStr(fmt.Sprintf("Failed to invoke %s:Analyze()", p.name), err)
 		fmt.Print(errStr)
 		if isFatal(err) {
-			BazelLog.Fatal(errStr)
+			log.Fatalf("%s", errStr)
 		} else {
-			BazelLog.Error(errStr)
+			log.Errorf("%s", errStr)
 		}
 		return nil
 	}
@@ -182,26 +239,154 @@ func (p starzellePluginProxy) DeclareTargets(ctx plugin.DeclareTargetsContext) p
 		return EmptyDeclareTargetsResult
 	}
 
-	_, err := starlark.Call(p.t, p.declare, starlark.Tuple{ctx}, starUtils.EmptyKwArgs)
+	log := p.logger("declare", ctx.Rel)
+
+	_, err := starlark.Call(p.thread(log, ctx.PrepareContext, ""), p.declare, starlark.Tuple{ctx}, starUtils.EmptyKwArgs)
 	if err != nil {
 		errStr := starUtils.ErrorStr(fmt.Sprintf("Failed to invoke %s:DeclareTargets()", p.name), err)
 		fmt.Print(errStr)
 		if isFatal(err) {
-			BazelLog.Fatal(errStr)
+			log.Fatalf("%s", errStr)
 		} else {
-			BazelLog.Error(errStr)
+			log.Errorf("%s", errStr)
 		}
 		return EmptyDeclareTargetsResult
 	}
 
 	actions := ctx.Targets.Actions()
 
-	BazelLog.Debugf("%s:declare(%q): %v\n", p.name, ctx.Rel, actions)
+	log.Debugf("%s:declare(%q): %v", p.name, ctx.Rel, actions)
 	return plugin.DeclareTargetsResult{
 		Actions: actions,
 	}
 }
 
+var _ plugin.RuleFixer = (*starzellePluginProxy)(nil)
+
+func (p starzellePluginProxy) Fix(ctx plugin.FixContext) plugin.FixResult {
+	if p.fix == nil {
+		return plugin.FixResult{}
+	}
+
+	log := p.logger("fix", ctx.Rel)
+
+	_, err := starlark.Call(p.thread(log, ctx.PrepareContext, ""), p.fix, starlark.Tuple{ctx}, starUtils.EmptyKwArgs)
+	if err != nil {
+		errStr := starUtils.ErrorStr(fmt.Sprintf("Failed to invoke %s:Fix()", p.name), err)
+		fmt.Print(errStr)
+		if isFatal(err) {
+			log.Fatalf("%s", errStr)
+		} else {
+			log.Errorf("%s", errStr)
+		}
+		return plugin.FixResult{}
+	}
+
+	actions := ctx.Fixes.Actions()
+
+	log.Debugf("%s:fix(%q): %v", p.name, ctx.Rel, actions)
+	return plugin.FixResult{
+		Actions: actions,
+	}
+}
+
+var _ plugin.ImportResolver = (*starzelleResolverProxy)(nil)
+
+// A plugin proxy with a resolve stage.
+type starzelleResolverProxy struct {
+	starzellePluginProxy
+	resolve *starlark.Function
+}
+
+func (p starzelleResolverProxy) Resolve(ctx plugin.ResolveContext) (plugin.ResolveResult, error) {
+	log := p.logger("resolve", ctx.Rel)
+
+	v, err := starlark.Call(p.thread(log, ctx.PrepareContext, ""), p.resolve, starlark.Tuple{ctx}, starUtils.EmptyKwArgs)
+	if err != nil {
+		errStr := starUtils.ErrorStr(fmt.Sprintf("Failed to invoke %s:Resolve()", p.name), err)
+		log.Errorf("%s", errStr)
+		return plugin.ResolveResult{}, &plugin.ResolveError{Message: errStr}
+	}
+
+	log.Debugf("%s:resolve(%q): %v", p.name, ctx.Import.Id, v)
+
+	return readResolveResult(v, ctx.Rel)
+}
+
+// readResolveResult reads the value returned by a resolve stage: None to use the
+// default resolution, a ResolveError, or one or more labels.
+func readResolveResult(v starlark.Value, rel string) (plugin.ResolveResult, error) {
+	switch v := v.(type) {
+	case starlark.NoneType:
+		return plugin.ResolveResult{}, nil
+	case *plugin.ResolveError:
+		return plugin.ResolveResult{}, v
+	case starlark.Indexable:
+		if _, isString := v.(starlark.String); !isString {
+			labels := make([]plugin.Label, 0, v.Len())
+			for i := range v.Len() {
+				l, err := readResolvedLabel(v.Index(i), rel)
+				if err != nil {
+					return plugin.ResolveResult{}, err
+				}
+				labels = append(labels, l)
+			}
+			return plugin.ResolveResult{Resolved: true, Labels: labels}, nil
+		}
+	}
+
+	l, err := readResolvedLabel(v, rel)
+	if err != nil {
+		return plugin.ResolveResult{}, err
+	}
+	return plugin.ResolveResult{Resolved: true, Labels: []plugin.Label{l}}, nil
+}
+
+// readResolvedLabel reads a Label or label string, relative labels being
+// relative to the package of the resolved target.
+func readResolvedLabel(v starlark.Value, rel string) (plugin.Label, error) {
+	switch v := v.(type) {
+	case plugin.Label:
+		return v, nil
+	case starlark.String:
+		l, err := label.Parse(v.GoString())
+		if err != nil {
+			return plugin.Label{}, &plugin.ResolveError{Message: fmt.Sprintf("invalid resolved label %s: %v", v, err)}
+		}
+		if l.Relative {
+			l.Pkg = rel
+		}
+		return plugin.Label{Repo: l.Repo, Pkg: l.Pkg, Name: l.Name}, nil
+	}
+	return plugin.Label{}, &plugin.ResolveError{Message: fmt.Sprintf("resolve must return None, a ResolveError or labels, got %s", v.Type())}
+}
+
+// thread creates a thread to invoke a plugin stage in, with the stage state
+// used by aspect.log and aspect.diagnostic.
+func (p starzellePluginProxy) thread(log *BazelLog.Logger, ctx plugin.PrepareContext, source string) *starlark.Thread {
+	t := &starlark.Thread{
+		Name:  p.t.Name,
+		Load:  p.t.Load,
+		Print: p.t.Print,
+	}
+	t.SetLocal(stageStateKey, &stageState{
+		log:    log,
+		ctx:    ctx,
+		source: source,
+	})
+	return t
+}
+
+// logger returns a logger with the context of a plugin stage invocation.
+func (p starzellePluginProxy) logger(phase, rel string) *BazelLog.Logger {
+	return BazelLog.With(BazelLog.Fields{
+		Language: orionLanguageName,
+		Plugin:   p.name,
+		Phase:    phase,
+		Package:  rel,
+	})
+}
+
 func readRuleKind(n starlark.String, v starlark.Value) (plugin.RuleKind, error) {
 	from, err1 := starUtils.ReadMapEntry(v, "From", starUtils.ReadString, "")
 	matchAny, err2 := starUtils.ReadMapEntry(v, "MatchAny", starUtils.ReadBool, false)
@@ -209,12 +394,14 @@ func readRuleKind(n starlark.String, v starlark.Value) (plugin.RuleKind, error)
 	nonEmptyAttrs, err4 := starUtils.ReadMapEntry(v, "NonEmptyAttrs", starUtils.ReadStringList, starUtils.EmptyStrings)
 	mergeableAttrs, err5 := starUtils.ReadMapEntry(v, "MergeableAttrs", starUtils.ReadStringList, starUtils.EmptyStrings)
 	resolveAttrs, err6 := starUtils.ReadMapEntry(v, "ResolveAttrs", starUtils.ReadStringList, starUtils.EmptyStrings)
+	workspaceRepo, err7 := starUtils.ReadMapEntry(v, "WorkspaceRepo", starUtils.ReadString, "")
 
-	err := errors.Join(err1, err2, err3, err4, err5, err6)
+	err := errors.Join(err1, err2, err3, err4, err5, err6, err7)
 
 	return plugin.RuleKind{
-		Name: n.GoString(),
-		From: from,
+		Name:          n.GoString(),
+		From:          from,
+		WorkspaceRepo: workspaceRepo,
 		KindInfo: plugin.KindInfo{
 			MatchAny:       matchAny,
 			MatchAttrs:     matchAttrs,
@@ -233,7 +420,7 @@ func readProperty(k string, v starlark.Value) (plugin.Property, error) {
 	}
 
 	if p.Name != "" && p.Name != k {
-		BazelLog.Errorf("Property name %q does not match key %q", p.Name, k)
+		BazelLog.With(BazelLog.Fields{Language: orionLanguageName, Phase: "load"}).Errorf("Property name %q does not match key %q", p.Name, k)
 	}
 
 	p.Name = k
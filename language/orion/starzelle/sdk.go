@@ -8,9 +8,13 @@ package starzelle
 
 import (
 	"fmt"
+	"slices"
 
+	"github.com/antchfx/xpath"
 	common "github.com/aspect-build/aspect-gazelle/common"
+	"github.com/aspect-build/aspect-gazelle/common/treesitter"
 	"github.com/aspect-build/aspect-gazelle/language/orion/plugin"
+	"github.com/aspect-build/aspect-gazelle/language/orion/queries/keyvalue"
 	starUtils "github.com/aspect-build/aspect-gazelle/language/orion/starlark/utils"
 	"go.starlark.net/starlark"
 )
@@ -23,7 +27,7 @@ func deprecatedRegisterConfigureExtension(t *starlark.Thread, b *starlark.Builti
 func registerOrionPlugin(t *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
 	var pluginId starlark.String
 	var properties *starlark.Dict
-	var prepare, analyze, declare *starlark.Function
+	var prepare, analyze, declare, resolve, fix *starlark.Function
 
 	err := starlark.UnpackArgs(
 		"orion_extension",
@@ -34,6 +38,8 @@ func registerOrionPlugin(t *starlark.Thread, b *starlark.Builtin, args starlark.
 		"prepare?", &prepare,
 		"analyze?", &analyze,
 		"declare?", &declare,
+		"resolve?", &resolve,
+		"fix?", &fix,
 	)
 	if err != nil {
 		return nil, err
@@ -46,6 +52,8 @@ func registerOrionPlugin(t *starlark.Thread, b *starlark.Builtin, args starlark.
 		prepare,
 		analyze,
 		declare,
+		resolve,
+		fix,
 	)
 
 	return starlark.None, err
@@ -125,6 +133,7 @@ func newAstQuery(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, k
 	var contentFilterValue starlark.String
 	var filterValue starlark.Value
 	var grammarValue starlark.String
+	var injectionValue *plugin.Injection
 
 	err := starlark.UnpackArgs(
 		"AstQuery",
@@ -134,11 +143,16 @@ func newAstQuery(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, k
 		"grammar?", &grammarValue,
 		"filter??", &filterValue,
 		"content_filter??", &contentFilterValue,
+		"injection??", &injectionValue,
 	)
 	if err != nil {
 		return nil, err
 	}
 
+	if injectionValue != nil && grammarValue.GoString() == "" {
+		return nil, fmt.Errorf("AstQuery: a grammar is required to query an injection")
+	}
+
 	base, err := readQueryBase(filterValue)
 	if err != nil {
 		return nil, err
@@ -153,9 +167,36 @@ func newAstQuery(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, k
 		QueryBase: base,
 		Grammar:   grammarValue.GoString(),
 		Query:     query.GoString(),
+		Injection: injectionValue,
 	}, nil
 }
 
+func newInjection(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var query, grammar, regex starlark.String
+
+	err := starlark.UnpackArgs(
+		"Injection",
+		args,
+		kwargs,
+		"query??", &query,
+		"grammar??", &grammar,
+		"regex??", &regex,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if grammar.GoString() != "" && query.GoString() == "" {
+		return nil, fmt.Errorf("Injection: a grammar is only applicable to an injection query")
+	}
+
+	inj, err := plugin.NewInjection(grammar.GoString(), query.GoString(), regex.GoString())
+	if err != nil {
+		return nil, err
+	}
+	return inj, nil
+}
+
 func newRegexQuery(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
 	var expression starlark.String
 	var contentFilterValue starlark.String
@@ -315,6 +356,156 @@ func newTomlQuery(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple,
 	}, nil
 }
 
+func newXmlQuery(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var queryValue starlark.String
+	var contentFilterValue starlark.String
+	var filterValue starlark.Value
+	var namespacesValue *starlark.Dict
+
+	err := starlark.UnpackArgs(
+		"XmlQuery",
+		args,
+		kwargs,
+		"query", &queryValue,
+		"filter??", &filterValue,
+		"content_filter??", &contentFilterValue,
+		"namespaces??", &namespacesValue,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var namespaces map[string]string
+	if namespacesValue != nil {
+		namespaces, err = starUtils.ReadMap2(namespacesValue, starUtils.ReadString)
+		if err != nil {
+			return nil, fmt.Errorf("XmlQuery: invalid namespaces: %w", err)
+		}
+	}
+
+	// Validate the expression when declared rather than when first run
+	if _, err := xpath.CompileWithNS(queryValue.GoString(), namespaces); err != nil {
+		return nil, err
+	}
+
+	base, err := readQueryBase(filterValue)
+	if err != nil {
+		return nil, err
+	}
+
+	base.ContentFilter, base.ContentFilterExpr, err = readContentFilter(contentFilterValue)
+	if err != nil {
+		return nil, err
+	}
+
+	return &plugin.XmlQuery{
+		QueryBase:  base,
+		Query:      queryValue.GoString(),
+		Namespaces: namespaces,
+	}, nil
+}
+
+func newProtoQuery(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var contentFilterValue starlark.String
+	var filterValue starlark.Value
+
+	err := starlark.UnpackArgs(
+		"ProtoQuery",
+		args,
+		kwargs,
+		"filter??", &filterValue,
+		"content_filter??", &contentFilterValue,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	base, err := readQueryBase(filterValue)
+	if err != nil {
+		return nil, err
+	}
+
+	base.ContentFilter, base.ContentFilterExpr, err = readContentFilter(contentFilterValue)
+	if err != nil {
+		return nil, err
+	}
+
+	return &plugin.ProtoQuery{
+		QueryBase: base,
+	}, nil
+}
+
+func newStarlarkQuery(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var contentFilterValue starlark.String
+	var filterValue starlark.Value
+
+	err := starlark.UnpackArgs(
+		"StarlarkQuery",
+		args,
+		kwargs,
+		"filter??", &filterValue,
+		"content_filter??", &contentFilterValue,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	base, err := readQueryBase(filterValue)
+	if err != nil {
+		return nil, err
+	}
+
+	base.ContentFilter, base.ContentFilterExpr, err = readContentFilter(contentFilterValue)
+	if err != nil {
+		return nil, err
+	}
+
+	return &plugin.StarlarkQuery{
+		QueryBase: base,
+	}, nil
+}
+
+func newKeyValueQuery(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var queryValue starlark.String
+	var formatValue starlark.String
+	var contentFilterValue starlark.String
+	var filterValue starlark.Value
+
+	err := starlark.UnpackArgs(
+		"KeyValueQuery",
+		args,
+		kwargs,
+		"query?", &queryValue,
+		"format??", &formatValue,
+		"filter??", &filterValue,
+		"content_filter??", &contentFilterValue,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	format := formatValue.GoString()
+	if format != "" && !slices.Contains(keyvalue.Formats, format) {
+		return nil, fmt.Errorf("KeyValueQuery: invalid format %q, expected one of %v", format, keyvalue.Formats)
+	}
+
+	base, err := readQueryBase(filterValue)
+	if err != nil {
+		return nil, err
+	}
+
+	base.ContentFilter, base.ContentFilterExpr, err = readContentFilter(contentFilterValue)
+	if err != nil {
+		return nil, err
+	}
+
+	return &plugin.KeyValueQuery{
+		QueryBase: base,
+		Query:     queryValue.GoString(),
+		Format:    format,
+	}, nil
+}
+
 func newSourceExtensions(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
 	exts, err := starUtils.ReadStringTuple(args)
 	if err != nil {
@@ -374,8 +565,9 @@ func newSourceFiles(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple
 }
 
 func newPrepareResult(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
-	var queriesValue *starlark.Dict
+	var queriesValue, grammarsValue *starlark.Dict
 	var sourcesValue starlark.Value
+	syntaxErrorsValue := string(plugin.SyntaxErrorsIgnore)
 
 	err := starlark.UnpackArgs(
 		"PrepareResult",
@@ -383,6 +575,8 @@ func newPrepareResult(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tup
 		kwargs,
 		"sources", &sourcesValue,
 		"queries??", &queriesValue,
+		"grammars??", &grammarsValue,
+		"syntax_errors??", &syntaxErrorsValue,
 	)
 	if err != nil {
 		return nil, err
@@ -427,12 +621,48 @@ func newPrepareResult(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tup
 		}
 	}
 
+	grammars, err := readGrammars(grammarsValue)
+	if err != nil {
+		return nil, err
+	}
+
+	syntaxErrors, err := plugin.ParseSyntaxErrorPolicy(syntaxErrorsValue)
+	if err != nil {
+		return nil, fmt.Errorf("'syntax_errors' %w", err)
+	}
+
 	return plugin.PrepareResult{
-		Sources: sources,
-		Queries: queries,
+		Sources:      sources,
+		Queries:      queries,
+		Grammars:     grammars,
+		SyntaxErrors: syntaxErrors,
 	}, nil
 }
 
+// readGrammars reads a `pattern:grammar` dict, later entries taking precedence.
+func readGrammars(d *starlark.Dict) (*treesitter.GrammarMapping, error) {
+	var grammars *treesitter.GrammarMapping
+	if d == nil {
+		return grammars, nil
+	}
+
+	for _, item := range d.Items() {
+		pattern, isPatternStr := item[0].(starlark.String)
+		grammar, isGrammarStr := item[1].(starlark.String)
+		if !isPatternStr || !isGrammarStr {
+			return nil, fmt.Errorf("'grammars' entry %v: %v must be a string to string mapping", item[0], item[1])
+		}
+
+		var err error
+		grammars, err = grammars.Add("", pattern.GoString(), treesitter.LanguageGrammar(grammar.GoString()))
+		if err != nil {
+			return nil, fmt.Errorf("'grammars' %w", err)
+		}
+	}
+
+	return grammars, nil
+}
+
 func readSourceFilterEntry(v starlark.Value) ([]plugin.SourceFilter, error) {
 	if list, isList := v.(*starlark.List); isList {
 		return starUtils.ReadList(list, readSourceFilter)
@@ -537,6 +767,84 @@ func newLabel(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwar
 	}, nil
 }
 
+func newSelect(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var conditions *starlark.Dict
+
+	err := starlark.UnpackArgs(
+		"Select",
+		args,
+		kwargs,
+		"conditions", &conditions,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if conditions.Len() == 0 {
+		return nil, fmt.Errorf("select conditions cannot be empty")
+	}
+
+	values, err := starUtils.ReadMap2(conditions, plugin.ReadSelectValues)
+	if err != nil {
+		return nil, err
+	}
+
+	return plugin.Select{
+		Conditions: values,
+	}, nil
+}
+
+func newGlob(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var include, exclude *starlark.List
+
+	err := starlark.UnpackArgs(
+		"Glob",
+		args,
+		kwargs,
+		"include", &include,
+		"exclude?", &exclude,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	g := plugin.Glob{}
+	if g.Include, err = starUtils.ReadStringList(include); err != nil {
+		return nil, err
+	}
+	if exclude != nil {
+		if g.Exclude, err = starUtils.ReadStringList(exclude); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(g.Include) == 0 {
+		return nil, fmt.Errorf("glob include patterns cannot be empty")
+	}
+
+	return g, nil
+}
+
+func newResolveError(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var message, fix starlark.String
+
+	err := starlark.UnpackArgs(
+		"ResolveError",
+		args,
+		kwargs,
+		"message", &message,
+		"fix?", &fix,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &plugin.ResolveError{
+		Message: message.GoString(),
+		Fix:     fix.GoString(),
+	}, nil
+}
+
 func newProperty(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
 	var propType starlark.String
 	var propDefault starlark.Value = starlark.None
@@ -571,19 +879,30 @@ var aspectModule = starUtils.CreateModule(
 		"orion_extension":              registerOrionPlugin,
 		"gazelle_rule_kind":            registerGazelleRuleKind,
 		"AstQuery":                     newAstQuery,
+		"Injection":                    newInjection,
 		"RegexQuery":                   newRegexQuery,
 		"RawQuery":                     newRawQuery,
 		"JsonQuery":                    newJsonQuery,
 		"YamlQuery":                    newYamlQuery,
 		"TomlQuery":                    newTomlQuery,
+		"XmlQuery":                     newXmlQuery,
+		"ProtoQuery":                   newProtoQuery,
+		"StarlarkQuery":                newStarlarkQuery,
+		"KeyValueQuery":                newKeyValueQuery,
 		"PrepareResult":                newPrepareResult,
 		"Import":                       newImport,
 		"Symbol":                       newSymbol,
 		"Label":                        newLabel,
+		"Select":                       newSelect,
+		"Glob":                         newGlob,
+		"ResolveError":                 newResolveError,
+		"diagnostic":                   reportDiagnostic,
 		"Property":                     newProperty,
 		"SourceExtensions":             newSourceExtensions,
 		"SourceGlobs":                  newSourceGlobs,
 		"SourceFiles":                  newSourceFiles,
 	},
-	map[string]starlark.Value{},
+	map[string]starlark.Value{
+		"log": logModule,
+	},
 )
@@ -0,0 +1,232 @@
+package gazelle
+
+import (
+	"crypto"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path"
+	"slices"
+
+	common "github.com/aspect-build/aspect-gazelle/common"
+	"github.com/aspect-build/aspect-gazelle/common/cache"
+	BazelLog "github.com/aspect-build/aspect-gazelle/common/logger"
+	"github.com/aspect-build/aspect-gazelle/language/orion/plugin"
+	"github.com/bazelbuild/bazel-gazelle/config"
+	gazelleLanguage "github.com/bazelbuild/bazel-gazelle/language"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+)
+
+func init() {
+	gob.Register(&packageSymbols{})
+}
+
+// The cache key of the symbols of a package, a single entry of the package BUILD file
+// replaced when the package is generated again.
+const packageSymbolsCacheKey = "orion-symbols"
+
+// The symbols of a package generated for the imports of a partial run, persisted in
+// the cache so later partial and watch runs can resolve imports of the package without
+// generating it again.
+type packageSymbols struct {
+	// The digest of everything the symbols depend on known before generating the package
+	Key string
+
+	// The symbols exported by each target generated in the package
+	Targets []packageTarget
+
+	// The symbols added to the symbol database with a label of the package
+	Symbols []plugin.TargetSymbol
+
+	// The content digest of each workspace file read by the plugins of the package,
+	// including files read after the prepare stage which are not part of the Key.
+	ReadFiles map[string]string
+}
+
+type packageTarget struct {
+	Kind    string
+	Name    string
+	Symbols []plugin.Symbol
+}
+
+// importsPackageSymbols returns the symbols of a package not generated in this run,
+// loading them from the cache if the package sources, plugins and configuration have
+// not changed since they were persisted.
+func (host *GazelleHost) importsPackageSymbols(log *BazelLog.Logger, cfg *BUILDConfig, c *config.Config, f *rule.File) packageSymbols {
+	regularFiles, err := common.GetSourceRegularFiles(f.Pkg)
+	if err != nil {
+		log.Fatalf("Error getting regular files for %s: %v", f.Pkg, err)
+	}
+
+	return host.loadPackageSymbols(log, cfg, c, f, regularFiles)
+}
+
+// loadPackageSymbols is importsPackageSymbols of the given source files of the package.
+func (host *GazelleHost) loadPackageSymbols(log *BazelLog.Logger, cfg *BUILDConfig, c *config.Config, f *rule.File, regularFiles []string) packageSymbols {
+	generate := func(key string) packageSymbols {
+		log.Debugf("Imports.GenerateRules(%s): //%s", GazelleLanguageName, f.Pkg)
+		genResult := host.importsGenerateRules(log, cfg, c, f, regularFiles)
+		symbols := newPackageSymbols(genResult, host.database.PackageSymbols(cfg.repoName, f.Pkg))
+		symbols.Key = key
+		symbols.ReadFiles = readFileDigests(c.RepoRoot, cfg.getReadFiles())
+		return symbols
+	}
+
+	key, err := host.packageSymbolsKey(cfg, c, f.Pkg, regularFiles)
+	if err != nil {
+		log.Warnf("Failed to compute the symbols cache key of %q: %v", f.Pkg, err)
+		return generate("")
+	}
+
+	// Stored with the BUILD file to be invalidated with the package in watch mode
+	buildFile := path.Join(f.Pkg, path.Base(f.Path))
+
+	v, cached, err := cache.Get(c).LoadOrStoreFile(c.RepoRoot, buildFile, packageSymbolsCacheKey, func(string, []byte) (any, error) {
+		symbols := generate(key)
+		return &symbols, nil
+	})
+	if err != nil {
+		log.Fatalf("Failed to load the symbols of %q: %v", f.Pkg, err)
+	}
+
+	symbols := v.(*packageSymbols)
+
+	// Files read by the analyze and declare stages are only known once generated and
+	// are compared with the digests of the cached symbols instead of being part of the key.
+	if cached && (symbols.Key != key || symbols.hasChangedReadFiles(c.RepoRoot)) {
+		log.Debugf("Imports(%s): //%s (changed since cached)", GazelleLanguageName, f.Pkg)
+		*symbols = generate(key)
+		cached = false
+	}
+
+	// Restore the symbol database entries of the package not generated in this run
+	if cached {
+		log.Debugf("Imports(%s): //%s (cached symbols)", GazelleLanguageName, f.Pkg)
+		host.database.AddSymbols(symbols.Symbols)
+	}
+
+	return *symbols
+}
+
+func (host *GazelleHost) importsGenerateRules(log *BazelLog.Logger, cfg *BUILDConfig, c *config.Config, f *rule.File, regularFiles []string) gazelleLanguage.GenerateResult {
+	return host.generateRules(log, cfg, gazelleLanguage.GenerateArgs{
+		File:   f,
+		Rel:    f.Pkg,
+		Dir:    path.Join(c.RepoRoot, f.Pkg),
+		Config: c,
+
+		// The RegularFiles are processed by the GazelleHost and passed to plugins.
+		RegularFiles: regularFiles,
+	})
+}
+
+func newPackageSymbols(genResult gazelleLanguage.GenerateResult, symbols []plugin.TargetSymbol) packageSymbols {
+	targets := make([]packageTarget, 0, len(genResult.Gen))
+	for _, g := range genResult.Gen {
+		if declaration := g.PrivateAttr(targetDeclarationKey); declaration != nil {
+			targets = append(targets, packageTarget{
+				Kind:    g.Kind(),
+				Name:    g.Name(),
+				Symbols: declaration.(plugin.TargetDeclaration).Symbols,
+			})
+		}
+	}
+
+	return packageSymbols{
+		Targets: targets,
+		Symbols: symbols,
+	}
+}
+
+// hasChangedReadFiles returns true if a workspace file read by the plugins of the
+// package has changed since the symbols were generated.
+func (s packageSymbols) hasChangedReadFiles(repoRoot string) bool {
+	for p, digest := range s.ReadFiles {
+		if fileDigest(repoRoot, p) != digest {
+			return true
+		}
+	}
+	return false
+}
+
+// packageSymbolsKey computes a digest of everything the symbols of a package depend
+// on and known before generating the package: the source files of the package, the
+// directives of the package and its parents, the files read by the prepare stage and
+// the version, queries, properties and inherited data of each plugin enabled in the
+// package.
+func (host *GazelleHost) packageSymbolsKey(cfg *BUILDConfig, c *config.Config, pkg string, regularFiles []string) (string, error) {
+	cacheDigest := crypto.MD5.New()
+
+	fmt.Fprintf(cacheDigest, "%s\x00", cfg.repoName)
+
+	// All directives of the package and its parents, including those of other languages
+	for p := cfg; p != nil; p = p.parent {
+		fmt.Fprintf(cacheDigest, "%s\x00", p.rel)
+		for _, k := range slices.Sorted(maps.Keys(p.directiveRawValues)) {
+			fmt.Fprintf(cacheDigest, "%s\x00%q\x00", k, p.directiveRawValues[k])
+		}
+	}
+
+	// Iterate over the pluginIds[] in a deterministic order
+	for _, pluginId := range host.pluginIds {
+		if !cfg.IsPluginEnabled(pluginId) {
+			continue
+		}
+
+		version := ""
+		if p, isVersioned := host.plugins[pluginId].(plugin.VersionedPlugin); isVersioned {
+			version = p.Version()
+		}
+
+		prep := cfg.pluginPrepareResults[pluginId]
+		fmt.Fprintf(cacheDigest, "%s\x00%s\x00%s\x00%v\x00", pluginId, version, prep.queriesHash, prep.Properties)
+
+		// The plugin data of the package and inherited from its parents
+		for p := cfg; p != nil; p = p.parent {
+			fmt.Fprintf(cacheDigest, "%v\x00", p.pluginData[pluginId])
+		}
+	}
+
+	// Files read while preparing the plugins of the package
+	writeReadFileDigests(cacheDigest, readFileDigests(c.RepoRoot, cfg.getReadFiles()))
+
+	for _, f := range slices.Sorted(slices.Values(regularFiles)) {
+		content, err := os.ReadFile(path.Join(c.RepoRoot, pkg, f))
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(cacheDigest, "%s\x00%d\x00", f, len(content))
+		cacheDigest.Write(content)
+	}
+
+	return hex.EncodeToString(cacheDigest.Sum(nil)), nil
+}
+
+func writeReadFileDigests(w io.Writer, readFiles map[string]string) {
+	for _, p := range slices.Sorted(maps.Keys(readFiles)) {
+		fmt.Fprintf(w, "%s\x00%s\x00", p, readFiles[p])
+	}
+}
+
+// readFileDigests returns the content digest of each of the workspace files.
+func readFileDigests(repoRoot string, files []string) map[string]string {
+	digests := make(map[string]string, len(files))
+	for _, p := range files {
+		digests[p] = fileDigest(repoRoot, p)
+	}
+	return digests
+}
+
+// fileDigest returns the content digest of a workspace file, "" if it can not be read.
+func fileDigest(repoRoot, p string) string {
+	content, err := os.ReadFile(path.Join(repoRoot, p))
+	if err != nil {
+		return ""
+	}
+	digest := crypto.MD5.New()
+	digest.Write(content)
+	return hex.EncodeToString(digest.Sum(nil))
+}
@@ -0,0 +1,203 @@
+package gazelle
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/aspect-build/aspect-gazelle/common/cache"
+	"github.com/aspect-build/aspect-gazelle/language/orion/plugin"
+	"github.com/bazelbuild/bazel-gazelle/config"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+	"github.com/emirpasic/gods/v2/sets/treeset"
+)
+
+// symbolsTestPlugin declares a target exporting a symbol in each package, reading
+// a workspace file in both the prepare and declare stages.
+type symbolsTestPlugin struct {
+	version  string
+	declared int
+}
+
+var _ plugin.Plugin = (*symbolsTestPlugin)(nil)
+var _ plugin.VersionedPlugin = (*symbolsTestPlugin)(nil)
+
+func (p *symbolsTestPlugin) Name() plugin.PluginId                   { return "symbols_test" }
+func (p *symbolsTestPlugin) Version() string                         { return p.version }
+func (p *symbolsTestPlugin) Properties() map[string]plugin.Property  { return nil }
+func (p *symbolsTestPlugin) Analyze(ctx plugin.AnalyzeContext) error { return nil }
+
+func (p *symbolsTestPlugin) Prepare(ctx plugin.PrepareContext) plugin.PrepareResult {
+	ctx.ReadFile("../prepare.txt", plugin.QueryTypeRaw)
+
+	return plugin.PrepareResult{
+		Sources: map[string][]plugin.SourceFilter{
+			plugin.DeclareTargetsContextDefaultGroup: {plugin.NewSourceExtensionsFilter([]string{".txt"})},
+		},
+	}
+}
+
+func (p *symbolsTestPlugin) DeclareTargets(ctx plugin.DeclareTargetsContext) plugin.DeclareTargetsResult {
+	p.declared++
+
+	ctx.ReadFile("../declare.txt", plugin.QueryTypeRaw)
+
+	symbol := plugin.Symbol{Id: "lib", Provider: "test"}
+	ctx.AddSymbol(plugin.Label{Pkg: ctx.Rel, Name: "lib"}, symbol)
+	ctx.Targets.Add(plugin.TargetDeclaration{
+		Name:    "lib",
+		Kind:    "filegroup",
+		Symbols: []plugin.Symbol{symbol},
+	})
+
+	return plugin.DeclareTargetsResult{Actions: ctx.Targets.Actions()}
+}
+
+func writeSymbolsTestFile(t *testing.T, root, name, content string) {
+	t.Helper()
+	if err := os.MkdirAll(path.Dir(path.Join(root, name)), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path.Join(root, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// loadTestPackageSymbols loads the symbols of the "pkg" package the way a partial
+// run does, with a new cache, host and symbol database.
+func loadTestPackageSymbols(t *testing.T, root string, newCache cache.CacheFactory, p *symbolsTestPlugin) (packageSymbols, *GazelleHost) {
+	t.Helper()
+
+	cache.SetCacheFactory(newCache)
+	t.Cleanup(func() { cache.SetCacheFactory(nil) })
+
+	c := config.New()
+	c.RepoRoot = root
+	c.ValidBuildFileNames = config.DefaultValidBuildFileNames
+	if err := cache.NewConfigurer().CheckFlags(nil, c); err != nil {
+		t.Fatal(err)
+	}
+
+	host := &GazelleHost{
+		plugins:         make(map[string]plugin.Plugin),
+		kinds:           make(map[string]plugin.RuleKind),
+		sourceRuleKinds: treeset.NewWith(strings.Compare),
+		database:        &plugin.Database{},
+	}
+	host.AddPlugin(p)
+
+	var f *rule.File
+	for _, rel := range []string{"", "pkg"} {
+		var err error
+		f, err = rule.LoadFile(path.Join(root, rel, "BUILD.bazel"), rel)
+		if err != nil {
+			t.Fatal(err)
+		}
+		host.Configure(c, rel, f)
+	}
+
+	symbols := host.loadPackageSymbols(indexLog, getBUILDConfig(c, "pkg"), c, f, []string{"lib.txt"})
+	cache.Get(c).Persist()
+
+	return symbols, host
+}
+
+func TestPackageSymbolsCache(t *testing.T) {
+	root := t.TempDir()
+	cacheFile := path.Join(t.TempDir(), "gazelle.cache")
+
+	writeSymbolsTestFile(t, root, "BUILD.bazel", "# gazelle:inherited a\n")
+	writeSymbolsTestFile(t, root, "prepare.txt", "prepare")
+	writeSymbolsTestFile(t, root, "declare.txt", "declare")
+	writeSymbolsTestFile(t, root, "pkg/BUILD.bazel", "")
+	writeSymbolsTestFile(t, root, "pkg/lib.txt", "lib")
+
+	version := "1"
+
+	// Returns the number of times the package targets were declared
+	load := func(t *testing.T) int {
+		t.Helper()
+
+		p := &symbolsTestPlugin{version: version}
+		symbols, host := loadTestPackageSymbols(t, root, func(*config.Config) cache.Cache {
+			return cache.NewDiskCache(cacheFile)
+		}, p)
+
+		if len(symbols.Targets) != 1 || symbols.Targets[0].Name != "lib" || len(symbols.Targets[0].Symbols) != 1 {
+			t.Errorf("unexpected targets: %v", symbols.Targets)
+		}
+		if found := host.database.LookupSymbols("lib"); len(found) != 1 || found[0].Label != (plugin.Label{Pkg: "pkg", Name: "lib"}) {
+			t.Errorf("expected the symbol of //pkg:lib in the database, got %v", found)
+		}
+
+		return p.declared
+	}
+
+	if declared := load(t); declared != 1 {
+		t.Fatalf("expected the package to be generated without a cache, declared %d times", declared)
+	}
+
+	t.Run("cached", func(t *testing.T) {
+		if declared := load(t); declared != 0 {
+			t.Errorf("expected the cached symbols, declared %d times", declared)
+		}
+	})
+
+	for _, tc := range []struct {
+		name   string
+		change func(t *testing.T)
+	}{
+		{"package source", func(t *testing.T) { writeSymbolsTestFile(t, root, "pkg/lib.txt", "lib2") }},
+		{"plugin version", func(t *testing.T) { version = "2" }},
+		{"inherited directive", func(t *testing.T) { writeSymbolsTestFile(t, root, "BUILD.bazel", "# gazelle:inherited b\n") }},
+		{"file read by prepare", func(t *testing.T) { writeSymbolsTestFile(t, root, "prepare.txt", "prepare2") }},
+		{"file read by declare", func(t *testing.T) { writeSymbolsTestFile(t, root, "declare.txt", "declare2") }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.change(t)
+
+			if declared := load(t); declared != 1 {
+				t.Errorf("expected the package to be generated again, declared %d times", declared)
+			}
+			if declared := load(t); declared != 0 {
+				t.Errorf("expected the symbols generated again to be cached, declared %d times", declared)
+			}
+		})
+	}
+}
+
+func TestPackageSymbolsCacheEntry(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("ASPECT_GAZELLE_CACHE", path.Join(t.TempDir(), "gazelle.cache"))
+
+	writeSymbolsTestFile(t, root, "BUILD.bazel", "")
+	writeSymbolsTestFile(t, root, "prepare.txt", "prepare")
+	writeSymbolsTestFile(t, root, "declare.txt", "declare")
+	writeSymbolsTestFile(t, root, "pkg/BUILD.bazel", "")
+	writeSymbolsTestFile(t, root, "pkg/lib.txt", "lib")
+
+	var fileCache *cache.FileComputeCache
+	for i, change := range []string{"", "lib2", "lib3"} {
+		if change != "" {
+			writeSymbolsTestFile(t, root, "pkg/lib.txt", change)
+		}
+
+		fileCache = cache.NewFileComputeCache()
+		p := &symbolsTestPlugin{version: "1"}
+		loadTestPackageSymbols(t, root, fileCache.NewCache, p)
+
+		if p.declared != 1 {
+			t.Errorf("expected the package to be generated for change %d, declared %d times", i, p.declared)
+		}
+	}
+
+	// The symbols generated again replace those of the previous generation
+	entries := fileCache.SnapshotEntries()["pkg/BUILD.bazel"]
+	if len(entries) != 1 {
+		t.Fatalf("expected a single cache entry of the package, got %v", entries)
+	}
+	if symbols, _ := entries[packageSymbolsCacheKey].(*packageSymbols); symbols == nil || len(symbols.Targets) != 1 {
+		t.Errorf("expected the cached symbols of the package, got %v", entries)
+	}
+}
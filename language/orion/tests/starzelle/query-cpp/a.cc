@@ -0,0 +1,10 @@
+#include "lib/greeter.h"
+#include <string>
+#include <vector>
+
+namespace example {
+class Greeter {
+  public:
+    std::string greet(const std::vector<std::string>& names);
+};
+}  // namespace example
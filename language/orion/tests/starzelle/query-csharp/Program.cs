@@ -0,0 +1,7 @@
+using System;
+using System.Collections.Generic;
+
+class Program
+{
+    static void Main() => Console.WriteLine("hello");
+}
@@ -0,0 +1,5 @@
+#!/bin/bash
+
+python3 <<PY
+import sys
+PY
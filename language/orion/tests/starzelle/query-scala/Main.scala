@@ -0,0 +1,8 @@
+package example
+
+import cats.effect.IO
+import scala.collection.mutable
+
+object Main {
+  def main(args: Array[String]): Unit = println("hello")
+}
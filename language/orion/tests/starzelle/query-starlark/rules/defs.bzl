@@ -0,0 +1,18 @@
+"""Rule definitions."""
+
+load("@bazel_skylib//lib:paths.bzl", "paths")
+load(":private.bzl", _impl = "impl")
+
+def my_macro(name, srcs = [], *args, **kwargs):
+    """Creates a library.
+
+    More details.
+    """
+    native.filegroup(name = name, srcs = srcs)
+
+def _helper():
+    pass
+
+my_rule = rule(implementation = _impl)
+
+exports_files(["defs.bzl"], visibility = ["//visibility:public"])
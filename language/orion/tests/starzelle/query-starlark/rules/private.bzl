@@ -0,0 +1,2 @@
+def impl(ctx):
+    return []
@@ -0,0 +1,4 @@
+import Foundation
+import MyLib
+
+print("hello")
@@ -0,0 +1,241 @@
+package gazelle
+
+import (
+	"slices"
+
+	ruleUtils "github.com/aspect-build/aspect-gazelle/common/rule"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+	bzl "github.com/bazelbuild/buildtools/build"
+)
+
+// preservePinnedAttrs copies the pinned attribute values of an existing rule to the
+// generated rule like ruleUtils.PreservePinnedAttrs, except the glob() and select()
+// expressions replaced by a generated expression of the same form when merged,
+// see globValue and selectValue. Attributes with a `# keep` comment are always pinned.
+//
+// Returns the names of the pinned attributes.
+func preservePinnedAttrs(existing, generated *rule.Rule) []string {
+	if existing == nil {
+		return nil
+	}
+
+	var pinned []string
+	for _, attr := range ruleUtils.PinnedAttrs(existing) {
+		if kind := mergedExprKind(generated.Attr(attr)); kind != "" && kind == mergedExprKind(existing.Attr(attr)) && !ruleUtils.IsAttrKept(existing, attr) {
+			continue
+		}
+		pinned = append(pinned, attr)
+	}
+	for _, attr := range pinned {
+		generated.SetAttr(attr, pinnedValue{existing.Attr(attr)})
+	}
+	return pinned
+}
+
+// A pinned expression of an existing rule, left as-is when merged instead of
+// merged as a list of strings which fails for expressions such as `glob(...) + [...]`.
+type pinnedValue struct {
+	expr bzl.Expr
+}
+
+var _ rule.BzlExprValue = (*pinnedValue)(nil)
+var _ rule.Merger = (*pinnedValue)(nil)
+
+func (v pinnedValue) BzlExpr() bzl.Expr {
+	return v.expr
+}
+
+func (v pinnedValue) Merge(other bzl.Expr) bzl.Expr {
+	if other == nil {
+		return v.expr
+	}
+	return other
+}
+
+// mergedExprKind returns "glob" or "select" for the form of the expressions generated
+// by globValue, `glob(...)`, and selectValue, `select({...})` or `[...] + select({...})`.
+// Returns "" for other expressions.
+func mergedExprKind(expr bzl.Expr) string {
+	if binary, isBinary := expr.(*bzl.BinaryExpr); isBinary && binary.Op == "+" {
+		if _, isList := binary.X.(*bzl.ListExpr); isList && callName(binary.Y) == "select" {
+			return "select"
+		}
+		return ""
+	}
+
+	switch name := callName(expr); name {
+	case "glob", "select":
+		return name
+	}
+	return ""
+}
+
+// callName returns the name of the function of a call expression such as `glob(...)`.
+func callName(expr bzl.Expr) string {
+	if call, isCall := expr.(*bzl.CallExpr); isCall {
+		if ident, isIdent := call.X.(*bzl.Ident); isIdent {
+			return ident.Name
+		}
+	}
+	return ""
+}
+
+// A glob() replacing the existing expression when merged, such as a glob()
+// with different patterns.
+type globValue struct {
+	rule.GlobValue
+}
+
+var _ rule.BzlExprValue = (*globValue)(nil)
+var _ rule.Merger = (*globValue)(nil)
+
+// BzlExpr returns the glob() on a single line like a user-written glob().
+func (v globValue) BzlExpr() bzl.Expr {
+	call := v.GlobValue.BzlExpr().(*bzl.CallExpr)
+	call.ForceCompact = true
+	return call
+}
+
+func (v globValue) Merge(other bzl.Expr) bzl.Expr {
+	return v.BzlExpr()
+}
+
+// A dict replacing the existing expression when merged instead of being merged
+// as a list of strings, which drops dict expressions.
+type dictValue map[string]interface{}
+
+var _ rule.BzlExprValue = (*dictValue)(nil)
+var _ rule.Merger = (*dictValue)(nil)
+
+func (v dictValue) BzlExpr() bzl.Expr {
+	dict := rule.ExprFromValue(map[string]interface{}(v)).(*bzl.DictExpr)
+	dict.ForceMultiLine = len(dict.List) > 0
+	return dict
+}
+
+func (v dictValue) Merge(other bzl.Expr) bzl.Expr {
+	return v.BzlExpr()
+}
+
+// A select() of list values per condition, following the values common to all
+// conditions if any: `[...] + select({...})`.
+type selectValue struct {
+	values     []interface{}
+	conditions rule.SelectStringListValue
+}
+
+var _ rule.BzlExprValue = (*selectValue)(nil)
+var _ rule.Merger = (*selectValue)(nil)
+
+func (v selectValue) BzlExpr() bzl.Expr {
+	selectExpr := v.conditions.BzlExpr()
+	if len(v.values) == 0 {
+		return selectExpr
+	}
+
+	return &bzl.BinaryExpr{
+		X:  rule.ExprFromValue(v.values),
+		Op: "+",
+		Y:  selectExpr,
+	}
+}
+
+// Merge replaces the existing expression while preserving the `# keep` values of its
+// list and select() conditions, the same as gazelle merging of list attributes.
+func (v selectValue) Merge(other bzl.Expr) bzl.Expr {
+	merged := v.BzlExpr()
+	if other == nil {
+		return merged
+	}
+
+	list, dict := splitSelectExpr(merged)
+	otherList, otherDict := splitSelectExpr(other)
+
+	if otherList != nil {
+		if list == nil && hasKeptValues(otherList) {
+			list = &bzl.ListExpr{}
+			merged = &bzl.BinaryExpr{X: list, Op: "+", Y: merged}
+		}
+		if list != nil {
+			mergeKeptValues(list, otherList)
+		}
+	}
+
+	if otherDict != nil && dict != nil {
+		for _, item := range otherDict.List {
+			otherValues, isList := item.Value.(*bzl.ListExpr)
+			if !isList {
+				continue
+			}
+			if values := dictListValue(dict, item.Key); values != nil {
+				mergeKeptValues(values, otherValues)
+			}
+		}
+	}
+
+	return merged
+}
+
+// splitSelectExpr returns the list and select() dict of a `[...] + select({...})`
+// expression, either of which may be missing.
+func splitSelectExpr(expr bzl.Expr) (*bzl.ListExpr, *bzl.DictExpr) {
+	var list *bzl.ListExpr
+	var dict *bzl.DictExpr
+
+	parts := []bzl.Expr{expr}
+	if binary, isBinary := expr.(*bzl.BinaryExpr); isBinary && binary.Op == "+" {
+		parts = []bzl.Expr{binary.X, binary.Y}
+	}
+
+	for _, part := range parts {
+		switch part := part.(type) {
+		case *bzl.ListExpr:
+			list = part
+		case *bzl.CallExpr:
+			if ident, isIdent := part.X.(*bzl.Ident); isIdent && ident.Name == "select" && len(part.List) == 1 {
+				dict, _ = part.List[0].(*bzl.DictExpr)
+			}
+		}
+	}
+
+	return list, dict
+}
+
+func dictListValue(dict *bzl.DictExpr, key bzl.Expr) *bzl.ListExpr {
+	keyStr, isString := key.(*bzl.StringExpr)
+	if !isString {
+		return nil
+	}
+	for _, item := range dict.List {
+		if k, isString := item.Key.(*bzl.StringExpr); isString && k.Value == keyStr.Value {
+			values, _ := item.Value.(*bzl.ListExpr)
+			return values
+		}
+	}
+	return nil
+}
+
+func hasKeptValues(list *bzl.ListExpr) bool {
+	return slices.ContainsFunc(list.List, rule.ShouldKeep)
+}
+
+// mergeKeptValues adds the `# keep` values of other to list, replacing equal values
+// so the comment is preserved.
+func mergeKeptValues(list, other *bzl.ListExpr) {
+	for _, kept := range other.List {
+		if !rule.ShouldKeep(kept) {
+			continue
+		}
+
+		keptStr, isString := kept.(*bzl.StringExpr)
+		i := slices.IndexFunc(list.List, func(e bzl.Expr) bool {
+			str, isStr := e.(*bzl.StringExpr)
+			return isString && isStr && str.Value == keptStr.Value
+		})
+		if i >= 0 {
+			list.List[i] = kept
+		} else {
+			list.List = append(list.List, kept)
+		}
+	}
+}
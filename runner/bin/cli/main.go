@@ -7,6 +7,7 @@ import (
 	"os"
 	"path"
 
+	"github.com/aspect-build/aspect-gazelle/common"
 	"github.com/aspect-build/aspect-gazelle/common/bazel"
 	"github.com/aspect-build/aspect-gazelle/common/buildinfo"
 	host "github.com/aspect-build/aspect-gazelle/language/orion"
@@ -66,6 +67,7 @@ func parseArgs() (runner.GazelleMode, []string, []string, []string) {
 	args := flag.NewFlagSet("Aspect Configure", flag.ExitOnError)
 
 	mode := args.String("mode", runner.Diff, "Configure mode: fix|update|diff")
+	jobs := args.Int("jobs", 0, "Number of parallel jobs, defaults to the number of available CPUs")
 	help := args.Bool("help", false, "Print help message")
 	version := args.Bool("version", false, "Print version")
 	config := args.String("config", "", `Aspect CLI config file (yaml). Properties include:
@@ -90,6 +92,9 @@ configure:
 		os.Exit(0)
 	}
 
+	// Size the worker pool shared by all languages
+	common.SetJobs(*jobs)
+
 	if *config == "" {
 		fmt.Println("No --config file specified")
 		os.Exit(1)
@@ -3,9 +3,11 @@ package main
 import (
 	"log"
 	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/aspect-build/aspect-gazelle/runner"
+	"github.com/aspect-build/aspect-gazelle/runner/pkg/diagnostics"
 )
 
 // cacheType selects a cache implementation for --cache[=disk|watchman].
@@ -20,7 +22,7 @@ const (
 /**
  * Parse and extract arguments not directly passed along to gazelle.
  */
-func parseArgs(args []string) (runner.GazelleCommand, runner.GazelleMode, bool, cacheType, []string) {
+func parseArgs(args []string) (runner.GazelleCommand, runner.GazelleMode, bool, cacheType, diagnostics.Format, int, []string) {
 	// The optional initial command argument
 	cmd := runner.UpdateCmd
 	if len(args) > 0 && (args[0] == runner.UpdateCmd || args[0] == runner.FixCmd) {
@@ -43,7 +45,23 @@ func parseArgs(args []string) (runner.GazelleCommand, runner.GazelleMode, bool,
 		log.Fatalf("ERROR: invalid --cache value %q, expected \"disk\" or \"watchman\"", cacheRaw)
 	}
 
-	return cmd, mode, progress, ct, args
+	// The optional --diagnostics_format=text|json|github flag
+	diagRaw, args := extractArg("diagnostics_format", "", args)
+	df := diagnostics.Format(diagRaw)
+	switch df {
+	case "", diagnostics.FormatText, diagnostics.FormatJson, diagnostics.FormatGitHub:
+	default:
+		log.Fatalf("ERROR: invalid --diagnostics_format value %q, expected \"text\", \"json\" or \"github\"", diagRaw)
+	}
+
+	// The optional --jobs=N flag, 0 to use the default
+	jobsRaw, args := extractArg("jobs", "0", args)
+	jobs, err := strconv.Atoi(jobsRaw)
+	if err != nil || jobs < 0 {
+		log.Fatalf("ERROR: invalid --jobs value %q, expected a non-negative number", jobsRaw)
+	}
+
+	return cmd, mode, progress, ct, df, jobs, args
 }
 
 func extractFlag(flag string, defaultValue bool, args []string) (bool, []string) {
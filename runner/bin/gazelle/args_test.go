@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/aspect-build/aspect-gazelle/runner"
+	"github.com/aspect-build/aspect-gazelle/runner/pkg/diagnostics"
 )
 
 func TestParseArgs(t *testing.T) {
@@ -167,7 +168,7 @@ func TestParseArgs(t *testing.T) {
 
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
-			cmd, mode, progress, ct, args := parseArgs(tc.argv)
+			cmd, mode, progress, ct, _, _, args := parseArgs(tc.argv)
 			if cmd != tc.wantCmd {
 				t.Errorf("cmd: got %q, want %q", cmd, tc.wantCmd)
 			}
@@ -412,7 +413,7 @@ func TestProgressFlag(t *testing.T) {
 
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
-			_, _, progress, _, args := parseArgs(tc.argv)
+			_, _, progress, _, _, _, args := parseArgs(tc.argv)
 			if progress != tc.wantProg {
 				t.Errorf("progress: got %v, want %v", progress, tc.wantProg)
 			}
@@ -498,7 +499,7 @@ func TestCacheFlag(t *testing.T) {
 
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
-			_, _, _, ct, args := parseArgs(tc.argv)
+			_, _, _, ct, _, _, args := parseArgs(tc.argv)
 			if ct != tc.wantCache {
 				t.Errorf("cache: got %q, want %q", ct, tc.wantCache)
 			}
@@ -508,3 +509,83 @@ func TestCacheFlag(t *testing.T) {
 		})
 	}
 }
+
+func TestParseArgs_DiagnosticsFormat(t *testing.T) {
+	cases := []struct {
+		name       string
+		argv       []string
+		wantFormat diagnostics.Format
+		wantArgs   []string
+	}{
+		{
+			name:       "default",
+			argv:       []string{"pkg"},
+			wantFormat: "",
+			wantArgs:   []string{"pkg"},
+		},
+		{
+			name:       "--diagnostics_format=json",
+			argv:       []string{"--diagnostics_format=json", "pkg"},
+			wantFormat: diagnostics.FormatJson,
+			wantArgs:   []string{"pkg"},
+		},
+		{
+			name:       "-diagnostics_format github",
+			argv:       []string{"-diagnostics_format", "github", "-mode=diff"},
+			wantFormat: diagnostics.FormatGitHub,
+			wantArgs:   []string{},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, _, _, df, _, args := parseArgs(tc.argv)
+			if df != tc.wantFormat {
+				t.Errorf("diagnostics format: got %q, want %q", df, tc.wantFormat)
+			}
+			if !reflect.DeepEqual(args, tc.wantArgs) {
+				t.Errorf("args: got %v, want %v", args, tc.wantArgs)
+			}
+		})
+	}
+}
+
+func TestParseArgs_Jobs(t *testing.T) {
+	cases := []struct {
+		name     string
+		argv     []string
+		wantJobs int
+		wantArgs []string
+	}{
+		{
+			name:     "default",
+			argv:     []string{"pkg"},
+			wantJobs: 0,
+			wantArgs: []string{"pkg"},
+		},
+		{
+			name:     "--jobs=32",
+			argv:     []string{"--jobs=32", "pkg"},
+			wantJobs: 32,
+			wantArgs: []string{"pkg"},
+		},
+		{
+			name:     "-jobs 4",
+			argv:     []string{"-jobs", "4", "-mode=diff"},
+			wantJobs: 4,
+			wantArgs: []string{},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, _, _, _, jobs, args := parseArgs(tc.argv)
+			if jobs != tc.wantJobs {
+				t.Errorf("jobs: got %d, want %d", jobs, tc.wantJobs)
+			}
+			if !reflect.DeepEqual(args, tc.wantArgs) {
+				t.Errorf("args: got %v, want %v", args, tc.wantArgs)
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"log"
 	"os"
 
+	"github.com/aspect-build/aspect-gazelle/common"
 	"github.com/aspect-build/aspect-gazelle/common/bazel"
 	"github.com/aspect-build/aspect-gazelle/common/cache"
 	"github.com/aspect-build/aspect-gazelle/runner"
@@ -22,12 +23,26 @@ func main() {
 	log.SetPrefix("aspect-gazelle: ")
 	log.SetFlags(0) // don't print timestamps
 
+	// The plugin fixture test subcommand, independent of the current workspace
+	if len(os.Args) > 1 && os.Args[1] == testCmd {
+		os.Exit(runPluginTest(os.Args[2:]))
+	}
+
 	wd := bazel.FindWorkspaceDirectory()
 
-	cmd, mode, progress, ct, args := parseArgs(os.Args[1:])
+	cmd, mode, progress, ct, df, jobs, args := parseArgs(os.Args[1:])
+
+	// Size the worker pool shared by all languages
+	common.SetJobs(jobs)
 
 	c := runner.New(wd, progress)
 
+	if df != "" {
+		if err := c.SetDiagnosticsFormat(df); err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+	}
+
 	// Add languages
 	for _, lang := range envLanguages {
 		c.AddLanguage(lang)
@@ -50,6 +65,10 @@ func main() {
 
 		hasChanges, err := c.Generate(cmd, mode, args)
 		if err != nil {
+			// Diagnostics have already been rendered in the requested format.
+			if df != "" && len(common.DiagnosticsFromError(err)) > 0 {
+				os.Exit(1)
+			}
 			log.Fatalf("Error running gazelle: %v", err)
 		}
 
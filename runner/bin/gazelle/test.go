@@ -0,0 +1,115 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aspect-build/aspect-gazelle/runner/pkg/plugintest"
+)
+
+// The subcommand running orion plugins against fixture directories.
+//
+// Not "test" which is the update of a `test` package directory.
+const testCmd = "plugin-test"
+
+// A repeatable string flag.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+/**
+ * Run the orion plugin fixture tests, returning the exit code.
+ *
+ *	gazelle plugin-test [--plugin=path.axl]... [--update] [--stages] fixture_dir...
+ */
+func runPluginTest(args []string) int {
+	flags := flag.NewFlagSet(testCmd, flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s [flags] fixture_dir...\n", testCmd)
+		flags.PrintDefaults()
+	}
+
+	var plugins stringList
+	flags.Var(&plugins, "plugin", "A plugin to test, repeatable. Defaults to the *.axl files of each fixture")
+	update := flags.Bool("update", false, "Rewrite the golden files with the generated files, deleting stale golden files")
+	stages := flags.Bool("stages", false, "Print the results of each plugin stage, otherwise only for failures")
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	opts := plugintest.Options{Update: *update}
+	for _, p := range plugins {
+		opts.Plugins = append(opts.Plugins, resolvePath(p))
+	}
+
+	dirs := make([]string, 0, flags.NArg())
+	for _, d := range flags.Args() {
+		dirs = append(dirs, resolvePath(d))
+	}
+
+	fixtures, err := plugintest.Discover(dirs...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+
+	failed := 0
+	for _, fixture := range fixtures {
+		result, err := plugintest.Run(fixture, opts)
+		if err != nil {
+			fmt.Printf("ERROR %s: %v\n", fixture, err)
+			failed++
+			continue
+		}
+
+		switch {
+		case !result.Passed():
+			fmt.Printf("FAIL %s\n", fixture)
+			failed++
+		case len(result.Updated) > 0:
+			fmt.Printf("UPDATED %s: %s\n", fixture, strings.Join(result.Updated, ", "))
+		default:
+			fmt.Printf("PASS %s\n", fixture)
+		}
+
+		for _, f := range result.Failures {
+			fmt.Printf("  %s\n", f)
+		}
+		for _, d := range result.Diffs {
+			fmt.Print(d.String())
+		}
+
+		if *stages || !result.Passed() {
+			for _, s := range result.Stages {
+				fmt.Printf("  %s\n", strings.ReplaceAll(s.String(), "\n", "\n  "))
+			}
+		}
+	}
+
+	fmt.Printf("%d/%d fixtures passed\n", len(fixtures)-failed, len(fixtures))
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// resolvePath resolves paths relative to where `bazel run` was invoked.
+func resolvePath(p string) string {
+	if wd := os.Getenv("BUILD_WORKING_DIRECTORY"); wd != "" && !filepath.IsAbs(p) {
+		return filepath.Join(wd, p)
+	}
+	return p
+}
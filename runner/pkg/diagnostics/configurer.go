@@ -0,0 +1,41 @@
+package diagnostics
+
+import (
+	"flag"
+	"io"
+
+	"github.com/aspect-build/aspect-gazelle/common"
+	"github.com/bazelbuild/bazel-gazelle/config"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+)
+
+type Configurer struct {
+	reporter common.DiagnosticReporter
+}
+
+// NewConfigurer returns a Configurer that renders all diagnostics reported
+// by languages to w in the given format.
+func NewConfigurer(format Format, w io.Writer) (config.Configurer, error) {
+	reporter, err := NewRenderer(format, w)
+	if err != nil {
+		return nil, err
+	}
+	return &Configurer{reporter: reporter}, nil
+}
+
+// NewReporterConfigurer returns a Configurer passing all diagnostics reported
+// by languages to the reporter.
+func NewReporterConfigurer(reporter common.DiagnosticReporter) config.Configurer {
+	return &Configurer{reporter: reporter}
+}
+
+func (*Configurer) RegisterFlags(fs *flag.FlagSet, cmd string, c *config.Config) {}
+
+func (cc *Configurer) CheckFlags(fs *flag.FlagSet, c *config.Config) error {
+	common.SetDiagnosticReporter(c, cc.reporter)
+	return nil
+}
+
+func (*Configurer) KnownDirectives() []string { return nil }
+
+func (*Configurer) Configure(c *config.Config, rel string, f *rule.File) {}
@@ -0,0 +1,146 @@
+package diagnostics
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/aspect-build/aspect-gazelle/common"
+)
+
+type Format string
+
+const (
+	FormatText   Format = "text"
+	FormatJson   Format = "json"
+	FormatGitHub Format = "github"
+)
+
+// NewRenderer returns a reporter writing each diagnostic to w in the given format.
+func NewRenderer(format Format, w io.Writer) (common.DiagnosticReporter, error) {
+	var render func(w io.Writer, d common.Diagnostic)
+	switch format {
+	case FormatText:
+		render = renderText
+	case FormatJson:
+		render = renderJson
+	case FormatGitHub:
+		render = renderGitHub
+	default:
+		return nil, fmt.Errorf("unknown format %q, expected %q, %q or %q", format, FormatText, FormatJson, FormatGitHub)
+	}
+
+	// Diagnostics may be reported concurrently, ensure each is written atomically.
+	var mu sync.Mutex
+	return func(d common.Diagnostic) {
+		mu.Lock()
+		defer mu.Unlock()
+		render(w, d)
+	}, nil
+}
+
+// The primary location of a diagnostic: the source file if known, otherwise the BUILD file.
+func primaryLocation(d common.Diagnostic) common.Location {
+	if !d.Source.IsZero() {
+		return d.Source
+	}
+	return d.BuildFile
+}
+
+func renderText(w io.Writer, d common.Diagnostic) {
+	if loc := primaryLocation(d); !loc.IsZero() {
+		fmt.Fprintf(w, "%s: ", loc)
+	}
+	fmt.Fprintf(w, "%s[%s]: %s\n", d.Severity, d.Code, strings.TrimSpace(d.Message))
+	if d.Directive != "" {
+		fmt.Fprintf(w, "  directive: # gazelle:%s (%s)\n", d.Directive, d.BuildFile)
+	} else if !d.Source.IsZero() && !d.BuildFile.IsZero() {
+		fmt.Fprintf(w, "  in: %s\n", d.BuildFile)
+	}
+	if d.SuggestedFix != "" {
+		fmt.Fprintf(w, "  fix: %s\n", d.SuggestedFix)
+	}
+}
+
+type jsonLocation struct {
+	File   string `json:"file"`
+	Line   int    `json:"line,omitempty"`
+	Column int    `json:"column,omitempty"`
+}
+
+type jsonDiagnostic struct {
+	Severity     string        `json:"severity"`
+	Code         string        `json:"code"`
+	Name         string        `json:"name,omitempty"`
+	Message      string        `json:"message"`
+	Source       *jsonLocation `json:"source,omitempty"`
+	BuildFile    *jsonLocation `json:"build_file,omitempty"`
+	Directive    string        `json:"directive,omitempty"`
+	SuggestedFix string        `json:"suggested_fix,omitempty"`
+}
+
+func toJsonLocation(l common.Location) *jsonLocation {
+	if l.IsZero() {
+		return nil
+	}
+	return &jsonLocation{File: l.File, Line: l.Line, Column: l.Column}
+}
+
+// renderJson writes one JSON object per line (JSON Lines).
+func renderJson(w io.Writer, d common.Diagnostic) {
+	b, err := json.Marshal(jsonDiagnostic{
+		Severity:     d.Severity.String(),
+		Code:         d.Code.ID,
+		Name:         d.Code.Name,
+		Message:      strings.TrimSpace(d.Message),
+		Source:       toJsonLocation(d.Source),
+		BuildFile:    toJsonLocation(d.BuildFile),
+		Directive:    d.Directive,
+		SuggestedFix: d.SuggestedFix,
+	})
+	if err != nil {
+		panic(err)
+	}
+	w.Write(append(b, '\n'))
+}
+
+// renderGitHub writes a GitHub Actions workflow command annotation.
+// See https://docs.github.com/en/actions/reference/workflow-commands-for-github-actions
+func renderGitHub(w io.Writer, d common.Diagnostic) {
+	command := "error"
+	switch d.Severity {
+	case common.SeverityWarning:
+		command = "warning"
+	case common.SeverityInfo:
+		command = "notice"
+	}
+
+	props := []string{}
+	if loc := primaryLocation(d); !loc.IsZero() {
+		props = append(props, "file="+escapeGitHubProperty(loc.File))
+		if loc.Line > 0 {
+			props = append(props, fmt.Sprintf("line=%d", loc.Line))
+		}
+		if loc.Column > 0 {
+			props = append(props, fmt.Sprintf("col=%d", loc.Column))
+		}
+	}
+	props = append(props, "title="+escapeGitHubProperty(d.Code.String()))
+
+	msg := strings.TrimSpace(d.Message)
+	if d.SuggestedFix != "" {
+		msg += "\n\nFix: " + d.SuggestedFix
+	}
+
+	fmt.Fprintf(w, "::%s %s::%s\n", command, strings.Join(props, ","), escapeGitHubData(msg))
+}
+
+func escapeGitHubData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+func escapeGitHubProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
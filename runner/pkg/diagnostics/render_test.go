@@ -0,0 +1,58 @@
+package diagnostics
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/aspect-build/aspect-gazelle/common"
+)
+
+var testCode = common.DiagnosticCode{ID: "TST001", Name: "test-code"}
+
+func render(t *testing.T, format Format, d common.Diagnostic) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	reporter, err := NewRenderer(format, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reporter(d)
+	return buf.String()
+}
+
+func TestRenderText(t *testing.T) {
+	d := common.NewDiagnostic(testCode, "bad import %q", "x").
+		WithSource("pkg/a.ts", 3, 7).
+		WithFix("add it")
+
+	expected := "pkg/a.ts:3:7: error[TST001 test-code]: bad import \"x\"\n  fix: add it\n"
+	if got := render(t, FormatText, d); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestRenderJson(t *testing.T) {
+	d := common.NewDiagnostic(testCode, "careful").AsWarning()
+	d.BuildFile = common.Location{File: "pkg/BUILD", Line: 2}
+
+	expected := `{"severity":"warning","code":"TST001","name":"test-code","message":"careful","build_file":{"file":"pkg/BUILD","line":2}}` + "\n"
+	if got := render(t, FormatJson, d); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestRenderGitHub(t *testing.T) {
+	d := common.NewDiagnostic(testCode, "multi\nline 100%%").WithSource("a,b.ts", 1, 0)
+
+	expected := "::error file=a%2Cb.ts,line=1,title=TST001 test-code::multi%0Aline 100%25\n"
+	if got := render(t, FormatGitHub, d); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestUnknownFormat(t *testing.T) {
+	if _, err := NewRenderer("xml", &bytes.Buffer{}); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
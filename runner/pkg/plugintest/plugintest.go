@@ -0,0 +1,482 @@
+// Package plugintest runs orion plugins against fixture directories and
+// compares the generated BUILD files with golden files.
+//
+// A fixture is a directory containing a WORKSPACE, MODULE.bazel or REPO.bazel
+// file, laid out like the gazelle_generation_test fixtures:
+//   - `BUILD.in` / `BUILD.bazel.in`: BUILD files present before generation
+//   - `*.out`: golden files of the generated files, such as `BUILD.out`
+//   - `expectedExitCode.txt`: the expected exit code, 0 by default
+//   - `expectedStderr.txt`: the expected stderr, the diagnostics rendered in the text format
+//   - `expectedStdout.txt`: the expected output of the plugins, such as print()
+//   - `arguments.txt`: `fix` to run the fixture as `gazelle fix` instead of `gazelle update`
+//   - `*.axl`: the plugins under test, unless plugins are explicitly passed
+package plugintest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aspect-build/aspect-gazelle/common"
+	orion "github.com/aspect-build/aspect-gazelle/language/orion"
+	"github.com/aspect-build/aspect-gazelle/language/orion/plugin"
+	"github.com/aspect-build/aspect-gazelle/language/orion/starzelle"
+	"github.com/aspect-build/aspect-gazelle/runner"
+	"github.com/aspect-build/aspect-gazelle/runner/pkg/diagnostics"
+	"github.com/bazelbuild/bazel-gazelle/language"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+const (
+	goldenSuffix       = ".out"
+	inputSuffix        = ".in"
+	expectedExitCode   = "expectedExitCode.txt"
+	expectedStderr     = "expectedStderr.txt"
+	expectedStdout     = "expectedStdout.txt"
+	argumentsFile      = "arguments.txt"
+	pluginExtension    = ".axl"
+	buildFileName      = "BUILD"
+	buildBazelFileName = "BUILD.bazel"
+)
+
+// Files marking the root of a fixture
+var fixtureRootFiles = []string{"WORKSPACE", "WORKSPACE.bazel", "MODULE.bazel", "REPO.bazel"}
+
+type Options struct {
+	// Paths to the plugins under test, the `*.axl` files of each fixture if empty.
+	Plugins []string
+
+	// Rewrite the golden files with the generated files instead of comparing them,
+	// deleting the golden files of files no longer generated.
+	Update bool
+}
+
+// Result of running a fixture.
+type Result struct {
+	Fixture string
+
+	// The result of each plugin stage, ordered by package, stage and plugin.
+	Stages []StageResult
+
+	// All diagnostics reported while generating.
+	Diagnostics []common.Diagnostic
+
+	// The output of the plugins while generating, such as print()s.
+	Stdout string
+
+	// The output written to stderr while generating: the diagnostics rendered
+	// in the text format and any other generation error.
+	Stderr string
+
+	// The generation error, nil if gazelle succeeded.
+	Err error
+
+	// Generated files differing from the golden files.
+	Diffs []FileDiff
+
+	// Unmet expectations other than golden files, such as the exit code.
+	Failures []string
+
+	// Golden files rewritten or deleted in update mode, relative to the fixture.
+	Updated []string
+}
+
+func (r *Result) Passed() bool {
+	return len(r.Diffs) == 0 && len(r.Failures) == 0
+}
+
+// FileDiff is a generated file differing from the golden file.
+type FileDiff struct {
+	// The path of the generated file relative to the fixture.
+	Path string
+
+	// The golden and generated content, nil if the file does not exist.
+	Want, Got []byte
+}
+
+func (d FileDiff) String() string {
+	if d.Got == nil {
+		return fmt.Sprintf("%s: not generated\n", d.Path)
+	}
+	if d.Want == nil {
+		return fmt.Sprintf("%s: generated without a golden file\n", d.Path)
+	}
+
+	diff, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(d.Want)),
+		B:        difflib.SplitLines(string(d.Got)),
+		FromFile: d.Path + goldenSuffix,
+		ToFile:   d.Path,
+		Context:  3,
+	})
+	return diff
+}
+
+// Discover returns the fixtures within the directories: each directory that is
+// a fixture, otherwise the fixtures in its immediate subdirectories.
+func Discover(dirs ...string) ([]string, error) {
+	var fixtures []string
+	for _, dir := range dirs {
+		if isFixture(dir) {
+			fixtures = append(fixtures, dir)
+			continue
+		}
+
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+
+		found := false
+		for _, e := range entries {
+			if sub := filepath.Join(dir, e.Name()); e.IsDir() && isFixture(sub) {
+				fixtures = append(fixtures, sub)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no fixtures found in %q", dir)
+		}
+	}
+	return fixtures, nil
+}
+
+func isFixture(dir string) bool {
+	for _, f := range fixtureRootFiles {
+		if _, err := os.Stat(filepath.Join(dir, f)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Test runs each fixture as a subtest, failing on differences from the golden files.
+func Test(t *testing.T, opts Options, fixtures ...string) {
+	t.Helper()
+
+	for _, fixture := range fixtures {
+		t.Run(filepath.Base(fixture), func(t *testing.T) {
+			result, err := Run(fixture, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, d := range result.Diffs {
+				t.Error(d.String())
+			}
+			for _, f := range result.Failures {
+				t.Error(f)
+			}
+			if !result.Passed() {
+				for _, s := range result.Stages {
+					t.Log(s.String())
+				}
+			}
+		})
+	}
+}
+
+// Run runs the plugins on a copy of the fixture and compares the generated
+// files with the golden files, or rewrites the golden files in update mode.
+func Run(fixture string, opts Options) (*Result, error) {
+	fixture, err := filepath.Abs(fixture)
+	if err != nil {
+		return nil, err
+	}
+
+	plugins := opts.Plugins
+	if len(plugins) == 0 {
+		plugins, err = filepath.Glob(filepath.Join(fixture, "*"+pluginExtension))
+		if err != nil {
+			return nil, err
+		}
+		if len(plugins) == 0 {
+			return nil, fmt.Errorf("no plugins specified and no %s files found in %q", pluginExtension, fixture)
+		}
+	}
+
+	workDir, err := os.MkdirTemp("", "plugintest-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(workDir)
+
+	inputs, err := copyFixture(fixture, workDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy fixture %q: %w", fixture, err)
+	}
+
+	rec := &recorder{}
+	var stdout, stderr lockedBuffer
+	lang, err := newLanguage(plugins, rec, &stdout)
+	if err != nil {
+		return nil, err
+	}
+
+	render, err := diagnostics.NewRenderer(diagnostics.FormatText, &stderr)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd, err := fixtureCommand(fixture)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{Fixture: fixture}
+
+	var diagnosticsLock sync.Mutex
+	r := runner.New(workDir, false)
+	r.SetDiagnosticReporter(func(d common.Diagnostic) {
+		diagnosticsLock.Lock()
+		defer diagnosticsLock.Unlock()
+		result.Diagnostics = append(result.Diagnostics, d)
+		render(d)
+	})
+	r.AddLanguageFactory(orion.GazelleLanguageName, func() language.Language {
+		return lang
+	})
+
+	_, result.Err = r.Generate(cmd, runner.Fix, []string{"-repo_root=" + workDir})
+
+	// Errors other than diagnostics are printed like the gazelle binary does
+	if result.Err != nil && len(common.DiagnosticsFromError(result.Err)) == 0 {
+		fmt.Fprintf(&stderr, "Error running gazelle: %v\n", result.Err)
+	}
+
+	result.Stdout = stdout.String()
+	result.Stderr = stderr.String()
+	result.Stages = rec.sorted()
+
+	if err := result.checkExpectations(fixture); err != nil {
+		return nil, err
+	}
+
+	if err := result.compareGoldens(fixture, workDir, inputs, opts.Update); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// fixtureCommand returns the gazelle command of the fixture arguments.txt, update by default.
+func fixtureCommand(fixture string) (runner.GazelleCommand, error) {
+	content, err := os.ReadFile(filepath.Join(fixture, argumentsFile))
+	if os.IsNotExist(err) {
+		return runner.UpdateCmd, nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if args := strings.Fields(string(content)); len(args) > 0 && args[0] == runner.FixCmd {
+		return runner.FixCmd, nil
+	}
+	return runner.UpdateCmd, nil
+}
+
+// newLanguage creates an orion language hosting the plugins, recording the
+// results of each plugin stage and their output to stdout.
+func newLanguage(plugins []string, rec *recorder, stdout io.Writer) (language.Language, error) {
+	lang := orion.NewLanguage()
+	host := &recordingHost{PluginHost: lang.(plugin.PluginHost), rec: rec, stdout: stdout}
+
+	for _, p := range plugins {
+		p, err := filepath.Abs(p)
+		if err != nil {
+			return nil, err
+		}
+		if err := starzelle.LoadProxy(host, filepath.Dir(p), filepath.Base(p)); err != nil {
+			return nil, fmt.Errorf("failed to load plugin %q: %w", p, err)
+		}
+	}
+
+	return lang, nil
+}
+
+// copyFixture copies the fixture inputs to dir, renaming `BUILD.in` files to
+// the BUILD file they represent. Returns the paths of the copied files.
+func copyFixture(fixture, dir string) (map[string]bool, error) {
+	inputs := make(map[string]bool)
+
+	err := filepath.WalkDir(fixture, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		rel, _ := filepath.Rel(fixture, p)
+		if isExpectationFile(rel) {
+			return nil
+		}
+		if base := filepath.Base(rel); base == buildFileName+inputSuffix || base == buildBazelFileName+inputSuffix {
+			rel = strings.TrimSuffix(rel, inputSuffix)
+		}
+
+		content, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+
+		dest := filepath.Join(dir, rel)
+		if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+			return err
+		}
+		inputs[filepath.ToSlash(rel)] = true
+		return os.WriteFile(dest, content, 0o644)
+	})
+
+	return inputs, err
+}
+
+func isExpectationFile(rel string) bool {
+	base := filepath.Base(rel)
+	return strings.HasSuffix(rel, goldenSuffix) || base == expectedExitCode || base == expectedStderr || base == expectedStdout || base == argumentsFile
+}
+
+// lockedBuffer is a bytes.Buffer written to concurrently by the plugins.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// checkExpectations checks the exit code and output expected by the fixture.
+func (r *Result) checkExpectations(fixture string) error {
+	wantExitCode := 0
+	if content, err := os.ReadFile(filepath.Join(fixture, expectedExitCode)); err == nil {
+		wantExitCode, err = strconv.Atoi(strings.TrimSpace(string(content)))
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", expectedExitCode, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	exitCode := 0
+	if r.Err != nil {
+		exitCode = 1
+	}
+	if exitCode != wantExitCode {
+		r.Failures = append(r.Failures, fmt.Sprintf("exit code %d, want %d (error: %v)", exitCode, wantExitCode, r.Err))
+	}
+
+	if content, err := os.ReadFile(filepath.Join(fixture, expectedStderr)); err == nil {
+		if want, got := strings.TrimSpace(string(content)), strings.TrimSpace(r.Stderr); got != want {
+			r.Failures = append(r.Failures, fmt.Sprintf("stderr %q, want %q", got, want))
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	if content, err := os.ReadFile(filepath.Join(fixture, expectedStdout)); err == nil {
+		if want, got := strings.TrimSpace(string(content)), strings.TrimSpace(r.Stdout); got != want {
+			r.Failures = append(r.Failures, fmt.Sprintf("stdout %q, want %q", got, want))
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// compareGoldens compares the generated files with the golden files of the
+// fixture, including BUILD files generated without a golden file.
+func (r *Result) compareGoldens(fixture, workDir string, inputs map[string]bool, update bool) error {
+	goldens := make(map[string]bool)
+
+	err := filepath.WalkDir(fixture, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(p, goldenSuffix) {
+			return err
+		}
+
+		rel, _ := filepath.Rel(fixture, strings.TrimSuffix(p, goldenSuffix))
+		goldens[filepath.ToSlash(rel)] = true
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// BUILD files created by the generation
+	err = filepath.WalkDir(workDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || (d.Name() != buildFileName && d.Name() != buildBazelFileName) {
+			return err
+		}
+
+		rel, _ := filepath.Rel(workDir, p)
+		if rel = filepath.ToSlash(rel); !inputs[rel] {
+			goldens[rel] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, rel := range slices.Sorted(maps.Keys(goldens)) {
+		golden := filepath.Join(fixture, filepath.FromSlash(rel)+goldenSuffix)
+
+		got, err := readOptionalFile(filepath.Join(workDir, filepath.FromSlash(rel)))
+		if err != nil {
+			return err
+		}
+		want, err := readOptionalFile(golden)
+		if err != nil {
+			return err
+		}
+
+		if got != nil && want != nil && bytes.Equal(bytes.TrimSpace(got), bytes.TrimSpace(want)) {
+			continue
+		}
+
+		if update {
+			// Golden files of files no longer generated are stale
+			if got == nil {
+				err = os.Remove(golden)
+			} else {
+				err = os.WriteFile(golden, got, 0o644)
+			}
+			if err != nil {
+				return err
+			}
+			r.Updated = append(r.Updated, rel+goldenSuffix)
+			continue
+		}
+
+		r.Diffs = append(r.Diffs, FileDiff{Path: rel, Want: want, Got: got})
+	}
+
+	return nil
+}
+
+// readOptionalFile reads the file, returning nil if it does not exist.
+func readOptionalFile(p string) ([]byte, error) {
+	content, err := os.ReadFile(p)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if content == nil && err == nil {
+		content = []byte{}
+	}
+	return content, err
+}
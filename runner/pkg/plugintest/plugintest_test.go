@@ -0,0 +1,174 @@
+package plugintest
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+const simpleFixture = "testdata/simple"
+
+func TestDiscover(t *testing.T) {
+	t.Run("fixture", func(t *testing.T) {
+		fixtures, err := Discover(simpleFixture)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []string{simpleFixture}; !slices.Equal(fixtures, want) {
+			t.Errorf("got %v, want %v", fixtures, want)
+		}
+	})
+
+	t.Run("parent", func(t *testing.T) {
+		fixtures, err := Discover("testdata")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []string{filepath.Join("testdata", "simple")}; !slices.Equal(fixtures, want) {
+			t.Errorf("got %v, want %v", fixtures, want)
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		if _, err := Discover(t.TempDir()); err == nil {
+			t.Error("expected an error for a directory without fixtures")
+		}
+	})
+}
+
+func TestRun(t *testing.T) {
+	Test(t, Options{}, simpleFixture)
+}
+
+func TestRunStages(t *testing.T) {
+	result, err := Run(simpleFixture, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stages []Stage
+	for _, s := range result.Stages {
+		if s.Plugin != "filegroup-test" {
+			t.Errorf("unexpected plugin %q", s.Plugin)
+		}
+		stages = append(stages, s.Stage)
+	}
+
+	want := []Stage{StagePrepare, StageAnalyze, StageAnalyze, StageDeclare}
+	if !slices.Equal(stages, want) {
+		t.Errorf("stages: got %v, want %v", stages, want)
+	}
+	if sources := []string{result.Stages[1].Source, result.Stages[2].Source}; !slices.Equal(sources, []string{"a.txt", "b.txt"}) {
+		t.Errorf("analyzed sources: got %v", sources)
+	}
+}
+
+func TestRunUpdate(t *testing.T) {
+	fixture := t.TempDir()
+	if err := os.CopyFS(fixture, os.DirFS(simpleFixture)); err != nil {
+		t.Fatal(err)
+	}
+
+	golden := filepath.Join(fixture, "BUILD.out")
+	if err := os.WriteFile(golden, []byte("# outdated\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := Run(fixture, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Diffs) != 1 || result.Diffs[0].Path != "BUILD" {
+		t.Fatalf("expected a diff of BUILD, got %v", result.Diffs)
+	}
+
+	result, err = Run(fixture, Options{Update: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"BUILD.out"}; !slices.Equal(result.Updated, want) || !result.Passed() {
+		t.Fatalf("expected %v to be updated, got %v", want, result.Updated)
+	}
+
+	got, _ := os.ReadFile(golden)
+	want, _ := os.ReadFile(filepath.Join(simpleFixture, "BUILD.out"))
+	if string(got) != string(want) {
+		t.Errorf("updated golden:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestRunUpdateStale(t *testing.T) {
+	fixture := t.TempDir()
+	if err := os.CopyFS(fixture, os.DirFS(simpleFixture)); err != nil {
+		t.Fatal(err)
+	}
+
+	// A golden file of a BUILD file no longer generated
+	stale := filepath.Join(fixture, "sub", "BUILD.out")
+	if err := os.MkdirAll(filepath.Dir(stale), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(stale, []byte("# stale\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := Run(fixture, Options{Update: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"sub/BUILD.out"}; !slices.Equal(result.Updated, want) || !result.Passed() {
+		t.Fatalf("expected %v to be deleted, got %v", want, result.Updated)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("expected the stale golden to be deleted, got %v", err)
+	}
+}
+
+func TestRunStderr(t *testing.T) {
+	fixture := t.TempDir()
+	if err := os.CopyFS(fixture, os.DirFS(simpleFixture)); err != nil {
+		t.Fatal(err)
+	}
+
+	plugin, err := os.ReadFile(filepath.Join(fixture, "filegroup.axl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plugin = bytes.Replace(plugin, []byte("def declare(ctx):\n"), []byte("def declare(ctx):\n    aspect.diagnostic(\"declared\", file = \"a.txt\", line = 1)\n"), 1)
+	if err := os.WriteFile(filepath.Join(fixture, "filegroup.axl"), plugin, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	stderr := "a.txt:1: warning[ORN010 plugin-diagnostic]: declared\n  in: BUILD\n"
+	if err := os.WriteFile(filepath.Join(fixture, "expectedStderr.txt"), []byte(stderr), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := Run(fixture, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Stderr != stderr || !result.Passed() {
+		t.Errorf("expected stderr %q, got %q with failures %v", stderr, result.Stderr, result.Failures)
+	}
+}
+
+func TestRunStdout(t *testing.T) {
+	fixture := t.TempDir()
+	if err := os.CopyFS(fixture, os.DirFS(simpleFixture)); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(fixture, "expectedStdout.txt"), []byte("unexpected\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := Run(fixture, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Failures) != 1 || len(result.Diffs) != 0 {
+		t.Errorf("expected only a stdout failure, got failures %v and diffs %v", result.Failures, result.Diffs)
+	}
+}
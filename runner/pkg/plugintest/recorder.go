@@ -0,0 +1,205 @@
+package plugintest
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/aspect-build/aspect-gazelle/language/orion/plugin"
+)
+
+// A Stage of an orion plugin.
+type Stage string
+
+const (
+	StagePrepare Stage = "prepare"
+	StageAnalyze Stage = "analyze"
+	StageDeclare Stage = "declare"
+	StageResolve Stage = "resolve"
+)
+
+var stageOrder = map[Stage]int{
+	StagePrepare: 0,
+	StageAnalyze: 1,
+	StageDeclare: 2,
+	StageResolve: 3,
+}
+
+// StageResult is the result of a single plugin stage invocation.
+type StageResult struct {
+	Stage  Stage
+	Plugin plugin.PluginId
+
+	// The package the stage ran in
+	Rel string
+
+	// The source file analyzed, or the name of the target resolving an import
+	Source string
+
+	// The import resolved and its result, only for the resolve stage
+	Import  *plugin.TargetImport
+	Resolve *plugin.ResolveResult
+
+	// The result of the prepare stage
+	Prepare *plugin.PrepareResult
+
+	// The target actions of the declare stage
+	Actions []plugin.TargetAction
+
+	// An error returned by the analyze or resolve stage
+	Err error
+}
+
+func (r StageResult) String() string {
+	var s strings.Builder
+	fmt.Fprintf(&s, "%s //%s %s", r.Stage, r.Rel, r.Plugin)
+
+	switch r.Stage {
+	case StagePrepare:
+		fmt.Fprintf(&s, ": sources=%v queries=%v", slices.Sorted(maps.Keys(r.Prepare.Sources)), slices.Sorted(maps.Keys(r.Prepare.Queries)))
+	case StageAnalyze:
+		fmt.Fprintf(&s, ": %s", r.Source)
+		if r.Err != nil {
+			fmt.Fprintf(&s, " error: %v", r.Err)
+		}
+	case StageDeclare:
+		for _, a := range r.Actions {
+			switch a := a.(type) {
+			case plugin.AddTargetAction:
+				fmt.Fprintf(&s, "\n  + %s(name = %q) %v", a.Kind, a.Name, a.Attrs)
+			case plugin.RemoveTargetAction:
+				fmt.Fprintf(&s, "\n  - %s(name = %q)", a.Kind, a.Name)
+			default:
+				fmt.Fprintf(&s, "\n  ? %v", a)
+			}
+		}
+	case StageResolve:
+		fmt.Fprintf(&s, ": %s %q", r.Source, r.Import.Id)
+		switch {
+		case r.Err != nil:
+			fmt.Fprintf(&s, " error: %v", r.Err)
+		case r.Resolve.Resolved:
+			fmt.Fprintf(&s, " -> %v", r.Resolve.Labels)
+		default:
+			s.WriteString(" -> default")
+		}
+	}
+
+	return s.String()
+}
+
+// recorder collects the stage results of all plugins, invoked concurrently.
+type recorder struct {
+	mu      sync.Mutex
+	results []StageResult
+}
+
+func (r *recorder) add(result StageResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.results = append(r.results, result)
+}
+
+// sorted returns the results in a deterministic order independent of the
+// concurrent execution of the stages.
+func (r *recorder) sorted() []StageResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	results := slices.Clone(r.results)
+	slices.SortStableFunc(results, func(a, b StageResult) int {
+		return cmp.Or(
+			cmp.Compare(a.Rel, b.Rel),
+			cmp.Compare(stageOrder[a.Stage], stageOrder[b.Stage]),
+			cmp.Compare(a.Plugin, b.Plugin),
+			cmp.Compare(a.Source, b.Source),
+		)
+	})
+	return results
+}
+
+// recordingHost wraps each plugin added to the host to record its stage results,
+// receiving the output of the plugins.
+type recordingHost struct {
+	plugin.PluginHost
+	rec    *recorder
+	stdout io.Writer
+}
+
+var _ plugin.OutputHost = (*recordingHost)(nil)
+
+func (h *recordingHost) Stdout() io.Writer {
+	return h.stdout
+}
+
+func (h *recordingHost) AddPlugin(p plugin.Plugin) {
+	rp := &recordingPlugin{Plugin: p, rec: h.rec}
+	if resolver, isResolver := p.(plugin.ImportResolver); isResolver {
+		h.PluginHost.AddPlugin(&recordingResolverPlugin{recordingPlugin: rp, resolver: resolver})
+		return
+	}
+	h.PluginHost.AddPlugin(rp)
+}
+
+type recordingPlugin struct {
+	plugin.Plugin
+	rec *recorder
+}
+
+func (p *recordingPlugin) Prepare(ctx plugin.PrepareContext) plugin.PrepareResult {
+	result := p.Plugin.Prepare(ctx)
+	p.rec.add(StageResult{
+		Stage:   StagePrepare,
+		Plugin:  p.Name(),
+		Rel:     ctx.Rel,
+		Prepare: &result,
+	})
+	return result
+}
+
+func (p *recordingPlugin) Analyze(ctx plugin.AnalyzeContext) error {
+	err := p.Plugin.Analyze(ctx)
+	p.rec.add(StageResult{
+		Stage:  StageAnalyze,
+		Plugin: p.Name(),
+		Rel:    ctx.Rel,
+		Source: ctx.Source.Path,
+		Err:    err,
+	})
+	return err
+}
+
+func (p *recordingPlugin) DeclareTargets(ctx plugin.DeclareTargetsContext) plugin.DeclareTargetsResult {
+	result := p.Plugin.DeclareTargets(ctx)
+	p.rec.add(StageResult{
+		Stage:   StageDeclare,
+		Plugin:  p.Name(),
+		Rel:     ctx.Rel,
+		Actions: result.Actions,
+	})
+	return result
+}
+
+// recordingResolverPlugin records the resolve stage of plugins implementing it.
+type recordingResolverPlugin struct {
+	*recordingPlugin
+	resolver plugin.ImportResolver
+}
+
+func (p *recordingResolverPlugin) Resolve(ctx plugin.ResolveContext) (plugin.ResolveResult, error) {
+	result, err := p.resolver.Resolve(ctx)
+	p.rec.add(StageResult{
+		Stage:   StageResolve,
+		Plugin:  p.Name(),
+		Rel:     ctx.Rel,
+		Source:  ctx.Target.Name,
+		Import:  &ctx.Import,
+		Resolve: &result,
+		Err:     err,
+	})
+	return result, err
+}
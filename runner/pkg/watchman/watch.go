@@ -167,8 +167,20 @@ func (w *WatchmanWatcher) makeQueryParams(clockspec string, includeHash bool) ma
 		fmt.Printf("failed to load bazelignore: %v", err)
 	}
 
+	// Literal directories can be ignored by watchman entirely while
+	// glob patterns must be matched against every path.
+	ignoredDirs := make([]string, 0, len(bazelignoreDirs))
 	bazelignoreDirnameExpressions := make([]any, 0, len(bazelignoreDirs))
 	for _, ignoredDir := range bazelignoreDirs {
+		if bazel.IsBazelIgnoreGlob(ignoredDir) {
+			// Paths within a directory matching the pattern
+			bazelignoreDirnameExpressions = append(bazelignoreDirnameExpressions, []any{
+				"match", ignoredDir + "/**", "wholename", map[string]any{"includedotfiles": true},
+			})
+			continue
+		}
+
+		ignoredDirs = append(ignoredDirs, ignoredDir)
 		bazelignoreDirnameExpressions = append(bazelignoreDirnameExpressions, []any{
 			"dirname", ignoredDir,
 		})
@@ -203,7 +215,7 @@ func (w *WatchmanWatcher) makeQueryParams(clockspec string, includeHash bool) ma
 				bazelignoreDirnameExpressions...,
 			),
 		},
-		"ignore_dirs": bazelignoreDirs,
+		"ignore_dirs": ignoredDirs,
 	}
 
 	if clockspec != "" {
@@ -27,10 +27,13 @@ import (
 	"sync"
 
 	"github.com/EngFlow/gazelle_cc/language/cc"
+	"github.com/aspect-build/aspect-gazelle/common"
 	"github.com/aspect-build/aspect-gazelle/common/cache"
+	"github.com/aspect-build/aspect-gazelle/common/treesitter"
 	js "github.com/aspect-build/aspect-gazelle/language/js"
 	kotlin "github.com/aspect-build/aspect-gazelle/language/kotlin"
 	orion "github.com/aspect-build/aspect-gazelle/language/orion"
+	"github.com/aspect-build/aspect-gazelle/runner/pkg/diagnostics"
 	"github.com/aspect-build/aspect-gazelle/runner/pkg/git"
 	"github.com/aspect-build/aspect-gazelle/runner/pkg/ibp"
 	"github.com/aspect-build/aspect-gazelle/runner/progress"
@@ -58,6 +61,8 @@ type GazelleRunner struct {
 	interactive  bool
 	showProgress bool
 
+	diagnostics config.Configurer
+
 	languageKeys []string
 	languages    []func() language.Language
 }
@@ -108,6 +113,23 @@ func New(workspaceDir string, showProgress bool) *GazelleRunner {
 	return c
 }
 
+// SetDiagnosticsFormat renders all diagnostics reported by languages to stderr
+// in the given format instead of only printing error messages.
+func (c *GazelleRunner) SetDiagnosticsFormat(format diagnostics.Format) error {
+	d, err := diagnostics.NewConfigurer(format, os.Stderr)
+	if err != nil {
+		return err
+	}
+	c.diagnostics = d
+	return nil
+}
+
+// SetDiagnosticReporter passes all diagnostics reported by languages to the
+// reporter instead of only printing error messages.
+func (c *GazelleRunner) SetDiagnosticReporter(reporter common.DiagnosticReporter) {
+	c.diagnostics = diagnostics.NewReporterConfigurer(reporter)
+}
+
 func pluralize(s string, num int) string {
 	if num == 1 {
 		return s
@@ -223,6 +245,9 @@ func (runner *GazelleRunner) instantiateConfigs() []config.Configurer {
 		cache.NewConfigurer(),
 		git.NewConfigurer(),
 	}
+	if runner.diagnostics != nil {
+		configs = append(configs, runner.diagnostics)
+	}
 	return configs
 }
 
@@ -245,6 +270,9 @@ func (runner *GazelleRunner) Generate(cmd GazelleCommand, mode GazelleMode, args
 	configs := runner.instantiateConfigs()
 	visited, updated, err := vendoredGazelle.RunGazelleFixUpdate(runner.workspaceDir, cmd, configs, langs, fixArgs)
 
+	// Stop the workers of the run, started again by the next run such as when watching.
+	common.SharedPool().Close()
+
 	if mode == Fix && runner.interactive && err == nil {
 		fmt.Printf("%v BUILD %s visited\n", visited, pluralize("file", visited))
 		fmt.Printf("%v BUILD %s updated\n", updated, pluralize("file", updated))
@@ -272,6 +300,9 @@ func (p *GazelleRunner) Watch(watchAddress string, cmd GazelleCommand, mode Gaze
 	wc := cache.NewWatchCache()
 	cache.SetCacheFactory(wc.NewCache)
 
+	// Retain parsed trees so edited files are incrementally reparsed.
+	treesitter.SetRetainedTrees(treesitter.DefaultRetainedTrees)
+
 	invalidator := &walkCacheInvalidator{}
 
 	// Params for the underlying gazelle call
@@ -0,0 +1 @@
+export const sub = 1;
@@ -0,0 +1,93 @@
+// NOTE: aspect-gazelle addition, not synced from bazel-gazelle
+
+package gazelle
+
+import (
+	"flag"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/aspect-build/aspect-gazelle/common/bazel"
+	"github.com/bazelbuild/bazel-gazelle/config"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+	"github.com/bazelbuild/bazel-gazelle/walk"
+)
+
+// bazelIgnoreConfigurer is the walk.Configurer additionally excluding the directories
+// ignored by .bazelignore and REPO.bazel ignore_directories(), including glob patterns.
+//
+// The ignored subdirectories of each directory are passed to the walk as
+// `# gazelle:exclude` directives so they are never visited.
+type bazelIgnoreConfigurer struct {
+	walk.Configurer
+
+	ignores []string
+}
+
+func (cr *bazelIgnoreConfigurer) CheckFlags(fs *flag.FlagSet, c *config.Config) error {
+	ignores, err := bazel.LoadBazelIgnore(c.RepoRoot)
+	if err != nil {
+		return err
+	}
+	cr.ignores = ignores
+
+	return cr.Configurer.CheckFlags(fs, c)
+}
+
+func (cr *bazelIgnoreConfigurer) Configure(c *config.Config, rel string, f *rule.File) {
+	if ignored := cr.ignoredSubdirs(c.RepoRoot, rel); len(ignored) > 0 {
+		directives := make([]rule.Directive, 0, len(ignored))
+		for _, dir := range ignored {
+			directives = append(directives, rule.Directive{Key: "exclude", Value: escapeGlob(dir)})
+		}
+
+		// Directives of a copy, only visible to the walk configuration.
+		if f == nil {
+			f = rule.EmptyFile(path.Join(c.RepoRoot, rel, c.DefaultBuildFileName()), rel)
+		} else {
+			fCopy := *f
+			f = &fCopy
+		}
+		f.Directives = append(slices.Clip(f.Directives), directives...)
+	}
+
+	cr.Configurer.Configure(c, rel, f)
+}
+
+// ignoredSubdirs returns the names of the subdirectories of rel ignored by bazel.
+func (cr *bazelIgnoreConfigurer) ignoredSubdirs(repoRoot, rel string) []string {
+	if !cr.mayIgnoreWithin(rel) {
+		return nil
+	}
+
+	entries, err := os.ReadDir(filepath.Join(repoRoot, filepath.FromSlash(rel)))
+	if err != nil {
+		return nil
+	}
+
+	var ignored []string
+	for _, e := range entries {
+		if e.IsDir() && bazel.IsBazelIgnored(cr.ignores, path.Join(rel, e.Name())) {
+			ignored = append(ignored, e.Name())
+		}
+	}
+	return ignored
+}
+
+// mayIgnoreWithin returns true if an ignore entry may apply to a subdirectory of rel.
+func (cr *bazelIgnoreConfigurer) mayIgnoreWithin(rel string) bool {
+	for _, ignore := range cr.ignores {
+		if bazel.IsBazelIgnoreGlob(ignore) || rel == "" || strings.HasPrefix(ignore, rel+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// escapeGlob escapes a directory name to match literally as an exclude pattern.
+func escapeGlob(name string) string {
+	return strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`, `{`, `\{`, `}`, `\}`).Replace(name)
+}
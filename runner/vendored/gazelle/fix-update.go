@@ -291,7 +291,7 @@ func runFixUpdate(wd string, languages []language.Language, cmd command, args []
 	cexts = append(cexts,
 		&config.CommonConfigurer{},
 		&updateConfigurer{},
-		&walk.Configurer{},
+		&bazelIgnoreConfigurer{}, // NOTE: aspect-gazelle walk.Configurer including bazel ignored globs
 		&resolve.Configurer{})
 
 	// NOTE: additional aspect-gazelle configurers
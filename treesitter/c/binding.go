@@ -0,0 +1,17 @@
+package c
+
+//typedef struct TSLanguage TSLanguage;
+//TSLanguage *tree_sitter_c();
+import "C"
+import (
+	"unsafe"
+
+	// Linked only for its C grammar symbols (tree_sitter_c).
+	_ "github.com/smacker/go-tree-sitter/c"
+)
+
+// LanguagePtr returns the raw tree-sitter grammar (`const TSLanguage *`),
+// for use with a parsing backend such as common/treesitter NewLanguage().
+func LanguagePtr() unsafe.Pointer {
+	return unsafe.Pointer(C.tree_sitter_c())
+}
@@ -0,0 +1,17 @@
+package scala
+
+//typedef struct TSLanguage TSLanguage;
+//TSLanguage *tree_sitter_scala();
+import "C"
+import (
+	"unsafe"
+
+	// Linked only for its C grammar symbols (tree_sitter_scala).
+	_ "github.com/smacker/go-tree-sitter/scala"
+)
+
+// LanguagePtr returns the raw tree-sitter grammar (`const TSLanguage *`),
+// for use with a parsing backend such as common/treesitter NewLanguage().
+func LanguagePtr() unsafe.Pointer {
+	return unsafe.Pointer(C.tree_sitter_scala())
+}